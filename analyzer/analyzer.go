@@ -0,0 +1,185 @@
+// Package analyzer runs a gogrep pattern as an analysis pass,
+// so it can be executed by go vet, golangci-lint and other go/analysis drivers.
+//
+// The analysis framework provides the types info, so the patterns are
+// compiled in the type-aware mode: fmt.Printf only matches the fmt package function,
+// not a Printf method of some local fmt variable.
+package analyzer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/quasilyte/gogrep"
+	"golang.org/x/tools/go/analysis"
+)
+
+// Config describes the analyzer created by New.
+type Config struct {
+	// Name is the analyzer name, "gogrep" is used if it's empty.
+	Name string
+
+	// Pattern is a gogrep pattern to search for.
+	// If it's empty, the -pattern analyzer flag value is used.
+	Pattern string
+
+	// Message is a diagnostic message text/template.
+	// The {{.Match}} is the match text and every named capture
+	// is available by its name, like {{.x}} for $x.
+	// If it's empty, the -message analyzer flag value is used.
+	Message string
+
+	// Strict disables the syntax normalizations, see gogrep.CompileConfig.
+	Strict bool
+
+	// Filter can reject some of the matches, all matches are reported if it's nil.
+	// It's called for every match, the match data is only valid during the call.
+	//
+	// The gogrep command-line filter expressions are implemented by the command
+	// itself, so they're not available here: a Go function is used instead.
+	Filter func(pass *analysis.Pass, m gogrep.MatchData) bool
+}
+
+// defaultMessage is a -message flag default value.
+const defaultMessage = "{{.Match}}"
+
+// Analyzer reports the matches of the -pattern flag pattern.
+var Analyzer = New(Config{})
+
+// New returns an analyzer that reports the config pattern matches.
+func New(config Config) *analysis.Analyzer {
+	a := &analyzer{config: config}
+	if a.config.Name == "" {
+		a.config.Name = "gogrep"
+	}
+	result := &analysis.Analyzer{
+		Name: a.config.Name,
+		Doc:  "reports the gogrep pattern matches",
+		Run:  a.run,
+	}
+	if config.Pattern == "" {
+		result.Flags.StringVar(&a.config.Pattern, "pattern", "", "a gogrep pattern to search for")
+	}
+	if config.Message == "" {
+		a.config.Message = defaultMessage
+		result.Flags.StringVar(&a.config.Message, "message", defaultMessage,
+			"a diagnostic message template, {{.Match}} and {{.x}} (for $x capture) are available")
+	}
+	return result
+}
+
+type analyzer struct {
+	config Config
+}
+
+func (a *analyzer) run(pass *analysis.Pass) (interface{}, error) {
+	if a.config.Pattern == "" {
+		return nil, fmt.Errorf("%s: pattern is not specified", a.config.Name)
+	}
+	tmpl, err := template.New("message").Option("missingkey=zero").Parse(a.config.Message)
+	if err != nil {
+		return nil, fmt.Errorf("%s: parse message template: %v", a.config.Name, err)
+	}
+
+	// The imports differ between packages, so the pattern is compiled for every one of them.
+	imports := make(map[string]string)
+	for _, pkg := range pass.Pkg.Imports() {
+		imports[pkg.Name()] = pkg.Path()
+	}
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{
+		Fset:      token.NewFileSet(),
+		Src:       a.config.Pattern,
+		Strict:    a.config.Strict,
+		WithTypes: true,
+		Imports:   imports,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: compile pattern: %v", a.config.Name, err)
+	}
+
+	state := gogrep.NewMatcherState()
+	state.Types = pass.TypesInfo
+	var reportErr error
+	for _, f := range pass.Files {
+		src := fileSource{fset: pass.Fset, filename: pass.Fset.File(f.Pos()).Name()}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n == nil || reportErr != nil {
+				return false
+			}
+			pat.MatchNode(&state, n, func(m gogrep.MatchData) {
+				if reportErr != nil {
+					return
+				}
+				if a.config.Filter != nil && !a.config.Filter(pass, m) {
+					return
+				}
+				msg, err := renderMessage(tmpl, &src, m)
+				if err != nil {
+					reportErr = fmt.Errorf("%s: render message: %v", a.config.Name, err)
+					return
+				}
+				pass.Report(analysis.Diagnostic{
+					Pos:     m.Node.Pos(),
+					End:     m.Node.End(),
+					Message: msg,
+				})
+			})
+			return true
+		})
+	}
+	return nil, reportErr
+}
+
+func renderMessage(tmpl *template.Template, src *fileSource, m gogrep.MatchData) (string, error) {
+	data := make(map[string]string, len(m.Capture)+1)
+	data["Match"] = src.nodeText(m.Node)
+	for _, c := range m.Capture {
+		data[c.Name] = src.nodeText(c.Node)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// fileSource gives access to the analyzed file text.
+// The source is loaded lazily, most of the files don't have any matches.
+type fileSource struct {
+	fset     *token.FileSet
+	filename string
+
+	loaded bool
+	data   []byte
+}
+
+// nodeText returns the n source text in a single line.
+// If the file can't be read, the node is printed instead.
+func (s *fileSource) nodeText(n ast.Node) string {
+	if gogrep.IsEmptyNodeSlice(n) {
+		return ""
+	}
+	if !s.loaded {
+		s.loaded = true
+		s.data, _ = os.ReadFile(s.filename)
+	}
+	var text []byte
+	from := s.fset.Position(n.Pos()).Offset
+	to := s.fset.Position(n.End()).Offset
+	if n.Pos().IsValid() && from <= to && to <= len(s.data) {
+		text = s.data[from:to]
+	} else {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, s.fset, n); err != nil {
+			return ""
+		}
+		text = buf.Bytes()
+	}
+	return strings.ReplaceAll(string(text), "\n", `\n`)
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"go/ast"
+	"testing"
+
+	"github.com/quasilyte/gogrep"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	tests := []struct {
+		pkg    string
+		config Config
+	}{
+		{"a", Config{Pattern: `$x == $x`, Message: `suspicious {{.x}} == {{.x}} comparison`}},
+		{"typed", Config{Pattern: `fmt.Sprintf($*args)`, Message: `use fmt.Sprint({{.args}})`}},
+	}
+
+	for _, test := range tests {
+		analysistest.Run(t, analysistest.TestData(), New(test.config), test.pkg)
+	}
+}
+
+func TestAnalyzerEnumFilter(t *testing.T) {
+	// Find the conversions to an enum-like type that are not its known constants.
+	config := Config{
+		Pattern: `$T($x)`,
+		Message: `{{.x}} is not a {{.T}} constant`,
+		Filter: func(pass *analysis.Pass, m gogrep.MatchData) bool {
+			call := m.Node.(*ast.CallExpr)
+			if isConversion, _ := gogrep.IsConversion(pass.TypesInfo, call); !isConversion {
+				return false
+			}
+			typ := pass.TypesInfo.TypeOf(call.Fun)
+			return len(gogrep.EnumConsts(typ)) != 0 && !gogrep.IsEnumValue(pass.TypesInfo, call.Args[0], typ)
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), New(config), "enum")
+}
+
+func TestAnalyzerCallResultsFilter(t *testing.T) {
+	// Find the statements that discard a multi-value call results.
+	config := Config{
+		Pattern: `$f($*_);`,
+		Message: `{{.Match}} discards 2 results`,
+		Filter: func(pass *analysis.Pass, m gogrep.MatchData) bool {
+			call, ok := m.Node.(*ast.ExprStmt).X.(*ast.CallExpr)
+			if !ok {
+				return false
+			}
+			n, known := gogrep.CallResults(pass.TypesInfo, call)
+			return known && n == 2
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), New(config), "results")
+}
+
+func TestAnalyzerPanicFilter(t *testing.T) {
+	// Find the panics which argument is not an error, like panic("unreachable").
+	config := Config{
+		Pattern: `panic($x)`,
+		Message: `panic argument {{.x}} is not an error`,
+		Filter: func(pass *analysis.Pass, m gogrep.MatchData) bool {
+			x, _ := m.CapturedByName("x")
+			isError, known := gogrep.IsErrorValue(pass.TypesInfo, x.(ast.Expr))
+			return known && !isError
+		},
+	}
+	analysistest.Run(t, analysistest.TestData(), New(config), "panics")
+}
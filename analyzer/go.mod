@@ -0,0 +1,17 @@
+module github.com/quasilyte/gogrep/analyzer
+
+go 1.22.0
+
+require (
+	github.com/quasilyte/gogrep v0.0.0-20221002170714-e78263da2dd3
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/go-toolsmith/astequal v1.0.3 // indirect
+	golang.org/x/exp/typeparams v0.0.0-20220428152302-39d4317da171 // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
+
+replace github.com/quasilyte/gogrep => ../
@@ -0,0 +1,8 @@
+package a
+
+func selfCompare(x, y int, xs []int) {
+	_ = x == x // want `suspicious x == x comparison`
+	_ = x == y
+	_ = xs[0] == xs[0] // want `suspicious xs\[0\] == xs\[0\] comparison`
+	_ = x != x
+}
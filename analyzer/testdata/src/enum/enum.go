@@ -0,0 +1,19 @@
+package enum
+
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusFailed
+)
+
+func f(code int) {
+	_ = Status(0)
+	_ = Status(StatusFailed)
+	_ = Status(2)    // want `2 is not a Status constant`
+	_ = Status(code) // want `code is not a Status constant`
+	_ = int(code)
+	_ = g(code)
+}
+
+func g(x int) int { return x }
@@ -0,0 +1,27 @@
+package panics
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Error struct{ msg string }
+
+func (e *Error) Error() string { return e.msg }
+
+func f(err error, n int) {
+	switch n {
+	case 0:
+		panic("unreachable") // want `panic argument "unreachable" is not an error`
+	case 1:
+		panic(fmt.Sprintf("bad %d", n)) // want `panic argument fmt.Sprintf\("bad %d", n\) is not an error`
+	case 2:
+		panic(n) // want `panic argument n is not an error`
+	case 3:
+		panic(err)
+	case 4:
+		panic(errors.New("bad"))
+	case 5:
+		panic(&Error{msg: "bad"})
+	}
+}
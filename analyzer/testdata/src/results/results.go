@@ -0,0 +1,19 @@
+package results
+
+import "strconv"
+
+type T struct{}
+
+func (T) Pair() (int, error) { return 0, nil }
+
+func (T) Close() error { return nil }
+
+func f(t T, s string) {
+	strconv.Atoi(s) // want `strconv.Atoi\(s\) discards 2 results`
+	t.Pair()        // want `t.Pair\(\) discards 2 results`
+	t.Close()
+	_, _ = t.Pair()
+	if _, err := strconv.Atoi(s); err != nil {
+		return
+	}
+}
@@ -0,0 +1,15 @@
+package typed
+
+import "fmt"
+
+type printer struct{}
+
+func (printer) Sprintf(format string, args ...interface{}) string { return format }
+
+func f(name string) {
+	_ = fmt.Sprintf("%s", name) // want `use fmt.Sprint\("%s", name\)`
+
+	// A local fmt variable is not the fmt package.
+	var fmt printer
+	_ = fmt.Sprintf("%s", name)
+}
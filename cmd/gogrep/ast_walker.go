@@ -4,6 +4,9 @@ import (
 	"go/ast"
 )
 
+// astWalker is a pre-order AST traversal: every node is visited before its children,
+// so the enclosing matches are always found before the nested ones.
+// The reported matches are ordered by matchBefore, which relies on it.
 type astWalker struct {
 	worker *worker
 
@@ -54,8 +57,13 @@ func (w *astWalker) getTypeName(typeExpr ast.Expr) string {
 }
 
 func (w *astWalker) walk(n ast.Node) {
+	if !w.worker.inLineRanges(n) || w.worker.isMasked(n) {
+		return
+	}
 	w.visit(n)
 
+	w.worker.ancestors = append(w.worker.ancestors, n)
+
 	switch n := n.(type) {
 	case *ast.Field:
 		w.walkIdentList(n.Names)
@@ -302,7 +310,9 @@ func (w *astWalker) walk(n ast.Node) {
 		}
 		prevTypeName := w.worker.typeName
 		prevFuncName := w.worker.funcName
+		prevFuncDecl := w.worker.funcDecl
 		w.worker.funcName = n.Name.Name
+		w.worker.funcDecl = n
 		if n.Recv != nil {
 			if len(n.Recv.List) != 0 {
 				w.worker.typeName = w.getTypeName(n.Recv.List[0].Type)
@@ -316,9 +326,12 @@ func (w *astWalker) walk(n ast.Node) {
 		}
 		w.worker.typeName = prevTypeName
 		w.worker.funcName = prevFuncName
+		w.worker.funcDecl = prevFuncDecl
 
 	case *ast.File:
 		w.walk(n.Name)
 		w.walkDeclList(n.Decls)
 	}
+
+	w.worker.ancestors = w.worker.ancestors[:len(w.worker.ancestors)-1]
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// baselineVersion is a baseline file format version.
+const baselineVersion = 1
+
+type baselineFile struct {
+	Version int             `json:"version"`
+	Entries []baselineEntry `json:"entries"`
+}
+
+type baselineEntry struct {
+	File        string `json:"file"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// baseline is a set of the known match fingerprints that should be suppressed.
+//
+// Several matches can have identical fingerprints (like the same
+// pattern matched twice on the same line), so every fingerprint
+// can suppress as many matches as there are entries for it.
+type baseline struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// suppress reports whether a match with the specified fingerprint is a known one.
+// Every call "consumes" one fingerprint occurrence.
+func (b *baseline) suppress(fingerprint string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.counts[fingerprint] == 0 {
+		return false
+	}
+	b.counts[fingerprint]--
+	return true
+}
+
+func (p *program) loadBaseline() error {
+	filename := p.args.baseline
+	if filename == "" {
+		filename = p.args.writeBaseline
+	}
+	if filename == "" {
+		return nil
+	}
+	// The fingerprints use the filenames relative to the baseline file directory,
+	// so the same baseline can be used from any directory.
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	p.baselineDir = filepath.Dir(abs)
+	if p.args.baseline == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(p.args.baseline)
+	if err != nil {
+		return err
+	}
+	var f baselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return &locatedError{filename: p.args.baseline, err: err}
+	}
+	if f.Version != baselineVersion {
+		return &locatedError{
+			filename: p.args.baseline,
+			err:      fmt.Errorf("unsupported baseline version %d", f.Version),
+		}
+	}
+
+	b := &baseline{counts: make(map[string]int, len(f.Entries))}
+	for _, e := range f.Entries {
+		b.counts[e.Fingerprint]++
+	}
+	p.baseline = b
+	if p.args.verbose {
+		log.Printf("debug: loaded %d baseline entries from %s", len(f.Entries), p.args.baseline)
+	}
+	return nil
+}
+
+func (p *program) writeBaseline() error {
+	if p.args.writeBaseline == "" {
+		return nil
+	}
+
+	f := baselineFile{
+		Version: baselineVersion,
+		Entries: []baselineEntry{},
+	}
+	for _, w := range p.workers {
+		for _, m := range w.matches {
+			f.Entries = append(f.Entries, baselineEntry{
+				File:        baselineFilename(p.baselineDir, p.workDir, m.filename),
+				Fingerprint: m.fingerprint,
+			})
+		}
+	}
+	// Keep the file contents stable, so it can be committed and diffed.
+	sort.Slice(f.Entries, func(i, j int) bool {
+		x := f.Entries[i]
+		y := f.Entries[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		return x.Fingerprint < y.Fingerprint
+	})
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.args.writeBaseline, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	log.Printf("wrote %d baseline entries to %s", len(f.Entries), p.args.writeBaseline)
+	return nil
+}
+
+// matchFingerprint computes a content-based match identity.
+//
+// The line number is deliberately not included, so the fingerprint
+// is not affected by the edits above the match.
+func (w *worker) matchFingerprint(r *rule, line []byte) string {
+	key := r.id
+	if key == "" {
+		key = r.pattern
+	}
+	h := sha256.New()
+	h.Write([]byte(baselineFilename(w.baselineDir, w.workDir, w.filename)))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(bytes.TrimSpace(line))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceLine returns the source line that contains the specified offset.
+func (w *worker) sourceLine(offset int) []byte {
+	start := offset
+	for start > 0 && w.data[start-1] != '\n' {
+		start--
+	}
+	end := offset
+	for end < len(w.data) && w.data[end] != '\n' {
+		end++
+	}
+	return w.data[start:end]
+}
+
+// baselineFilename returns a slash-separated filename relative to the baseline file dir.
+// The filename itself is relative to the work dir.
+func baselineFilename(dir, wd, filename string) string {
+	rel, err := filepath.Rel(dir, filepathAbs(wd, filename))
+	if err != nil {
+		rel = filename
+	}
+	return filepath.ToSlash(rel)
+}
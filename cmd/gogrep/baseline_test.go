@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBaselineWorkDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	r := &rule{pattern: `panic($_)`}
+	line := []byte("\tpanic(1)")
+
+	// fingerprint returns the match fingerprint as seen from the dir.
+	fingerprint := func(p *program, dir, filename string) string {
+		if err := os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.workDir = wd
+		if err := p.loadBaseline(); err != nil {
+			t.Fatal(err)
+		}
+		w := &worker{workDir: wd, baselineDir: p.baselineDir, filename: filename}
+		return w.matchFingerprint(r, line)
+	}
+
+	// The baseline is written from the module root.
+	p := &program{args: arguments{writeBaseline: "baseline.json"}}
+	fp := fingerprint(p, root, filepath.Join("sub", "a.go"))
+	p.workers = []*worker{{matches: []match{{filename: filepath.Join("sub", "a.go"), fingerprint: fp}}}}
+	if err := p.writeBaseline(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "baseline.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f baselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Entries) != 1 || f.Entries[0].File != "sub/a.go" {
+		t.Fatalf("unexpected baseline entries: %+v", f.Entries)
+	}
+
+	// And then it's used from the package dir.
+	tests := []struct {
+		dir      string
+		baseline string
+		filename string
+	}{
+		{root, "baseline.json", filepath.Join("sub", "a.go")},
+		{filepath.Join(root, "sub"), filepath.Join("..", "baseline.json"), "a.go"},
+		{filepath.Join(root, "sub"), filepath.Join(root, "baseline.json"), filepath.Join(root, "sub", "a.go")},
+	}
+	for _, test := range tests {
+		p := &program{args: arguments{baseline: test.baseline}}
+		have := fingerprint(p, test.dir, test.filename)
+		if have != fp {
+			t.Errorf("%s from %s: fingerprint mismatch", test.filename, test.dir)
+			continue
+		}
+		if !p.baseline.suppress(have) {
+			t.Errorf("%s from %s: match is not suppressed", test.filename, test.dir)
+		}
+	}
+}
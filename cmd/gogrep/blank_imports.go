@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os"
+	"strconv"
+)
+
+// initCallInfo is a -blank-imports mode init function call match data.
+type initCallInfo struct {
+	// pkgPath is the import path of the package that declares the init function.
+	pkgPath string
+}
+
+// blankImportGroup is a package that is imported for its side effects.
+type blankImportGroup struct {
+	path     string
+	numFiles int
+	matches  []match
+
+	// initCalls are the package init functions calls, like sql.Register(...),
+	// they're only known if the package is one of the target packages.
+	initCalls []match
+}
+
+type jsonBlankImportGroup struct {
+	Path      string      `json:"path"`
+	Files     int         `json:"files"`
+	Matches   []jsonMatch `json:"matches"`
+	InitCalls []jsonMatch `json:"init_calls,omitempty"`
+}
+
+// collectBlankImports reports every blank file import spec as a match.
+// The package-qualified calls statements of the file init functions are reported too,
+// so the blank-imported target packages can be correlated with their registrations.
+func (w *worker) collectBlankImports(root *ast.File) {
+	pkgPath := ""
+	for _, decl := range root.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && isInitFunc(fn) {
+			pkgPath = w.filePkgPath(w.filename)
+			break
+		}
+	}
+
+	for _, i := range w.activeRules {
+		r := w.rules[i]
+		for _, imp := range root.Imports {
+			if imp.Name == nil || imp.Name.Name != "_" {
+				continue
+			}
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			numMatches := len(w.matches)
+			w.addMatch(r, imp, nil)
+			if len(w.matches) != numMatches {
+				w.matches[numMatches].importSpec = &importSpecInfo{name: "_", path: path}
+			}
+		}
+		if pkgPath == "" {
+			continue
+		}
+		for _, decl := range root.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || !isInitFunc(fn) {
+				continue
+			}
+			for _, call := range initCallStmts(root, fn) {
+				numMatches := len(w.matches)
+				w.addMatch(r, call, nil)
+				if len(w.matches) != numMatches {
+					w.matches[numMatches].initCall = &initCallInfo{pkgPath: pkgPath}
+				}
+			}
+		}
+	}
+}
+
+func isInitFunc(fn *ast.FuncDecl) bool {
+	return fn.Recv == nil && fn.Name.Name == "init" && fn.Body != nil
+}
+
+// initCallStmts returns the fn init function statements that call the other packages functions,
+// like `sql.Register("pg", &Driver{})` or `image.RegisterFormat(...)`.
+// The function literals are skipped, they're not necessarily called during the init.
+func initCallStmts(f *ast.File, fn *ast.FuncDecl) []*ast.ExprStmt {
+	var result []*ast.ExprStmt
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ExprStmt:
+			call, ok := unparenExpr(n.X).(*ast.CallExpr)
+			if !ok {
+				break
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				break
+			}
+			if pkg, ok := sel.X.(*ast.Ident); ok && fileImportsName(f, pkg.Name) {
+				result = append(result, n)
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// collectBlankImportGroups returns the blank-imported packages with their
+// import specs and init functions calls matches.
+// The groups are sorted by their first import location.
+func (p *program) collectBlankImportGroups() []blankImportGroup {
+	groupByPath := make(map[string]int)
+	var groups []blankImportGroup
+	var initCalls []match
+	for _, m := range p.sortedMatches() {
+		if m.initCall != nil {
+			initCalls = append(initCalls, m)
+			continue
+		}
+		i, ok := groupByPath[m.importSpec.path]
+		if !ok {
+			i = len(groups)
+			groupByPath[m.importSpec.path] = i
+			groups = append(groups, blankImportGroup{path: m.importSpec.path})
+		}
+		g := &groups[i]
+		if len(g.matches) == 0 || g.matches[len(g.matches)-1].filename != m.filename {
+			g.numFiles++
+		}
+		g.matches = append(g.matches, m)
+	}
+	for _, m := range initCalls {
+		if i, ok := groupByPath[m.initCall.pkgPath]; ok {
+			groups[i].initCalls = append(groups[i].initCalls, m)
+		}
+	}
+	return groups
+}
+
+func (p *program) printBlankImports() error {
+	groups := p.collectBlankImportGroups()
+
+	// The init calls are only reported as a part of the groups, so only the imports are counted.
+	p.numMatches = 0
+	for _, g := range groups {
+		p.numMatches += uint64(len(g.matches))
+	}
+
+	var enc *json.Encoder
+	if p.args.format == jsonFormat {
+		enc = json.NewEncoder(os.Stdout)
+	}
+
+	printed := uint64(0)
+	for i, g := range groups {
+		if printed >= p.args.limit {
+			p.printSummary("results limited to %d matches", p.args.limit)
+			return nil
+		}
+		if enc != nil {
+			jsonGroup := jsonBlankImportGroup{Path: g.path, Files: g.numFiles}
+			for _, m := range g.matches {
+				jsonGroup.Matches = append(jsonGroup.Matches, p.newJSONMatch(m))
+			}
+			for _, m := range g.initCalls {
+				jsonGroup.InitCalls = append(jsonGroup.InitCalls, p.newJSONMatch(m))
+			}
+			if err := enc.Encode(jsonGroup); err != nil {
+				return err
+			}
+			printed += uint64(len(g.matches))
+			continue
+		}
+
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%q is blank-imported in %d files\n", g.path, g.numFiles)
+		for _, m := range g.matches {
+			if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+				return err
+			}
+			printed++
+		}
+		if len(g.initCalls) != 0 {
+			fmt.Printf("its init functions call:\n")
+			for _, m := range g.initCalls {
+				if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	p.printSummary("found %d blank-imported packages", len(groups))
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// branchTargets returns the statement that the n labeled break or continue (bound to varname) targets
+// and the statement that the same branch would target without a label.
+// n is either a branch statement or its label, like $label in `continue $label`.
+// The labeled result is nil if n is not a labeled break or continue, or its label is not
+// declared by an enclosing statement of the same function.
+func (ctx *filterContext) branchTargets(varname string, n ast.Node) (labeled, unlabeled ast.Stmt) {
+	branch, isBranch := n.(*ast.BranchStmt)
+	label, isLabel := n.(*ast.Ident)
+	if isBranch {
+		label = branch.Label
+	}
+	if !isBranch && !isLabel || label == nil {
+		return nil, nil
+	}
+
+	var child ast.Node = n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		if branch == nil {
+			// The label ident parent should be a branch statement.
+			b, ok := parent.(*ast.BranchStmt)
+			if !ok || b.Label != label {
+				return false
+			}
+			branch = b
+			child = parent
+			return true
+		}
+		switch parent := parent.(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			if unlabeled == nil {
+				unlabeled = parent.(ast.Stmt)
+			}
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			if unlabeled == nil && branch.Tok == token.BREAK {
+				unlabeled = parent.(ast.Stmt)
+			}
+		case *ast.LabeledStmt:
+			if parent.Label.Name == label.Name && parent.Stmt == child {
+				labeled = parent.Stmt
+				return false
+			}
+		}
+		child = parent
+		return true
+	})
+	if branch == nil || branch.Tok != token.BREAK && branch.Tok != token.CONTINUE {
+		return nil, nil
+	}
+	return labeled, unlabeled
+}
+
+// labelTarget returns the kind of the statement targeted by the n labeled branch, like "RangeStmt",
+// or an empty string if the target is unknown, see branchTargets.
+func (ctx *filterContext) labelTarget(varname string, n ast.Node) string {
+	labeled, _ := ctx.branchTargets(varname, n)
+	if labeled == nil {
+		return ""
+	}
+	return nodeKindName(labeled)
+}
+
+// isRedundantLabel reports whether the n labeled branch targets the same statement
+// as it would without a label, like the `continue inner` of the innermost `inner` loop.
+func (ctx *filterContext) isRedundantLabel(varname string, n ast.Node) bool {
+	labeled, unlabeled := ctx.branchTargets(varname, n)
+	return labeled != nil && labeled == unlabeled
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// platformTags are the build tags that are set by the go tool depending on the
+// target platform and toolchain, see `go help buildconstraint`.
+// The release tags (go1.N) and the experiment tags are recognized by their prefixes.
+// The GOOS and GOARCH values are listed separately, see knownOS and knownArch.
+var platformTags = map[string]bool{
+	"gc":           true,
+	"gccgo":        true,
+	"cgo":          true,
+	"unix":         true,
+	"boringcrypto": true,
+}
+
+// knownOS maps the GOOS values to whether they satisfy the unix tag.
+var knownOS = map[string]bool{
+	"aix":       true,
+	"android":   true,
+	"darwin":    true,
+	"dragonfly": true,
+	"freebsd":   true,
+	"hurd":      true,
+	"illumos":   true,
+	"ios":       true,
+	"js":        false,
+	"linux":     true,
+	"nacl":      false,
+	"netbsd":    true,
+	"openbsd":   true,
+	"plan9":     false,
+	"solaris":   true,
+	"wasip1":    false,
+	"windows":   false,
+	"zos":       false,
+}
+
+// impliedOS maps the GOOS values to the other GOOS tags that they set,
+// like GOOS=android that also satisfies the linux tag.
+var impliedOS = map[string]string{
+	"android": "linux",
+	"illumos": "solaris",
+	"ios":     "darwin",
+}
+
+var knownArch = map[string]bool{
+	"386":         true,
+	"amd64":       true,
+	"amd64p32":    true,
+	"arm":         true,
+	"arm64":       true,
+	"arm64be":     true,
+	"armbe":       true,
+	"loong64":     true,
+	"mips":        true,
+	"mips64":      true,
+	"mips64le":    true,
+	"mips64p32":   true,
+	"mips64p32le": true,
+	"mipsle":      true,
+	"ppc":         true,
+	"ppc64":       true,
+	"ppc64le":     true,
+	"riscv":       true,
+	"riscv64":     true,
+	"s390":        true,
+	"s390x":       true,
+	"sparc":       true,
+	"sparc64":     true,
+	"wasm":        true,
+}
+
+func isPlatformTag(tag string) bool {
+	_, isOS := knownOS[tag]
+	return platformTags[tag] || isOS || knownArch[tag] ||
+		strings.HasPrefix(tag, "go1.") ||
+		strings.HasPrefix(tag, "goexperiment.")
+}
+
+// buildConstraintError is a malformed build constraint line.
+// The file is searched as if the line was not there.
+type buildConstraintError struct {
+	line int
+	err  error
+}
+
+func (e *buildConstraintError) Error() string {
+	return fmt.Sprintf("line %d: malformed build constraint: %v", e.line, e.err)
+}
+
+// buildConstraint returns the file build constraint, nil if there is none.
+// Like in the go tool, both the filename constraint (see filenameBuildConstraint)
+// and the file header one (see fileBuildConstraint) should be satisfied.
+func buildConstraint(filename string, data []byte) (constraint.Expr, error) {
+	x, err := fileBuildConstraint(data)
+	nameX := filenameBuildConstraint(filename)
+	switch {
+	case nameX == nil:
+		return x, err
+	case x == nil:
+		return nameX, err
+	default:
+		return &constraint.AndExpr{X: nameX, Y: x}, err
+	}
+}
+
+// fileBuildConstraint returns the build constraint from the file header, nil if there is none.
+// A //go:build line takes precedence over the // +build lines, like in the go tool.
+// The constraints with a syntax error are ignored, the first of them is returned as an error.
+func fileBuildConstraint(data []byte) (constraint.Expr, error) {
+	var goBuild constraint.Expr
+	var plusBuild constraint.Expr
+	var malformed error
+	lineNum := 0
+	// The constraints are only allowed before the package clause,
+	// they can only be preceded by the blank lines and comments.
+headerLoop:
+	for len(data) != 0 {
+		line, rest := cutLine(data)
+		line = bytes.TrimSpace(line)
+		lineNum++
+		switch {
+		case len(line) == 0:
+			// Skip the blank lines.
+		case bytes.HasPrefix(line, []byte("/*")):
+			// A block comment, like a license header.
+			end := bytes.Index(data, []byte("*/"))
+			if end == -1 {
+				break headerLoop
+			}
+			// The rest of the comment end line is handled as a separate line.
+			lineNum += bytes.Count(data[:end], []byte("\n")) - 1
+			rest = data[end+len("*/"):]
+		case bytes.HasPrefix(line, []byte("//")):
+			text := string(line)
+			switch {
+			case constraint.IsGoBuild(text):
+				if goBuild != nil {
+					break
+				}
+				x, err := constraint.Parse(text)
+				if err != nil {
+					if malformed == nil {
+						malformed = &buildConstraintError{line: lineNum, err: err}
+					}
+					break
+				}
+				goBuild = x
+			case constraint.IsPlusBuild(text):
+				x, err := constraint.Parse(text)
+				if err != nil {
+					if malformed == nil {
+						malformed = &buildConstraintError{line: lineNum, err: err}
+					}
+					break
+				}
+				if plusBuild == nil {
+					plusBuild = x
+				} else {
+					plusBuild = &constraint.AndExpr{X: plusBuild, Y: x}
+				}
+			}
+		default:
+			break headerLoop
+		}
+		data = rest
+	}
+	if goBuild != nil {
+		return goBuild, malformed
+	}
+	return plusBuild, malformed
+}
+
+// filenameBuildConstraint returns the GOOS and GOARCH constraint implied by
+// the filename suffixes, like _windows.go or _linux_arm64.go, nil if there is none.
+// The rules are the same as in the go/build package: the first name element
+// is never a constraint, so windows.go is built for any GOOS.
+func filenameBuildConstraint(filename string) constraint.Expr {
+	name := filepath.Base(filename)
+	if i := strings.IndexByte(name, '.'); i != -1 {
+		name = name[:i]
+	}
+	i := strings.IndexByte(name, '_')
+	if i == -1 {
+		return nil
+	}
+	name = strings.TrimSuffix(name[i:], "_test")
+
+	l := strings.Split(name, "_")
+	n := len(l)
+	if n >= 2 {
+		if _, isOS := knownOS[l[n-2]]; isOS && knownArch[l[n-1]] {
+			return &constraint.AndExpr{
+				X: &constraint.TagExpr{Tag: l[n-2]},
+				Y: &constraint.TagExpr{Tag: l[n-1]},
+			}
+		}
+	}
+	if _, isOS := knownOS[l[n-1]]; isOS || knownArch[l[n-1]] {
+		return &constraint.TagExpr{Tag: l[n-1]}
+	}
+	return nil
+}
+
+func cutLine(data []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(data, '\n'); i != -1 {
+		return data[:i], data[i+1:]
+	}
+	return data, nil
+}
+
+// isBuildIgnored reports whether the x constraint can't be satisfied on any platform,
+// like `//go:build ignore`. The platform tags that are not in the tags set can be
+// either set or unset, the other tags are only set if they're set in the tags set.
+func isBuildIgnored(x constraint.Expr, tags map[string]bool) bool {
+	var free []string
+	seen := make(map[string]bool)
+	collectTags(x, func(tag string) {
+		_, fixed := tags[tag]
+		if !seen[tag] && !fixed && isPlatformTag(tag) {
+			seen[tag] = true
+			free = append(free, tag)
+		}
+	})
+	// Every platform tags combination is checked, even if it's impossible,
+	// like linux && windows, so the constraint is never rejected by mistake.
+	// The constraints are usually short, but a very long one is not checked at all.
+	if len(free) > 16 {
+		return false
+	}
+	assignment := make(map[string]bool, len(free))
+	for bits := 0; bits < 1<<len(free); bits++ {
+		for i, tag := range free {
+			assignment[tag] = bits&(1<<i) != 0
+		}
+		ok := x.Eval(func(tag string) bool {
+			return tags[tag] || assignment[tag]
+		})
+		if ok {
+			return false
+		}
+	}
+	return true
+}
+
+func collectTags(x constraint.Expr, visit func(tag string)) {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		visit(x.Tag)
+	case *constraint.NotExpr:
+		collectTags(x.X, visit)
+	case *constraint.AndExpr:
+		collectTags(x.X, visit)
+		collectTags(x.Y, visit)
+	case *constraint.OrExpr:
+		collectTags(x.X, visit)
+		collectTags(x.Y, visit)
+	}
+}
+
+// parseBuildTags parses a comma-separated -build-tags list.
+// The tags set maps the set tags to true and the explicitly unset ones, like !cgo, to false.
+//
+// A GOOS value in the list fixes the target OS, like GOOS does for the go tool:
+// the other GOOS tags become unset and the unix tag is set according to the OS.
+// A GOARCH value fixes the target architecture the same way.
+func parseBuildTags(s string) map[string]bool {
+	tags := make(map[string]bool)
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.TrimSpace(tag)
+		switch {
+		case tag == "":
+		case strings.HasPrefix(tag, "!"):
+			tags[strings.TrimSpace(tag[len("!"):])] = false
+		default:
+			tags[tag] = true
+		}
+	}
+
+	var goos []string
+	hasArch := false
+	for tag, set := range tags {
+		if _, ok := knownOS[tag]; ok && set {
+			goos = append(goos, tag)
+		}
+		if knownArch[tag] && set {
+			hasArch = true
+		}
+	}
+	if len(goos) != 0 {
+		unix := false
+		for _, tag := range goos {
+			if implied, ok := impliedOS[tag]; ok {
+				tags[implied] = true
+			}
+			unix = unix || knownOS[tag]
+		}
+		for tag := range knownOS {
+			if _, ok := tags[tag]; !ok {
+				tags[tag] = false
+			}
+		}
+		if _, ok := tags["unix"]; !ok {
+			tags["unix"] = unix
+		}
+	}
+	if hasArch {
+		for tag := range knownArch {
+			if _, ok := tags[tag]; !ok {
+				tags[tag] = false
+			}
+		}
+	}
+	return tags
+}
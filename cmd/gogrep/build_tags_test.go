@@ -0,0 +1,143 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBuildConstraints(t *testing.T) {
+	tests := []struct {
+		src     string
+		tags    string
+		ignored bool
+	}{
+		{"package p", "", false},
+		{"//go:build linux\n\npackage p", "", false},
+		{"//go:build windows && amd64 && cgo\n\npackage p", "", false},
+		{"//go:build !linux && !go1.18\n\npackage p", "", false},
+		{"// +build darwin freebsd\n\npackage p", "", false},
+		{"// Copyright.\n\n/*\n * License.\n */\n\n//go:build ignore\n\npackage p", "", true},
+
+		{"//go:build ignore\n\npackage p", "", true},
+		{"// +build ignore\n\npackage p", "", true},
+		{"//go:build integration\n\npackage p", "", true},
+		{"//go:build integration\n\npackage p", "integration", false},
+		{"//go:build integration\n\npackage p", "tools, integration", false},
+		{"//go:build !integration\n\npackage p", "integration", true},
+		{"//go:build linux && tools\n\npackage p", "", true},
+		{"//go:build linux || tools\n\npackage p", "", false},
+
+		// The //go:build line takes precedence over the // +build lines.
+		{"//go:build linux\n// +build ignore\n\npackage p", "", false},
+		// Several // +build lines are combined with &&.
+		{"// +build linux\n// +build ignore\n\npackage p", "", true},
+		// The constraints after the package clause are just comments.
+		{"package p\n\n//go:build ignore", "", false},
+
+		// Compound constraints.
+		{"//go:build (linux || darwin) && !integration\n\npackage p", "", false},
+		{"//go:build (linux || darwin) && !integration\n\npackage p", "integration", true},
+		{"//go:build !(tools || integration)\n\npackage p", "tools", true},
+		{"//go:build (tools || integration) && !(linux && !linux)\n\npackage p", "integration", false},
+		{"// +build linux,amd64 darwin,!cgo\n\npackage p", "", false},
+		{"// +build tools,integration\n\npackage p", "tools", true},
+		{"// +build tools,integration !ignore\n\npackage p", "tools", false},
+
+		// The GOOS and GOARCH values fix the target platform.
+		{"//go:build windows\n\npackage p", "linux", true},
+		{"//go:build linux || windows\n\npackage p", "windows", false},
+		{"//go:build !windows\n\npackage p", "windows", true},
+		{"//go:build unix\n\npackage p", "windows", true},
+		{"//go:build unix\n\npackage p", "darwin", false},
+		{"//go:build linux\n\npackage p", "android", false},
+		{"//go:build android\n\npackage p", "linux", true},
+		{"//go:build linux && arm64\n\npackage p", "linux,amd64", true},
+		{"//go:build linux && arm64\n\npackage p", "amd64", true},
+		{"//go:build linux && arm64\n\npackage p", "windows", true},
+		{"//go:build windows && cgo\n\npackage p", "windows", false},
+		{"// +build windows\n\npackage p", "linux", true},
+
+		// The explicitly unset tags.
+		{"//go:build cgo\n\npackage p", "!cgo", true},
+		{"//go:build !cgo\n\npackage p", "!cgo", false},
+		{"//go:build linux\n\npackage p", "!linux", true},
+
+		// The malformed constraints are ignored.
+		{"//go:build linux &&\n\npackage p", "", false},
+		{"//go:build (ignore\n// +build ignore\n\npackage p", "", true},
+	}
+
+	for _, test := range tests {
+		ignored := false
+		if x, _ := fileBuildConstraint([]byte(test.src)); x != nil {
+			ignored = isBuildIgnored(x, parseBuildTags(test.tags))
+		}
+		if ignored != test.ignored {
+			t.Errorf("%q with %q tags:\nhave: %v\nwant: %v", test.src, test.tags, ignored, test.ignored)
+		}
+	}
+}
+
+func TestFilenameBuildConstraint(t *testing.T) {
+	tests := []struct {
+		filename string
+		src      string
+		tags     string
+		ignored  bool
+	}{
+		{"x_windows.go", "package p", "", false},
+		{"x_windows.go", "package p", "linux", true},
+		{"x_windows.go", "package p", "windows", false},
+		{"x_windows_test.go", "package p", "linux", true},
+		{"dir/x_windows.go", "package p", "linux", true},
+		{"x_amd64.go", "package p", "arm64", true},
+		{"x_amd64.go", "package p", "linux", false},
+		{"x_linux_arm64.go", "package p", "linux,amd64", true},
+		{"x_linux_arm64.go", "package p", "linux,arm64", false},
+		{"x_linux_arm64.go", "package p", "windows", true},
+		{"x_android.go", "package p", "linux", true},
+
+		// The first name element is not a constraint.
+		{"windows.go", "package p", "linux", false},
+		{"windows_test.go", "package p", "linux", false},
+		{"linux_amd64.go", "package p", "linux,arm64", true},
+		// Only the known GOOS and GOARCH values are constraints.
+		{"x_unix.go", "package p", "windows", false},
+		{"x_tools.go", "package p", "", false},
+
+		// Both the filename and the header constraints should be satisfied.
+		{"x_linux.go", "//go:build integration\n\npackage p", "linux", true},
+		{"x_linux.go", "//go:build integration\n\npackage p", "linux,integration", false},
+		{"x_linux.go", "//go:build windows\n\npackage p", "linux", true},
+	}
+
+	for _, test := range tests {
+		x, _ := buildConstraint(test.filename, []byte(test.src))
+		ignored := x != nil && isBuildIgnored(x, parseBuildTags(test.tags))
+		if ignored != test.ignored {
+			t.Errorf("%s: %q with %q tags:\nhave: %v\nwant: %v", test.filename, test.src, test.tags, ignored, test.ignored)
+		}
+	}
+}
+
+func TestMalformedBuildConstraint(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"//go:build linux\n\npackage p", ""},
+		{"//go:build linux &&\n\npackage p", "line 1: malformed build constraint: unexpected end of expression"},
+		{"// Copyright.\n\n/*\n * License.\n */\n\n//go:build (linux\n\npackage p", "line 7: malformed build constraint: missing close paren"},
+		{"// +build linux\n//go:build linux ||\n\npackage p", "line 2: malformed build constraint: unexpected end of expression"},
+	}
+
+	for _, test := range tests {
+		_, err := fileBuildConstraint([]byte(test.src))
+		have := ""
+		if err != nil {
+			have = err.Error()
+		}
+		if have != test.want {
+			t.Errorf("%q:\nhave: %q\nwant: %q", test.src, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"go/ast"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// callResults returns the number of values the n call returns, or -1 if n is not a call
+// or its callee can't be resolved.
+//
+// There is no types info, so in addition to the calls resolved by gogrep.CallResults,
+// the calls of the current file functions are resolved by their names,
+// unless the name is shadowed by a local declaration. The method calls
+// and the calls of the other files functions are never resolved.
+func (ctx *filterContext) callResults(varname string, n ast.Node) int {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return -1
+	}
+	call, ok := unparenExpr(e).(*ast.CallExpr)
+	if !ok {
+		return -1
+	}
+	if results, known := gogrep.CallResults(nil, call); known {
+		return results
+	}
+	fn, ok := unparenExpr(call.Fun).(*ast.Ident)
+	if !ok || len(ctx.w.ancestors) == 0 {
+		return -1
+	}
+	file, ok := ctx.w.ancestors[0].(*ast.File)
+	if !ok {
+		return -1
+	}
+	decl := fileFuncDecl(file, fn.Name)
+	if decl == nil {
+		return -1
+	}
+
+	shadowed := false
+	child := n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		if _, ok := parent.(*ast.File); ok {
+			return false
+		}
+		names, _ := scopeNamesBefore(parent, child)
+		child = parent
+		for _, name := range names {
+			if name == fn.Name {
+				shadowed = true
+				return false
+			}
+		}
+		return true
+	})
+	if shadowed {
+		return -1
+	}
+	return decl.Type.Results.NumFields()
+}
+
+// fileFuncDecl returns the f top-level function (not a method) with the specified name.
+func fileFuncDecl(f *ast.File, name string) *ast.FuncDecl {
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if ok && fn.Recv == nil && fn.Name.Name == name {
+			return fn
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+)
+
+type cloneGroup struct {
+	matches []match
+}
+
+type jsonCloneGroup struct {
+	Group   int         `json:"group"`
+	Matches []jsonMatch `json:"matches"`
+}
+
+// initMatchCloneKey computes a structural hash of the match.
+//
+// The match source is tokenized, so the whitespace and comments are ignored;
+// every named capture is replaced by its name, so the matches that only
+// differ in the captured parts get identical keys.
+func (w *worker) initMatchCloneKey(m *match) {
+	src := w.data[m.startOffset:m.endOffset]
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+
+	h := sha256.New()
+	emitted := make([]bool, len(m.capture))
+	// A semicolon before the closing brace or paren is optional,
+	// so `{ return err }` and a multi-line block with an automatically
+	// inserted semicolon get the same keys.
+	pendingSemicolon := false
+	for {
+		pos, tok, lit := s.Scan()
+		if pendingSemicolon && tok != token.RBRACE && tok != token.RPAREN && tok != token.EOF {
+			h.Write([]byte("; "))
+		}
+		pendingSemicolon = false
+		if tok == token.EOF {
+			break
+		}
+		offset := m.startOffset + file.Offset(pos)
+		captured := false
+		for i, c := range m.capture {
+			if offset < c.startOffset || offset >= c.endOffset {
+				continue
+			}
+			captured = true
+			if !emitted[i] {
+				emitted[i] = true
+				fmt.Fprintf(h, "$%s ", c.data.Name)
+			}
+			break
+		}
+		if captured {
+			continue
+		}
+		switch {
+		case tok == token.SEMICOLON:
+			// Both explicit and automatically inserted semicolons are identical.
+			pendingSemicolon = true
+		case lit != "":
+			fmt.Fprintf(h, "%s ", lit)
+		default:
+			fmt.Fprintf(h, "%s ", tok)
+		}
+	}
+	m.cloneKey = hex.EncodeToString(h.Sum(nil))
+}
+
+// collectCloneGroups returns the groups of matches with more than one member.
+// The result is sorted by the match locations, so it doesn't depend on the workers scheduling.
+func (p *program) collectCloneGroups() []cloneGroup {
+	all := p.sortedMatches()
+
+	groupByKey := make(map[string]int)
+	var groups []cloneGroup
+	for _, m := range all {
+		i, ok := groupByKey[m.cloneKey]
+		if !ok {
+			i = len(groups)
+			groupByKey[m.cloneKey] = i
+			groups = append(groups, cloneGroup{})
+		}
+		groups[i].matches = append(groups[i].matches, m)
+	}
+
+	result := groups[:0]
+	for _, g := range groups {
+		if len(g.matches) > 1 {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+func (p *program) printCloneGroups() error {
+	groups := p.collectCloneGroups()
+
+	// Only the clones are reported, so the exit status should depend on them.
+	p.numMatches = 0
+	for _, g := range groups {
+		p.numMatches += uint64(len(g.matches))
+	}
+
+	var enc *json.Encoder
+	if p.args.format == jsonFormat {
+		enc = json.NewEncoder(os.Stdout)
+	}
+
+	printed := uint64(0)
+	for i, g := range groups {
+		if printed >= p.args.limit {
+			p.printSummary("results limited to %d matches", p.args.limit)
+			return nil
+		}
+		if enc != nil {
+			jsonGroup := jsonCloneGroup{Group: i + 1}
+			for _, m := range g.matches {
+				jsonGroup.Matches = append(jsonGroup.Matches, p.newJSONMatch(m))
+			}
+			if err := enc.Encode(jsonGroup); err != nil {
+				return err
+			}
+			printed += uint64(len(g.matches))
+			continue
+		}
+
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("clone group #%d (%d matches):\n", i+1, len(g.matches))
+		for _, m := range g.matches {
+			if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+				return err
+			}
+			printed++
+		}
+	}
+	p.printSummary("found %d clone groups", len(groups))
+	return nil
+}
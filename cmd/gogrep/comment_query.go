@@ -0,0 +1,115 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// queryComments applies the comment query filter to every file comment.
+//
+// Most comments are not attached to any node, so the file comments list
+// is used instead of the AST traversal.
+func (w *worker) queryComments(root *ast.File) {
+	// Both comments and declarations are sorted by their positions,
+	// so the enclosing declaration search can continue from the last one.
+	declIndex := 0
+	for _, group := range root.Comments {
+		for _, c := range group.List {
+			if !w.inLineRanges(c) {
+				continue
+			}
+			for declIndex < len(root.Decls) && root.Decls[declIndex].End() < c.Pos() {
+				declIndex++
+			}
+			w.funcDecl = nil
+			w.funcName = ""
+			w.inFuncBody = false
+			if declIndex < len(root.Decls) {
+				w.bindCommentFunc(root.Decls[declIndex], c)
+			}
+
+			data := gogrep.MatchData{Node: c}
+			for _, i := range w.activeRules {
+				r := w.rules[i]
+				if w.acceptMatch(r, data) {
+					w.addMatch(r, c, nil)
+				}
+			}
+		}
+	}
+}
+
+// bindCommentFunc records the function that encloses the c comment, if any.
+// Only function bodies are taken into account, so a function doc comment
+// or a comment inside the params list is not enclosed by that function.
+func (w *worker) bindCommentFunc(decl ast.Decl, c *ast.Comment) {
+	if fn, ok := decl.(*ast.FuncDecl); ok {
+		if fn.Body != nil && posInside(c.Pos(), fn.Body) {
+			w.funcDecl = fn
+			w.funcName = fn.Name.Name
+			w.inFuncBody = true
+		}
+		return
+	}
+	// A package-level function literal, like in `var f = func() { ... }`.
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if w.inFuncBody {
+			return false
+		}
+		if lit, ok := n.(*ast.FuncLit); ok && posInside(c.Pos(), lit.Body) {
+			w.inFuncBody = true
+		}
+		return n == nil || (n.Pos() <= c.Pos() && c.Pos() < n.End())
+	})
+}
+
+// posInside reports whether pos is located between the block braces.
+func posInside(pos token.Pos, block *ast.BlockStmt) bool {
+	return block.Lbrace < pos && pos < block.Rbrace
+}
+
+// commentBody returns the c text without the comment markers.
+func commentBody(c *ast.Comment) string {
+	if strings.HasPrefix(c.Text, "//") {
+		return c.Text[len("//"):]
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(c.Text, "/*"), "*/")
+}
+
+// commentDirective splits the c directive comment into its name and arguments,
+// like "go:embed" and "static/*" for the `//go:embed static/*` comment.
+//
+// Just like in the Go toolchain, a directive is a `//name:value` line comment
+// with a lowercase alphanumeric name prefix; the //line, //export and //extern
+// comments are directives as well. For other comments, both results are empty.
+func commentDirective(c *ast.Comment) (name, args string) {
+	if !strings.HasPrefix(c.Text, "//") {
+		return "", ""
+	}
+	name = c.Text[len("//"):]
+	if i := strings.IndexAny(name, " \t"); i != -1 {
+		args = strings.TrimSpace(name[i+1:])
+		name = name[:i]
+	}
+	switch name {
+	case "line", "export", "extern":
+		return name, args
+	}
+	colon := strings.IndexByte(name, ':')
+	if colon <= 0 || colon+1 == len(name) || !isDirectiveChar(name[colon+1]) {
+		return "", ""
+	}
+	for i := 0; i < colon; i++ {
+		if !isDirectiveChar(name[i]) {
+			return "", ""
+		}
+	}
+	return name, args
+}
+
+func isDirectiveChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9')
+}
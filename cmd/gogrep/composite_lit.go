@@ -0,0 +1,164 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/exp/typeparams"
+)
+
+// compositeLitTypeName returns the n composite literal (bound to varname) type name,
+// like "pkg.Thing" for both `pkg.Thing{}` and `&pkg.Thing{}`.
+// An elided literal type, like `{}` in `[]*pkg.Thing{{}}`, is taken from the enclosing literals.
+// An empty string is returned if n is not a composite literal or its type is unnamed, like `[]int{}`.
+func (ctx *filterContext) compositeLitTypeName(varname string, n ast.Node) string {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return ""
+	}
+	e = unparenExpr(e)
+	if addr, ok := e.(*ast.UnaryExpr); ok && addr.Op == token.AND {
+		e = unparenExpr(addr.X)
+	}
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return ""
+	}
+	if lit.Type != nil {
+		return namedTypeName(lit.Type)
+	}
+	if lit != n {
+		// Only the literals that are nested into other literals can have their types elided.
+		return ""
+	}
+
+	// Collect the elided literals chain up to the first literal with a type,
+	// every path element tells whether the literal is a map key.
+	var path []bool
+	var typ ast.Expr
+	var child ast.Node = lit
+	isKey := false
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent := parent.(type) {
+		case *ast.KeyValueExpr:
+			isKey = parent.Key == child
+			child = parent
+			return true
+		case *ast.CompositeLit:
+			path = append(path, isKey)
+			if parent.Type != nil {
+				typ = parent.Type
+				return false
+			}
+			child = parent
+			isKey = false
+			return true
+		}
+		return false
+	})
+	for i := len(path) - 1; i >= 0 && typ != nil; i-- {
+		typ = elidedElemType(typ, path[i])
+	}
+	if typ == nil {
+		return ""
+	}
+	return namedTypeName(typ)
+}
+
+// elidedElemType returns the typ literal element type, the type of its key if isKey is set.
+// For the pointer element types, the pointer base type is returned:
+// an elided `{}` inside of `[]*T{}` is a `&T{}`.
+func elidedElemType(typ ast.Expr, isKey bool) ast.Expr {
+	var elem ast.Expr
+	switch typ := unparenExpr(typ).(type) {
+	case *ast.ArrayType:
+		elem = typ.Elt
+	case *ast.MapType:
+		elem = typ.Value
+		if isKey {
+			elem = typ.Key
+		}
+	default:
+		// A named slice or map type element type is unknown without types info.
+		return nil
+	}
+	if ptr, ok := unparenExpr(elem).(*ast.StarExpr); ok {
+		return ptr.X
+	}
+	return elem
+}
+
+// namedTypeName returns the typ name, like "T" or "pkg.T".
+// The type args of a generic type are ignored: `pkg.List[int]` is a "pkg.List".
+// An empty string is returned for the type literals, like `[]int` or `struct{}`.
+func namedTypeName(typ ast.Expr) string {
+	switch typ := unparenExpr(typ).(type) {
+	case *ast.Ident:
+		return typ.Name
+	case *ast.SelectorExpr:
+		if pkg, ok := typ.X.(*ast.Ident); ok {
+			return pkg.Name + "." + typ.Sel.Name
+		}
+		return ""
+	case *ast.IndexExpr:
+		return namedTypeName(typ.X)
+	case *typeparams.IndexListExpr:
+		return namedTypeName(typ.X)
+	default:
+		return ""
+	}
+}
+
+// isStructType reports whether n is a struct type, like `struct{ x int }` or `T` if it's
+// declared as `type T struct{ x int }`. There is no types info, so only the struct type
+// literals and the current file top-level types are resolved, but the chains of
+// the type definitions, like `type A B; type B struct{}`, are followed.
+// The type args of a generic type are ignored: `List[int]` is a struct if List is.
+func (ctx *filterContext) isStructType(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	var file *ast.File
+	if len(ctx.w.ancestors) != 0 {
+		file, _ = ctx.w.ancestors[0].(*ast.File)
+	}
+	// The type definitions chain length is limited, so the invalid cyclic definitions are not followed forever.
+	for i := 0; i < 10; i++ {
+		switch typ := unparenExpr(e).(type) {
+		case *ast.StructType:
+			return true
+		case *ast.Ident, *ast.IndexExpr, *typeparams.IndexListExpr:
+			name := namedTypeName(typ)
+			if file == nil || strings.Contains(name, ".") {
+				return false
+			}
+			spec := fileTypeSpec(file, name)
+			if spec == nil {
+				return false
+			}
+			e = spec.Type
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// fileTypeSpec returns the f top-level type spec with the specified name.
+func fileTypeSpec(f *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range f.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.TypeSpec)
+			if spec.Name.Name == name {
+				return spec
+			}
+		}
+	}
+	return nil
+}
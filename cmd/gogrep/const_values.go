@@ -0,0 +1,87 @@
+package main
+
+import (
+	"go/ast"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// matchConst is a const declaration match constant, see gogrep.ConstValues.
+type matchConst struct {
+	name string
+
+	// value is the constant value in the Go syntax, like 1 or "s".
+	// It's empty if the value can't be computed without the types info.
+	value string
+}
+
+type jsonConst struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// matchConsts returns the constants declared by the n const decl or spec.
+// A spec can use iota and repeat the values of the preceding specs,
+// so the values are computed for the entire enclosing decl.
+func (w *worker) matchConsts(n ast.Node) []matchConst {
+	var values []gogrep.ConstValue
+	switch n := n.(type) {
+	case *ast.GenDecl:
+		values = gogrep.ConstValues(nil, n)
+	case *ast.ValueSpec:
+		decl := w.enclosingGenDecl(n)
+		if decl == nil {
+			return nil
+		}
+		for _, c := range gogrep.ConstValues(nil, decl) {
+			if c.Name.Pos() >= n.Pos() && c.Name.End() <= n.End() {
+				values = append(values, c)
+			}
+		}
+	}
+	var result []matchConst
+	for _, c := range values {
+		mc := matchConst{name: c.Name.Name}
+		if c.Value != nil {
+			mc.value = c.Value.ExactString()
+		}
+		result = append(result, mc)
+	}
+	return result
+}
+
+// enclosingGenDecl returns the spec parent decl.
+// The spec is either the visited node or the visited decl submatch.
+func (w *worker) enclosingGenDecl(spec ast.Spec) *ast.GenDecl {
+	candidates := []ast.Node{w.visited}
+	if len(w.ancestors) != 0 {
+		candidates = append(candidates, w.ancestors[len(w.ancestors)-1])
+	}
+	for _, n := range candidates {
+		decl, ok := n.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, s := range decl.Specs {
+			if s == spec {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// formatConsts returns the {{.Consts}} format var value, like `A=0, B=1`.
+// The unknown values are printed as ?.
+func formatConsts(consts []matchConst) string {
+	parts := make([]string, len(consts))
+	for i, c := range consts {
+		value := c.value
+		if value == "" {
+			value = "?"
+		}
+		parts[i] = c.name + "=" + value
+	}
+	return strings.Join(parts, ", ")
+}
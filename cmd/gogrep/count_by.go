@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// lineKey is a -count-by line aggregation key.
+type lineKey struct {
+	filename string
+	line     int
+}
+
+type lineCount struct {
+	lineKey
+	count int
+}
+
+func (p *program) validateCountByFlags() error {
+	if p.args.countBy != "line" {
+		return fmt.Errorf("count-by: unexpected value %q (want line)", p.args.countBy)
+	}
+	switch {
+	case p.args.fileQuery || p.args.clones || p.args.importAliases || p.args.receiverNames || p.args.blankImports:
+		return fmt.Errorf("can't use -file-query, -clones, -import-aliases, -receiver-names or -blank-imports together with -count-by")
+	case p.args.distinct != "" || p.args.groupByFile || p.args.dryRun || p.args.listFiles:
+		return fmt.Errorf("can't use -distinct, -group-by-file, -dry-run or -l together with -count-by")
+	case p.args.writeBaseline != "" || p.args.rewrite != "" || p.args.watch || p.args.testMode:
+		return fmt.Errorf("can't use -write-baseline, -rewrite, -watch or test mode together with -count-by")
+	case p.args.format != defaultFormat:
+		return fmt.Errorf("can't use -format together with -count-by")
+	}
+	return nil
+}
+
+// addLineCount records the match start line for the -count-by line mode.
+// Only the counters are collected, the matches themselves are not stored.
+func (w *worker) addLineCount(line int) {
+	if w.lineCounts == nil {
+		w.lineCounts = make(map[lineKey]int)
+	}
+	w.lineCounts[lineKey{filename: w.filename, line: line}]++
+}
+
+// collectLineCounts merges the workers line counters.
+// The lines are sorted by their counts in descending order, then by their locations.
+func (p *program) collectLineCounts() []lineCount {
+	counts := make(map[lineKey]int)
+	for _, w := range p.workers {
+		for key, n := range w.lineCounts {
+			counts[key] += n
+		}
+	}
+	result := make([]lineCount, 0, len(counts))
+	for key, n := range counts {
+		result = append(result, lineCount{lineKey: key, count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		x, y := result[i], result[j]
+		if x.count != y.count {
+			return x.count > y.count
+		}
+		if x.filename != y.filename {
+			return x.filename < y.filename
+		}
+		return x.line < y.line
+	})
+	return result
+}
+
+// printLineCounts prints every matched line with its matches count, like `3 main.go:42`.
+// The -limit applies to the printed lines, the matches are counted regardless of it.
+// With -c, only the totals are printed.
+func (p *program) printLineCounts() error {
+	counts := p.collectLineCounts()
+	for i, c := range counts {
+		if p.args.countMode {
+			break
+		}
+		if uint64(i) >= p.args.limit {
+			p.printSummary("results limited to %d lines", p.args.limit)
+			break
+		}
+		filename := c.filename
+		if p.args.abs {
+			filename = filepathAbs(p.workDir, filename)
+		}
+		fmt.Printf("%d %s:%d\n", c.count, filename, c.line)
+	}
+	p.printSummary("found %d matches on %d lines", p.numMatches, len(counts))
+	return nil
+}
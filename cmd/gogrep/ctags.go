@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"os"
+	"sort"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// ctagsFormat is a special -format value that makes gogrep print
+// the matches as a sorted ctags file, see matchTag for the tag names.
+const ctagsFormat = "ctags"
+
+type ctagsEntry struct {
+	name     string
+	filename string
+	line     int
+	kind     string
+}
+
+func (p *program) validateCtagsFlags() error {
+	if p.args.format != ctagsFormat {
+		return nil
+	}
+	switch {
+	case p.args.dryRun || p.args.listFiles || p.args.groupByFile || p.args.contextFunc || p.args.matchIDs:
+		return fmt.Errorf("can't use -dry-run, -l, -group-by-file, -context-func or -E together with ctags format")
+	case p.args.fileQuery || p.args.commentQuery || p.args.distinct != "" || p.args.clones:
+		return fmt.Errorf("can't use -file-query, -comment-query, -distinct or -clones together with ctags format")
+	case p.args.importAliases || p.args.receiverNames || p.args.blankImports:
+		return fmt.Errorf("can't use -import-aliases, -receiver-names or -blank-imports together with ctags format")
+	case p.args.rewrite != "" || p.args.writeBaseline != "" || p.args.watch || p.args.testMode:
+		return fmt.Errorf("can't use -rewrite, -write-baseline, -watch or test mode together with ctags format")
+	}
+	return nil
+}
+
+// matchTag returns the n match tag name, or an empty string if it has no meaningful symbol.
+// The first named capture that is bound to an identifier wins, like $name in `func $name($*_) $*_`.
+// Otherwise, the matched declaration (or identifier) name is used.
+// The matches without a symbol of their own are tagged with the enclosing function name.
+func (w *worker) matchTag(n ast.Node, capture []gogrep.CapturedNode) string {
+	for _, c := range capture {
+		if ident, ok := c.Node.(*ast.Ident); ok && ident.Name != "_" {
+			return ident.Name
+		}
+	}
+	if name := declName(n); name != "" {
+		return name
+	}
+	return w.funcName
+}
+
+// declName returns the name of the n declaration, or an empty string
+// if n is not a named declaration. A declaration group is named after
+// its only spec, the multi-spec groups have no single name.
+func declName(n ast.Node) string {
+	var ident *ast.Ident
+	switch n := n.(type) {
+	case *ast.Ident:
+		ident = n
+	case *ast.FuncDecl:
+		ident = n.Name
+	case *ast.TypeSpec:
+		ident = n.Name
+	case *ast.ValueSpec:
+		ident = n.Names[0]
+	case *ast.GenDecl:
+		if len(n.Specs) == 1 {
+			return declName(n.Specs[0])
+		}
+	case *ast.LabeledStmt:
+		ident = n.Label
+	}
+	if ident == nil || ident.Name == "_" {
+		return ""
+	}
+	return ident.Name
+}
+
+// printCtags prints the tags sorted by their names, so the result
+// can be saved as a tags file that editors can binary search.
+// The tag addresses are line numbers, the node kind is an extension field.
+func (p *program) printCtags(tags []ctagsEntry) error {
+	sort.SliceStable(tags, func(i, j int) bool {
+		x, y := tags[i], tags[j]
+		if x.name != y.name {
+			return x.name < y.name
+		}
+		if x.filename != y.filename {
+			return x.filename < y.filename
+		}
+		return x.line < y.line
+	})
+
+	w := bufio.NewWriter(os.Stdout)
+	fmt.Fprintf(w, "!_TAG_FILE_FORMAT\t2\t/extended format/\n")
+	fmt.Fprintf(w, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n")
+	fmt.Fprintf(w, "!_TAG_PROGRAM_NAME\tgogrep\t//\n")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "%s\t%s\t%d;\"\tkind:%s\n", tag.name, tag.filename, tag.line, tag.kind)
+	}
+	return w.Flush()
+}
+
+func (p *program) newCtagsEntry(m match) ctagsEntry {
+	filename := m.filename
+	if p.args.abs {
+		filename = filepathAbs(p.workDir, filename)
+	}
+	return ctagsEntry{name: m.tag, filename: filename, line: m.line, kind: m.kind}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// customFilterContext is a filters.FilterContext implementation
+// that is passed to the registered custom filter functions.
+type customFilterContext struct {
+	ctx *filterContext
+}
+
+func (c customFilterContext) Fset() *token.FileSet { return c.ctx.w.fset }
+
+func (c customFilterContext) Filename() string { return c.ctx.w.filename }
+
+func (c customFilterContext) Capture(varname string) (ast.Node, bool) {
+	return capturedByName(c.ctx.m, varname)
+}
+
+func (c customFilterContext) NodeText(n ast.Node) []byte { return c.ctx.w.nodeText(n) }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// addDistinctValue records the -distinct capture text of the match.
+// Only the values are collected, the matches themselves are not stored.
+// Empty captures (like $*args that matched nothing) are not recorded.
+func (w *worker) addDistinctValue(capture []gogrep.CapturedNode) {
+	for _, c := range capture {
+		if c.Name != w.distinct {
+			continue
+		}
+		if gogrep.IsEmptyNodeSlice(c.Node) {
+			return
+		}
+		if w.distinctValues == nil {
+			w.distinctValues = make(map[string]struct{})
+		}
+		w.distinctValues[string(w.nodeText(c.Node))] = struct{}{}
+		return
+	}
+}
+
+func (p *program) validateDistinctFlags() error {
+	name := strings.TrimPrefix(p.args.distinct, "$")
+	if name == "" || name == "_" || strings.HasPrefix(name, "*") {
+		return fmt.Errorf("distinct: expected a capture name, like $x, found %q", p.args.distinct)
+	}
+	switch {
+	case p.args.fileQuery || p.args.clones || p.args.importAliases:
+		return fmt.Errorf("can't use -file-query, -clones or -import-aliases together with -distinct")
+	case p.args.invertMatch != "":
+		return fmt.Errorf("can't use -invert-match together with -distinct")
+	case p.args.firstPer != "" || p.args.lastPer != "" || p.args.groupByFile:
+		return fmt.Errorf("can't use -first-per, -last-per or -group-by-file together with -distinct")
+	case p.args.writeBaseline != "":
+		return fmt.Errorf("can't use -write-baseline together with -distinct")
+	case p.args.format != defaultFormat:
+		return fmt.Errorf("can't use -format together with -distinct")
+	}
+	p.args.distinct = name
+	return nil
+}
+
+// printDistinctValues prints the values collected by all workers, one per line.
+// The values are sorted, so the output doesn't depend on the workers scheduling.
+func (p *program) printDistinctValues() error {
+	set := make(map[string]struct{})
+	for _, w := range p.workers {
+		for v := range w.distinctValues {
+			set[v] = struct{}{}
+		}
+	}
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		if !p.args.multiline {
+			v = strings.ReplaceAll(v, "\n", `\n`)
+		}
+		fmt.Println(v)
+	}
+	if p.args.countMode {
+		p.printSummary("found %d distinct values", len(values))
+	} else {
+		p.printSummary("found %d matches", p.numMatches)
+	}
+	return nil
+}
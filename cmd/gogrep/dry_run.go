@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"sort"
+)
+
+// printDryRunFiles reports the files collected in the -dry-run mode.
+// Only the filename-based filters are applied to them: -exclude,
+// file.IsTest() hints and the heatmap filenames.
+func (p *program) printDryRunFiles() error {
+	var files []string
+	for _, w := range p.workers {
+		files = append(files, w.dryRunFiles...)
+	}
+	sort.Strings(files)
+
+	// There are no matches in the dry run mode,
+	// the exit status depends on the files that would be searched.
+	p.numMatches = uint64(len(files))
+
+	if !p.args.countMode {
+		for _, filename := range files {
+			p.printFilename(filename)
+		}
+	}
+	log.Printf("would search %d files", len(files))
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// fileQueryFormat is a default -format for the -file-query mode.
+const fileQueryFormat = `{{.Filename}}`
+
+// fileSummary is a file query match data.
+// In -file-query mode, every match describes a single file.
+type fileSummary struct {
+	pkgName   string
+	funcCount int
+	lineCount int
+}
+
+type jsonFileMatch struct {
+	Filename  string `json:"filename"`
+	Package   string `json:"package"`
+	FuncCount int    `json:"func_count"`
+	LineCount int    `json:"line_count"`
+	ID        int    `json:"id,omitempty"`
+}
+
+// queryFile applies the file query filter to the entire file.
+func (w *worker) queryFile(root *ast.File) {
+	data := gogrep.MatchData{Node: root}
+	for _, i := range w.activeRules {
+		r := w.rules[i]
+		if !w.acceptMatch(r, data) {
+			continue
+		}
+		fingerprint := ""
+		if w.needFingerprint {
+			fingerprint = w.matchFingerprint(r, nil)
+			if w.baseline != nil && w.baseline.suppress(fingerprint) {
+				continue
+			}
+		}
+		w.n++
+		if w.countMode {
+			continue
+		}
+		w.matches = append(w.matches, match{
+			rule:        r,
+			filename:    w.filename,
+			fingerprint: fingerprint,
+			file: &fileSummary{
+				pkgName:   root.Name.Name,
+				funcCount: fileFuncCount(root),
+				lineCount: w.fset.File(root.Pos()).LineCount(),
+			},
+		})
+	}
+}
+
+// fileFuncCount returns the number of top-level functions and methods.
+func fileFuncCount(f *ast.File) int {
+	n := 0
+	for _, decl := range f.Decls {
+		if _, ok := decl.(*ast.FuncDecl); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// fileImports reports whether f imports the specified package path.
+func fileImports(f *ast.File, path string) bool {
+	for _, imp := range f.Imports {
+		importPath, err := strconv.Unquote(imp.Path.Value)
+		if err == nil && importPath == path {
+			return true
+		}
+	}
+	return false
+}
+
+func printFileMatch(tmpl *template.Template, wd string, args *arguments, m match) error {
+	filename := m.filename
+	if args.abs {
+		filename = filepathAbs(wd, filename)
+	}
+	data := map[string]interface{}{
+		"Filename":  filename,
+		"PkgName":   m.file.pkgName,
+		"FuncCount": m.file.funcCount,
+		"LineCount": m.file.lineCount,
+		"ID":        m.id,
+	}
+	if !args.noColor {
+		data["Filename"] = mustColorizeText(filename, args.filenameColor)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+	fmt.Println(buf.String())
+	return nil
+}
+
+func (p *program) newJSONFileMatch(m match) jsonFileMatch {
+	filename := m.filename
+	if p.args.abs {
+		filename = filepathAbs(p.workDir, filename)
+	}
+	return jsonFileMatch{
+		Filename:  filename,
+		Package:   m.file.pkgName,
+		FuncCount: m.file.funcCount,
+		LineCount: m.file.lineCount,
+		ID:        m.id,
+	}
+}
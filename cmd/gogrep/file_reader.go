@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	// maxPooledFileSize is the largest file that is read into the reused buffer.
+	// Larger files get a one-off allocation, so a single huge file
+	// doesn't make the worker hold a lot of memory till the end of the run.
+	maxPooledFileSize = 1 << 20
+
+	// mmapThreshold is the smallest file that is memory-mapped in -mmap mode.
+	mmapThreshold = maxPooledFileSize
+)
+
+// fileReader loads the target files contents.
+//
+// Every worker has its own reader and the buffer is reused across the files,
+// so the memory usage is bounded by the workers count times the maxPooledFileSize.
+//
+// The data returned by read is only valid until the release call.
+// Everything that outlives the file processing (like the match text) should be copied.
+type fileReader struct {
+	buf []byte
+
+	// mmap enables the memory-mapping of the large files.
+	mmap bool
+
+	// mapped is the current file mapping, if any.
+	mapped []byte
+}
+
+func (r *fileReader) read(filename string) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := int(info.Size())
+
+	switch {
+	case size == 0:
+		return nil, nil
+	case r.mmap && size >= mmapThreshold:
+		data, err := mmapFile(f, size)
+		if err != nil {
+			return nil, err
+		}
+		r.mapped = data
+		return data, nil
+	case size > maxPooledFileSize:
+		return readAll(f, make([]byte, size))
+	}
+
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size, maxPooledFileSize)
+	}
+	return readAll(f, r.buf[:size])
+}
+
+// release frees the resources that are associated with the last read.
+func (r *fileReader) release() error {
+	if r.mapped == nil {
+		return nil
+	}
+	err := munmapFile(r.mapped)
+	r.mapped = nil
+	return err
+}
+
+// readAll reads the f contents into buf.
+// If the file was truncated after its size was checked, the result is truncated as well.
+func readAll(f *os.File, buf []byte) ([]byte, error) {
+	n, err := io.ReadFull(f, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return buf[:n], err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func BenchmarkFileReader(b *testing.B) {
+	dir := b.TempDir()
+	var filenames []string
+	for i, size := range []int{1 << 10, 16 << 10, 64 << 10, 256 << 10} {
+		filename := filepath.Join(dir, fmt.Sprintf("file%d.go", i))
+		data := strings.Repeat("x", size)
+		if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+			b.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	// Simulate a lot of workers, every parallel goroutine is a worker.
+	const parallelism = 16
+
+	b.Run("ReadFile", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetParallelism(parallelism)
+		var counter uint64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				i := atomic.AddUint64(&counter, 1)
+				data, err := os.ReadFile(filenames[i%uint64(len(filenames))])
+				if err != nil || len(data) == 0 {
+					b.Fail()
+				}
+			}
+		})
+	})
+
+	b.Run("fileReader", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetParallelism(parallelism)
+		var counter uint64
+		b.RunParallel(func(pb *testing.PB) {
+			var r fileReader
+			for pb.Next() {
+				i := atomic.AddUint64(&counter, 1)
+				data, err := r.read(filenames[i%uint64(len(filenames))])
+				if err != nil || len(data) == 0 {
+					b.Fail()
+				}
+				if err := r.release(); err != nil {
+					b.Fail()
+				}
+			}
+		})
+	})
+}
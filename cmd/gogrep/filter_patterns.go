@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+)
+
+func isFilterPatternOp(op filters.Operation) bool {
+	switch op {
+	case opVarFollowedBy, opVarContains, opVarHasElem, opVarFuncContains:
+		return true
+	default:
+		return false
+	}
+}
+
+// compileFilterPatterns compiles the rule filter patterns, like the FollowedBy() one.
+func (p *program) compileFilterPatterns(optab *filters.OperationsTable, r *rule, e *filters.Expr) error {
+	for _, arg := range e.Args {
+		if err := p.compileFilterPatterns(optab, r, arg); err != nil {
+			return err
+		}
+	}
+	if !isFilterPatternOp(e.Op) {
+		return nil
+	}
+
+	src := e.Args[0].Str
+	if _, ok := r.filterPatterns[src]; ok {
+		return nil
+	}
+	config := gogrep.CompileConfig{
+		Fset:         token.NewFileSet(),
+		Src:          src,
+		Strict:       p.args.strictSyntax,
+		IgnoreParens: !p.args.strictSyntax,
+	}
+	m, _, err := gogrep.Compile(config)
+	if err != nil {
+		return fmt.Errorf("%s %s: %v", optab.VarFuncName(e.Op), src, err)
+	}
+	if r.filterPatterns == nil {
+		r.filterPatterns = make(map[string]*gogrep.Pattern)
+	}
+	r.filterPatterns[src] = m
+	return nil
+}
+
+// contains reports whether pat matches n or any of its descendants.
+// Like in followedBy, the pattern shares the captures with the current match.
+func (ctx *filterContext) contains(n ast.Node, pat *gogrep.Pattern) bool {
+	state := &ctx.w.filterPatternState
+	state.CapturePreset = ctx.m.Capture
+	matched := false
+	gogrep.Walk(n, func(n ast.Node) bool {
+		if matched || n == nil {
+			return false
+		}
+		pat.MatchNode(state, n, func(gogrep.MatchData) {
+			matched = true
+		})
+		return !matched
+	})
+	state.CapturePreset = nil
+	return matched
+}
+
+// hasElem reports whether pat matches any of the n slice elements, like a call argument
+// of the $*args capture. Unlike contains, the nodes nested inside of the elements are not checked.
+// A node that is not a slice is treated as a single element slice.
+func (ctx *filterContext) hasElem(n ast.Node, pat *gogrep.Pattern) bool {
+	state := &ctx.w.filterPatternState
+	state.CapturePreset = ctx.m.Capture
+	matched := false
+	match := func(elem ast.Node) {
+		pat.MatchNode(state, elem, func(gogrep.MatchData) {
+			matched = true
+		})
+	}
+	if slice, ok := n.(*gogrep.NodeSlice); ok {
+		for i := 0; i < slice.Len() && !matched; i++ {
+			match(slice.At(i))
+		}
+	} else {
+		match(n)
+	}
+	state.CapturePreset = nil
+	return matched
+}
+
+// enclosingFuncBody returns the body of the innermost function declaration
+// or function literal that encloses the varname node.
+// Returns nil if there is no such function.
+func (ctx *filterContext) enclosingFuncBody(varname string) *ast.BlockStmt {
+	var body *ast.BlockStmt
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent := parent.(type) {
+		case *ast.FuncDecl:
+			body = parent.Body
+			return false
+		case *ast.FuncLit:
+			body = parent.Body
+			return false
+		}
+		return true
+	})
+	return body
+}
@@ -6,10 +6,12 @@ import (
 	"go/token"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/quasilyte/gogrep"
 	"github.com/quasilyte/gogrep/filters"
 	"github.com/quasilyte/perf-heatmap/heatmap"
+	"golang.org/x/text/unicode/norm"
 )
 
 type bool3 byte
@@ -49,10 +51,126 @@ const (
 	opVarIsComplexLit
 	opVarText
 	opVarIsHot
+	opVarInDefer
+	opVarInGoroutine
+	opVarInLoop
+	opVarIsSink
+	opVarIsCalled
+	opVarShadows
+	opVarIsExprStmt
+	opVarIsVariadic
+	opVarUsesReceiver
+	opVarIsPkgName
+	opVarCapturesLoopVar
+	opVarEqual
+	opVarIsSpreadCall
+	opVarIsAlias
+	opVarIsExported
+	opVarIsVerbArg
+	opVarReturns
+	opVarDepth
+	opVarPkgRefs
+	opVarVerbCount
+	opVarSimilar
+	opVarFollowedBy
+	opVarContains
+	opVarHasElem
+	opVarFuncContains
+	opVarLitKind
+	opVarKind
+	opVarTypeName
+	opVarIsNil
+	opVarIsTypedNil
+	opVarIsConversion
+	opVarIsError
+	opVarIsStructType
+	opVarIsRedundantLabel
+	opVarLabelTarget
+	opVarIsRedundantConversion
+	opVarCount
+	opVarHasElse
+	opVarHasDefault
+	opVarStringMatches
+	opVarStringIs
+	opVarPkgPath
+	opVarInPackage
+
+	// Comment query ops, they're only available in -comment-query mode.
+	opVarHasPrefix
+	opVarDirective
+	opVarDirectiveArgs
+	opVarInFunc
+	opVarFuncName
+
+	// File query ops, they're only available in -file-query mode.
+	opVarFuncCount
+	opVarLineCount
+	opVarImports
+	opVarPkgName
+	opVarDirName
+	opVarFileName
+)
+
+// isFileQueryOp reports whether op can only be applied to the $$ file in -file-query mode.
+func isFileQueryOp(op filters.Operation) bool {
+	switch op {
+	case opVarFuncCount, opVarLineCount, opVarImports, opVarPkgName, opVarDirName, opVarFileName:
+		return true
+	default:
+		return false
+	}
+}
+
+// isCommentQueryOp reports whether op can only be applied to the $$ comment in -comment-query mode.
+func isCommentQueryOp(op filters.Operation) bool {
+	switch op {
+	case opVarHasPrefix, opVarDirective, opVarDirectiveArgs, opVarInFunc, opVarFuncName:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterType is a filter expression result type.
+type filterType int
+
+const (
+	filterBool filterType = iota
+	filterInt
+	filterString
+	filterNode
 )
 
+func (typ filterType) String() string {
+	switch typ {
+	case filterInt:
+		return "int"
+	case filterString:
+		return "string"
+	case filterNode:
+		return "node"
+	default:
+		return "bool"
+	}
+}
+
+func filterExprType(e *filters.Expr) filterType {
+	switch e.Op {
+	case filters.OpInt, opVarCount, opVarReturns, opVarDepth, opVarPkgRefs, opVarVerbCount, opVarFuncCount, opVarLineCount:
+		return filterInt
+	case filters.OpVar:
+		return filterNode
+	case filters.OpString, opVarText, opVarLitKind, opVarKind, opVarLabelTarget, opVarTypeName, opVarPkgName, opVarPkgPath, opVarDirName, opVarFileName,
+		opVarDirective, opVarDirectiveArgs, opVarFuncName:
+		return filterString
+	default:
+		return filterBool
+	}
+}
+
 type filterContext struct {
 	m gogrep.MatchData
+	r *rule
 	w *worker
 }
 
@@ -64,6 +182,358 @@ func (ctx *filterContext) NodeText(varname string) []byte {
 	return ctx.w.nodeText(n)
 }
 
+// walkAncestors calls visit for every node that encloses the varname node,
+// starting from the innermost one. It stops when visit returns false.
+func (ctx *filterContext) walkAncestors(varname string, visit func(ast.Node) bool) {
+	n, ok := capturedByName(ctx.m, varname)
+	if !ok {
+		return
+	}
+	if n != ctx.m.Node {
+		path := findNodePath(ctx.m.Node, n)
+		for i := len(path) - 1; i >= 0; i-- {
+			if !visit(path[i]) {
+				return
+			}
+		}
+	}
+	if _, ok := ctx.m.Node.(*gogrep.NodeSlice); ok {
+		// Node slices are not visited on their own,
+		// they're matched as a part of the visited node.
+		if !visit(ctx.w.visited) {
+			return
+		}
+	}
+	for i := len(ctx.w.ancestors) - 1; i >= 0; i-- {
+		if !visit(ctx.w.ancestors[i]) {
+			return
+		}
+	}
+}
+
+// isExecutedBy reports whether the varname node is executed as a part of
+// an enclosing statement that satisfies pred, like a defer or go statement.
+// Only the called function itself is executed by the statement, its arguments are evaluated
+// right away: recover() in `defer f(recover())` is not deferred.
+// The closures are followed only if they're called right away, like in `defer func() { ... }()`:
+// the code of a closure that is only declared or passed somewhere is not executed by the statement itself.
+func (ctx *filterContext) isExecutedBy(varname string, pred func(ast.Node) bool) bool {
+	found := false
+	var closure ast.Node // The func literal that should be called by the next parent
+	// The last two visited nodes, starting from the matched node.
+	child, _ := capturedByName(ctx.m, varname)
+	var grandchild ast.Node
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		stmtCall, callChild := child, grandchild
+		grandchild, child = child, parent
+		if closure != nil {
+			switch parent := parent.(type) {
+			case *ast.ParenExpr:
+				closure = parent
+				return true
+			case *ast.CallExpr:
+				if parent.Fun != closure {
+					return false
+				}
+				closure = nil
+			default:
+				return false
+			}
+		}
+		switch parent.(type) {
+		case *ast.FuncLit:
+			closure = parent
+			return true
+		case *ast.FuncDecl:
+			return false
+		}
+		if !pred(parent) {
+			return true
+		}
+		// The stmtCall is the statement call, like `f(x)` in `defer f(x)`.
+		// The callChild is nil if the matched node is the call itself.
+		call, ok := stmtCall.(*ast.CallExpr)
+		if !ok || callChild == nil || callChild == call.Fun {
+			found = true
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// isCalled reports whether n (bound to varname) is used in a call position, like `f` in `f(x)`.
+func (ctx *filterContext) isCalled(varname string, n ast.Node) bool {
+	child := n
+	called := false
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent := parent.(type) {
+		case *ast.ParenExpr:
+			child = parent
+			return true
+		case *ast.CallExpr:
+			called = parent.Fun == child
+		}
+		return false
+	})
+	return called
+}
+
+// inLoop reports whether the varname node is located inside a for or range loop body.
+// The loop should belong to the same function: a function literal
+// that is declared inside a loop body is not in that loop.
+func (ctx *filterContext) inLoop(varname string) bool {
+	n, ok := capturedByName(ctx.m, varname)
+	if !ok {
+		return false
+	}
+	child := n
+	found := false
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent := parent.(type) {
+		case *ast.ForStmt:
+			found = parent.Body == child
+		case *ast.RangeStmt:
+			found = parent.Body == child
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		}
+		child = parent
+		return !found
+	})
+	return found
+}
+
+// depthCounters are the Depth() modes, they select the ancestors that are counted.
+var depthCounters = map[string]func(ast.Node) bool{
+	// Every node counts, so a top-level declaration depth is 1 (its parent is the file).
+	"node": func(ast.Node) bool { return true },
+
+	// Only the control flow statements that nest the other statements count.
+	// The blocks, case clauses and labels are not a nesting level on their own.
+	"stmt": func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			return true
+		}
+		return false
+	},
+
+	// Only the explicit blocks count, every {} pair is one level, including the function bodies.
+	// A switch body is a block, but its case clauses are not.
+	"block": func(n ast.Node) bool {
+		_, ok := n.(*ast.BlockStmt)
+		return ok
+	},
+}
+
+// depth returns the number of the varname node ancestors that are accepted by count.
+// The function literals don't reset the depth, it's always counted from the file root.
+func (ctx *filterContext) depth(varname string, count func(ast.Node) bool) int {
+	depth := 0
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		if count(parent) {
+			depth++
+		}
+		return true
+	})
+	return depth
+}
+
+// enclosingMethod returns n (bound to varname) if it's a method declaration,
+// otherwise the method that encloses n, including the function literals inside of it.
+// Returns nil for the nodes outside of any method.
+func (ctx *filterContext) enclosingMethod(varname string, n ast.Node) *ast.FuncDecl {
+	if fn, ok := n.(*ast.FuncDecl); ok {
+		if fn.Recv == nil {
+			return nil
+		}
+		return fn
+	}
+	var method *ast.FuncDecl
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		fn, ok := parent.(*ast.FuncDecl)
+		if !ok {
+			return true
+		}
+		if fn.Recv != nil {
+			method = fn
+		}
+		return false
+	})
+	return method
+}
+
+// isExprStmt reports whether n (bound to varname) is used as an expression statement,
+// like `f()` in `{ f() }`, so its results are discarded.
+func (ctx *filterContext) isExprStmt(varname string, n ast.Node) bool {
+	if _, ok := n.(*ast.ExprStmt); ok {
+		return true
+	}
+	isStmt := false
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent.(type) {
+		case *ast.ParenExpr:
+			return true
+		case *ast.ExprStmt:
+			isStmt = true
+		}
+		return false
+	})
+	return isStmt
+}
+
+// isVariadicFunc reports whether n is a function (or a function type)
+// that has a variadic last parameter, like `func f(format string, args ...any)`.
+func isVariadicFunc(n ast.Node) bool {
+	var typ *ast.FuncType
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		typ = n.Type
+	case *ast.FuncLit:
+		typ = n.Type
+	case *ast.FuncType:
+		typ = n
+	default:
+		return false
+	}
+	params := typ.Params.List
+	if len(params) == 0 {
+		return false
+	}
+	_, ok := params[len(params)-1].Type.(*ast.Ellipsis)
+	return ok
+}
+
+// isSpreadCall reports whether n is a call that passes a slice
+// as the variadic arguments, like `append(s, xs...)`.
+func isSpreadCall(n ast.Node) bool {
+	call, ok := n.(*ast.CallExpr)
+	return ok && call.Ellipsis.IsValid()
+}
+
+// isTypeAlias reports whether n is a type alias spec, like `type A = B`,
+// or a type declaration of a single alias spec.
+func isTypeAlias(n ast.Node) bool {
+	if decl, ok := n.(*ast.GenDecl); ok && decl.Tok == token.TYPE && len(decl.Specs) == 1 {
+		n = decl.Specs[0]
+	}
+	spec, ok := n.(*ast.TypeSpec)
+	return ok && spec.Assign.IsValid()
+}
+
+// isNilIdent reports whether n is an untyped nil, the predeclared nil identifier.
+func isNilIdent(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	ident, ok := unparenExpr(e).(*ast.Ident)
+	return ok && ident.Name == "nil"
+}
+
+// isTypedNil reports whether n is a nil converted to a non-interface type, like `(*T)(nil)`.
+// Without types info, only the conversions with a type literal are recognized:
+// `T(nil)` can be a function call, so it's not reported.
+func isTypedNil(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	call, ok := unparenExpr(e).(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() || !isNilIdent(call.Args[0]) {
+		return false
+	}
+	switch unparenExpr(call.Fun).(type) {
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType:
+		return true
+	default:
+		return false
+	}
+}
+
+// isConversion reports whether n is a type conversion call, like `string(b)`.
+// There is no types info, so only the conversions that can be recognized
+// syntactically are reported, see gogrep.IsConversion.
+func isConversion(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	call, ok := unparenExpr(e).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	conv, known := gogrep.IsConversion(nil, call)
+	return known && conv
+}
+
+// isError reports whether n is an expression of an error type, like `errors.New(s)`.
+// There is no types info, so only the well-known error constructors calls
+// are reported, see gogrep.IsErrorValue.
+func isError(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	isError, known := gogrep.IsErrorValue(nil, e)
+	return known && isError
+}
+
+// isRedundantConversion reports whether n is a conversion of a conversion
+// to the same type, like `[]byte([]byte(s))`, see gogrep.IsRedundantConversion.
+func isRedundantConversion(n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	call, ok := unparenExpr(e).(*ast.CallExpr)
+	return ok && gogrep.IsRedundantConversion(nil, call)
+}
+
+// hasDefaultClause reports whether n is a switch, type switch
+// or select statement with a default clause.
+func hasDefaultClause(n ast.Node) bool {
+	var body *ast.BlockStmt
+	switch n := n.(type) {
+	case *ast.SwitchStmt:
+		body = n.Body
+	case *ast.TypeSwitchStmt:
+		body = n.Body
+	case *ast.SelectStmt:
+		body = n.Body
+	default:
+		return false
+	}
+	for _, clause := range body.List {
+		switch clause := clause.(type) {
+		case *ast.CaseClause:
+			if clause.List == nil {
+				return true
+			}
+		case *ast.CommClause:
+			if clause.Comm == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nodeCount returns the number of nodes bound to a capture:
+// the $*x slice length or the number of statements inside a block.
+// Any other node is counted as a single one.
+func nodeCount(n ast.Node) int {
+	switch n := n.(type) {
+	case *gogrep.NodeSlice:
+		return n.Len()
+	case *ast.BlockStmt:
+		return len(n.List)
+	default:
+		return 1
+	}
+}
+
 func applyFilter(ctx filterContext, f *filters.Expr, n ast.Node) bool {
 	switch f.Op {
 	case filters.OpNot:
@@ -132,25 +602,418 @@ func applyFilter(ctx filterContext, f *filters.Expr, n ast.Node) bool {
 		})
 		return isHot
 
+	case opVarInDefer:
+		return ctx.isExecutedBy(f.Str, func(n ast.Node) bool {
+			_, ok := n.(*ast.DeferStmt)
+			return ok
+		})
+	case opVarInGoroutine:
+		return ctx.isExecutedBy(f.Str, func(n ast.Node) bool {
+			_, ok := n.(*ast.GoStmt)
+			return ok
+		})
+	case opVarInLoop:
+		return ctx.inLoop(f.Str)
+	case opVarUsesReceiver:
+		n, _ := capturedByName(ctx.m, f.Str)
+		fn := ctx.enclosingMethod(f.Str, n)
+		return fn != nil && methodUsesReceiver(fn)
+	case opVarEqual:
+		x, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		y, ok := capturedByName(ctx.m, f.Args[0].Str)
+		return ok && gogrep.EqualNodes(x, y)
+	case opVarCapturesLoopVar:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.capturesLoopVar(f.Str, v)
+	case opVarIsPkgName:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.isImportedPkgName(f.Str, v)
+
+	case opVarIsSink:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return isSinkCall(ctx.w.sinks, v)
+
+	case opVarIsCalled:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.isCalled(f.Str, v)
+
+	case opVarIsExprStmt:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.isExprStmt(f.Str, v)
+
+	case opVarIsVariadic:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isVariadicFunc(v)
+
+	case opVarIsSpreadCall:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isSpreadCall(v)
+	case opVarIsAlias:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isTypeAlias(v)
+	case opVarIsExported:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isExported(v)
+	case opVarIsVerbArg:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		verb, _ := parseVerbArg(f.Args[0].Str)
+		return ctx.isVerbArg(f.Str, v, verb)
+
+	case opVarIsNil:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isNilIdent(v)
+
+	case opVarIsTypedNil:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isTypedNil(v)
+
+	case opVarIsConversion:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isConversion(v)
+	case opVarIsRedundantConversion:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isRedundantConversion(v)
+	case opVarIsError:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && isError(v)
+	case opVarIsStructType:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && ctx.isStructType(v)
+	case opVarIsRedundantLabel:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && ctx.isRedundantLabel(f.Str, v)
+
+	case opVarHasElse:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		ifStmt, ok := v.(*ast.IfStmt)
+		return ok && ifStmt.Else != nil
+	case opVarHasDefault:
+		v, ok := capturedByName(ctx.m, f.Str)
+		return ok && hasDefaultClause(v)
+
+	case opVarStringMatches:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		s, ok := stringLitValue(v)
+		return ok && ctx.r.stringRegexps[f.Args[0].Str].MatchString(s)
+	case opVarStringIs:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		s, ok := stringLitValue(v)
+		return ok && stringValidators[f.Args[0].Str].MatchString(s)
+
+	case opVarSimilar:
+		_, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		limit := int(f.Args[1].Num)
+		return editDistance(string(ctx.NodeText(f.Str)), f.Args[0].Str, limit) <= limit
+
+	case opVarFollowedBy:
+		return ctx.followedBy(f.Str, ctx.r.filterPatterns[f.Args[0].Str])
+	case opVarContains:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.contains(v, ctx.r.filterPatterns[f.Args[0].Str])
+	case opVarHasElem:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.hasElem(v, ctx.r.filterPatterns[f.Args[0].Str])
+	case opVarFuncContains:
+		body := ctx.enclosingFuncBody(f.Str)
+		if body == nil {
+			return false
+		}
+		return ctx.contains(body, ctx.r.filterPatterns[f.Args[0].Str])
+
+	case opVarShadows:
+		v, ok := capturedByName(ctx.m, f.Str)
+		if !ok {
+			return false
+		}
+		return ctx.shadowsOuterVar(f.Str, v)
+
+	case opVarImports:
+		file, ok := ctx.m.Node.(*ast.File)
+		return ok && fileImports(file, f.Args[0].Str)
+
+	case opVarHasPrefix:
+		c, ok := ctx.m.Node.(*ast.Comment)
+		return ok && strings.HasPrefix(commentBody(c), f.Args[0].Str)
+	case opVarInFunc:
+		return ctx.w.inFuncBody
+	case opVarInPackage:
+		path := ctx.w.filePkgPath(ctx.w.filename)
+		return path != "" && matchPkgPattern(f.Args[0].Str, path)
+
+	case filters.OpCustomFunc:
+		v, ok := capturedByName(ctx.m, f.Args[0].Str)
+		if !ok {
+			return false
+		}
+		return filters.LookupFunc(f.Str)(customFilterContext{ctx: &ctx}, v)
+
 	case filters.OpEq:
 		return applyEqFilter(ctx, f, n)
 	case filters.OpNotEq:
 		return !applyEqFilter(ctx, f, n)
 
+	case filters.OpIn:
+		s := evalStringFilter(ctx, f.Args[0])
+		if ctx.w.nfc {
+			s = norm.NFC.String(s)
+		}
+		_, ok := ctx.r.valueSets[f.Args[1].Str][s]
+		return ok
+
+	case filters.OpLt:
+		return evalIntFilter(ctx, f.Args[0]) < evalIntFilter(ctx, f.Args[1])
+	case filters.OpLtEq:
+		return evalIntFilter(ctx, f.Args[0]) <= evalIntFilter(ctx, f.Args[1])
+	case filters.OpGt:
+		return evalIntFilter(ctx, f.Args[0]) > evalIntFilter(ctx, f.Args[1])
+	case filters.OpGtEq:
+		return evalIntFilter(ctx, f.Args[0]) >= evalIntFilter(ctx, f.Args[1])
+
 	default:
-		panic(fmt.Sprintf("can't handle %s\n", filters.Sprint(ctx.w.filterInfo, f)))
+		panic(fmt.Sprintf("can't handle %s\n", filters.Sprint(&ctx.r.filterInfo, f)))
 	}
 }
 
 func applyEqFilter(ctx filterContext, f *filters.Expr, n ast.Node) bool {
 	x := f.Args[0]
 	y := f.Args[1]
-	if x.Op == opVarText {
-		if y.Op == filters.OpString {
-			return string(ctx.NodeText(x.Str)) == y.Str
+	if filterExprType(x) == filterInt {
+		return evalIntFilter(ctx, x) == evalIntFilter(ctx, y)
+	}
+	if ctx.w.nfc {
+		return norm.NFC.String(evalStringFilter(ctx, x)) == norm.NFC.String(evalStringFilter(ctx, y))
+	}
+	return evalStringFilter(ctx, x) == evalStringFilter(ctx, y)
+}
+
+func evalIntFilter(ctx filterContext, e *filters.Expr) int {
+	switch e.Op {
+	case filters.OpInt:
+		return int(e.Num)
+	case opVarCount:
+		n, ok := capturedByName(ctx.m, e.Str)
+		if !ok {
+			return 0
+		}
+		return nodeCount(n)
+	case opVarReturns:
+		n, ok := capturedByName(ctx.m, e.Str)
+		if !ok {
+			return -1
+		}
+		return ctx.callResults(e.Str, n)
+	case opVarDepth:
+		mode := "node"
+		if len(e.Args) != 0 {
+			mode = e.Args[0].Str
+		}
+		return ctx.depth(e.Str, depthCounters[mode])
+	case opVarPkgRefs:
+		n, ok := capturedByName(ctx.m, e.Str)
+		if !ok {
+			return -1
+		}
+		return ctx.pkgRefs(n)
+	case opVarVerbCount:
+		n, ok := capturedByName(ctx.m, e.Str)
+		if !ok {
+			return -1
+		}
+		verb, _ := parseVerbArg(e.Args[0].Str)
+		return verbCount(n, verb)
+	case opVarFuncCount:
+		file, ok := ctx.m.Node.(*ast.File)
+		if !ok {
+			return 0
+		}
+		return fileFuncCount(file)
+	case opVarLineCount:
+		return ctx.w.fset.File(ctx.m.Node.Pos()).LineCount()
+	}
+	panic(fmt.Sprintf("can't handle %s\n", filters.Sprint(&ctx.r.filterInfo, e)))
+}
+
+func evalStringFilter(ctx filterContext, e *filters.Expr) string {
+	switch e.Op {
+	case filters.OpString:
+		return e.Str
+	case opVarText:
+		return string(ctx.NodeText(e.Str))
+	case opVarLitKind:
+		n, _ := capturedByName(ctx.m, e.Str)
+		return basicLitKind(n)
+	case opVarKind:
+		n, _ := capturedByName(ctx.m, e.Str)
+		return nodeKindName(n)
+	case opVarLabelTarget:
+		n, ok := capturedByName(ctx.m, e.Str)
+		if !ok {
+			return ""
+		}
+		return ctx.labelTarget(e.Str, n)
+	case opVarTypeName:
+		n, _ := capturedByName(ctx.m, e.Str)
+		return ctx.compositeLitTypeName(e.Str, n)
+	case opVarPkgName:
+		return ctx.w.pkgName
+	case opVarPkgPath:
+		return ctx.w.filePkgPath(ctx.w.filename)
+	case opVarDirName:
+		return filepath.Base(filepath.Dir(filepathAbs(ctx.w.workDir, ctx.w.filename)))
+	case opVarFileName:
+		return filepath.Base(ctx.w.filename)
+	case opVarDirective:
+		c, ok := ctx.m.Node.(*ast.Comment)
+		if !ok {
+			return ""
+		}
+		name, _ := commentDirective(c)
+		return name
+	case opVarDirectiveArgs:
+		c, ok := ctx.m.Node.(*ast.Comment)
+		if !ok {
+			return ""
+		}
+		_, args := commentDirective(c)
+		return args
+	case opVarFuncName:
+		return ctx.w.funcName
+	}
+	panic(fmt.Sprintf("can't handle %s\n", filters.Sprint(&ctx.r.filterInfo, e)))
+}
+
+// checkFilterExpr reports the filter expressions that can't be evaluated,
+// like comparisons of the incompatible types or file query ops outside of the file query mode.
+func checkFilterExpr(info *filters.Info, e *filters.Expr, fileQuery, commentQuery bool) error {
+	for _, arg := range e.Args {
+		if err := checkFilterExpr(info, arg, fileQuery, commentQuery); err != nil {
+			return err
 		}
 	}
-	panic(fmt.Sprintf("can't handle %s\n", filters.Sprint(ctx.w.filterInfo, f)))
+
+	switch e.Op {
+	case filters.OpNot, filters.OpAnd, filters.OpOr:
+		for _, arg := range e.Args {
+			if typ := filterExprType(arg); typ != filterBool {
+				return fmt.Errorf("%s value can't be used as a condition", typ)
+			}
+		}
+		return nil
+
+	case filters.OpEq, filters.OpNotEq:
+		xtype := filterExprType(e.Args[0])
+		ytype := filterExprType(e.Args[1])
+		if xtype != ytype || xtype == filterBool || xtype == filterNode {
+			return fmt.Errorf("can't compare %s and %s values", xtype, ytype)
+		}
+		return nil
+
+	case filters.OpIn:
+		if typ := filterExprType(e.Args[0]); typ != filterString {
+			return fmt.Errorf("can't check whether %s value is in a set of strings", typ)
+		}
+		return nil
+
+	case filters.OpLt, filters.OpLtEq, filters.OpGt, filters.OpGtEq:
+		xtype := filterExprType(e.Args[0])
+		ytype := filterExprType(e.Args[1])
+		if xtype != filterInt || ytype != filterInt {
+			return fmt.Errorf("can't compare %s and %s values, ints expected", xtype, ytype)
+		}
+		return nil
+	}
+
+	if e.Op.IsBuiltin() {
+		return nil
+	}
+
+	name := info.OpTab.VarFuncName(e.Op)
+	if isFileQueryOp(e.Op) && !fileQuery {
+		return fmt.Errorf("%s() is only available in -file-query mode", name)
+	}
+	if fileQuery && !filters.IsRootVarname(e.Str) {
+		return fmt.Errorf("$%s: only $$ can be used in -file-query mode", e.Str)
+	}
+	if isCommentQueryOp(e.Op) && !commentQuery {
+		return fmt.Errorf("%s() is only available in -comment-query mode", name)
+	}
+	if commentQuery && !filters.IsRootVarname(e.Str) {
+		return fmt.Errorf("$%s: only $$ can be used in -comment-query mode", e.Str)
+	}
+	switch e.Op {
+	case opVarImports, opVarFollowedBy, opVarContains, opVarHasElem, opVarFuncContains, opVarStringMatches, opVarStringIs, opVarHasPrefix,
+		opVarInPackage:
+		if len(e.Args) != 1 || e.Args[0].Op != filters.OpString {
+			return fmt.Errorf("%s() expects a single string literal argument", name)
+		}
+		return nil
+	case opVarVerbCount, opVarIsVerbArg:
+		if len(e.Args) != 1 || e.Args[0].Op != filters.OpString {
+			return fmt.Errorf("%s() expects a single string literal argument", name)
+		}
+		if _, ok := parseVerbArg(e.Args[0].Str); !ok {
+			return fmt.Errorf("%s(): %q is not a format verb, expected a single character like \"w\"", name, e.Args[0].Str)
+		}
+		return nil
+	case opVarEqual:
+		if len(e.Args) != 1 || e.Args[0].Op != filters.OpVar {
+			return fmt.Errorf("%s() expects a single pattern var argument", name)
+		}
+		return nil
+	case opVarDepth:
+		if len(e.Args) > 1 || len(e.Args) == 1 && (e.Args[0].Op != filters.OpString || depthCounters[e.Args[0].Str] == nil) {
+			return fmt.Errorf(`%s() expects an optional "node", "stmt" or "block" argument`, name)
+		}
+		return nil
+	case opVarSimilar:
+		if len(e.Args) != 2 || e.Args[0].Op != filters.OpString || e.Args[1].Op != filters.OpInt {
+			return fmt.Errorf("%s() expects a string literal and an int literal arguments", name)
+		}
+		return nil
+	}
+	if len(e.Args) != 0 {
+		return fmt.Errorf("%s() expects no arguments", name)
+	}
+	return nil
 }
 
 func isPureExpr(expr ast.Expr) bool {
@@ -243,6 +1106,15 @@ func getMatchExpr(m gogrep.MatchData, name string) ast.Expr {
 	return e
 }
 
+// basicLitKind returns the n literal kind name, like "INT" or "STRING".
+// An empty string is returned if n is not a basic literal.
+func basicLitKind(n ast.Node) string {
+	if lit, ok := n.(*ast.BasicLit); ok {
+		return lit.Kind.String()
+	}
+	return ""
+}
+
 func checkBasicLit(n ast.Expr, kind token.Token) bool {
 	if lit, ok := n.(*ast.BasicLit); ok {
 		return lit.Kind == kind
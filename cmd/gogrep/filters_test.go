@@ -0,0 +1,1475 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/perf-heatmap/heatmap"
+)
+
+func TestNilPredicates(t *testing.T) {
+	tests := []struct {
+		expr       string
+		isNil      bool
+		isTypedNil bool
+	}{
+		{`nil`, true, false},
+		{`(nil)`, true, false},
+		{`x`, false, false},
+		{`"nil"`, false, false},
+
+		{`(*T)(nil)`, false, true},
+		{`(*pkg.T)(nil)`, false, true},
+		{`((*T))((nil))`, false, true},
+		{`[]byte(nil)`, false, true},
+		{`map[string]int(nil)`, false, true},
+		{`chan int(nil)`, false, true},
+		{`(func())(nil)`, false, true},
+
+		// A conversion to the interface type is a nil interface.
+		{`interface{}(nil)`, false, false},
+		// T can be a function, so it's not reported without types info.
+		{`T(nil)`, false, false},
+		{`error(nil)`, false, false},
+		{`(*T)(x)`, false, false},
+		{`(*T)(nil, nil)`, false, false},
+		{`new(T)`, false, false},
+	}
+
+	for _, test := range tests {
+		e, err := parser.ParseExpr(test.expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.expr, err)
+		}
+		if have := isNilIdent(e); have != test.isNil {
+			t.Errorf("isNilIdent(%s):\nhave: %v\nwant: %v", test.expr, have, test.isNil)
+		}
+		if have := isTypedNil(e); have != test.isTypedNil {
+			t.Errorf("isTypedNil(%s):\nhave: %v\nwant: %v", test.expr, have, test.isTypedNil)
+		}
+	}
+}
+
+func TestTypedNilInterface(t *testing.T) {
+	// The classic gotcha: err is never nil, since it holds a typed nil.
+	const src = `package p
+func f() error {
+	var err error = (*MyError)(nil)
+	if err != nil {
+		return err
+	}
+	return nil
+}`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body.List
+	spec := body[0].(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	if !isTypedNil(spec.Values[0]) {
+		t.Errorf("the err initializer is not reported as a typed nil")
+	}
+	cond := body[1].(*ast.IfStmt).Cond.(*ast.BinaryExpr)
+	if !isNilIdent(cond.Y) || isTypedNil(cond.Y) {
+		t.Errorf("the err comparison operand is not reported as an untyped nil")
+	}
+	ret := body[2].(*ast.ReturnStmt)
+	if !isNilIdent(ret.Results[0]) {
+		t.Errorf("the return operand is not reported as an untyped nil")
+	}
+}
+
+func TestIsConversion(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`string(b)`, true},
+		{`(string)(b)`, true},
+		{`[]byte(s)`, true},
+		{`(*T)(p)`, true},
+		{`interface{}(x)`, true},
+
+		// T can be a function, there is no types info to tell.
+		{`T(x)`, false},
+		{`f(x)`, false},
+		{`pkg.T(x)`, false},
+		{`len(x)`, false},
+		{`func() {}()`, false},
+		{`string`, false},
+	}
+
+	for _, test := range tests {
+		e, err := parser.ParseExpr(test.expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.expr, err)
+		}
+		if have := isConversion(e); have != test.want {
+			t.Errorf("isConversion(%s):\nhave: %v\nwant: %v", test.expr, have, test.want)
+		}
+	}
+}
+
+func TestIsSpreadCall(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`append(s, xs...)`, true},
+		{`fmt.Println(args...)`, true},
+		{`f(1, 2, xs...)`, true},
+		{`f(g(xs...))`, false},
+		{`append(s, xs)`, false},
+		{`f()`, false},
+		{`xs`, false},
+	}
+
+	for _, test := range tests {
+		e, err := parser.ParseExpr(test.expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.expr, err)
+		}
+		if have := isSpreadCall(e); have != test.want {
+			t.Errorf("isSpreadCall(%s):\nhave: %v\nwant: %v", test.expr, have, test.want)
+		}
+	}
+}
+
+func TestIsTypeAlias(t *testing.T) {
+	tests := []struct {
+		decl string
+		want bool
+	}{
+		{`type A = B`, true},
+		{`type A = pkg.B`, true},
+		{`type (A = B)`, true},
+		{`type Set[K comparable] = map[K]bool`, true},
+
+		{`type A B`, false},
+		{`type List[E any] []E`, false},
+		// The multi-spec declarations are neither aliases nor definitions.
+		{`type (A = B; C = D)`, false},
+		{`var A = B`, false},
+	}
+
+	for _, test := range tests {
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p; "+test.decl, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.decl, err)
+		}
+		if have := isTypeAlias(f.Decls[0]); have != test.want {
+			t.Errorf("isTypeAlias(%s):\nhave: %v\nwant: %v", test.decl, have, test.want)
+		}
+	}
+
+	// The specs are checked on their own.
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p; type (A = B; C D)", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := f.Decls[0].(*ast.GenDecl).Specs
+	if !isTypeAlias(specs[0]) || isTypeAlias(specs[1]) {
+		t.Errorf("isTypeAlias reports the wrong grouped specs")
+	}
+}
+
+func TestIsRedundantConversion(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`[]byte([]byte(s))`, true},
+		{`([]byte)([] byte(s))`, true},
+		{`string((string(s)))`, true},
+		{`map[string]int(map[string]int(m))`, true},
+
+		{`string([]byte(s))`, false},
+		{`[]byte(s)`, false},
+		{`[]byte([]byte(s), x)`, false},
+		// T and f can be functions, there is no types info to tell.
+		{`T(T(x))`, false},
+		{`f(f(x))`, false},
+		{`G[int](G[int](x))`, false},
+	}
+
+	for _, test := range tests {
+		e, err := parser.ParseExpr(test.expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.expr, err)
+		}
+		if have := isRedundantConversion(e); have != test.want {
+			t.Errorf("isRedundantConversion(%s):\nhave: %v\nwant: %v", test.expr, have, test.want)
+		}
+	}
+}
+
+func TestHasDefaultClause(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want bool
+	}{
+		{`switch x { case 1: f(); default: g() }`, true},
+		{`switch { default: }`, true},
+		{`switch y := x.(type) { case int: default: }`, true},
+		{`select { case <-ch: default: }`, true},
+
+		{`switch x { case 1: f(); case 2, 3: g() }`, false},
+		{`switch {}`, false},
+		{`switch x.(type) { case int: }`, false},
+		{`select { case v := <-ch: f(v) }`, false},
+		{`if x { f() } else { g() }`, false},
+	}
+
+	for _, test := range tests {
+		src := "package p; func _() { " + test.stmt + " }"
+		f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.stmt, err)
+		}
+		stmt := f.Decls[0].(*ast.FuncDecl).Body.List[0]
+		if have := hasDefaultClause(stmt); have != test.want {
+			t.Errorf("hasDefaultClause(%s):\nhave: %v\nwant: %v", test.stmt, have, test.want)
+		}
+	}
+}
+
+func TestNodeCount(t *testing.T) {
+	const src = `package p
+func f() {
+	if a {}
+	if b { ; }
+	if c { f(); g() }
+	for {}
+}`
+	tests := []struct {
+		pat  string
+		want []int
+	}{
+		{`if $_ { $*body }`, []int{0, 1, 2}},
+		{`for { $*body }`, []int{0}},
+		{`func $_() $body`, nil},
+		{`func f() { $*body }`, []int{4}},
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: test.pat})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The captured slices are reused by the matcher, so they're counted inside the callback.
+		state := gogrep.NewMatcherState()
+		var have []int
+		gogrep.Walk(f, func(n ast.Node) bool {
+			pat.MatchNode(&state, n, func(m gogrep.MatchData) {
+				if body, ok := m.CapturedByName("body"); ok {
+					have = append(have, nodeCount(body))
+				}
+			})
+			return true
+		})
+		if fmt.Sprint(have) != fmt.Sprint(test.want) {
+			t.Errorf("%s: counts:\nhave: %v\nwant: %v", test.pat, have, test.want)
+		}
+	}
+
+	block := &ast.BlockStmt{List: []ast.Stmt{&ast.EmptyStmt{}}}
+	if have := nodeCount(block); have != 1 {
+		t.Errorf("block count:\nhave: %d\nwant: 1", have)
+	}
+}
+
+func TestStringLitValue(t *testing.T) {
+	tests := []struct {
+		expr  string
+		value string
+		ok    bool
+	}{
+		{`"SELECT * FROM t"`, `SELECT * FROM t`, true},
+		{`("SELECT\x20*")`, `SELECT *`, true},
+		{"`raw\\n`", `raw\n`, true},
+		{`""`, ``, true},
+
+		{`'x'`, ``, false},
+		{`10`, ``, false},
+		{`q`, ``, false},
+		{`"a" + "b"`, ``, false},
+	}
+
+	for _, test := range tests {
+		e, err := parser.ParseExpr(test.expr)
+		if err != nil {
+			t.Fatalf("parse %s: %v", test.expr, err)
+		}
+		value, ok := stringLitValue(e)
+		if value != test.value || ok != test.ok {
+			t.Errorf("stringLitValue(%s):\nhave: %q, %v\nwant: %q, %v", test.expr, value, ok, test.value, test.ok)
+		}
+	}
+
+	utf8Validator := stringValidators["utf8"]
+	if !utf8Validator.MatchString("привет") || utf8Validator.MatchString("\xff") {
+		t.Errorf("utf8 validator: invalid results")
+	}
+}
+
+func TestCommentDirective(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		args string
+		body string
+	}{
+		{`//go:generate stringer -type=Kind`, `go:generate`, `stringer -type=Kind`, `go:generate stringer -type=Kind`},
+		{`//go:embed static/*  templates/*.tmpl `, `go:embed`, `static/*  templates/*.tmpl`, `go:embed static/*  templates/*.tmpl `},
+		{`//go:noinline`, `go:noinline`, ``, `go:noinline`},
+		{`//nolint:errcheck // reason`, `nolint:errcheck`, `// reason`, `nolint:errcheck // reason`},
+		{`//line a.go:10`, `line`, `a.go:10`, `line a.go:10`},
+		{`//export Foo`, `export`, `Foo`, `export Foo`},
+
+		{`// go:embed x`, ``, ``, ` go:embed x`},
+		{`//Go:embed x`, ``, ``, `Go:embed x`},
+		{`//go: x`, ``, ``, `go: x`},
+		{`//:embed`, ``, ``, `:embed`},
+		{`// TODO: fix`, ``, ``, ` TODO: fix`},
+		{`/*go:embed x*/`, ``, ``, `go:embed x`},
+	}
+
+	for _, test := range tests {
+		c := &ast.Comment{Text: test.text}
+		name, args := commentDirective(c)
+		if name != test.name || args != test.args {
+			t.Errorf("commentDirective(%s):\nhave: %q %q\nwant: %q %q", test.text, name, args, test.name, test.args)
+		}
+		if body := commentBody(c); body != test.body {
+			t.Errorf("commentBody(%s):\nhave: %q\nwant: %q", test.text, body, test.body)
+		}
+	}
+}
+
+func TestBindCommentFunc(t *testing.T) {
+	src := `package p
+
+// TODO: package level
+var x = 1 // TODO: trailing
+
+// TODO: doc comment
+func f(a int /* TODO: param */) {
+	// TODO: f
+	_ = func() {
+		// TODO: f closure
+	}
+}
+
+var g = func() {
+	// TODO: closure
+}
+
+func (T) m() { /* TODO: m */ }
+`
+	want := map[string]string{
+		`// TODO: package level`: ``,
+		`// TODO: trailing`:      ``,
+		`// TODO: doc comment`:   ``,
+		`/* TODO: param */`:      ``,
+		`// TODO: f`:             `f`,
+		`// TODO: f closure`:     `f`,
+		`// TODO: closure`:       `<closure>`,
+		`/* TODO: m */`:          `m`,
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			var w worker
+			for _, decl := range f.Decls {
+				if decl.Pos() <= c.Pos() && c.Pos() < decl.End() {
+					w.bindCommentFunc(decl, c)
+				}
+			}
+			have := w.funcName
+			if w.inFuncBody && have == "" {
+				have = "<closure>"
+			}
+			if !w.inFuncBody && have != "" {
+				t.Errorf("%s: func name %q is set outside of the func body", c.Text, have)
+			}
+			if have != want[c.Text] {
+				t.Errorf("%s:\nhave: %q\nwant: %q", c.Text, have, want[c.Text])
+			}
+		}
+	}
+}
+
+func TestCompositeLitTypeName(t *testing.T) {
+	src := `package p
+func f() {
+	_ = T{}
+	_ = &pkg.Thing{Name: "a"}
+	_ = (&pkg.Thing{})
+	_ = pkg.List[int]{}
+	_ = []pkg.Thing{{Name: "b"}, {}}
+	_ = []*pkg.Thing{{}}
+	_ = map[T]pkg.Thing{{}: {}}
+	_ = [][]T{{{}}}
+	_ = map[string][]pkg.Thing{"c": {{}}}
+	_ = Things{{}}
+	_ = []int{1}
+	_ = struct{}{}
+}`
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`$x.TypeName() == "T"`, []string{`T{}`, `{}`, `{}`}},
+		{`$x.TypeName() == "pkg.Thing"`, []string{
+			`&pkg.Thing{Name: "a"}`,
+			`pkg.Thing{Name: "a"}`,
+			`(&pkg.Thing{})`,
+			`&pkg.Thing{}`,
+			`pkg.Thing{}`,
+			`{Name: "b"}`,
+			`{}`,
+			`{}`,
+			`{}`,
+			`{}`,
+		}},
+		{`$x.TypeName() == "pkg.List"`, []string{`pkg.List[int]{}`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, `$x`, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.filter, have, test.want)
+		}
+	}
+}
+
+func TestFilterShortCircuit(t *testing.T) {
+	src := `package p
+func f() {
+	x := 1
+	g(x, "s")
+}`
+
+	// IsHot() panics without a heatmap, so the filters below
+	// only succeed if it's never evaluated.
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`$x.IsFloatLit() && $x.IsHot()`, nil},
+		{`$x.Text() == "y" && $x.IsHot()`, nil},
+		{`($x.IsIntLit() || $x.IsStringLit()) && !($x.IsConst() || $x.IsHot())`, nil},
+		{`$x.IsStringLit() || ($x.IsFloatLit() && $x.IsHot())`, []string{`"s"`}},
+		{`($x.IsStringLit() || $x.IsIntLit()) && ($x.IsConst() || $x.IsHot())`, []string{`1`, `"s"`}},
+		{`!(!$x.IsFloatLit() || $x.IsHot()) || $x.Text() == "x" && ($x.IsPure() || $x.IsHot())`, []string{`x`, `x`}},
+	}
+	// The same filters with the unguarded IsHot(), to make sure that it panics.
+	panicTests := []string{
+		`$x.IsHot()`,
+		`$x.IsStringLit() && $x.IsHot()`,
+		`$x.IsFloatLit() || $x.IsHot()`,
+	}
+
+	grep := func(filter string) (w *worker, panicked bool) {
+		r := testCompileRule(t, `$x`, "")
+		expr, info, err := filters.Parse(newFilterOperationTable(), filter)
+		if err != nil {
+			t.Fatalf("parse %s: %v", filter, err)
+		}
+		r.filterExpr = expr
+		r.filterInfo = info
+		defer func() {
+			panicked = recover() != nil
+		}()
+		return testGrepRule(t, r, src, false), false
+	}
+
+	for _, filter := range panicTests {
+		if _, panicked := grep(filter); !panicked {
+			t.Fatalf("%s: expected IsHot() to panic", filter)
+		}
+	}
+	for _, test := range tests {
+		w, panicked := grep(test.filter)
+		if panicked {
+			t.Errorf("%s: IsHot() is evaluated", test.filter)
+			continue
+		}
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.filter, have, test.want)
+		}
+	}
+}
+
+func TestFilterHeatmapBound(t *testing.T) {
+	tests := []struct {
+		filter string
+		bound  bool
+	}{
+		{`$x.IsHot()`, true},
+		{`$x.IsPure() && $x.IsHot()`, true},
+		{`$x.IsPure() && ($y.IsConst() && $x.IsHot())`, true},
+		{`$x.IsHot() || $y.IsHot()`, false},
+		{`$x.IsPure() || $x.IsHot()`, false},
+		{`!$x.IsHot()`, false},
+		{`$x.IsPure() && !($y.IsConst() && $x.IsHot())`, false},
+		{`$x.IsPure()`, false},
+	}
+
+	p := &program{heatmap: &heatmap.Index{}}
+	for _, test := range tests {
+		r := &rule{filter: test.filter}
+		if err := p.compileFilter(newFilterOperationTable(), r); err != nil {
+			t.Fatalf("compile %s: %v", test.filter, err)
+		}
+		if r.heatmapBound != test.bound {
+			t.Errorf("%s: have heatmapBound=%v, want %v", test.filter, r.heatmapBound, test.bound)
+		}
+	}
+
+	p = &program{}
+	for _, filter := range []string{`$x.IsHot()`, `!$x.IsHot()`, `$x.IsPure() || $x.IsHot()`} {
+		err := p.compileFilter(newFilterOperationTable(), &rule{filter: filter})
+		if err == nil || err.Error() != "specified filters require a --heatmap" {
+			t.Errorf("%s: have %v error, want a missing heatmap error", filter, err)
+		}
+	}
+}
+
+func TestLoopPredicates(t *testing.T) {
+	src := `package p
+func leak(items []int) {
+	for _, x := range items {
+		go process(x)
+	}
+}
+func bounded(items []int) {
+	var wg sync.WaitGroup
+	for _, x := range items {
+		wg.Add(1)
+		go func(x int) {
+			defer wg.Done()
+			process(x)
+		}(x)
+	}
+	wg.Wait()
+}
+func closures() {
+	for {
+		f := func() { go process(1) }
+		f()
+	}
+	go func() {
+		for i := 0; i < 10; i++ {
+			go process(i)
+		}
+	}()
+}
+func notInBody() {
+	for i := 0; i < f(); i++ {}
+	go process(2)
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`go $f($*_)`, `$$.InLoop()`, []string{
+			`go process(x)`,
+			"go func(x int) {\n\t\t\tdefer wg.Done()\n\t\t\tprocess(x)\n\t\t}(x)",
+			`go process(i)`,
+		}},
+		{`go $f($*_)`, `!$$.InLoop()`, []string{
+			`go process(1)`,
+			"go func() {\n\t\tfor i := 0; i < 10; i++ {\n\t\t\tgo process(i)\n\t\t}\n\t}()",
+			`go process(2)`,
+		}},
+		{`$f()`, `$$.InLoop()`, []string{`f()`}},
+		{`$x.Done()`, `$$.InLoop()`, nil},
+
+		{`go $f($*_)`, `$$.InLoop() && !$$.FuncContains("$_.Wait()")`, []string{
+			`go process(x)`,
+			`go process(i)`,
+		}},
+		{`$wg.Add($_)`, `$$.FuncContains("$wg.Wait()")`, []string{`wg.Add(1)`}},
+		{`$wg.Add($_)`, `$$.FuncContains("$x.Done()")`, []string{`wg.Add(1)`}},
+		{`$wg.Add($_)`, `$$.FuncContains("other.Wait()")`, nil},
+		// The innermost function is checked, process(1) is inside of a literal.
+		{`$f($_)`, `$$.FuncContains("f()")`, []string{`process(2)`}},
+
+		{`func $_($*_) { $*_ }`, `$$.Contains("go $_($*_)") && !$$.Contains("$_.Wait()")`, []string{
+			`func leak(items []int) {`,
+			`func closures() {`,
+			`func notInBody() {`,
+		}},
+		// The nested function literals are a part of the node.
+		{`for $*_; $*_; $*_ { $*_ }`, `$$.Contains("go process($x)")`, []string{
+			"for {\n\t\tf := func() { go process(1) }\n\t\tf()\n\t}",
+			"for i := 0; i < 10; i++ {\n\t\t\tgo process(i)\n\t\t}",
+		}},
+		{`$x := $_`, `$$.Contains("$x")`, []string{`f := func() { go process(1) }`, `i := 0`, `i := 0`}},
+		{`$x := $_`, `$$.Contains("go $f($*_)")`, []string{`f := func() { go process(1) }`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			text := m.text
+			if strings.HasPrefix(text, "func ") {
+				text = text[:strings.IndexByte(text, '\n')]
+			}
+			have = append(have, text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestUsesReceiver(t *testing.T) {
+	src := `package p
+func (t *T) uses() int { return t.x }
+func (t *T) nested() { println(f(g(t))) }
+func (t *T) closure() func() { return func() { t.x++ } }
+func (t *T) field() { v.t++ }
+func (t *T) key() { _ = S{t: 1} }
+func (t T) shadowed() { t := 1; _ = t }
+func (t *T) unused(x int) int { return x }
+func (_ T) blank() {}
+func (T) unnamed() {}
+func (t *T) decl()
+func fn(t *T) { _ = t }`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`func ($*_) $_($*_) $*_ { $*_ }`, `$$.UsesReceiver()`, []string{
+			`func (t *T) uses() int { return t.x }`,
+			`func (t *T) nested() { println(f(g(t))) }`,
+			`func (t *T) closure() func() { return func() { t.x++ } }`,
+			// The composite literal keys and the shadowing variables are name matches.
+			`func (t *T) key() { _ = S{t: 1} }`,
+			`func (t T) shadowed() { t := 1; _ = t }`,
+		}},
+		{`func ($*_) $_($*_) $*_ { $*_ }`, `!$$.UsesReceiver()`, []string{
+			`func (t *T) field() { v.t++ }`,
+			`func (t *T) unused(x int) int { return x }`,
+			`func (_ T) blank() {}`,
+			`func (T) unnamed() {}`,
+		}},
+		// The nodes inside of a method are checked against that method.
+		{`return $x`, `$$.UsesReceiver()`, []string{`return t.x`, `return func() { t.x++ }`}},
+		{`return $x`, `!$$.UsesReceiver()`, []string{`return x`}},
+		// Plain functions have no receiver.
+		{`func $_($*_) { $*_ }`, `$$.UsesReceiver()`, nil},
+		{`$_ = $x`, `$$.UsesReceiver()`, []string{`_ = S{t: 1}`, `_ = t`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestHasElem(t *testing.T) {
+	src := `package p
+func _() {
+	New(WithX(1), WithTimeout(2))
+	New(WithX(1), WithY(2))
+	New()
+	New(WithX(WithTimeout(3)))
+	New(opts...)
+	_ = &http.Client{Transport: t}
+	_ = &http.Client{Timeout: time.Second}
+	_ = []int{x, y, x + y}
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`New($*opts)`, `!$opts.HasElem("WithTimeout($_)")`, []string{
+			`New(WithX(1), WithY(2))`,
+			`New()`,
+			// Only the elements themselves are matched, unlike with Contains.
+			`New(WithX(WithTimeout(3)))`,
+			`New(opts...)`,
+		}},
+		{`New($*opts)`, `!$opts.Contains("WithTimeout($_)")`, []string{
+			`New(WithX(1), WithY(2))`,
+			`New()`,
+			`New(opts...)`,
+		}},
+		{`New($*opts)`, `$opts.HasElem("WithX($_)") && $opts.HasElem("WithY($_)")`, []string{
+			`New(WithX(1), WithY(2))`,
+		}},
+		{`&http.Client{$*fields}`, `!$fields.HasElem("Timeout: $_")`, []string{
+			`&http.Client{Transport: t}`,
+		}},
+		// The patterns share the captures with the main pattern.
+		{`[]int{$x, $*rest}`, `$rest.HasElem("$x + $_")`, []string{`[]int{x, y, x + y}`}},
+		{`[]int{$x, $*rest}`, `$rest.HasElem("$x")`, nil},
+		// A non-slice capture is a single element.
+		{`New($opt, $*_)`, `$opt.HasElem("WithX($_)")`, []string{
+			`New(WithX(1), WithTimeout(2))`,
+			`New(WithX(1), WithY(2))`,
+			`New(WithX(WithTimeout(3)))`,
+		}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestNodeKind(t *testing.T) {
+	src := `package p
+func f(a, b int) {
+	x := g(a).b
+	for _, v := range xs {}
+	_ = []int{1, 2}
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`$x`, `$x.Kind() == "SelectorExpr"`, []string{`SelectorExpr g(a).b`}},
+		{`$x`, `$x.Kind() == "RangeStmt"`, []string{`RangeStmt for _, v := range xs {}`}},
+		{`$x`, `$x.Kind() == "Field"`, []string{`Field a, b int`}},
+		{`$f($*args)`, `$args.Kind() == "[]Expr"`, []string{`CallExpr g(a)`}},
+		{`[]int{$*xs}`, `$xs.Kind() == "[]Expr" && $xs.Count() == 2`, []string{`CompositeLit []int{1, 2}`}},
+		{`$x := $y`, `$y.Kind() != "SelectorExpr"`, nil},
+		{`{ $*body }`, `$body.Kind() == "[]Stmt"`, []string{
+			"BlockStmt {\n\tx := g(a).b\n\tfor _, v := range xs {}\n\t_ = []int{1, 2}\n}",
+			`BlockStmt {}`,
+		}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.kind+" "+m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestIsPkgName(t *testing.T) {
+	src := `package p
+import (
+	"context"
+	yaml "gopkg.in/yaml.v3"
+	"github.com/org/lib/v2"
+	. "strings"
+)
+func f(ctx context.Context, log *Logger) {
+	ctx.Done()
+	context.WithCancel(ctx)
+	log.Printf("x")
+	yaml.Marshal(v)
+	lib.Run()
+	v.context.Err()
+	Contains(s, "x")
+}
+func g(context string) { context.Len() }
+func h() {
+	if lib := 1; lib > 0 { lib.Run() }
+	lib.Stop()
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`$x.$_($*_)`, `$x.IsPkgName()`, []string{
+			`context.WithCancel(ctx)`,
+			`yaml.Marshal(v)`,
+			`lib.Run()`,
+			`lib.Stop()`,
+		}},
+		{`$x.$_($*_)`, `!$x.IsPkgName() && $x.Kind() == "Ident"`, []string{
+			`ctx.Done()`,
+			`log.Printf("x")`,
+			// The params and the local variables shadow the package names.
+			`context.Len()`,
+			`lib.Run()`,
+		}},
+		{`$x.$m($*_)`, `$x.Text() == "ctx"`, []string{`ctx.Done()`}},
+		// The selector names are never package names.
+		{`$x.$m`, `$m.IsPkgName()`, nil},
+		{`$f($*_)`, `$f.IsPkgName()`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func init() {
+	filters.Register("IsLegacyType", func(ctx filters.FilterContext, n ast.Node) bool {
+		return strings.HasPrefix(string(ctx.NodeText(n)), "legacy.")
+	})
+	filters.Register("OnLine4", func(ctx filters.FilterContext, n ast.Node) bool {
+		return ctx.Fset().Position(n.Pos()).Line == 4
+	})
+	filters.Register("HasIntArg", func(ctx filters.FilterContext, n ast.Node) bool {
+		arg, ok := ctx.Capture("x")
+		lit, isLit := arg.(*ast.BasicLit)
+		return ok && isLit && lit.Kind == token.INT
+	})
+}
+
+func TestCustomFilters(t *testing.T) {
+	src := `package p
+func f() {
+	_ = legacy.Config{}
+	_ = modern.Config{}
+	legacy.New("x")
+	modern.New(1)
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`$T{}`, `IsLegacyType($T)`, []string{`legacy.Config{}`}},
+		{`$T{}`, `!IsLegacyType($T)`, []string{`modern.Config{}`}},
+		{`$T{}`, `IsLegacyType($T) || OnLine4($$)`, []string{`legacy.Config{}`, `modern.Config{}`}},
+		{`$f($x)`, `HasIntArg($$)`, []string{`modern.New(1)`}},
+		{`$f($x)`, `!HasIntArg($f) && $x.IsStringLit()`, []string{`legacy.New("x")`}},
+		// The unbound vars are never matched.
+		{`$f($x)`, `OnLine4($y)`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestCapturesLoopVar(t *testing.T) {
+	src := `package p
+func f() {
+	for _, v := range xs { defer func() { use(v) }() }
+	for i := 0; i < n; i++ { go func() { println(i) }() }
+	for k := range m { for _, v := range m[k] { go func() { use(k, v) }() } }
+	for _, v := range xs { v := v; defer func() { use(v) }() }
+	for _, v := range xs { go func(v int) { use(v) }(v) }
+	for _, v := range xs { defer func() { use(x.v) }() }
+	for _, v := range xs { defer use(v) }
+	for _, _ = range xs { defer func() { use(_) }() }
+	for _, v := range xs { fns = append(fns, func() { use(v) }) }
+	for _, v := range xs { func() { for _, w := range v { go func() { use(v) }() } }() }
+	if v := f(); v != nil { defer func() { use(v) }() }
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`go $f($*_)`, `$$.CapturesLoopVar()`, []string{
+			`go func() { println(i) }()`,
+			`go func() { use(k, v) }()`,
+		}},
+		{`defer $f($*_)`, `$$.CapturesLoopVar()`, []string{`defer func() { use(v) }()`}},
+		{`defer $f($*_)`, `$f.CapturesLoopVar()`, []string{`defer func() { use(v) }()`}},
+		// The closures that are not called by go or defer are reported as well.
+		// The enclosing function literal is a loop boundary: the innermost
+		// closure only sees the w loop, while the v loop is outside.
+		{`func() { $*_ }`, `$$.CapturesLoopVar()`, []string{
+			`func() { use(v) }`,
+			`func() { println(i) }`,
+			`func() { use(k, v) }`,
+			`func() { use(v) }`,
+			`func() { for _, w := range v { go func() { use(v) }() } }`,
+		}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestReturns(t *testing.T) {
+	src := `package p
+func pair() (int, error) { return 0, nil }
+func single() error { return nil }
+func none() {}
+func f(xs []int) {
+	pair()
+	single()
+	none()
+	x.pair()
+	func() (a, b int) { return 0, 0 }()
+	close(ch)
+	other()
+	_ = len(xs) + cap(xs)
+	_ = string(xs)
+}
+func g(pair func() int) {
+	pair()
+	{
+		single := func() (int, int) { return 0, 0 }
+		single()
+	}
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`$f($*_)`, `$$.Returns() == 2`, []string{
+			`pair()`,
+			`func() (a, b int) { return 0, 0 }()`,
+		}},
+		{`$f($*_)`, `$$.Returns() == 1`, []string{
+			`single()`,
+			`len(xs)`,
+			`cap(xs)`,
+			`string(xs)`,
+		}},
+		{`$f($*_)`, `$$.Returns() == 0`, []string{
+			`none()`,
+			`close(ch)`,
+		}},
+		// The unresolved calls are the method calls, the other files functions
+		// and the calls of the local variables that shadow the file functions.
+		{`$f($*_)`, `$$.Returns() < 0`, []string{
+			`x.pair()`,
+			`other()`,
+			`pair()`,
+			`single()`,
+		}},
+		{`$f($*_)`, `$$.IsExprStmt() && $$.Returns() >= 2`, []string{
+			`pair()`,
+			`func() (a, b int) { return 0, 0 }()`,
+		}},
+		{`$x + $y`, `$x.Returns() == 1`, []string{`len(xs) + cap(xs)`}},
+		{`$x + $y`, `$$.Returns() >= 0`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestDepth(t *testing.T) {
+	src := `package p
+var v = 1
+func f() {
+	for {
+		if a {
+			switch {
+			case b:
+				if c {
+				}
+			}
+		}
+	}
+	go func() {
+		if d {
+		}
+	}()
+	panic(v)
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`1`, `$$.Depth() == 3`, []string{`1`}},
+		{`for {$*_}`, `$$.Depth() == 3`, []string{"for {\n\t\tif a {\n\t\t\tswitch {\n\t\t\tcase b:\n\t\t\t\tif c {\n\t\t\t\t}\n\t\t\t}\n\t\t}\n\t}"}},
+		// The go statement is not a control flow statement.
+		{`if $c {$*_}`, `$$.Depth("stmt") == 0`, []string{"if d {\n\t\t}"}},
+		{`if $c {$*_}`, `$$.Depth("stmt") == 1`, []string{"if a {\n\t\t\tswitch {\n\t\t\tcase b:\n\t\t\t\tif c {\n\t\t\t\t}\n\t\t\t}\n\t\t}"}},
+		{`if $c {$*_}`, `$$.Depth("stmt") > 2`, []string{"if c {\n\t\t\t\t}"}},
+		{`if $c {$*_}`, `$c.Depth("block") == 4`, []string{"if c {\n\t\t\t\t}"}},
+		{`panic($_)`, `$$.Depth("stmt") == 0`, []string{`panic(v)`}},
+		{`if $c {$*_}`, `$$.Depth("block") == 2`, []string{"if a {\n\t\t\tswitch {\n\t\t\tcase b:\n\t\t\t\tif c {\n\t\t\t\t}\n\t\t\t}\n\t\t}", "if d {\n\t\t}"}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestFormatVerbs(t *testing.T) {
+	src := `package p
+func f() {
+	_ = fmt.Errorf("read %s: %w", name, err)
+	_ = fmt.Errorf("%w; %w", err1, err2)
+	_ = fmt.Errorf("%[2]w: %[1]s", name, err3)
+	_ = fmt.Errorf(format, err4)
+	_ = fmt.Errorf("%v", err5)
+	fmt.Fprintf(w, "%d: %w", 1, err6)
+	_ = fmt.Errorf("%w", errs...)
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`fmt.Errorf($fmt, $*_)`, `$fmt.VerbCount("w") > 1`, []string{`fmt.Errorf("%w; %w", err1, err2)`}},
+		{`fmt.Errorf($fmt, $*_)`, `$fmt.VerbCount("w") < 0`, []string{`fmt.Errorf(format, err4)`}},
+		{`fmt.Errorf($fmt, $*_)`, `$fmt.VerbCount("s") == 1`, []string{`fmt.Errorf("read %s: %w", name, err)`, `fmt.Errorf("%[2]w: %[1]s", name, err3)`}},
+		{`$err`, `$err.IsVerbArg("w")`, []string{`err`, `err1`, `err2`, `err3`, `err6`}},
+		{`$x`, `$x.IsVerbArg("s")`, []string{`name`, `name`}},
+		{`$x`, `$x.IsVerbArg("d")`, []string{`1`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestPanicArgs(t *testing.T) {
+	src := `package p
+func f() {
+	panic("unreachable")
+	panic("bad " + "state")
+	panic(fmt.Sprintf("bad %d", n))
+	panic(errors.New("bad"))
+	panic(fmt.Errorf("bad: %w", err))
+	panic(err)
+	panic(42)
+}`
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`$x.LitKind() == "STRING"`, []string{`panic("unreachable")`}},
+		{`$x.LitKind() != ""`, []string{`panic("unreachable")`, `panic(42)`}},
+		// panic(err) is not reported: err type is unknown without the types info.
+		{`$x.IsError()`, []string{`panic(errors.New("bad"))`, `panic(fmt.Errorf("bad: %w", err))`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, `panic($x)`, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.filter, have, test.want)
+		}
+	}
+}
+
+func TestIsStructType(t *testing.T) {
+	src := `package p
+type T struct{ x int }
+type U T
+type A = T
+type L[E any] struct{ items []E }
+type S []int
+type C1 C2
+type C2 C1
+func f() {
+	_ = new(T)
+	_ = new(U)
+	_ = new(A)
+	_ = new(L[int])
+	_ = new(struct{})
+	_ = new(int)
+	_ = new(S)
+	_ = new([]int)
+	_ = new(pkg.T)
+	_ = new(C1)
+	_ = new(*T)
+}`
+
+	w := testGrepSourceFilter(t, `new($T)`, `$T.IsStructType()`, src, false)
+	var have []string
+	for _, m := range w.matches {
+		have = append(have, m.text)
+	}
+	want := []string{`new(T)`, `new(U)`, `new(A)`, `new(L[int])`, `new(struct{})`}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("matches:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestBranchLabels(t *testing.T) {
+	src := `package p
+func f(xs [][]int, ch chan int) {
+outer:
+	for _, row := range xs {
+	inner:
+		for i := 0; i < len(row); i++ {
+			switch row[i] {
+			case 0:
+				continue outer
+			case 1:
+				continue inner
+			case 2:
+				break inner
+			}
+			if row[i] < 0 {
+				break inner
+			}
+		}
+	sel:
+		select {
+		case <-ch:
+			break sel
+		}
+		func() {
+		loop:
+			for {
+				break loop
+			}
+		}()
+	}
+retry:
+	goto retry
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`continue $l`, `$$.IsRedundantLabel()`, []string{`continue inner`}},
+		{`continue $l`, `!$l.IsRedundantLabel()`, []string{`continue outer`}},
+		{`break $l`, `$$.IsRedundantLabel()`, []string{`break inner`, `break sel`, `break loop`}},
+		{`break $l`, `$$.LabelTarget() == "ForStmt"`, []string{`break inner`, `break inner`, `break loop`}},
+		{`continue $l`, `$l.LabelTarget() == "RangeStmt"`, []string{`continue outer`}},
+		{`break $l`, `$$.LabelTarget() == "SelectStmt"`, []string{`break sel`}},
+		{`goto $l`, `$$.LabelTarget() == ""`, []string{`goto retry`}},
+		{`goto $l`, `$$.IsRedundantLabel()`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s with %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestInDeferInGoroutine(t *testing.T) {
+	src := `package p
+func f() {
+	defer recover()
+	defer func() {
+		recover()
+		func() {
+			cleanup(1)
+		}()
+		(func() { cleanup(2) })()
+		retry := func() { cleanup(3) }
+		register(func() { cleanup(4) })
+		_ = retry
+	}()
+	go func() {
+		func() { work(1) }()
+		later := func() { work(2) }
+		_ = later
+	}()
+	go work(3)
+	cleanup(5)
+	work(4)
+	recover()
+	defer wrap(recover())
+	defer wrap(func() int { return cleanup(6) }())
+	defer (func() { cleanup(7) })()
+	go wrap(work(5))
+	go func() { wrap(work(6)) }()
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`cleanup($_)`, `$$.InDefer()`, []string{`cleanup(1)`, `cleanup(2)`, `cleanup(7)`}},
+		{`cleanup($_)`, `!$$.InDefer()`, []string{`cleanup(3)`, `cleanup(4)`, `cleanup(5)`, `cleanup(6)`}},
+		// The deferred call arguments are evaluated right away.
+		{`recover()`, `!$$.InDefer()`, []string{`recover()`, `recover()`}},
+		{`recover()`, `$$.InDefer()`, []string{`recover()`, `recover()`}},
+		{`wrap($_)`, `$$.InDefer()`, []string{`wrap(recover())`, `wrap(func() int { return cleanup(6) }())`}},
+		{`work($_)`, `$$.InGoroutine()`, []string{`work(1)`, `work(3)`, `work(6)`}},
+		{`work($_)`, `!$$.InGoroutine()`, []string{`work(2)`, `work(4)`, `work(5)`}},
+		{`wrap($_)`, `$$.InGoroutine()`, []string{`wrap(work(5))`, `wrap(work(6))`}},
+		{`cleanup($_)`, `$$.InGoroutine()`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s with %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestShadows(t *testing.T) {
+	src := `package p
+var global = 0
+func f(param int) (result int) {
+	err := do(1)
+	err = do(2)
+	if err := do(3); err != nil {
+		return 0
+	}
+	a, err := do(4)
+	{
+		err := do(5)
+		a := do(6)
+		_, _ = err, a
+	}
+	global := do(7)
+	param := do(8)
+	b, param := do(9)
+	result := do(10)
+	for _, err := range do(11) {
+		_ = err
+	}
+	func(x int) {
+		x := do(12)
+		err := do(13)
+		_, _ = x, err
+	}(1)
+	switch err := do(14); err {
+	case nil:
+		err := do(15)
+		_ = err
+	}
+	c := do(16)
+	for i := range do(17) {
+		_ = i
+	}
+	_, _, _, _ = a, b, c, global
+	return 0
+}`
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{
+			`$_ := $_`,
+			[]string{
+				`err := do(3)`,
+				`err := do(5)`,
+				`a := do(6)`,
+				`global := do(7)`,
+				`err := do(13)`,
+				`err := do(14)`,
+				`err := do(15)`,
+			},
+		},
+		{
+			`$_, $_ := $_`,
+			nil,
+		},
+		{
+			`for $_, $_ := range $_ { $*_ }`,
+			[]string{"for _, err := range do(11) {\n\t\t_ = err\n\t}"},
+		},
+		{
+			`for $_ := range $_ { $*_ }`,
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, `$$.Shadows()`, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.pattern, have, test.want)
+		}
+	}
+}
+
+func TestLitKind(t *testing.T) {
+	src := `package p
+func f() {
+	g(1)
+	g(0x1F)
+	g(1.5)
+	g(2i)
+	g('a')
+	g("s")
+	g(` + "`raw`" + `)
+	g(x)
+	g(-1)
+}`
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`$x.LitKind() == "INT"`, []string{`g(1)`, `g(0x1F)`}},
+		{`$x.LitKind() == "FLOAT"`, []string{`g(1.5)`}},
+		{`$x.LitKind() == "IMAG"`, []string{`g(2i)`}},
+		{`$x.LitKind() == "CHAR"`, []string{`g('a')`}},
+		{`$x.LitKind() == "STRING"`, []string{`g("s")`, "g(`raw`)"}},
+		// A negative number is a unary expression, not a literal.
+		{`$x.LitKind() == ""`, []string{`g(x)`, `g(-1)`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, `g($x)`, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.filter, have, test.want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	src := `package p
+func f() {
+	x = x
+	a.b = a.b
+	xs[i] = xs[i+1]
+	x, y = y, x
+	total = total+1
+	if a && a {}
+	if a+b > 0 && a + b > 0 {}
+	if a && b {}
+	if f(x) || f(y) {}
+	if ok || (ok) {}
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		// Self-assignments, the independent captures are compared after the match.
+		{`$x = $y`, `$x.Equal($y)`, []string{`x = x`, `a.b = a.b`}},
+		{`$x = $y`, `!$x.Equal($y)`, []string{`xs[i] = xs[i+1]`, `total = total+1`}},
+
+		// Duplicate conditions: the positions and the formatting are ignored,
+		// while the text comparison is sensitive to them.
+		{`$x && $y`, `$x.Equal($y)`, []string{`a && a`, `a+b > 0 && a + b > 0`}},
+		{`$x && $y`, `$x.Text() == $y.Text()`, []string{`a && a`}},
+		{`$x || $y`, `$x.Equal($y)`, nil},
+		{`$x || $y`, `!$x.Equal($y)`, []string{`f(x) || f(y)`, `ok || (ok)`}},
+
+		{`$x, $y = $y, $x`, `!$x.Equal($y)`, []string{`x, y = y, x`}},
+
+		// Unbound vars are never equal.
+		{`$x = $y`, `$x.Equal($z)`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
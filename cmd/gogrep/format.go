@@ -40,7 +40,7 @@ func inspectFormatDeps(format string) (formatDeps, error) {
 			}
 
 			switch n.Ident[0] {
-			case "Filename", "Line", "Match", "MatchLine":
+			case "Filename", "Line", "Match", "MatchLine", "RuleID", "Severity", "Message", "RuleInfo", "Context", "Kind", "Consts", "Patterns":
 				// No need to track these.
 			default:
 				deps.capture = true
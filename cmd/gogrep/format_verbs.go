@@ -0,0 +1,166 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+	"unicode/utf8"
+)
+
+// formatVerb is a printf-style format string verb, like %w in "read: %w".
+type formatVerb struct {
+	verb rune
+
+	// arg is the index of the formatted operand, counted from the first argument after the format.
+	// The explicit indexes, like %[2]d, and the star width and precision operands are taken into account.
+	arg int
+}
+
+// parseFormatVerbs returns the format verbs, the %% escapes are skipped.
+// It follows the fmt package rules, but doesn't report the format errors:
+// the bad explicit indexes are ignored and the unfinished verbs are dropped.
+func parseFormatVerbs(format string) []formatVerb {
+	var verbs []formatVerb
+	arg := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		// Flags.
+		for i < len(format) && isFormatFlag(format[i]) {
+			i++
+		}
+		// Width and precision, either of them can be a star operand.
+		for _, prefix := range []string{"", "."} {
+			if prefix != "" {
+				if i >= len(format) || format[i] != '.' {
+					break
+				}
+				i++
+			}
+			i, arg = parseFormatArgIndex(format, i, arg)
+			if i < len(format) && format[i] == '*' {
+				i++
+				arg++
+				continue
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				i++
+			}
+		}
+		i, arg = parseFormatArgIndex(format, i, arg)
+		if i >= len(format) {
+			break
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size - 1
+		if verb == '%' {
+			continue
+		}
+		verbs = append(verbs, formatVerb{verb: verb, arg: arg})
+		arg++
+	}
+	return verbs
+}
+
+func isFormatFlag(ch byte) bool {
+	switch ch {
+	case '+', '-', '#', ' ', '0':
+		return true
+	default:
+		return false
+	}
+}
+
+// parseFormatArgIndex parses an optional [n] explicit argument index at format[i].
+// Returns the index after it and the argument it selects.
+func parseFormatArgIndex(format string, i, arg int) (int, int) {
+	if i >= len(format) || format[i] != '[' {
+		return i, arg
+	}
+	for j := i + 1; j < len(format); j++ {
+		if format[j] != ']' {
+			continue
+		}
+		n, err := strconv.Atoi(format[i+1 : j])
+		if err != nil || n < 1 {
+			return j + 1, arg
+		}
+		return j + 1, n - 1
+	}
+	return i, arg
+}
+
+// formatString returns the e string literal value, ok is false if e is not a string literal.
+func formatString(e ast.Expr) (string, bool) {
+	lit, ok := unparenExpr(e).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// verbCount returns the number of the verb occurrences in the n format string literal.
+// Returns -1 if n is not a string literal.
+func verbCount(n ast.Node, verb rune) int {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return -1
+	}
+	format, ok := formatString(e)
+	if !ok {
+		return -1
+	}
+	count := 0
+	for _, v := range parseFormatVerbs(format) {
+		if v.verb == verb {
+			count++
+		}
+	}
+	return count
+}
+
+// isVerbArg reports whether n (bound to varname) is a call argument that is
+// formatted with the verb, like err in fmt.Errorf("read %s: %w", filename, err) for 'w'.
+// The call format is its first string literal argument that precedes n.
+func (ctx *filterContext) isVerbArg(varname string, n ast.Node, verb rune) bool {
+	var call *ast.CallExpr
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		call, _ = parent.(*ast.CallExpr)
+		return false
+	})
+	if call == nil || call.Ellipsis.IsValid() {
+		return false
+	}
+	formatIndex := -1
+	for i, arg := range call.Args {
+		if arg == n {
+			if formatIndex == -1 {
+				return false
+			}
+			format, _ := formatString(call.Args[formatIndex])
+			for _, v := range parseFormatVerbs(format) {
+				if v.verb == verb && v.arg == i-formatIndex-1 {
+					return true
+				}
+			}
+			return false
+		}
+		if _, ok := formatString(arg); ok && formatIndex == -1 {
+			formatIndex = i
+		}
+	}
+	return false
+}
+
+// parseVerbArg returns the filter verb argument, like "w" for %w.
+// ok is false if s is not a single verb character.
+func parseVerbArg(s string) (verb rune, ok bool) {
+	verb, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || verb == utf8.RuneError || verb == '%' {
+		return 0, false
+	}
+	return verb, true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseFormatVerbs(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", ""},
+		{"hello", ""},
+		{"100%%", ""},
+		{"%d", "d0"},
+		{"%s: %w", "s0 w1"},
+		{"%w; %w", "w0 w1"},
+		{"%+v %#x % d %-5s %05d", "v0 x1 d2 s3 d4"},
+		{"%6.2f %.3s %5d", "f0 s1 d2"},
+		{"%*d", "d1"},
+		{"%-*.*f %w", "f2 w3"},
+		{"%[2]w %[1]s", "w1 s0"},
+		{"%[2]d %d", "d1 d2"},
+		{"%[3]*.[2]*[1]f", "f0"},
+		{"%[x]d %[0]d", "d0 d1"},
+		{"%ü%d", "ü0 d1"},
+		{"%", ""},
+		{"%5", ""},
+	}
+
+	for _, test := range tests {
+		var have []string
+		for _, v := range parseFormatVerbs(test.format) {
+			have = append(have, fmt.Sprintf("%c%d", v.verb, v.arg))
+		}
+		if strings.Join(have, " ") != test.want {
+			t.Errorf("%q:\nhave: %q\nwant: %q", test.format, strings.Join(have, " "), test.want)
+		}
+	}
+}
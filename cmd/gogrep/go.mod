@@ -3,8 +3,12 @@ module github.com/quasilyte/gogrep/cmd/gogrep
 go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/google/pprof v0.0.0-20211214055906-6f57359322fd
 	github.com/quasilyte/gogrep v0.0.0-20221002170714-e78263da2dd3
 	github.com/quasilyte/perf-heatmap v0.0.0-20211220153856-7361377975b8
-	golang.org/x/exp/typeparams v0.0.0-20221002003631-540bb7301a08 // indirect
+	golang.org/x/exp/typeparams v0.0.0-20221002003631-540bb7301a08
+	golang.org/x/text v0.3.7
 )
+
+replace github.com/quasilyte/gogrep => ../../
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// importSpecInfo is an -import-aliases mode match data.
+type importSpecInfo struct {
+	// name is an explicit import name, like `.` or `pkgerrors`.
+	// It's empty for the imports without an alias.
+	name string
+
+	path string
+}
+
+// importAliasGroup is a package path that is imported under several different names.
+type importAliasGroup struct {
+	path    string
+	names   []string
+	matches []match
+}
+
+type jsonImportAliasGroup struct {
+	Path    string      `json:"path"`
+	Aliases []string    `json:"aliases"`
+	Matches []jsonMatch `json:"matches"`
+}
+
+// collectImportSpecs reports every non-blank file import spec as a match.
+// Blank imports are only used for their side effects, their names
+// can't be inconsistent with other imports.
+func (w *worker) collectImportSpecs(root *ast.File) {
+	for _, i := range w.activeRules {
+		r := w.rules[i]
+		for _, imp := range root.Imports {
+			name := ""
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			if name == "_" {
+				continue
+			}
+			path, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				continue
+			}
+			numMatches := len(w.matches)
+			w.addMatch(r, imp, nil)
+			if len(w.matches) != numMatches {
+				w.matches[numMatches].importSpec = &importSpecInfo{name: name, path: path}
+			}
+		}
+	}
+}
+
+// collectImportAliasGroups returns the package paths that are imported under more than one name.
+// The groups are sorted by their first match location.
+func (p *program) collectImportAliasGroups() []importAliasGroup {
+	groupByPath := make(map[string]int)
+	var groups []importAliasGroup
+	for _, m := range p.sortedMatches() {
+		i, ok := groupByPath[m.importSpec.path]
+		if !ok {
+			i = len(groups)
+			groupByPath[m.importSpec.path] = i
+			groups = append(groups, importAliasGroup{path: m.importSpec.path})
+		}
+		groups[i].matches = append(groups[i].matches, m)
+	}
+
+	result := groups[:0]
+	for _, g := range groups {
+		nameSet := make(map[string]struct{})
+		for _, m := range g.matches {
+			nameSet[m.importSpec.name] = struct{}{}
+		}
+		if len(nameSet) < 2 {
+			continue
+		}
+		for name := range nameSet {
+			g.names = append(g.names, name)
+		}
+		sort.Strings(g.names)
+		result = append(result, g)
+	}
+	return result
+}
+
+func (p *program) printImportAliases() error {
+	groups := p.collectImportAliasGroups()
+
+	// Only the inconsistent imports are reported, so the exit status should depend on them.
+	p.numMatches = 0
+	for _, g := range groups {
+		p.numMatches += uint64(len(g.matches))
+	}
+
+	var enc *json.Encoder
+	if p.args.format == jsonFormat {
+		enc = json.NewEncoder(os.Stdout)
+	}
+
+	printed := uint64(0)
+	for i, g := range groups {
+		if printed >= p.args.limit {
+			p.printSummary("results limited to %d matches", p.args.limit)
+			return nil
+		}
+		if enc != nil {
+			jsonGroup := jsonImportAliasGroup{Path: g.path, Aliases: g.names}
+			for _, m := range g.matches {
+				jsonGroup.Matches = append(jsonGroup.Matches, p.newJSONMatch(m))
+			}
+			if err := enc.Encode(jsonGroup); err != nil {
+				return err
+			}
+			printed += uint64(len(g.matches))
+			continue
+		}
+
+		names := make([]string, len(g.names))
+		for i, name := range g.names {
+			names[i] = name
+			if name == "" {
+				names[i] = "(no alias)"
+			}
+		}
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%q is imported under %d names: %s\n", g.path, len(names), strings.Join(names, ", "))
+		for _, m := range g.matches {
+			if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+				return err
+			}
+			printed++
+		}
+	}
+	p.printSummary("found %d inconsistently imported packages", len(groups))
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"log"
+	"os"
+)
+
+// jsonFormat is a special -format value that makes gogrep print
+// every match as a JSON object (one object per line).
+// Errors are reported as JSON objects to the stderr as well.
+const jsonFormat = "json"
+
+type jsonMatch struct {
+	Filename     string            `json:"filename"`
+	Line         int               `json:"line"`
+	Column       int               `json:"column"`
+	EndLine      int               `json:"end_line"`
+	EndColumn    int               `json:"end_column"`
+	Match        string            `json:"match"`
+	Kind         string            `json:"kind"`
+	Capture      map[string]string `json:"capture,omitempty"`
+	CaptureKinds map[string]string `json:"capture_kinds,omitempty"`
+	Consts       []jsonConst       `json:"consts,omitempty"`
+	Patterns     []int             `json:"patterns,omitempty"`
+	RuleIDs      []string          `json:"rule_ids,omitempty"`
+	Reprinted    []string          `json:"reprinted,omitempty"`
+	RuleID       string            `json:"rule_id,omitempty"`
+	Severity     string            `json:"severity,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Context      string            `json:"context,omitempty"`
+	ID           int               `json:"id,omitempty"`
+}
+
+// jsonError is a machine-readable error description.
+type jsonError struct {
+	// Kind describes the error origin: "flags", "rules", "filter",
+	// "pattern", "format", "read", "parse" and so on.
+	// The non-fatal problems, like malformed build constraints, have a "warning" kind.
+	// The final results summary line has a "summary" kind.
+	Kind string `json:"kind"`
+
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// locatedError is an error that is bound to a file position.
+type locatedError struct {
+	filename string
+	line     int
+	err      error
+}
+
+func (e *locatedError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("%s: %v", e.filename, e.err)
+	}
+	return fmt.Sprintf("%s:%d: %v", e.filename, e.line, e.err)
+}
+
+func (e *locatedError) Unwrap() error { return e.err }
+
+// stepError is a program step execution error.
+type stepError struct {
+	step string
+	err  error
+
+	// json is set if the error should be reported as a JSON object.
+	json bool
+}
+
+func (e *stepError) Error() string { return fmt.Sprintf("%s: %v", e.step, e.err) }
+
+func (e *stepError) Unwrap() error { return e.err }
+
+// fileError is a target file processing error.
+type fileError struct {
+	filename string
+	err      error
+}
+
+type readFileError struct {
+	err error
+}
+
+func (e *readFileError) Error() string { return fmt.Sprintf("read file: %v", e.err) }
+
+func (e *readFileError) Unwrap() error { return e.err }
+
+func (p *program) newJSONMatch(m match) jsonMatch {
+	filename := m.filename
+	if p.args.abs {
+		filename = filepathAbs(p.workDir, filename)
+	}
+	result := jsonMatch{
+		Filename:  filename,
+		Line:      m.line,
+		Column:    m.column,
+		EndLine:   m.endLine,
+		EndColumn: m.endColumn,
+		Match:     m.text[m.matchStartOffset : m.matchStartOffset+m.matchLength],
+		Kind:      m.kind,
+		RuleID:    m.rule.id,
+		Severity:  m.rule.severity,
+		Message:   m.rule.message,
+		Context:   m.context,
+		ID:        m.id,
+	}
+	result.Patterns = m.patterns
+	for _, n := range m.patterns {
+		if id := p.rules[n-1].id; id != "" {
+			result.RuleIDs = append(result.RuleIDs, id)
+		}
+	}
+	for _, c := range m.consts {
+		result.Consts = append(result.Consts, jsonConst{Name: c.name, Value: c.value})
+	}
+	if len(m.capture) != 0 {
+		result.Capture = make(map[string]string, len(m.capture))
+		result.CaptureKinds = make(map[string]string, len(m.capture))
+		for _, c := range m.capture {
+			result.Capture[c.data.Name] = m.captureText(c)
+			result.CaptureKinds[c.data.Name] = nodeKindName(c.data.Node)
+			if c.reprinted {
+				result.Reprinted = append(result.Reprinted, c.data.Name)
+			}
+		}
+	}
+	return result
+}
+
+func printError(err error) {
+	var stepErr *stepError
+	if errors.As(err, &stepErr) && stepErr.json {
+		printJSONError(newStepJSONError(stepErr))
+		return
+	}
+	log.Printf("error: %+v", err)
+}
+
+func (p *program) printFileError(e fileError) {
+	if p.args.format == jsonFormat {
+		printJSONError(newFileJSONError(e))
+		return
+	}
+	log.Printf("error: execute pattern: %s: %v", e.filename, e.err)
+}
+
+func (p *program) printFileWarning(e fileError) {
+	if p.args.format == jsonFormat {
+		result := newFileJSONError(e)
+		result.Kind = "warning"
+		var constraintErr *buildConstraintError
+		if errors.As(e.err, &constraintErr) {
+			result.Line = constraintErr.line
+			result.Message = "malformed build constraint: " + constraintErr.err.Error()
+		}
+		printJSONError(result)
+		return
+	}
+	log.Printf("warning: %s: %v", e.filename, e.err)
+}
+
+// printSummary prints the results summary line to the stderr.
+// In JSON mode, it's printed as a "summary" object, so the stderr
+// output consists of the JSON objects only.
+func (p *program) printSummary(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if p.args.format == jsonFormat {
+		printJSONError(jsonError{Kind: "summary", Message: msg})
+		return
+	}
+	log.Print(msg)
+}
+
+func printJSONError(e jsonError) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		// Should never happen.
+		panic(err)
+	}
+	os.Stderr.Write(append(data, '\n'))
+}
+
+func newStepJSONError(err *stepError) jsonError {
+	result := jsonError{
+		Kind:    stepErrorKind(err.step),
+		Message: err.err.Error(),
+	}
+	var located *locatedError
+	if errors.As(err.err, &located) {
+		result.File = located.filename
+		result.Line = located.line
+		result.Message = located.err.Error()
+	}
+	return result
+}
+
+func newFileJSONError(e fileError) jsonError {
+	result := jsonError{
+		Kind:    "execute",
+		File:    e.filename,
+		Message: e.err.Error(),
+	}
+	var readErr *readFileError
+	var parseErr scanner.ErrorList
+	switch {
+	case errors.As(e.err, &readErr):
+		result.Kind = "read"
+		result.Message = readErr.err.Error()
+	case errors.As(e.err, &parseErr) && len(parseErr) != 0:
+		result.Kind = "parse"
+		result.Line = parseErr[0].Pos.Line
+		result.Message = parseErr[0].Msg
+	}
+	return result
+}
+
+func stepErrorKind(step string) string {
+	switch step {
+	case "validate flags":
+		return "flags"
+	case "load rules":
+		return "rules"
+	case "compile filter":
+		return "filter"
+	case "compile scope patterns", "compile pattern":
+		return "pattern"
+	case "compile exclude pattern":
+		return "exclude"
+	case "compile output format":
+		return "format"
+	case "load baseline", "write baseline":
+		return "baseline"
+	default:
+		return "internal"
+	}
+}
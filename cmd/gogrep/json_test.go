@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+)
+
+func TestJSONErrors(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "broken.go")
+	if err := os.WriteFile(filename, []byte("package p\nfunc f() {\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    jsonError
+	}{
+		{`f(`, ``, jsonError{Kind: "pattern", Message: "cannot parse expr: 1:4: expected operand, found '}'"}},
+		{`f($x)`, `$x.Foo()`, jsonError{Kind: "filter", Message: "convert method expr: unsupported Foo method"}},
+		{`f($x)`, `$x.IsConst(`, jsonError{Kind: "filter", Message: "1:17: expected ')', found 'EOF'"}},
+		{`f($x)`, ``, jsonError{Kind: "parse", File: filename, Line: 2, Message: "expected '}', found 'EOF'"}},
+	}
+
+	for _, test := range tests {
+		p := &program{args: arguments{pattern: test.pattern, filter: test.filter, format: jsonFormat}}
+		steps := []struct {
+			name string
+			fn   func() error
+		}{
+			{"load rules", p.loadRules},
+			{"compile filter", p.compileFilters},
+			{"compile pattern", p.compilePatterns},
+		}
+		var have jsonError
+		for _, step := range steps {
+			if err := step.fn(); err != nil {
+				have = newStepJSONError(&stepError{step: step.name, err: err, json: true})
+				break
+			}
+		}
+		if have.Kind == "" {
+			r := p.rules[0]
+			w := &worker{
+				rules:       []*rule{r},
+				patterns:    []*gogrep.Pattern{r.m},
+				gogrepState: gogrep.NewMatcherState(),
+			}
+			if r.filterExpr == nil {
+				r.filterExpr = &filters.Expr{Op: filters.OpNop}
+			}
+			_, err := w.grepFile(filename)
+			if err == nil {
+				t.Fatalf("%s: expected a file error", test.pattern)
+			}
+			have = newFileJSONError(fileError{filename: filename, err: err})
+		}
+		if have != test.want {
+			t.Errorf("pattern %q, filter %q:\nhave: %+v\nwant: %+v", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestJSONSummary(t *testing.T) {
+	p := &program{args: arguments{format: jsonFormat, progressMode: "update"}}
+	out := captureStderr(t, func() {
+		p.printSummary("found %d matches", 3)
+	})
+	var have jsonError
+	if err := json.Unmarshal([]byte(out), &have); err != nil {
+		t.Fatalf("summary is not a JSON object: %q: %v", out, err)
+	}
+	want := jsonError{Kind: "summary", Message: "found 3 matches"}
+	if have != want {
+		t.Errorf("summary:\nhave: %+v\nwant: %+v", have, want)
+	}
+
+	p.args.targets = "."
+	p.args.pattern = "f($_)"
+	p.args.ruleMode = "all"
+	if err := p.validateFlags(); err != nil {
+		t.Fatal(err)
+	}
+	if p.args.progressMode != "none" {
+		t.Errorf("progress mode: have %q, want none", p.args.progressMode)
+	}
+}
+
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = out
+	fn()
+	os.Stderr = stderr
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
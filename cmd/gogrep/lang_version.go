@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/typeparams"
+)
+
+// langVersion is a Go language minor version, like 18 for go1.18.
+// Zero means that any syntax is allowed.
+type langVersion int
+
+func (v langVersion) String() string { return "go1." + strconv.Itoa(int(v)) }
+
+func parseLangVersion(s string) (langVersion, error) {
+	minor, err := strconv.Atoi(strings.TrimPrefix(s, "go1."))
+	if !strings.HasPrefix(s, "go1.") || err != nil || minor <= 0 {
+		return 0, fmt.Errorf("expected a go1.N version, found %q", s)
+	}
+	return langVersion(minor), nil
+}
+
+// syntaxError is a syntax construct that requires a newer Go version than the -lang one.
+type syntaxError struct {
+	pos     token.Position
+	feature string
+	version langVersion
+	lang    langVersion
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("%s: %s requires %s or later (-lang is %s)", e.pos, e.feature, e.version, e.lang)
+}
+
+// checkLangVersion reports the first f syntax construct that is not available in lang.
+//
+// The go/parser accepts the newest syntax regardless of the file Go version,
+// so this check makes the results independent of the toolchain gogrep was built with.
+// Only the syntax is checked: features like range-over-int are recognized
+// by their syntactic form, types info would be needed to find all of them.
+func checkLangVersion(fset *token.FileSet, f *ast.File, lang langVersion) error {
+	var err *syntaxError
+	report := func(n ast.Node, feature string, version langVersion) {
+		if err == nil && version > lang {
+			err = &syntaxError{
+				pos:     fset.Position(n.Pos()),
+				feature: feature,
+				version: version,
+				lang:    lang,
+			}
+		}
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SliceExpr:
+			if n.Slice3 {
+				report(n, "3-index slice expression", 2)
+			}
+		case *ast.RangeStmt:
+			if n.Key == nil {
+				report(n, "range statement without variables", 4)
+			}
+			switch x := n.X.(type) {
+			case *ast.BasicLit:
+				if x.Kind == token.INT {
+					report(n, "range over int", 22)
+				}
+			case *ast.FuncLit:
+				report(n, "range over func", 23)
+			}
+		case *ast.TypeSpec:
+			if n.Assign.IsValid() && typeparams.ForTypeSpec(n) != nil {
+				report(n, "generic type alias", 24)
+			}
+			if n.Assign.IsValid() {
+				report(n, "type alias", 9)
+			}
+			if typeparams.ForTypeSpec(n) != nil {
+				report(n, "type parameters", 18)
+			}
+		case *ast.FuncType:
+			if typeparams.ForFuncType(n) != nil {
+				report(n, "type parameters", 18)
+			}
+		case *typeparams.IndexListExpr:
+			report(n, "generic instantiation", 18)
+		case *ast.InterfaceType:
+			for _, elem := range n.Methods.List {
+				switch elem.Type.(type) {
+				case *ast.UnaryExpr, *ast.BinaryExpr:
+					report(elem, "type set constraint", 18)
+				}
+			}
+		case *ast.BasicLit:
+			if feature := newNumberLitFeature(n); feature != "" {
+				report(n, feature, 13)
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// newNumberLitFeature returns a go1.13 number literal feature used by lit, if any.
+func newNumberLitFeature(lit *ast.BasicLit) string {
+	switch lit.Kind {
+	case token.INT, token.FLOAT, token.IMAG:
+	default:
+		return ""
+	}
+	s := strings.ToLower(lit.Value)
+	switch {
+	case strings.Contains(s, "_"):
+		return "digit separator"
+	case strings.HasPrefix(s, "0b"):
+		return "binary literal"
+	case strings.HasPrefix(s, "0o"):
+		return "0o-prefixed octal literal"
+	case strings.HasPrefix(s, "0x") && strings.Contains(s, "p"):
+		return "hexadecimal floating-point literal"
+	case strings.HasPrefix(s, "0x") && lit.Kind == token.IMAG:
+		return "hexadecimal imaginary literal"
+	}
+	return ""
+}
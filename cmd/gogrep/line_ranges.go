@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// lineRange is a -lines inclusive range of the 1-based line numbers.
+// Zero to means that the range is open-ended, like in `10-`.
+type lineRange struct {
+	from int
+	to   int
+}
+
+// posRange is a lineRange converted to the current file positions, end is exclusive.
+type posRange struct {
+	start token.Pos
+	end   token.Pos
+}
+
+// parseLineRanges parses a comma-separated list of the line ranges,
+// like `10-40,50-` or `7` for a single line.
+func parseLineRanges(s string) ([]lineRange, error) {
+	var ranges []lineRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		fromText, toText := part, part
+		if i := strings.IndexByte(part, '-'); i != -1 {
+			fromText, toText = part[:i], part[i+1:]
+			if toText == "" {
+				toText = "0"
+			}
+		}
+		from, err1 := strconv.Atoi(fromText)
+		to, err2 := strconv.Atoi(toText)
+		if err1 != nil || err2 != nil || from <= 0 || to < 0 {
+			return nil, fmt.Errorf("expected a start-end line range, like 10-40 or 10-, found %q", part)
+		}
+		if to != 0 && to < from {
+			return nil, fmt.Errorf("%q range end is less than its start", part)
+		}
+		ranges = append(ranges, lineRange{from: from, to: to})
+	}
+	return ranges, nil
+}
+
+// lineRangesPos returns the line ranges positions inside of the f file.
+// The ranges that start after the end of the file are omitted.
+func lineRangesPos(f *token.File, ranges []lineRange) []posRange {
+	fileEnd := token.Pos(f.Base() + f.Size())
+	var result []posRange
+	for _, r := range ranges {
+		if r.from > f.LineCount() {
+			continue
+		}
+		pr := posRange{start: f.LineStart(r.from), end: fileEnd}
+		if r.to != 0 && r.to < f.LineCount() {
+			pr.end = f.LineStart(r.to + 1)
+		}
+		result = append(result, pr)
+	}
+	return result
+}
+
+// inLineRanges reports whether n overlaps any of the -lines ranges.
+// It's always true if there are no -lines ranges.
+func (w *worker) inLineRanges(n ast.Node) bool {
+	if w.lineRanges == nil {
+		return true
+	}
+	for _, r := range w.linePosRanges {
+		if n.Pos() < r.end && n.End() > r.start {
+			return true
+		}
+	}
+	return false
+}
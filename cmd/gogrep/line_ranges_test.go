@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/quasilyte/gogrep"
+)
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{`10-40`, `[{10 40}]`},
+		{`10-`, `[{10 0}]`},
+		{`7`, `[{7 7}]`},
+		{`1-1`, `[{1 1}]`},
+		{`1-5, 10-20,30-`, `[{1 5} {10 20} {30 0}]`},
+
+		{``, `error: expected a start-end line range, like 10-40 or 10-, found ""`},
+		{`-10`, `error: expected a start-end line range, like 10-40 or 10-, found "-10"`},
+		{`0-10`, `error: expected a start-end line range, like 10-40 or 10-, found "0-10"`},
+		{`1-2-3`, `error: expected a start-end line range, like 10-40 or 10-, found "1-2-3"`},
+		{`1,`, `error: expected a start-end line range, like 10-40 or 10-, found ""`},
+		{`a-b`, `error: expected a start-end line range, like 10-40 or 10-, found "a-b"`},
+		{`40-10`, `error: "40-10" range end is less than its start`},
+	}
+
+	for _, test := range tests {
+		ranges, err := parseLineRanges(test.s)
+		have := fmt.Sprint(ranges)
+		if err != nil {
+			have = "error: " + err.Error()
+		}
+		if have != test.want {
+			t.Errorf("%q:\nhave: %s\nwant: %s", test.s, have, test.want)
+		}
+	}
+}
+
+func TestLineRanges(t *testing.T) {
+	src := `package p
+
+func f() {
+	println(1)
+	if cond {
+		println(2)
+	}
+	println(3)
+}
+
+func g() { println(4) }
+`
+	filename := filepath.Join(t.TempDir(), "p.go")
+	if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		lines   string
+		want    []string
+	}{
+		{`println($_)`, `4`, []string{`4: println(1)`}},
+		{`println($_)`, `4-6`, []string{`4: println(1)`, `6: println(2)`}},
+		{`println($_)`, `8-`, []string{`8: println(3)`, `11: println(4)`}},
+		{`println($_)`, `1-3,7,10-11`, []string{`11: println(4)`}},
+		{`println($_)`, `100-`, nil},
+
+		// The nodes that overlap the range are matched even if they start before it.
+		{`if $_ { $*_ }`, `6`, []string{`5: if cond {...`}},
+		{`func $_() { $*_ }`, `8`, []string{`3: func f() {...`}},
+		{`func $_() { $*_ }`, `10`, nil},
+	}
+
+	for _, test := range tests {
+		r := testCompileRule(t, test.pattern, "")
+		ranges, err := parseLineRanges(test.lines)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := &worker{
+			rules:       []*rule{r},
+			patterns:    []*gogrep.Pattern{r.m},
+			lineRanges:  ranges,
+			gogrepState: gogrep.NewMatcherState(),
+		}
+		if _, err := w.grepFile(filename); err != nil {
+			t.Fatal(err)
+		}
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, fmt.Sprintf("%d: %s", m.line, firstLine(matchText(m))))
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s with -lines %s:\nhave: %q\nwant: %q", test.pattern, test.lines, have, test.want)
+		}
+	}
+}
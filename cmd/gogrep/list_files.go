@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func (p *program) validateListFlags() error {
+	if p.args.nullSeparator && !p.args.listFiles && !p.args.dryRun {
+		return fmt.Errorf("-0 can only be used together with -l or -dry-run")
+	}
+	if !p.args.listFiles {
+		return nil
+	}
+	switch {
+	case p.args.format != defaultFormat:
+		return fmt.Errorf("can't use -format together with -l")
+	case p.args.countMode || p.args.dryRun || p.args.writeBaseline != "" || p.args.testMode:
+		return fmt.Errorf("can't use -c, -dry-run, -write-baseline or test mode together with -l")
+	case p.args.distinct != "" || p.args.clones || p.args.importAliases || p.args.receiverNames:
+		return fmt.Errorf("can't use -distinct, -clones, -import-aliases or -receiver-names together with -l")
+	case p.args.groupByFile || p.args.contextFunc || p.args.matchIDs:
+		return fmt.Errorf("can't use -group-by-file, -context-func or -E together with -l")
+	}
+	return nil
+}
+
+// printListedFile prints the m match filename in the -l mode.
+// The matches are printed in the file order, so every file is printed once.
+func (mp *matchPrinter) printListedFile(m match) {
+	mp.printed++
+	if m.filename == mp.lastFilename {
+		return
+	}
+	mp.lastFilename = m.filename
+	mp.p.printFilename(m.filename)
+}
+
+// printFilename prints a file names list element, like a -l mode filename.
+// The -0 mode filenames are terminated by a NUL byte and are never colorized,
+// so they're safe to be piped into `xargs -0`.
+func (p *program) printFilename(filename string) {
+	if p.args.abs {
+		filename = filepathAbs(p.workDir, filename)
+	}
+	if p.args.nullSeparator {
+		os.Stdout.WriteString(filename + "\x00")
+		return
+	}
+	if !p.args.noColor {
+		filename = mustColorizeText(filename, p.args.filenameColor)
+	}
+	fmt.Println(filename)
+}
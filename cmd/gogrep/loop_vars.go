@@ -0,0 +1,87 @@
+package main
+
+import "go/ast"
+
+// capturesLoopVar reports whether n is a function literal (or a call of it,
+// like in `defer func() { ... }()`) that references a variable declared
+// by one of the enclosing loops of the same function.
+// Before go1.22, such variables are shared between the loop iterations,
+// so the deferred and the goroutine closures usually see their last value.
+//
+// There is no types info, so this is a name-based heuristic: the closure params
+// and the variables that are re-declared inside of the loop body,
+// like in `v := v`, are taken into account, the closure body shadowing is not.
+func (ctx *filterContext) capturesLoopVar(varname string, n ast.Node) bool {
+	// The -lang go1.22 loop variables are per-iteration, they can't be shared.
+	if ctx.w.lang >= 22 {
+		return false
+	}
+	fn := closureFuncLit(n)
+	if fn == nil {
+		return false
+	}
+
+	var loopVars []string
+	shadowed := make(map[string]bool)
+	for _, id := range appendFieldListNames(nil, fn.Type.Params) {
+		shadowed[id] = true
+	}
+	for _, id := range appendFieldListNames(nil, fn.Type.Results) {
+		shadowed[id] = true
+	}
+	addLoopVars := func(idents []*ast.Ident) {
+		for _, id := range idents {
+			if id.Name != "_" && !shadowed[id.Name] {
+				loopVars = append(loopVars, id.Name)
+			}
+		}
+	}
+
+	child := n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		switch parent := parent.(type) {
+		case *ast.FuncLit, *ast.FuncDecl:
+			return false
+		case *ast.RangeStmt:
+			if parent.Body == child {
+				addLoopVars(definedIdents(parent))
+			}
+		case *ast.ForStmt:
+			if parent.Body == child {
+				addLoopVars(definedIdents(parent.Init))
+			}
+		default:
+			names, _ := scopeNamesBefore(parent, child)
+			for _, name := range names {
+				shadowed[name] = true
+			}
+		}
+		child = parent
+		return true
+	})
+
+	for _, name := range loopVars {
+		if referencesName(fn.Body, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// closureFuncLit returns the function literal that is either n itself,
+// or is called by n, like in `go func() { ... }()` statement.
+func closureFuncLit(n ast.Node) *ast.FuncLit {
+	switch n := n.(type) {
+	case *ast.GoStmt:
+		return closureFuncLit(n.Call)
+	case *ast.DeferStmt:
+		return closureFuncLit(n.Call)
+	case *ast.CallExpr:
+		fn, _ := n.Fun.(*ast.FuncLit)
+		return fn
+	case *ast.FuncLit:
+		return n
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// ruleMacro is a rules file `define $name = pattern` sub-pattern.
+type ruleMacro struct {
+	name string
+	body string
+	line int
+}
+
+func parseMacro(line string) (*ruleMacro, error) {
+	s := strings.TrimSpace(strings.TrimPrefix(line, "define "))
+	eq := strings.Index(s, "=")
+	if !strings.HasPrefix(s, "$") || eq == -1 {
+		return nil, fmt.Errorf("define: expected $name = pattern")
+	}
+	name := strings.TrimSpace(s[len("$"):eq])
+	if !token.IsIdentifier(name) || name == "_" {
+		return nil, fmt.Errorf("define: invalid macro name $%s", name)
+	}
+	body := strings.TrimSpace(s[eq+len("="):])
+	if body == "" {
+		return nil, fmt.Errorf("define: empty $%s pattern", name)
+	}
+	return &ruleMacro{name: name, body: body}, nil
+}
+
+// expandMacros replaces every $name macro reference inside the src pattern
+// with the macro body; the macro bodies can reference other macros.
+// stack is a chain of the macros being expanded, it's used to detect the recursion.
+//
+// The expansion is textual, so the macro wildcards are shared with the pattern it's used in.
+func expandMacros(macros map[string]*ruleMacro, src string, stack []string) (string, error) {
+	type tokenInfo struct {
+		offset int
+		tok    token.Token
+		lit    string
+	}
+
+	// Wildcards are not valid Go tokens: the scanner reports $ as ILLEGAL
+	// followed by the wildcard name IDENT. String literals and comments are skipped.
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), func(token.Position, string) {}, 0)
+	var tokens []tokenInfo
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		tokens = append(tokens, tokenInfo{offset: file.Offset(pos), tok: tok, lit: lit})
+	}
+
+	var buf strings.Builder
+	last := 0
+	for i := 0; i < len(tokens)-1; i++ {
+		dollar, ident := tokens[i], tokens[i+1]
+		if dollar.tok != token.ILLEGAL || dollar.lit != "$" || ident.tok != token.IDENT || ident.offset != dollar.offset+1 {
+			continue
+		}
+		m, ok := macros[ident.lit]
+		if !ok {
+			continue
+		}
+		for j, name := range stack {
+			if name == m.name {
+				chain := strings.Join(stack[j:], " -> $")
+				return "", fmt.Errorf("recursive macro: $%s -> $%s", chain, m.name)
+			}
+		}
+		body, err := expandMacros(macros, m.body, append(stack, m.name))
+		if err != nil {
+			return "", err
+		}
+		buf.WriteString(src[last:dollar.offset])
+		buf.WriteString(body)
+		last = ident.offset + len(ident.lit)
+		i++
+	}
+	if last == 0 {
+		return src, nil
+	}
+	buf.WriteString(src[last:])
+	return buf.String(), nil
+}
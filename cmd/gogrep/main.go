@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	"go/token"
@@ -14,6 +15,7 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -23,6 +25,7 @@ import (
 	"github.com/google/pprof/profile"
 	"github.com/quasilyte/gogrep"
 	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
 	"github.com/quasilyte/perf-heatmap/heatmap"
 )
 
@@ -33,12 +36,33 @@ const (
 	exitError      = 2
 )
 
-const defaultFormat = `{{.Filename}}:{{.Line}}: {{.MatchLine}}`
+const defaultFormat = `{{.Filename}}:{{.Line}}: {{.RuleInfo}}{{.MatchLine}}`
+
+// groupedFormat is a default format for the -group-by-file mode,
+// the filename is printed once in the group header instead.
+const groupedFormat = `{{.Line}}: {{.RuleInfo}}{{.MatchLine}}`
+
+// matchIDFormat is a default format prefix for the -E mode.
+const matchIDFormat = `#{{.ID}} `
+
+// groupIndent is a -group-by-file prefix for the lines inside a group.
+const groupIndent = "  "
+
+// defaultExclude is a default -exclude pattern.
+// The test mode default doesn't skip the testdata dirs, they're the usual test targets.
+const (
+	defaultExclude     = `/node_modules$|/testdata$|/\.\w+$`
+	defaultTestExclude = `/node_modules$|/\.\w+$`
+)
+
+// sarifFormat is a special -format value that makes gogrep print
+// all matches as a single SARIF report.
+const sarifFormat = "sarif"
 
 func main() {
 	exitCode, err := mainNoExit()
 	if err != nil {
-		log.Printf("error: %+v", err)
+		printError(err)
 		return
 	}
 	os.Exit(exitCode)
@@ -51,7 +75,8 @@ func mainNoExit() (int, error) {
 	parseFlags(&args)
 
 	p := &program{
-		args: args,
+		args:      args,
+		startTime: time.Now(),
 	}
 
 	steps := []struct {
@@ -61,12 +86,20 @@ func mainNoExit() (int, error) {
 		{"validate flags", p.validateFlags},
 		{"start profiling", p.startProfiling},
 		{"load heatmap", p.loadHeatmap},
-		{"compile filter", p.compileFilter},
-		{"compile pattern", p.compilePattern},
+		{"load rules", p.loadRules},
+		{"load baseline", p.loadBaseline},
+		{"compile filter", p.compileFilters},
+		{"compile scope patterns", p.compileNotInPatterns},
+		{"compile pattern", p.compilePatterns},
 		{"compile exclude pattern", p.compileExcludePattern},
 		{"compile output format", p.compileOutputFormat},
+		{"index packages", p.indexPackages},
 		{"execute pattern", p.executePattern},
 		{"print matches", p.printMatches},
+		{"check timeout", p.checkTimeout},
+		{"watch changes", p.watchChanges},
+		{"write baseline", p.writeBaseline},
+		{"print stats", p.printStats},
 		{"finish profiling", p.finishProfiling},
 	}
 
@@ -75,10 +108,21 @@ func mainNoExit() (int, error) {
 			log.Printf("debug: starting %q step", step.name)
 		}
 		if err := step.fn(); err != nil {
-			return exitError, fmt.Errorf("%s: %v", step.name, err)
+			return exitError, &stepError{
+				step: step.name,
+				err:  err,
+				json: p.args.format == jsonFormat,
+			}
 		}
 	}
 
+	if p.args.testMode {
+		// The test exit status depends on the want comments check instead.
+		if p.testFailures != 0 {
+			return exitNotMatched, nil
+		}
+		return exitMatched, nil
+	}
 	if p.numMatches == 0 {
 		return exitNotMatched, nil
 	}
@@ -88,7 +132,9 @@ func mainNoExit() (int, error) {
 type arguments struct {
 	abs          bool
 	multiline    bool
+	contextFunc  bool
 	verbose      bool
+	stats        bool
 	strictSyntax bool
 	workers      uint
 	limit        uint64
@@ -97,6 +143,61 @@ type arguments struct {
 
 	countMode bool
 
+	dryRun bool
+
+	watch bool
+
+	fileQuery bool
+
+	commentQuery bool
+
+	clones bool
+
+	importAliases bool
+
+	receiverNames bool
+
+	blankImports bool
+
+	decls bool
+
+	fast bool
+
+	lang string
+
+	buildTags      string
+	includeIgnored bool
+
+	groupByFile bool
+
+	matchIDs bool
+
+	listFiles     bool
+	nullSeparator bool
+
+	testMode bool
+
+	distinct string
+
+	countBy string
+
+	firstPer string
+	lastPer  string
+
+	invertMatch string
+
+	report string
+
+	sinks string
+
+	nfc bool
+
+	notIn stringList
+
+	mask stringList
+
+	lines string
+
 	exclude      string
 	progressMode string
 
@@ -108,9 +209,26 @@ type arguments struct {
 	cpuProfile string
 	memProfile string
 
+	mmap        bool
+	maxFileSize int64
+	timeout     time.Duration
+
 	heatmapFile      string
 	heatmapThreshold float64
 
+	rulesFile        string
+	ruleMode         string
+	patterns         stringList
+	mergeOverlapping bool
+
+	baseline      string
+	writeBaseline string
+
+	rewrite     string
+	interactive bool
+
+	numPositional int
+
 	targets string
 	pattern string
 	filter  string
@@ -119,6 +237,10 @@ type arguments struct {
 func parseFlags(args *arguments) {
 	flag.Usage = func() {
 		const usage = `Usage: gogrep [flags...] targets pattern [filter]
+   Or: gogrep [flags...] -e pattern [-e pattern...] targets [filter]
+   Or: gogrep [flags...] -rules rules.txt targets
+   Or: gogrep [flags...] -file-query targets filter
+   Or: gogrep test [flags...] targets pattern [filter]
 Where:
   flags are command-line arguments that are listed in -help (see below)
   targets is a comma-separated list of file or directory names to search in
@@ -133,8 +255,55 @@ Examples:
   gogrep dir1,dir2 '"some string"'
   # Run gogrep in src folder, ignoring all auto-generated files
   gogrep src 'os.Exit($_)' '!file.IsAutogen()'
+  # Find all calls except the fmt.Sprintf ones.
+  gogrep -invert-match CallExpr src 'fmt.Sprintf($*_)'
+  # Find all program-terminating calls outside of tests.
+  gogrep src '$f($*_)' '$$.IsSink() && !file.IsTest()'
+  # Find files with more than 50 functions.
+  gogrep -file-query src '$$.FuncCount() > 50'
+  # Find all //go:embed directives.
+  gogrep -comment-query src '$$.Directive() == "go:embed"'
+  # Find := declarations that shadow the outer err variable.
+  gogrep src 'err := $_' '$$.Shadows()'
+  # Find calls which results are discarded.
+  gogrep src '$f($*_)' '$$.IsExprStmt()'
+  # Find error assignments that are not followed by the error check.
+  gogrep src '$_, $err := $_($*_)' '!$$.FollowedBy("if $err != nil { $*_ }")'
+  # Find goroutines that are started in a loop without waiting for them.
+  gogrep src 'go $f($*_)' '$$.InLoop() && !$$.FuncContains("$_.Wait()")'
+  # Report only the format arguments of the log.Printf calls.
+  gogrep -report '$format' src 'log.Printf($format, $*_)'
+  # Find method values (or method expressions) that are not called.
+  gogrep src '$_.$_' '!$$.IsCalled()'
+  # Find $x.Do() calls that are not located inside a for loop.
+  gogrep -not-in 'for $*_; $*_; $*_ { $*_ }' src '$x.Do()'
+  # Count the panic calls outside of the init functions.
+  gogrep -c -mask 'func init() { $*_ }' src 'panic($_)'
+  # Find recover() calls that are not inside a deferred call.
+  gogrep src 'recover()' '!$$.InDefer()'
   # Ignore third_party and vendor folders while searching.
   gogrep --exclude '/third_party$|/vendor$' project/ 'pattern'
+  # Search for several patterns at once.
+  gogrep -e 'fmt.Println($*_)' -e 'log.Println($*_)' src
+  # Find if statements that only differ in the condition.
+  gogrep -clones src 'if $cond { $*_ }'
+  # Run all rules from the rules file.
+  gogrep -rules rules.txt project/
+  # Find packages that are imported under different names.
+  gogrep -import-aliases project/
+  # Find types which methods use different receiver names.
+  gogrep -receiver-names project/
+  # Find const blocks that are immediately followed by a var block.
+  gogrep -decls src 'const ($*_); var ($*_)'
+  # Print the files that contain matches, separated by NUL bytes for xargs -0.
+  gogrep -l -0 project/ 'ioutil.ReadFile($_)'
+  # Check which files would be searched without searching them.
+  gogrep -dry-run project/ 'pattern'
+  # Check that the rules report exactly the lines with the // want comments.
+  gogrep test -rules rules.txt testdata/
+  # Record the current matches, then report only the new ones.
+  gogrep -write-baseline baseline.json -rules rules.txt project/
+  gogrep -baseline baseline.json -rules rules.txt project/
 
 The output colors can be configured with "--color-<name>" flags.
 Use --no-color to disable the output coloring.
@@ -154,6 +323,8 @@ Supported command-line flags:
 
 	flag.BoolVar(&args.verbose, "v", false,
 		`verbose mode: turn on additional debug logging`)
+	flag.BoolVar(&args.stats, "stats", false,
+		`print the search statistics (like the skipped files and visited nodes counts) to the stderr when finished`)
 	flag.Uint64Var(&args.limit, "limit", 1000,
 		`stop after this many match results, 0 for unlimited`)
 	flag.UintVar(&args.workers, "workers", uint(runtime.NumCPU()),
@@ -162,15 +333,47 @@ Supported command-line flags:
 		`write memory profile to the specified file`)
 	flag.StringVar(&args.cpuProfile, "cpuprofile", "",
 		`write CPU profile to the specified file`)
+	flag.BoolVar(&args.mmap, "mmap", false,
+		`memory-map the large files instead of reading them into memory`)
+	flag.Int64Var(&args.maxFileSize, "max-filesize", 0,
+		`skip the files that are larger than this many bytes, 0 for unlimited`)
+	flag.DurationVar(&args.timeout, "timeout", 0,
+		`interrupt the search after this duration, like 30s, the matches found so far are still reported`)
+	flag.StringVar(&args.rewrite, "rewrite", "",
+		`replace the matches with this template in place, $x is replaced with the $x capture text`)
+	flag.BoolVar(&args.interactive, "i", false,
+		`ask for a confirmation before applying every -rewrite replacement`)
+	flag.BoolVar(&args.fast, "fast", false,
+		`skip the files that don't contain the identifiers required by the patterns without parsing them`)
 
 	flag.BoolVar(&args.strictSyntax, "strict-syntax", false,
-		`disable syntax normalizations, so 10 and 0xA are not considered to be identical, and so on`)
-	flag.StringVar(&args.exclude, "exclude", `/node_modules$|/testdata$|/\.\w+$`,
+		`disable syntax normalizations, so 10 and 0xA are not considered to be identical, (x) and x are different, and so on`)
+	flag.BoolVar(&args.decls, "decls", false,
+		`match the patterns as sequences of top-level declarations, so const and var declarations are not parsed as statements`)
+	flag.StringVar(&args.lang, "lang", "",
+		`reject the files that use a syntax unavailable in the specified Go version, like go1.17`)
+	flag.StringVar(&args.buildTags, "build-tags", "",
+		`a comma-separated list of the build tags that are considered to be set, like integration,tools or linux,!cgo`)
+	flag.BoolVar(&args.includeIgnored, "include-ignored", false,
+		`search the files regardless of their build constraints, including the //go:build ignore ones`)
+	flag.StringVar(&args.exclude, "exclude", defaultExclude,
 		`exclude files or directories by regexp pattern`)
 	flag.StringVar(&args.progressMode, "progress", "update",
 		`progress printing mode: "update", "append" or "none"`)
 	flag.StringVar(&args.format, "format", defaultFormat,
-		`specify an alternate format for the output, using the syntax Go templates`)
+		`specify an alternate format for the output, using the syntax Go templates; "json", "sarif" and "ctags" are special values`)
+	flag.Var(&args.patterns, "e",
+		`a pattern to search for; can be given several times, all patterns are matched in a single pass`)
+	flag.StringVar(&args.rulesFile, "rules", "",
+		`a file with rules to run instead of the command-line pattern, see docs for the syntax`)
+	flag.StringVar(&args.ruleMode, "rule-mode", "all",
+		`"all" reports the matches of every rule, "first" stops at the first matching rule for every node, in the rules order`)
+	flag.BoolVar(&args.mergeOverlapping, "merge-overlapping", false,
+		`report the matches of several patterns (or rules) with identical positions only once`)
+	flag.StringVar(&args.baseline, "baseline", "",
+		`a baseline file created by -write-baseline, matches listed in it are not reported`)
+	flag.StringVar(&args.writeBaseline, "write-baseline", "",
+		`write fingerprints of all matches to the specified baseline file instead of printing them`)
 
 	flag.StringVar(&args.heatmapFile, "heatmap", "",
 		`a CPU profile that will be used to build a heatmap, needed for IsHot() filters`)
@@ -179,11 +382,61 @@ Supported command-line flags:
 
 	flag.BoolVar(&args.countMode, "c", false,
 		`count mode that discards all match data, but prints the total matches count`)
+	flag.BoolVar(&args.dryRun, "dry-run", false,
+		`print the files that would be searched without parsing them; with -c, only print their count`)
+	flag.BoolVar(&args.watch, "watch", false,
+		`re-run the search for the changed files and reprint the matches until interrupted; requires the watch build tag`)
+	flag.BoolVar(&args.clones, "clones", false,
+		`clone detection mode: group the matches that only differ in the named captures, print groups with more than one match`)
+	flag.BoolVar(&args.importAliases, "import-aliases", false,
+		`report the packages that are imported under different names across the target files`)
+	flag.BoolVar(&args.receiverNames, "receiver-names", false,
+		`report the types which methods use different receiver names, the pointer and value receivers are grouped together`)
+	flag.BoolVar(&args.blankImports, "blank-imports", false,
+		`report the blank-imported packages with their importing files and init functions calls`)
+	flag.BoolVar(&args.commentQuery, "comment-query", false,
+		`apply the filter to every comment (bound to $$) instead of matching a pattern`)
+	flag.BoolVar(&args.fileQuery, "file-query", false,
+		`file query mode: apply the filter to every file (bound to $$) and print the matching file names`)
 
 	flag.BoolVar(&args.abs, "abs", false,
 		`print absolute filenames in the output`)
+	flag.StringVar(&args.report, "report", "",
+		`report the position and text of the specified capture (like $x) instead of the entire match`)
+	flag.StringVar(&args.invertMatch, "invert-match", "",
+		`report the nodes of the specified kind (like CallExpr or Stmt) that are not matched by the pattern`)
+	flag.StringVar(&args.sinks, "sinks", defaultSinks,
+		`a comma-separated list of functions recognized by IsSink() filter; a trailing * matches any name suffix`)
+	flag.Var(&args.notIn, "not-in",
+		`discard matches that are located inside a node matching this pattern; can be given several times`)
+	flag.Var(&args.mask, "mask",
+		`skip the nodes matching this pattern along with their subtrees; can be given several times`)
+	flag.StringVar(&args.lines, "lines", "",
+		`a comma-separated list of line ranges, like 10-40,50-; only the nodes that overlap these lines are matched`)
+	flag.StringVar(&args.firstPer, "first-per", "",
+		`keep only the first match of every pattern inside the scope: "func" or "file"`)
+	flag.StringVar(&args.lastPer, "last-per", "",
+		`keep only the last match of every pattern inside the scope: "func" or "file"`)
+	flag.BoolVar(&args.nfc, "nfc", false,
+		`apply Unicode NFC normalization before comparing texts in filters, like $x.Text() == "s"`)
+	flag.BoolVar(&args.contextFunc, "context-func", false,
+		`print the enclosing function signature (or a package clause for the file scope) before the matches`)
 	flag.BoolVar(&args.multiline, "m", false,
 		`multiline mode: print matches without escaping newlines to \n`)
+	flag.StringVar(&args.distinct, "distinct", "",
+		`print the sorted distinct text values of the specified capture (like $x) instead of the matches`)
+	flag.StringVar(&args.countBy, "count-by", "",
+		`print the matches count of every matched line, sorted by the count; the only supported key is "line"`)
+	flag.BoolVar(&args.groupByFile, "group-by-file", false,
+		`print every filename once as a header, followed by its matches sorted by their location`)
+	flag.BoolVar(&args.matchIDs, "E", false,
+		`extended output: print every match with its id, like #7, that is stable for the same input`)
+	flag.BoolVar(&args.listFiles, "l", false,
+		`print only the names of the files with matches, every file is printed once`)
+	flag.BoolVar(&args.nullSeparator, "0", false,
+		`terminate the -l and -dry-run filenames with a NUL byte instead of a newline, for xargs -0`)
+	flag.BoolVar(&args.nullSeparator, "null", false,
+		`an alias for -0`)
 
 	flag.BoolVar(&args.noColor, "no-color", false,
 		`disable colored output`)
@@ -194,7 +447,16 @@ Supported command-line flags:
 	flag.StringVar(&args.matchColor, "color-match", envVarOrDefault("GOGREP_COLOR_MATCH", "dark-red"),
 		`{{.Match}} text color, can also override via $GOGREP_COLOR_MATCH`)
 
-	flag.Parse()
+	// The `gogrep test` subcommand accepts the same flags and arguments.
+	cmdArgs := os.Args[1:]
+	if len(cmdArgs) != 0 && cmdArgs[0] == "test" {
+		args.testMode = true
+		cmdArgs = cmdArgs[1:]
+	}
+	flag.CommandLine.Parse(cmdArgs)
+	if args.testMode && args.exclude == defaultExclude {
+		args.exclude = defaultTestExclude
+	}
 
 	argv := flag.Args()
 	if len(argv) != 0 {
@@ -206,10 +468,26 @@ Supported command-line flags:
 	if len(argv) >= 3 {
 		args.filter = argv[2]
 	}
+	args.numPositional = len(argv)
+	switch {
+	case args.rulesFile != "", args.importAliases, args.receiverNames, args.blankImports:
+		args.pattern = ""
+		args.filter = ""
+	case len(args.patterns) != 0, args.fileQuery, args.commentQuery:
+		// With -e, -file-query and -comment-query, there is no pattern positional argument.
+		args.pattern = ""
+		args.filter = ""
+		if len(argv) >= 2 {
+			args.filter = argv[1]
+		}
+	}
 
 	if args.verbose {
 		log.Printf("debug: targets: %s", args.targets)
 		log.Printf("debug: pattern: %s", args.pattern)
+		for _, pat := range args.patterns {
+			log.Printf("debug: -e pattern: %s", pat)
+		}
 		log.Printf("debug: filter: %s", args.filter)
 	}
 }
@@ -219,18 +497,61 @@ type program struct {
 
 	numMatches uint64
 
+	// startTime is used to report the -stats wall time.
+	startTime time.Time
+
+	// stats are the driver-side -stats counters, like the excluded files count.
+	// The workers have their own stats that are merged with these.
+	stats searchStats
+
 	workDir string
 	exclude *regexp.Regexp
 
 	heatmap            *heatmap.Index
 	heatmapFilenameSet map[string]struct{}
 
-	filterHints filterHints
-	filterInfo  filters.Info
-	filterExpr  *filters.Expr
+	rules []*rule
+
+	baseline    *baseline
+	baselineDir string
+
+	// valueSets is a cache of the loaded `in @filename` filter sets.
+	valueSets map[string]valueSet
+
+	invertKind nodetag.Value
+
+	lang langVersion
+
+	keepScope matchScope
+	keepLast  bool
+
+	sinks []sinkPattern
+
+	notIn []*gogrep.Pattern
+
+	mask []*gogrep.Pattern
+
+	lineRanges []lineRange
+
+	// pkgRefs is built for the PkgRefs() filters, see indexPackages.
+	pkgRefs *pkgRefsIndex
+
+	// ctx is the search context, it's done once the -timeout is exceeded.
+	ctx context.Context
 
 	workers []*worker
 
+	// filesQueued is the number of files sent to the workers so far.
+	filesQueued int
+
+	// printer is set in the streaming output mode, the matches are printed
+	// by it during the search instead of being collected by the workers.
+	// The SARIF report is still printed in the end, but its results are collected by the printer.
+	printer *matchPrinter
+
+	// testFailures is the number of `gogrep test` mode failures.
+	testFailures int
+
 	outputTemplate *template.Template
 
 	cpuProfile bytes.Buffer
@@ -245,8 +566,99 @@ func (p *program) validateFlags() error {
 	if p.args.targets == "" {
 		return fmt.Errorf("target can't be empty")
 	}
-	if p.args.pattern == "" {
-		return fmt.Errorf("pattern can't be empty")
+	if p.args.maxFileSize < 0 {
+		return fmt.Errorf("-max-filesize can't be negative")
+	}
+	if p.args.timeout < 0 {
+		return fmt.Errorf("-timeout can't be negative")
+	}
+	switch {
+	case p.args.importAliases:
+		if err := p.validateAggregationFlags("-import-aliases"); err != nil {
+			return err
+		}
+		switch {
+		case p.args.receiverNames:
+			return fmt.Errorf("can't use -receiver-names together with -import-aliases")
+		case p.args.blankImports:
+			return fmt.Errorf("can't use -blank-imports together with -import-aliases")
+		}
+	case p.args.receiverNames:
+		if err := p.validateAggregationFlags("-receiver-names"); err != nil {
+			return err
+		}
+		if p.args.blankImports {
+			return fmt.Errorf("can't use -blank-imports together with -receiver-names")
+		}
+	case p.args.blankImports:
+		if err := p.validateAggregationFlags("-blank-imports"); err != nil {
+			return err
+		}
+	case p.args.fileQuery:
+		if p.args.rulesFile != "" || len(p.args.patterns) != 0 {
+			return fmt.Errorf("can't use -rules or -e together with -file-query")
+		}
+		if p.args.invertMatch != "" || len(p.args.notIn) != 0 || len(p.args.mask) != 0 {
+			return fmt.Errorf("can't use -invert-match, -not-in or -mask together with -file-query")
+		}
+		if p.args.contextFunc || p.args.report != "" {
+			return fmt.Errorf("can't use -context-func or -report together with -file-query")
+		}
+		if p.args.numPositional > 2 {
+			return fmt.Errorf("can't use a pattern argument together with -file-query")
+		}
+		if p.args.decls {
+			return fmt.Errorf("can't use -decls together with -file-query")
+		}
+		if p.args.filter == "" {
+			return fmt.Errorf("file query filter can't be empty")
+		}
+		if p.args.commentQuery {
+			return fmt.Errorf("can't use -comment-query together with -file-query")
+		}
+	case p.args.commentQuery:
+		switch {
+		case p.args.rulesFile != "" || len(p.args.patterns) != 0:
+			return fmt.Errorf("can't use -rules or -e together with -comment-query")
+		case p.args.invertMatch != "" || len(p.args.notIn) != 0 || len(p.args.mask) != 0:
+			return fmt.Errorf("can't use -invert-match, -not-in or -mask together with -comment-query")
+		case p.args.contextFunc || p.args.report != "" || p.args.decls:
+			return fmt.Errorf("can't use -context-func, -report or -decls together with -comment-query")
+		case p.args.clones || p.args.distinct != "":
+			return fmt.Errorf("can't use -clones or -distinct together with -comment-query")
+		case p.args.firstPer != "" || p.args.lastPer != "":
+			return fmt.Errorf("can't use -first-per or -last-per together with -comment-query")
+		case p.args.numPositional > 2:
+			return fmt.Errorf("can't use a pattern argument together with -comment-query")
+		case p.args.filter == "":
+			return fmt.Errorf("comment query filter can't be empty")
+		}
+	case p.args.rulesFile != "":
+		if len(p.args.patterns) != 0 {
+			return fmt.Errorf("can't use -e together with -rules")
+		}
+		if p.args.numPositional > 1 {
+			return fmt.Errorf("can't use a pattern argument together with -rules")
+		}
+	case len(p.args.patterns) != 0:
+		if p.args.numPositional > 2 {
+			return fmt.Errorf("can't use a pattern argument together with -e")
+		}
+		for _, pat := range p.args.patterns {
+			if pat == "" {
+				return fmt.Errorf("pattern can't be empty")
+			}
+		}
+	default:
+		if p.args.pattern == "" {
+			return fmt.Errorf("pattern can't be empty")
+		}
+	}
+
+	switch p.args.ruleMode {
+	case "all", "first":
+	default:
+		return fmt.Errorf("rule-mode: unexpected value %q, expected all or first", p.args.ruleMode)
 	}
 
 	if _, err := colorizeText("", p.args.filenameColor); err != nil {
@@ -259,18 +671,179 @@ func (p *program) validateFlags() error {
 		return fmt.Errorf("color-match: %v", err)
 	}
 
+	if p.args.includeIgnored && p.args.buildTags != "" {
+		return fmt.Errorf("can't use -build-tags together with -include-ignored")
+	}
+
+	if p.args.lang != "" {
+		lang, err := parseLangVersion(p.args.lang)
+		if err != nil {
+			return fmt.Errorf("lang: %v", err)
+		}
+		p.lang = lang
+	}
+
+	if p.args.invertMatch != "" {
+		kind := nodetag.FromString(p.args.invertMatch)
+		if kind == nodetag.Unknown {
+			return fmt.Errorf("invert-match: unexpected node kind %q", p.args.invertMatch)
+		}
+		p.invertKind = kind
+		if p.args.fast {
+			return fmt.Errorf("can't use -fast together with -invert-match")
+		}
+	}
+
+	if p.args.report != "" {
+		name := strings.TrimPrefix(p.args.report, "$")
+		if name == "" || name == "_" || strings.HasPrefix(name, "*") {
+			return fmt.Errorf("report: expected a capture name, like $x, found %q", p.args.report)
+		}
+		if p.args.invertMatch != "" {
+			return fmt.Errorf("can't use -report together with -invert-match")
+		}
+		p.args.report = name
+	}
+
+	if p.args.distinct != "" {
+		if err := p.validateDistinctFlags(); err != nil {
+			return err
+		}
+	}
+	if p.args.countBy != "" {
+		if err := p.validateCountByFlags(); err != nil {
+			return err
+		}
+	}
+
+	sinks, err := parseSinkList(p.args.sinks)
+	if err != nil {
+		return fmt.Errorf("sinks: %v", err)
+	}
+	p.sinks = sinks
+
+	if p.args.clones {
+		switch {
+		case p.args.countMode:
+			return fmt.Errorf("can't use -c together with -clones")
+		case p.args.fileQuery:
+			return fmt.Errorf("can't use -file-query together with -clones")
+		case p.args.writeBaseline != "":
+			return fmt.Errorf("can't use -write-baseline together with -clones")
+		case p.args.format == sarifFormat:
+			return fmt.Errorf("can't use sarif format together with -clones")
+		}
+	}
+
+	if p.args.dryRun {
+		switch {
+		case p.args.clones:
+			return fmt.Errorf("can't use -clones together with -dry-run")
+		case p.args.writeBaseline != "":
+			return fmt.Errorf("can't use -write-baseline together with -dry-run")
+		case p.args.format == jsonFormat || p.args.format == sarifFormat:
+			return fmt.Errorf("can't use %s format together with -dry-run", p.args.format)
+		}
+	}
+
+	if p.args.watch {
+		if err := p.validateWatchFlags(); err != nil {
+			return err
+		}
+	}
+
+	if p.args.firstPer != "" || p.args.lastPer != "" {
+		if err := p.validateScopeFlags(); err != nil {
+			return err
+		}
+	}
+
+	if p.args.groupByFile {
+		switch {
+		case p.args.fileQuery:
+			return fmt.Errorf("can't use -file-query together with -group-by-file")
+		case p.args.format == jsonFormat || p.args.format == sarifFormat:
+			return fmt.Errorf("can't use %s format together with -group-by-file", p.args.format)
+		}
+	}
+
+	if p.args.matchIDs {
+		switch {
+		case p.args.countMode || p.args.dryRun || p.args.writeBaseline != "":
+			return fmt.Errorf("can't use -E together with -c, -dry-run or -write-baseline")
+		case p.args.distinct != "" || p.args.clones || p.args.importAliases || p.args.receiverNames:
+			return fmt.Errorf("can't use -E together with -distinct, -clones, -import-aliases or -receiver-names")
+		}
+	}
+
+	if err := p.validateListFlags(); err != nil {
+		return err
+	}
+	if err := p.validateRewriteFlags(); err != nil {
+		return err
+	}
+	if err := p.validateMergeFlags(); err != nil {
+		return err
+	}
+	if err := p.validateCtagsFlags(); err != nil {
+		return err
+	}
+
+	if p.args.lines != "" {
+		if p.args.fileQuery || p.args.importAliases || p.args.receiverNames || p.args.blankImports {
+			return fmt.Errorf("can't use -lines together with -file-query, -import-aliases, -receiver-names or -blank-imports")
+		}
+		ranges, err := parseLineRanges(p.args.lines)
+		if err != nil {
+			return fmt.Errorf("lines: %v", err)
+		}
+		p.lineRanges = ranges
+	}
+
+	if p.args.testMode {
+		switch {
+		case p.args.countMode || p.args.dryRun || p.args.watch || p.args.writeBaseline != "":
+			return fmt.Errorf("can't use -c, -dry-run, -watch or -write-baseline in test mode")
+		case p.args.distinct != "" || p.args.clones || p.args.importAliases || p.args.receiverNames:
+			return fmt.Errorf("can't use -distinct, -clones, -import-aliases or -receiver-names in test mode")
+		case p.args.fileQuery || p.args.commentQuery:
+			return fmt.Errorf("can't use -file-query or -comment-query in test mode")
+		}
+	}
+
+	if p.args.writeBaseline != "" {
+		if p.args.baseline != "" {
+			return fmt.Errorf("can't use -baseline together with -write-baseline")
+		}
+		if p.args.countMode {
+			return fmt.Errorf("can't use -c together with -write-baseline")
+		}
+	}
+
+	if p.args.mmap && !mmapSupported {
+		return fmt.Errorf("mmap: not supported on this platform")
+	}
+
 	switch p.args.progressMode {
 	case "none", "append", "update":
 		// OK.
 	default:
 		return fmt.Errorf("progress: unexpected mode %q", p.args.progressMode)
 	}
+	if p.args.format == jsonFormat {
+		// The progress text would break the JSON objects stream on the stderr.
+		p.args.progressMode = "none"
+	}
 
-	if p.args.countMode {
+	switch {
+	case p.args.writeBaseline != "" || p.args.testMode || p.args.rewrite != "":
+		// The baseline should include all matches, and so should the test and the rewrite.
+		p.args.limit = math.MaxUint64
+	case p.args.countMode:
 		if p.args.limit == 0 {
 			p.args.limit = math.MaxUint64
 		}
-	} else {
+	default:
 		// If there are more than 100k results, something is wrong.
 		// Most likely, a user pattern is too generic and needs adjustment.
 		const maxLimit = 100000
@@ -282,6 +855,33 @@ func (p *program) validateFlags() error {
 	return nil
 }
 
+// validateAggregationFlags checks the flags of the modes that report
+// the packages or types instead of the individual matches, like -import-aliases.
+// These modes have no pattern, so the per-match flags make no sense for them.
+func (p *program) validateAggregationFlags(mode string) error {
+	switch {
+	case p.args.rulesFile != "" || len(p.args.patterns) != 0:
+		return fmt.Errorf("can't use -rules or -e together with %s", mode)
+	case p.args.fileQuery || p.args.commentQuery || p.args.clones || p.args.distinct != "":
+		return fmt.Errorf("can't use -file-query, -comment-query, -clones or -distinct together with %s", mode)
+	case p.args.decls:
+		return fmt.Errorf("can't use -decls together with %s", mode)
+	case p.args.invertMatch != "" || len(p.args.notIn) != 0 || len(p.args.mask) != 0:
+		return fmt.Errorf("can't use -invert-match, -not-in or -mask together with %s", mode)
+	case p.args.contextFunc || p.args.report != "":
+		return fmt.Errorf("can't use -context-func or -report together with %s", mode)
+	case p.args.firstPer != "" || p.args.lastPer != "" || p.args.groupByFile || p.args.watch:
+		return fmt.Errorf("can't use -first-per, -last-per, -group-by-file or -watch together with %s", mode)
+	case p.args.countMode || p.args.writeBaseline != "" || p.args.format == sarifFormat:
+		return fmt.Errorf("can't use -c, -write-baseline or sarif format together with %s", mode)
+	case p.args.listFiles || p.args.rewrite != "" || p.args.mergeOverlapping || p.args.testMode:
+		return fmt.Errorf("can't use -l, -rewrite, -merge-overlapping or test mode together with %s", mode)
+	case p.args.numPositional > 1:
+		return fmt.Errorf("can't use a pattern argument together with %s", mode)
+	}
+	return nil
+}
+
 func (p *program) startProfiling() error {
 	if p.args.memProfile != "" {
 		runtime.MemProfileRate = 1024
@@ -324,7 +924,63 @@ func (p *program) loadHeatmap() error {
 	return nil
 }
 
-func (p *program) compileFilter() error {
+func (p *program) loadRules() error {
+	if len(p.args.patterns) != 0 {
+		p.rules = make([]*rule, len(p.args.patterns))
+		for i, pat := range p.args.patterns {
+			p.rules[i] = &rule{
+				pattern: pat,
+				filter:  p.args.filter,
+				label:   fmt.Sprintf("-e #%d", i+1),
+			}
+		}
+		return nil
+	}
+	if p.args.rulesFile == "" {
+		// For the -file-query, it's a rule without a pattern.
+		p.rules = []*rule{{pattern: p.args.pattern, filter: p.args.filter}}
+		return nil
+	}
+
+	rules, err := parseRulesFile(p.args.rulesFile)
+	if err != nil {
+		return err
+	}
+	p.rules = rules
+	if p.args.verbose {
+		log.Printf("debug: loaded %d rules from %s", len(rules), p.args.rulesFile)
+	}
+	return nil
+}
+
+func (p *program) compileFilters() error {
+	optab := newFilterOperationTable()
+
+	allHeatmapBound := true
+	for _, r := range p.rules {
+		if err := p.compileFilter(optab, r); err != nil {
+			return withRuleLocation(r, err)
+		}
+		if !r.heatmapBound {
+			allHeatmapBound = false
+		}
+	}
+
+	// When doing a heatmap-based filtering, we can skip files
+	// that are 100% outside of the heatmap.
+	// This is only possible if every rule depends on the heatmap.
+	if allHeatmapBound {
+		filenameSet := make(map[string]struct{})
+		for _, filename := range p.heatmap.CollectFilenames() {
+			filenameSet[filepath.Base(filename)] = struct{}{}
+		}
+		p.heatmapFilenameSet = filenameSet
+	}
+
+	return nil
+}
+
+func newFilterOperationTable() *filters.OperationsTable {
 	varOps := map[string]filters.Operation{
 		"IsPure":       opVarIsPure,
 		"IsConst":      opVarIsConst,
@@ -334,62 +990,214 @@ func (p *program) compileFilter() error {
 		"IsFloatLit":   opVarIsFloatLit,
 		"IsComplexLit": opVarIsComplexLit,
 		"IsHot":        opVarIsHot,
+		"InDefer":      opVarInDefer,
+		"InGoroutine":  opVarInGoroutine,
+		"InLoop":       opVarInLoop,
+		"IsSink":       opVarIsSink,
+		"IsCalled":     opVarIsCalled,
+		"Shadows":      opVarShadows,
+		"IsExprStmt":   opVarIsExprStmt,
+		"IsVariadic":   opVarIsVariadic,
+		"UsesReceiver": opVarUsesReceiver,
+		"IsPkgName":    opVarIsPkgName,
+		"IsNil":        opVarIsNil,
+		"IsTypedNil":   opVarIsTypedNil,
+		"IsConversion": opVarIsConversion,
+		"IsError":      opVarIsError,
+		"IsStructType": opVarIsStructType,
+		"Count":        opVarCount,
+		"HasElse":      opVarHasElse,
+		"HasDefault":   opVarHasDefault,
+		"Similar":      opVarSimilar,
+		"FollowedBy":   opVarFollowedBy,
+		"Contains":     opVarContains,
+		"HasElem":      opVarHasElem,
+		"FuncContains": opVarFuncContains,
 		"Text":         opVarText,
+		"LitKind":      opVarLitKind,
+		"Kind":         opVarKind,
+		"TypeName":     opVarTypeName,
+
+		"StringMatches": opVarStringMatches,
+		"StringIs":      opVarStringIs,
+		"PkgPath":       opVarPkgPath,
+		"InPackage":     opVarInPackage,
+
+		"IsRedundantConversion": opVarIsRedundantConversion,
+		"CapturesLoopVar":       opVarCapturesLoopVar,
+		"Equal":                 opVarEqual,
+		"IsSpreadCall":          opVarIsSpreadCall,
+		"IsAlias":               opVarIsAlias,
+		"IsExported":            opVarIsExported,
+		"IsVerbArg":             opVarIsVerbArg,
+		"Returns":               opVarReturns,
+		"Depth":                 opVarDepth,
+		"LabelTarget":           opVarLabelTarget,
+		"IsRedundantLabel":      opVarIsRedundantLabel,
+		"PkgRefs":               opVarPkgRefs,
+		"VerbCount":             opVarVerbCount,
+
+		"FuncCount": opVarFuncCount,
+		"LineCount": opVarLineCount,
+		"Imports":   opVarImports,
+		"PkgName":   opVarPkgName,
+		"DirName":   opVarDirName,
+		"FileName":  opVarFileName,
+
+		"HasPrefix":     opVarHasPrefix,
+		"Directive":     opVarDirective,
+		"DirectiveArgs": opVarDirectiveArgs,
+		"InFunc":        opVarInFunc,
+		"FuncName":      opVarFuncName,
 	}
-	optab := filters.NewOperationTable(varOps)
-	expr, info, err := filters.Parse(optab, p.args.filter)
+	return filters.NewOperationTable(varOps)
+}
+
+func (p *program) compileFilter(optab *filters.OperationsTable, r *rule) error {
+	expr, info, err := filters.Parse(optab, r.filter)
 	if err != nil {
 		return err
 	}
 	for _, pred := range info.FilePredicates {
 		switch pred.Name {
 		case "IsAutogen":
-			p.filterHints.autogenCond = newBool3(!pred.Negated)
+			r.filterHints.autogenCond = newBool3(!pred.Negated)
 		case "IsTest":
-			p.filterHints.testCond = newBool3(!pred.Negated)
+			r.filterHints.testCond = newBool3(!pred.Negated)
 		default:
 			return fmt.Errorf("unsupported file predicate: %s", pred.Name)
 		}
 	}
-	p.filterInfo = info
-	p.filterExpr = expr
+	if typ := filterExprType(expr); typ != filterBool {
+		return fmt.Errorf("%s value can't be used as a condition", typ)
+	}
+	if err := checkFilterExpr(&info, expr, p.args.fileQuery, p.args.commentQuery); err != nil {
+		return err
+	}
+	if err := p.loadValueSets(r, expr); err != nil {
+		return err
+	}
+	if err := p.compileFilterPatterns(optab, r, expr); err != nil {
+		return err
+	}
+	if err := p.compileStringMatchers(r, expr); err != nil {
+		return err
+	}
+	r.filterInfo = info
+	r.filterExpr = expr
 
-	heatmapBound := false
+	// The rule is heatmap-bound if it can only match the hot code,
+	// so the IsHot() under || or ! doesn't count.
+	needHeatmap := false
 	filters.Walk(expr, func(e *filters.Expr) bool {
-		if e.Op == filters.OpOr {
+		if e.Op == opVarIsHot {
+			needHeatmap = true
+		}
+		return true
+	})
+	filters.Walk(expr, func(e *filters.Expr) bool {
+		if e.Op == filters.OpOr || e.Op == filters.OpNot {
 			return false
 		}
 		if e.Op == opVarIsHot {
-			heatmapBound = true
+			r.heatmapBound = true
 			return false
 		}
 		return true
 	})
-	if heatmapBound {
-		if p.heatmap == nil {
-			return fmt.Errorf("specified filters require a --heatmap")
+	if needHeatmap && p.heatmap == nil {
+		return fmt.Errorf("specified filters require a --heatmap")
+	}
+
+	needPkgRefs := false
+	filters.Walk(expr, func(e *filters.Expr) bool {
+		if e.Op == opVarPkgRefs {
+			needPkgRefs = true
 		}
-		filenameSet := make(map[string]struct{})
-		for _, filename := range p.heatmap.CollectFilenames() {
-			filenameSet[filepath.Base(filename)] = struct{}{}
+		return true
+	})
+	if needPkgRefs {
+		// The index is built once, so it would get stale after the files are changed.
+		if p.args.watch {
+			return fmt.Errorf("can't use PkgRefs() together with -watch")
+		}
+		if p.pkgRefs == nil {
+			p.pkgRefs = &pkgRefsIndex{dirs: make(map[string]map[string]int)}
 		}
-		p.heatmapFilenameSet = filenameSet
 	}
 
 	return nil
 }
 
-func (p *program) compilePattern() error {
-	fset := token.NewFileSet()
+func (p *program) compileNotInPatterns() error {
+	for _, src := range p.args.notIn {
+		m, err := p.compileScopePattern(src)
+		if err != nil {
+			return fmt.Errorf("not-in %s: %v", src, err)
+		}
+		p.notIn = append(p.notIn, m)
+	}
+	for _, src := range p.args.mask {
+		m, err := p.compileScopePattern(src)
+		if err != nil {
+			return fmt.Errorf("mask %s: %v", src, err)
+		}
+		p.mask = append(p.mask, m)
+	}
+	return nil
+}
+
+func (p *program) compileScopePattern(src string) (*gogrep.Pattern, error) {
 	config := gogrep.CompileConfig{
-		Fset:      fset,
-		Src:       p.args.pattern,
-		Strict:    p.args.strictSyntax,
-		WithTypes: false,
+		Fset:         token.NewFileSet(),
+		Src:          src,
+		Strict:       p.args.strictSyntax,
+		IgnoreParens: !p.args.strictSyntax,
 	}
 	m, _, err := gogrep.Compile(config)
-	if err != nil {
-		return err
+	return m, err
+}
+
+func (p *program) compilePatterns() error {
+	for _, r := range p.rules {
+		if p.args.fileQuery || p.args.commentQuery || p.args.importAliases || p.args.receiverNames || p.args.blankImports {
+			break
+		}
+		fset := token.NewFileSet()
+		config := gogrep.CompileConfig{
+			Fset:          fset,
+			Src:           r.pattern,
+			Strict:        p.args.strictSyntax,
+			IgnoreParens:  !p.args.strictSyntax,
+			WithTypes:     false,
+			TopLevelDecls: p.args.decls,
+		}
+		m, info, err := gogrep.Compile(config)
+		if err != nil {
+			return withRuleLocation(r, err)
+		}
+		if p.args.report != "" {
+			if _, ok := info.Vars[p.args.report]; !ok {
+				return withRuleLocation(r, fmt.Errorf("report: pattern has no $%s capture", p.args.report))
+			}
+		}
+		if p.args.distinct != "" {
+			if _, ok := info.Vars[p.args.distinct]; !ok {
+				return withRuleLocation(r, fmt.Errorf("distinct: pattern has no $%s capture", p.args.distinct))
+			}
+		}
+		if p.args.rewrite != "" {
+			if err := checkRewriteVars(p.args.rewrite, info); err != nil {
+				return withRuleLocation(r, err)
+			}
+		}
+		r.m = m
+		r.rootKind = m.RootKind()
+		if p.args.fast {
+			for _, name := range m.RequiredIdents() {
+				r.requiredIdents = append(r.requiredIdents, []byte(name))
+			}
+		}
 	}
 
 	workDir, err := os.Getwd()
@@ -398,34 +1206,127 @@ func (p *program) compilePattern() error {
 	}
 	p.workDir = workDir
 
-	deps, err := inspectFormatDeps(p.args.format)
-	if err != nil {
-		return err
+	var deps formatDeps
+	switch p.args.format {
+	case sarifFormat, ctagsFormat:
+		// No extra data needed.
+	case jsonFormat:
+		deps.capture = true
+	default:
+		deps, err = inspectFormatDeps(p.args.format)
+		if err != nil {
+			return err
+		}
 	}
-	needCapture := deps.capture
+	// Clone keys are computed with the captured nodes normalized.
+	// The rewrite templates are expanded with the captures text.
+	needCapture := deps.capture || p.args.clones || p.args.rewrite != ""
 	needMatchLine := deps.matchLine
 
 	p.workers = make([]*worker, p.args.workers)
 	for i := range p.workers {
+		patterns := make([]*gogrep.Pattern, len(p.rules))
+		for j, r := range p.rules {
+			if r.m != nil {
+				patterns[j] = r.m.Clone()
+			}
+		}
+		notIn := make([]*gogrep.Pattern, len(p.notIn))
+		for j, m := range p.notIn {
+			notIn[j] = m.Clone()
+		}
+		mask := make([]*gogrep.Pattern, len(p.mask))
+		for j, m := range p.mask {
+			mask[j] = m.Clone()
+		}
 		p.workers[i] = &worker{
-			needCapture:   needCapture,
-			needMatchLine: needMatchLine,
-			countMode:     p.args.countMode,
+			needCapture:        needCapture,
+			needMatchLine:      needMatchLine,
+			countMode:          p.args.countMode,
+			dryRun:             p.args.dryRun,
+			testMode:           p.args.testMode,
+			fileQuery:          p.args.fileQuery,
+			commentQuery:       p.args.commentQuery,
+			contextFunc:        p.args.contextFunc,
+			report:             p.args.report,
+			distinct:           p.args.distinct,
+			countByLine:        p.args.countBy == "line",
+			clones:             p.args.clones,
+			importAliases:      p.args.importAliases,
+			receiverNames:      p.args.receiverNames,
+			blankImports:       p.args.blankImports,
+			ctags:              p.args.format == ctagsFormat,
+			needFingerprint:    p.baseline != nil || p.args.writeBaseline != "",
+			baseline:           p.baseline,
+			baselineDir:        p.baselineDir,
+			invertKind:         p.invertKind,
+			sinks:              p.sinks,
+			nfc:                p.args.nfc,
+			lang:               p.lang,
+			checkBuildTags:     !p.args.includeIgnored,
+			buildTags:          parseBuildTags(p.args.buildTags),
+			keepScope:          p.keepScope,
+			keepLast:           p.keepLast,
+			firstRuleWins:      p.args.ruleMode == "first",
+			mergeOverlapping:   p.args.mergeOverlapping,
+			notIn:              notIn,
+			notInState:         gogrep.NewMatcherState(),
+			mask:               mask,
+			lineRanges:         p.lineRanges,
+			filterPatternState: gogrep.NewMatcherState(),
+			files:              fileReader{mmap: p.args.mmap},
+			maxFileSize:        p.args.maxFileSize,
 
 			workDir:            workDir,
 			heatmap:            p.heatmap,
+			pkgRefs:            p.pkgRefs,
 			heatmapFilenameSet: p.heatmapFilenameSet,
-			filterHints:        p.filterHints,
-			filterInfo:         &p.filterInfo,
-			filterExpr:         p.filterExpr,
 			id:                 i,
-			m:                  m.Clone(),
+			rules:              p.rules,
+			patterns:           patterns,
 		}
 	}
 
 	return nil
 }
 
+func withRuleLocation(r *rule, err error) error {
+	switch {
+	case r.file != "":
+		return &locatedError{filename: r.file, line: r.line, err: err}
+	case r.label != "":
+		return fmt.Errorf("%s: %v", r.label, err)
+	default:
+		return err
+	}
+}
+
+func (p *program) validateScopeFlags() error {
+	switch {
+	case p.args.firstPer != "" && p.args.lastPer != "":
+		return fmt.Errorf("can't use -first-per together with -last-per")
+	case p.args.countMode:
+		return fmt.Errorf("can't use -c together with -first-per or -last-per")
+	case p.args.fileQuery || p.args.clones || p.args.importAliases:
+		return fmt.Errorf("can't use -file-query, -clones or -import-aliases together with -first-per or -last-per")
+	}
+	if p.args.lastPer != "" {
+		scope, err := parseMatchScope(p.args.lastPer)
+		if err != nil {
+			return fmt.Errorf("last-per: %v", err)
+		}
+		p.keepScope = scope
+		p.keepLast = true
+		return nil
+	}
+	scope, err := parseMatchScope(p.args.firstPer)
+	if err != nil {
+		return fmt.Errorf("first-per: %v", err)
+	}
+	p.keepScope = scope
+	return nil
+}
+
 func (p *program) compileExcludePattern() error {
 	if p.args.exclude == "" {
 		return nil
@@ -439,9 +1340,22 @@ func (p *program) compileExcludePattern() error {
 }
 
 func (p *program) compileOutputFormat() error {
+	switch p.args.format {
+	case sarifFormat, jsonFormat, ctagsFormat:
+		return nil
+	}
 	format := p.args.format
+	if p.args.fileQuery && format == defaultFormat {
+		format = fileQueryFormat
+	}
+	if p.args.groupByFile && format == defaultFormat {
+		format = groupedFormat
+	}
+	if p.args.matchIDs && p.args.format == defaultFormat {
+		format = matchIDFormat + format
+	}
 	tmpl := template.New("output-format")
-	if format != defaultFormat {
+	if p.args.format != defaultFormat {
 		tmpl.Funcs(outputFormatTemplateFuncs())
 	}
 	var err error
@@ -452,23 +1366,54 @@ func (p *program) compileOutputFormat() error {
 	return nil
 }
 
-func (p *program) executePattern() error {
-	filenameQueue := make(chan string)
+func (p *program) executePattern() (err error) {
+	p.ctx = context.Background()
+	if p.args.timeout != 0 {
+		ctx, cancel := context.WithTimeout(p.ctx, p.args.timeout)
+		defer cancel()
+		p.ctx = ctx
+		for _, w := range p.workers {
+			w.ctx = ctx
+		}
+	}
+
+	fileQueue := make(chan fileTask)
 	ticker := time.NewTicker(time.Second)
 
+	var results chan fileResult
+	streamDone := make(chan error, 1)
+	if p.streamOutput() {
+		p.printer = p.newMatchPrinter()
+		results = make(chan fileResult, len(p.workers))
+		go func() {
+			streamDone <- streamMatches(results, p.printer.printFile)
+		}()
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(len(p.workers))
 	defer func() {
-		close(filenameQueue)
+		close(fileQueue)
 		ticker.Stop()
 		wg.Wait()
+		if results != nil {
+			close(results)
+			if streamErr := <-streamDone; err == nil {
+				err = streamErr
+			}
+		}
 		if p.args.progressMode == "update" {
 			// Clear the line so the progress text doesn't clutter the following output.
 			os.Stderr.WriteString("\r\033[K")
 		}
 		for _, w := range p.workers {
-			for _, err := range w.errors {
-				log.Print(err)
+			for _, e := range w.errors {
+				p.printFileError(e)
+			}
+		}
+		for _, w := range p.workers {
+			for _, e := range w.warnings {
+				p.printFileWarning(e)
 			}
 		}
 	}()
@@ -477,33 +1422,35 @@ func (p *program) executePattern() error {
 		go func(w *worker) {
 			defer wg.Done()
 
-			for filename := range filenameQueue {
+			for task := range fileQueue {
+				filename := task.filename
 				if p.args.verbose {
 					log.Printf("debug: worker#%d greps %q file", w.id, filename)
 				}
 
 				numMatches, err := w.grepFile(filename)
+				if err == nil && numMatches != 0 {
+					atomic.AddUint64(&p.numMatches, uint64(numMatches))
+				}
+				if results != nil {
+					results <- fileResult{id: task.id, matches: w.takeFileMatches()}
+				}
 				if err != nil {
-					msg := fmt.Sprintf("error: execute pattern: %s: %v", filename, err)
+					w.stats.filesFailed++
+					e := fileError{filename: filename, err: err}
 					if p.args.progressMode == "update" {
-						w.errors = append(w.errors, msg)
+						w.errors = append(w.errors, e)
 					} else {
-						log.Print(msg)
+						p.printFileError(e)
 					}
-					continue
-				}
-				if numMatches == 0 {
-					continue
 				}
-
-				atomic.AddUint64(&p.numMatches, uint64(numMatches))
 			}
 		}(w)
 	}
 
 	for _, target := range strings.Split(p.args.targets, ",") {
 		target = strings.TrimSpace(target)
-		if err := p.walkTarget(target, filenameQueue, ticker); err != nil {
+		if err := p.walkTarget(target, fileQueue, ticker); err != nil {
 			return err
 		}
 	}
@@ -511,15 +1458,22 @@ func (p *program) executePattern() error {
 	return nil
 }
 
-func (p *program) walkTarget(target string, filenameQueue chan<- string, ticker *time.Ticker) error {
+func (p *program) walkTarget(target string, fileQueue chan<- fileTask, ticker *time.Ticker) error {
 	filesProcessed := 0
 	err := filepath.WalkDir(target, func(path string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// In -clones, -import-aliases, -receiver-names and -blank-imports modes, all matches are needed to find the groups.
+		// The same goes for the -distinct values and the -count-by counters.
 		numMatches := atomic.LoadUint64(&p.numMatches)
-		if numMatches > p.args.limit {
+		needAllMatches := p.args.clones || p.args.importAliases || p.args.receiverNames || p.args.blankImports ||
+			p.args.distinct != "" || p.args.countBy != ""
+		if numMatches > p.args.limit && !needAllMatches {
+			return io.EOF
+		}
+		if p.ctx.Err() != nil {
 			return io.EOF
 		}
 
@@ -527,9 +1481,13 @@ func (p *program) walkTarget(target string, filenameQueue chan<- string, ticker
 			fullName := filepathAbs(p.workDir, path)
 			skip := p.exclude.MatchString(fullName)
 			if skip && info.IsDir() {
+				p.stats.dirsExcluded++
 				return filepath.SkipDir
 			}
 			if skip {
+				if isGoFilename(info.Name()) {
+					p.stats.filesSkipped[skipExclude]++
+				}
 				return nil
 			}
 		}
@@ -543,9 +1501,12 @@ func (p *program) walkTarget(target string, filenameQueue chan<- string, ticker
 
 		for {
 			select {
-			case filenameQueue <- path:
+			case fileQueue <- fileTask{id: p.filesQueued, filename: path}:
+				p.filesQueued++
 				filesProcessed++
 				return nil
+			case <-p.ctx.Done():
+				return io.EOF
 			case <-ticker.C:
 				switch p.args.progressMode {
 				case "append":
@@ -566,25 +1527,67 @@ func (p *program) walkTarget(target string, filenameQueue chan<- string, ticker
 }
 
 func (p *program) printMatches() error {
+	if p.args.dryRun {
+		return p.printDryRunFiles()
+	}
+	if p.args.testMode {
+		return p.printTestResults()
+	}
+	if p.args.distinct != "" {
+		return p.printDistinctValues()
+	}
+	if p.args.countBy != "" {
+		return p.printLineCounts()
+	}
 	if p.args.countMode {
-		log.Printf("found %d matches", p.numMatches)
+		p.printSummary("found %d matches", p.numMatches)
 		return nil
 	}
+	if p.args.writeBaseline != "" {
+		// Matches are written to the baseline file instead.
+		return nil
+	}
+	if p.args.clones {
+		return p.printCloneGroups()
+	}
+	if p.args.importAliases {
+		return p.printImportAliases()
+	}
+	if p.args.receiverNames {
+		return p.printReceiverNames()
+	}
+	if p.args.blankImports {
+		return p.printBlankImports()
+	}
+	if p.args.rewrite != "" {
+		return p.applyRewrites()
+	}
 
-	printed := uint64(0)
-	for _, w := range p.workers {
-		for _, m := range w.matches {
-			if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
-				return err
+	mp := p.printer
+	if mp == nil {
+		// Sorting makes the output order independent of the workers scheduling.
+		// In -watch mode, it also keeps the re-scanned files matches in place.
+		mp = p.newMatchPrinter()
+		for _, m := range p.sortedMatches() {
+			if mp.limitReached() {
+				break
 			}
-			printed++
-			if printed >= p.args.limit {
-				log.Printf("results limited to %d matches", p.args.limit)
-				return nil
+			if err := mp.print(m); err != nil {
+				return err
 			}
 		}
 	}
-	log.Printf("found %d matches", printed)
+	if p.args.format == sarifFormat {
+		if err := p.printSarifReport(mp.sarifResults); err != nil {
+			return err
+		}
+	}
+	if p.args.format == ctagsFormat {
+		if err := p.printCtags(mp.ctagsEntries); err != nil {
+			return err
+		}
+	}
+	mp.printSummary()
 	return nil
 }
 
@@ -624,10 +1627,42 @@ func printMatch(tmpl *template.Template, wd string, args *arguments, m match) er
 	if err != nil {
 		return err
 	}
+	if args.groupByFile {
+		s = groupIndent + strings.ReplaceAll(s, "\n", "\n"+groupIndent)
+	}
 	fmt.Println(s)
 	return nil
 }
 
+func printMatchContext(wd string, args *arguments, m match) {
+	filename := m.filename
+	if args.abs {
+		filename = filepathAbs(wd, filename)
+	}
+	line := strconv.Itoa(m.contextLine)
+	if !args.noColor {
+		filename = mustColorizeText(filename, args.filenameColor)
+		line = mustColorizeText(line, args.lineColor)
+	}
+	if args.groupByFile {
+		fmt.Printf("%s%s: %s\n", groupIndent, line, m.context)
+		return
+	}
+	fmt.Printf("%s:%s: %s\n", filename, line, m.context)
+}
+
+// printFileHeader prints the -group-by-file group header for the m file.
+func printFileHeader(wd string, args *arguments, m match) {
+	filename := m.filename
+	if args.abs {
+		filename = filepathAbs(wd, filename)
+	}
+	if !args.noColor {
+		filename = mustColorizeText(filename, args.filenameColor)
+	}
+	fmt.Println(filename)
+}
+
 type renderConfig struct {
 	wd          string
 	tmpl        *template.Template
@@ -652,10 +1687,7 @@ func renderTemplate(m match, config renderConfig) (string, error) {
 			// Since we don't have file contents at this point, we can't
 			// do a simple contents[StartPos:EndPos].
 			// But we do know that all submatches located somewhere inside m.text.
-			width := c.endOffset - c.startOffset
-			begin := c.startOffset - m.startOffset
-			end := begin + width
-			data[c.data.Name] = m.text[begin:end]
+			data[c.data.Name] = m.captureText(c)
 		}
 	}
 
@@ -664,6 +1696,15 @@ func renderTemplate(m match, config renderConfig) (string, error) {
 	data["Line"] = m.line
 	data["Match"] = matchText
 	data["MatchLine"] = m.text
+	data["RuleID"] = m.rule.id
+	data["Severity"] = m.rule.severity
+	data["Message"] = m.rule.message
+	data["RuleInfo"] = m.rule.infoPrefix()
+	data["Context"] = m.context
+	data["ID"] = m.id
+	data["Kind"] = m.kind
+	data["Consts"] = formatConsts(m.consts)
+	data["Patterns"] = formatPatterns(m.patterns)
 
 	if config.colors {
 		data["Filename"] = mustColorizeText(filename, config.args.filenameColor)
@@ -1,18 +1,66 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/quasilyte/gogrep"
 )
 
 type match struct {
+	rule *rule
+
+	// patterns are the numbers of all rules that produced this match range
+	// in the -merge-overlapping mode, see mergeOverlappingMatches.
+	patterns []int
+
 	text             string
 	matchStartOffset int
 	matchLength      int
 
 	capture []capturedNode
 
+	// file is set for the -file-query mode matches.
+	file *fileSummary
+
+	// importSpec is set for the -import-aliases and -blank-imports modes import matches.
+	importSpec *importSpecInfo
+
+	// receiver is set for the -receiver-names mode matches.
+	receiver *receiverInfo
+
+	// initCall is set for the -blank-imports mode init function call matches.
+	initCall *initCallInfo
+
+	// fingerprint is only computed if baseline is used.
+	fingerprint string
+
+	// id is the -E mode match number in the output, starting from 1.
+	id int
+
+	// kind is the reported node go/ast type name, like CallExpr.
+	kind string
+
+	// tag is the ctags format match symbol, see matchTag.
+	tag string
+
+	// consts are the constants declared by the matched const decl or spec.
+	consts []matchConst
+
+	// cloneKey is a -clones mode match structural hash.
+	cloneKey string
+
+	// context is an -context-func enclosing function signature (or a package clause).
+	context     string
+	contextLine int
+
+	// scopeOffset is an enclosing function start offset for the -first-per and -last-per modes.
+	scopeOffset int
+
 	filename    string
 	line        int
+	column      int
+	endLine     int
+	endColumn   int
 	startOffset int
 	endOffset   int
 }
@@ -21,4 +69,52 @@ type capturedNode struct {
 	startOffset int
 	endOffset   int
 	data        gogrep.CapturedNode
+
+	// reprinted is set for the captures that can't be sliced from the source,
+	// like the synthesized nodes without valid positions.
+	// Their text is produced by the printer and doesn't preserve the original formatting.
+	reprinted bool
+	text      string
+}
+
+// captureText returns the c source text.
+// All captures are located inside the match, but the match text can include
+// the entire source lines, so the match start offset inside the text is taken into account.
+func (m *match) captureText(c capturedNode) string {
+	if c.reprinted {
+		return c.text
+	}
+	begin := m.matchStartOffset + (c.startOffset - m.startOffset)
+	end := begin + (c.endOffset - c.startOffset)
+	return m.text[begin:end]
+}
+
+// sortedMatches returns all workers matches sorted by their locations,
+// so the result doesn't depend on the workers scheduling.
+func (p *program) sortedMatches() []match {
+	var all []match
+	for _, w := range p.workers {
+		all = append(all, w.matches...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].filename != all[j].filename {
+			return all[i].filename < all[j].filename
+		}
+		return matchBefore(&all[i], &all[j])
+	})
+	return all
+}
+
+// matchBefore reports whether x is reported before y, both matches are from the same file.
+//
+// The matches are ordered by their start position, and the matches that start
+// at the same position are reported outermost-first: `a.b.c()` call goes
+// before its `a.b.c` and `a.b` selectors. The matches with identical positions,
+// like an expression statement and its call, are kept in the walk order,
+// which visits the parent nodes before their children.
+func matchBefore(x, y *match) bool {
+	if x.startOffset != y.startOffset {
+		return x.startOffset < y.startOffset
+	}
+	return x.endOffset > y.endOffset
 }
@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// matchScope is a -first-per and -last-per scope kind.
+type matchScope int
+
+const (
+	scopeNone matchScope = iota
+	scopeFunc
+	scopeFile
+)
+
+func parseMatchScope(s string) (matchScope, error) {
+	switch s {
+	case "func":
+		return scopeFunc, nil
+	case "file":
+		return scopeFile, nil
+	default:
+		return scopeNone, fmt.Errorf("expected func or file, found %q", s)
+	}
+}
+
+// fileScopeOffset is a match scopeOffset for the nodes outside of any function.
+const fileScopeOffset = -1
+
+type matchScopeKey struct {
+	rule   *rule
+	offset int
+}
+
+// keepScopeMatches leaves only the first (or the last, for -last-per) match
+// of every rule inside the scope; from is the current file matches start index.
+//
+// The matches are ordered by their start position, the outermost match wins
+// if several matches start at the same position.
+func (w *worker) keepScopeMatches(from int) {
+	matches := w.matches[from:]
+	scopeKey := func(m *match) matchScopeKey {
+		key := matchScopeKey{rule: m.rule}
+		if w.keepScope == scopeFunc {
+			key.offset = m.scopeOffset
+		}
+		return key
+	}
+
+	best := make(map[matchScopeKey]int)
+	for i := range matches {
+		key := scopeKey(&matches[i])
+		j, ok := best[key]
+		switch {
+		case !ok:
+			best[key] = i
+		case w.keepLast && matches[i].startOffset > matches[j].startOffset:
+			best[key] = i
+		case matchBefore(&matches[i], &matches[j]) && (!w.keepLast || matches[i].startOffset == matches[j].startOffset):
+			best[key] = i
+		}
+	}
+
+	kept := matches[:0]
+	for i := range matches {
+		if best[scopeKey(&matches[i])] == i {
+			kept = append(kept, matches[i])
+		}
+	}
+	w.n -= len(matches) - len(kept)
+	w.matches = w.matches[:from+len(kept)]
+}
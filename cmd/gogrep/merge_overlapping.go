@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func (p *program) validateMergeFlags() error {
+	if !p.args.mergeOverlapping {
+		return nil
+	}
+	switch {
+	case p.args.countMode || p.args.distinct != "" || p.args.clones:
+		return fmt.Errorf("can't use -c, -distinct or -clones together with -merge-overlapping")
+	case p.args.fileQuery || p.args.commentQuery || p.args.importAliases || p.args.receiverNames:
+		return fmt.Errorf("can't use -file-query, -comment-query, -import-aliases or -receiver-names together with -merge-overlapping")
+	}
+	return nil
+}
+
+// matchRange is a match position inside of its file.
+type matchRange struct {
+	start int
+	end   int
+}
+
+// mergeOverlappingMatches collapses the file matches with identical byte ranges
+// into the first of them; from is the current file matches start index.
+// The merged match keeps the first match rule and data, the numbers of all
+// rules that produced the same range are recorded in its patterns.
+//
+// Only the identical ranges are merged: a match that encloses another one
+// is reported separately, even if it's the same node for a different pattern.
+func (w *worker) mergeOverlappingMatches(from int) {
+	matches := w.matches[from:]
+	first := make(map[matchRange]int, len(matches))
+	kept := matches[:0]
+	for _, m := range matches {
+		key := matchRange{start: m.startOffset, end: m.endOffset}
+		number := w.ruleNumber(m.rule)
+		i, ok := first[key]
+		if !ok {
+			first[key] = len(kept)
+			m.patterns = []int{number}
+			kept = append(kept, m)
+			continue
+		}
+		merged := &kept[i]
+		if !containsInt(merged.patterns, number) {
+			merged.patterns = append(merged.patterns, number)
+		}
+	}
+	for i := range kept {
+		sort.Ints(kept[i].patterns)
+	}
+	w.n -= len(matches) - len(kept)
+	w.matches = w.matches[:from+len(kept)]
+}
+
+// ruleNumber returns the r rule number, starting from 1 like the -e #N labels.
+func (w *worker) ruleNumber(r *rule) int {
+	for i, x := range w.rules {
+		if x == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func containsInt(list []int, x int) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+// formatPatterns returns the {{.Patterns}} format var value, like `1,3`.
+func formatPatterns(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
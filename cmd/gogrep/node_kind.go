@@ -0,0 +1,38 @@
+package main
+
+import (
+	"go/ast"
+	"reflect"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// nodeKindName returns the n node go/ast type name, like "CallExpr" for *ast.CallExpr.
+// The node slices are named after their element type, like "[]Expr" for a $*args capture.
+// An empty string is returned for a nil node.
+func nodeKindName(n ast.Node) string {
+	if slice, ok := n.(*gogrep.NodeSlice); ok {
+		switch slice.Kind {
+		case gogrep.ExprNodeSlice:
+			return "[]Expr"
+		case gogrep.StmtNodeSlice:
+			return "[]Stmt"
+		case gogrep.FieldNodeSlice:
+			return "[]Field"
+		case gogrep.IdentNodeSlice:
+			return "[]Ident"
+		case gogrep.SpecNodeSlice:
+			return "[]Spec"
+		default:
+			return "[]Decl"
+		}
+	}
+	if n == nil {
+		return ""
+	}
+	typ := reflect.TypeOf(n)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// isImportedPkgName reports whether the n identifier refers to one of the file imports,
+// like fmt in fmt.Println(x).
+//
+// There is no types info, so this is a name-based heuristic: the identifier
+// should be equal to an import name and it shouldn't be shadowed by a local declaration.
+// Just like with the shadowing checks, only the current file declarations are
+// taken into account and the unaliased import names are derived from their paths.
+func (ctx *filterContext) isImportedPkgName(varname string, n ast.Node) bool {
+	id, ok := n.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return false
+	}
+	if len(ctx.w.ancestors) == 0 {
+		return false
+	}
+	file, ok := ctx.w.ancestors[0].(*ast.File)
+	if !ok || !fileImportsName(file, id.Name) {
+		return false
+	}
+
+	isPkg := true
+	child := n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		if sel, ok := parent.(*ast.SelectorExpr); ok && sel.Sel == child {
+			// A field or a method name, like in x.fmt.
+			isPkg = false
+			return false
+		}
+		if _, ok := parent.(*ast.File); ok {
+			// The file-level declarations can't collide with the package names.
+			return false
+		}
+		names, _ := scopeNamesBefore(parent, child)
+		child = parent
+		for _, name := range names {
+			if name == id.Name {
+				isPkg = false
+				return false
+			}
+		}
+		return true
+	})
+	return isPkg
+}
+
+// fileImportsName reports whether f has an import with the specified package name.
+func fileImportsName(f *ast.File, name string) bool {
+	for _, imp := range f.Imports {
+		if importName(imp) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// importName returns the name the imp package is referenced by inside of the file.
+// An empty string is returned for the dot imports and the invalid import paths.
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		if imp.Name.Name == "." {
+			return ""
+		}
+		return imp.Name.Name
+	}
+	path, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return pkgNameFromPath(path)
+}
+
+// pkgNameFromPath guesses the package name by its import path.
+// The major version suffixes are skipped, so both gopkg.in/yaml.v3
+// and github.com/org/yaml/v3 are named yaml.
+func pkgNameFromPath(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+	if i := strings.LastIndex(name, ".v"); i != -1 && isMajorVersion(name[i+1:]) {
+		name = name[:i]
+	}
+	return name
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
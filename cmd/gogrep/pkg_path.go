@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pkgPathInfo is a resolved directory package import path.
+type pkgPathInfo struct {
+	path string
+
+	// ok is false if the directory is outside of any module and GOPATH,
+	// so its package path is unknown.
+	ok bool
+}
+
+// filePkgPath returns the import path of the package that contains the filename file.
+// The path is computed from the nearest go.mod module path and the file directory,
+// the GOPATH layout is used for the files outside of any module.
+// An empty string is returned if the package path can't be resolved.
+func (w *worker) filePkgPath(filename string) string {
+	dir := filepath.Dir(filepathAbs(w.workDir, filename))
+	return w.resolveDirPkgPath(dir).path
+}
+
+// resolveDirPkgPath returns the dir package path, every visited directory
+// result is cached, so the go.mod lookups are shared by the nested packages.
+func (w *worker) resolveDirPkgPath(dir string) pkgPathInfo {
+	if info, ok := w.pkgPaths[dir]; ok {
+		return info
+	}
+	if w.pkgPaths == nil {
+		w.pkgPaths = make(map[string]pkgPathInfo)
+	}
+
+	var info pkgPathInfo
+	if modPath := readModulePath(filepath.Join(dir, "go.mod")); modPath != "" {
+		info = pkgPathInfo{path: modPath, ok: true}
+	} else if isGopathSrc(dir) {
+		info = pkgPathInfo{path: "", ok: true}
+	} else if parent := filepath.Dir(dir); parent != dir {
+		info = w.resolveDirPkgPath(parent)
+		if info.ok {
+			info.path = joinPkgPath(info.path, filepath.Base(dir))
+		}
+	}
+	w.pkgPaths[dir] = info
+	return info
+}
+
+func joinPkgPath(parent, elem string) string {
+	if parent == "" {
+		return elem
+	}
+	return parent + "/" + elem
+}
+
+func isGopathSrc(dir string) bool {
+	for _, root := range filepath.SplitList(build.Default.GOPATH) {
+		if root != "" && dir == filepath.Join(root, "src") {
+			return true
+		}
+	}
+	return false
+}
+
+// readModulePath returns the module path declared in the go.mod file.
+// An empty string is returned if the file can't be read or has no module directive.
+func readModulePath(filename string) string {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		modPath := fields[1]
+		if unquoted, err := strconv.Unquote(modPath); err == nil {
+			modPath = unquoted
+		}
+		return modPath
+	}
+	return ""
+}
+
+// matchPkgPattern reports whether the path package import path is matched
+// by the pattern, which is using the `go list` syntax: `...` matches any string,
+// so `example.com/proj/internal/...` matches the internal package and its subpackages.
+func matchPkgPattern(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "/...") && matchPkgGlob(strings.TrimSuffix(pattern, "/..."), path) {
+		return true
+	}
+	return matchPkgGlob(pattern, path)
+}
+
+func matchPkgGlob(pattern, path string) bool {
+	parts := strings.Split(pattern, "...")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i == -1 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchPkgPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{`example.com/proj`, `example.com/proj`, true},
+		{`example.com/proj`, `example.com/proj/internal`, false},
+		{`example.com/proj/...`, `example.com/proj`, true},
+		{`example.com/proj/...`, `example.com/proj/internal/db`, true},
+		{`example.com/proj/...`, `example.com/project`, false},
+		{`example.com/proj...`, `example.com/project`, true},
+		{`.../internal/...`, `example.com/proj/internal`, true},
+		{`.../internal/...`, `example.com/proj/internal/db`, true},
+		{`.../internal/...`, `example.com/proj/internals`, false},
+		{`.../internal`, `example.com/proj/internal`, true},
+		{`.../internal`, `example.com/proj/internal/db`, false},
+		{`example.com/.../db`, `example.com/proj/internal/db`, true},
+		{`example.com/.../db`, `example.com/db`, false},
+		{`...`, `example.com/proj`, true},
+		{`a...b...c`, `abc`, true},
+		{`a...b...c`, `acb`, false},
+		{`a...bc...c`, `abcc`, true},
+	}
+
+	for _, test := range tests {
+		have := matchPkgPattern(test.pattern, test.path)
+		if have != test.want {
+			t.Errorf("match(%q, %q):\nhave: %v\nwant: %v", test.pattern, test.path, have, test.want)
+		}
+	}
+}
+
+func TestFilePkgPath(t *testing.T) {
+	files := map[string]string{
+		"proj/go.mod":            "module example.com/proj // comment\n\ngo 1.16\n",
+		"proj/internal/db/db.go": "package db",
+		"proj/main.go":           "package main",
+		"proj/tools/go.mod":      "// The nested module.\nmodule \"example.com/proj/tools\"\n",
+		"proj/tools/gen/gen.go":  "package gen",
+		"proj/broken/go.mod":     "go 1.16\n",
+		"proj/broken/x.go":       "package x",
+		"nomod/a.go":             "package a",
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"proj/main.go", "example.com/proj"},
+		{"proj/internal/db/db.go", "example.com/proj/internal/db"},
+		{"proj/tools/gen/gen.go", "example.com/proj/tools/gen"},
+		// A go.mod without a module directive is ignored.
+		{"proj/broken/x.go", "example.com/proj/broken"},
+		{"nomod/a.go", ""},
+	}
+
+	w := &worker{workDir: dir}
+	for _, test := range tests {
+		have := w.filePkgPath(filepath.FromSlash(test.filename))
+		if have != test.want {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.filename, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// pkgRefsIndex is a package-scoped identifiers index, it's built before the search
+// if any filter uses PkgRefs(), so the filters can look beyond the matched file.
+//
+// The packages are identified by their directories: the external _test package
+// files are a part of the package, as they're the usual users of its API.
+type pkgRefsIndex struct {
+	// dirs maps a package directory absolute path to its identifiers counts.
+	dirs map[string]map[string]int
+}
+
+// count returns the number of the name identifiers inside of the dir package files.
+func (idx *pkgRefsIndex) count(dir, name string) int {
+	return idx.dirs[dir][name]
+}
+
+// indexPackages builds the p.pkgRefs index for every package directory
+// that contains a target file. All package files are indexed, even if
+// the target is a single file, but the excluded directories are skipped.
+func (p *program) indexPackages() error {
+	if p.pkgRefs == nil {
+		return nil
+	}
+
+	dirSet := make(map[string]struct{})
+	for _, target := range strings.Split(p.args.targets, ",") {
+		target = strings.TrimSpace(target)
+		err := filepath.WalkDir(target, func(path string, info fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p.exclude != nil && p.exclude.MatchString(filepathAbs(p.workDir, path)) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.IsDir() && isGoFilename(info.Name()) {
+				dirSet[filepath.Dir(filepathAbs(p.workDir, path))] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	dirs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(len(p.workers))
+	for range p.workers {
+		go func() {
+			defer wg.Done()
+			for dir := range dirs {
+				refs := indexPackageDir(dir)
+				mu.Lock()
+				p.pkgRefs.dirs[dir] = refs
+				mu.Unlock()
+			}
+		}()
+	}
+	for dir := range dirSet {
+		dirs <- dir
+	}
+	close(dirs)
+	wg.Wait()
+	return nil
+}
+
+// indexPackageDir returns the identifiers counts of the dir Go files.
+// The files that can't be parsed are skipped, they're reported by the search itself.
+func indexPackageDir(dir string) map[string]int {
+	refs := make(map[string]int)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return refs
+	}
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !isGoFilename(entry.Name()) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		countFileIdents(f, refs)
+	}
+	return refs
+}
+
+// countFileIdents adds the f identifiers to refs, the package clause name is not counted.
+// The selector names, like f in x.f, are counted too: the methods and fields are referenced by them.
+func countFileIdents(f *ast.File, refs map[string]int) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident != f.Name && ident.Name != "_" {
+			refs[ident.Name]++
+		}
+		return true
+	})
+}
+
+// pkgRefs returns the number of the other identifiers with the n name
+// in the n package files, n itself is not counted.
+// n is either an identifier or a named declaration, see declName.
+// Returns -1 if n has no name.
+func (ctx *filterContext) pkgRefs(n ast.Node) int {
+	name := declName(n)
+	if name == "" {
+		return -1
+	}
+	dir := filepath.Dir(filepathAbs(ctx.w.workDir, ctx.w.filename))
+	return ctx.w.pkgRefs.count(dir, name) - 1
+}
+
+// isExported reports whether n is an exported identifier or a named declaration with an exported name.
+func isExported(n ast.Node) bool {
+	name := declName(n)
+	return name != "" && ast.IsExported(name)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/typeparams"
+)
+
+// receiverInfo is a -receiver-names mode match data.
+type receiverInfo struct {
+	// typeKey identifies the receiver type: the types with the same name
+	// that are declared in different packages are different types.
+	typeKey string
+
+	// typeName is a package-qualified type name, like pkg.T.
+	typeName string
+
+	name string
+}
+
+// receiverNameGroup is a type which methods use several different receiver names.
+type receiverNameGroup struct {
+	typeName string
+	names    []string
+	matches  []match
+}
+
+type jsonReceiverNameGroup struct {
+	Type    string      `json:"type"`
+	Names   []string    `json:"names"`
+	Matches []jsonMatch `json:"matches"`
+}
+
+// collectReceivers reports every named method receiver as a match.
+// The unnamed and blank receivers are not reported: they're not
+// referenced inside the method, so there is no name to be consistent with.
+func (w *worker) collectReceivers(root *ast.File) {
+	// The external test package is a different package, even if it's located in the same dir.
+	pkgKey := filepath.Dir(w.filename) + ":" + root.Name.Name
+	for _, i := range w.activeRules {
+		r := w.rules[i]
+		for _, decl := range root.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+				continue
+			}
+			recv := fn.Recv.List[0]
+			if len(recv.Names) != 1 || recv.Names[0].Name == "_" {
+				continue
+			}
+			typeName := receiverTypeName(recv.Type)
+			if typeName == "" {
+				continue
+			}
+			numMatches := len(w.matches)
+			w.addMatch(r, recv, nil)
+			if len(w.matches) != numMatches {
+				w.matches[numMatches].receiver = &receiverInfo{
+					typeKey:  pkgKey + "." + typeName,
+					typeName: root.Name.Name + "." + typeName,
+					name:     recv.Names[0].Name,
+				}
+			}
+		}
+	}
+}
+
+// receiverTypeName returns the receiver base type name, so the pointer and value
+// receivers of the same type are grouped together, like T for both `*T` and `T`.
+// The type params of a generic type are ignored: `*List[T]` is a List receiver.
+func receiverTypeName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.StarExpr:
+		return receiverTypeName(e.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(e.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(e.X)
+	case *typeparams.IndexListExpr:
+		return receiverTypeName(e.X)
+	default:
+		return ""
+	}
+}
+
+// methodUsesReceiver reports whether the fn method body references its receiver.
+//
+// The receiver is detected by its name, so the references to a shadowing
+// variable with the same name are counted as receiver uses too.
+// The field selectors, like `x` in `v.x`, are not references.
+// The unnamed and blank receivers can't be referenced at all.
+func methodUsesReceiver(fn *ast.FuncDecl) bool {
+	if fn.Body == nil || fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return false
+	}
+	recv := fn.Recv.List[0]
+	if len(recv.Names) != 1 || recv.Names[0].Name == "_" {
+		return false
+	}
+	return referencesName(fn.Body, recv.Names[0].Name)
+}
+
+// referencesName reports whether any identifier inside of root has the specified name.
+// The selector names, like f in x.f, are not references.
+func referencesName(root ast.Node, name string) bool {
+	found := false
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.Ident:
+			found = n.Name == name
+		}
+		return !found
+	}
+	ast.Inspect(root, visit)
+	return found
+}
+
+// collectReceiverNameGroups returns the types which methods use more than one receiver name.
+// The groups are sorted by their first match location.
+func (p *program) collectReceiverNameGroups() []receiverNameGroup {
+	groupByType := make(map[string]int)
+	var groups []receiverNameGroup
+	for _, m := range p.sortedMatches() {
+		i, ok := groupByType[m.receiver.typeKey]
+		if !ok {
+			i = len(groups)
+			groupByType[m.receiver.typeKey] = i
+			groups = append(groups, receiverNameGroup{typeName: m.receiver.typeName})
+		}
+		groups[i].matches = append(groups[i].matches, m)
+	}
+
+	result := groups[:0]
+	for _, g := range groups {
+		nameSet := make(map[string]struct{})
+		for _, m := range g.matches {
+			nameSet[m.receiver.name] = struct{}{}
+		}
+		if len(nameSet) < 2 {
+			continue
+		}
+		for name := range nameSet {
+			g.names = append(g.names, name)
+		}
+		sort.Strings(g.names)
+		result = append(result, g)
+	}
+	return result
+}
+
+func (p *program) printReceiverNames() error {
+	groups := p.collectReceiverNameGroups()
+
+	// Only the inconsistent receivers are reported, so the exit status should depend on them.
+	p.numMatches = 0
+	for _, g := range groups {
+		p.numMatches += uint64(len(g.matches))
+	}
+
+	var enc *json.Encoder
+	if p.args.format == jsonFormat {
+		enc = json.NewEncoder(os.Stdout)
+	}
+
+	printed := uint64(0)
+	for i, g := range groups {
+		if printed >= p.args.limit {
+			p.printSummary("results limited to %d matches", p.args.limit)
+			return nil
+		}
+		if enc != nil {
+			jsonGroup := jsonReceiverNameGroup{Type: g.typeName, Names: g.names}
+			for _, m := range g.matches {
+				jsonGroup.Matches = append(jsonGroup.Matches, p.newJSONMatch(m))
+			}
+			if err := enc.Encode(jsonGroup); err != nil {
+				return err
+			}
+			printed += uint64(len(g.matches))
+			continue
+		}
+
+		if i != 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s methods use %d receiver names: %s\n", g.typeName, len(g.names), strings.Join(g.names, ", "))
+		for _, m := range g.matches {
+			if err := printMatch(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+				return err
+			}
+			printed++
+		}
+	}
+	p.printSummary("found %d types with inconsistent receiver names", len(groups))
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// reprintNode returns the gofmt-formatted n text.
+//
+// It's used for the nodes that can't be sliced from the source,
+// like the synthesized nodes without valid positions.
+// The go/printer doesn't know about gogrep-specific nodes and can't
+// print a field or a field list on its own, so these are handled here.
+func reprintNode(fset *token.FileSet, n ast.Node) []byte {
+	switch n := n.(type) {
+	case *ast.Comment:
+		return []byte(n.Text)
+	case *gogrep.OperatorNode:
+		return []byte(n.Op.String())
+	case *gogrep.NodeSlice:
+		sep := ", "
+		switch n.Kind {
+		case gogrep.StmtNodeSlice, gogrep.SpecNodeSlice:
+			sep = "; "
+		case gogrep.DeclNodeSlice:
+			sep = "\n\n"
+		}
+		parts := make([]string, n.Len())
+		for i := range parts {
+			parts[i] = string(reprintNode(fset, n.At(i)))
+		}
+		return []byte(strings.Join(parts, sep))
+	case *ast.FieldList:
+		parts := make([]string, len(n.List))
+		for i, field := range n.List {
+			parts[i] = string(reprintNode(fset, field))
+		}
+		return []byte(strings.Join(parts, ", "))
+	case *ast.Field:
+		var buf strings.Builder
+		for i, name := range n.Names {
+			if i != 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(name.Name)
+		}
+		if len(n.Names) != 0 {
+			buf.WriteByte(' ')
+		}
+		buf.Write(reprintNode(fset, n.Type))
+		if n.Tag != nil {
+			buf.WriteByte(' ')
+			buf.WriteString(n.Tag.Value)
+		}
+		return []byte(buf.String())
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		// Some nodes can't be printed outside of their context.
+		// An empty text is better than a failed search.
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// hasSourcePos reports whether n positions can be used to slice its text from the source.
+func hasSourcePos(n ast.Node) bool {
+	return n.Pos().IsValid() && n.End().IsValid()
+}
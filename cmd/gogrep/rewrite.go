@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// rewriteEdit is a -rewrite replacement of the [start, end) file bytes.
+type rewriteEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// fileRewrites are the accepted rewrites of a single file, sorted by their offsets.
+type fileRewrites struct {
+	filename string
+	edits    []rewriteEdit
+}
+
+func (p *program) validateRewriteFlags() error {
+	if p.args.interactive && p.args.rewrite == "" {
+		return fmt.Errorf("-i can only be used together with -rewrite")
+	}
+	if p.args.rewrite == "" {
+		return nil
+	}
+	switch {
+	case p.args.countMode || p.args.listFiles || p.args.dryRun || p.args.writeBaseline != "" || p.args.testMode:
+		return fmt.Errorf("can't use -c, -l, -dry-run, -write-baseline or test mode together with -rewrite")
+	case p.args.distinct != "" || p.args.clones || p.args.importAliases || p.args.receiverNames:
+		return fmt.Errorf("can't use -distinct, -clones, -import-aliases or -receiver-names together with -rewrite")
+	case p.args.fileQuery || p.args.commentQuery || p.args.watch:
+		return fmt.Errorf("can't use -file-query, -comment-query or -watch together with -rewrite")
+	}
+	if p.args.interactive && !isTerminal(os.Stdin) {
+		return fmt.Errorf("-i requires the stdin to be a terminal")
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// checkRewriteVars reports an error if the tmpl uses a var that is not bound by the pattern.
+func checkRewriteVars(tmpl string, info gogrep.PatternInfo) error {
+	var err error
+	expandRewriteVars(tmpl, func(name string) string {
+		if _, ok := info.Vars[name]; !ok && name != "$" && err == nil {
+			err = fmt.Errorf("rewrite: pattern has no $%s capture", name)
+		}
+		return ""
+	})
+	return err
+}
+
+// expandRewriteVars replaces every $x var inside of the tmpl with the value(x) result.
+// The $$ var is passed as a "$" name. A $ that is not followed by a var name is kept as is.
+func expandRewriteVars(tmpl string, value func(name string) string) string {
+	var buf strings.Builder
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '$' {
+			buf.WriteByte(tmpl[i])
+			continue
+		}
+		if strings.HasPrefix(tmpl[i:], "$$") {
+			buf.WriteString(value("$"))
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(tmpl) && isWordByte(tmpl[end]) {
+			end++
+		}
+		if end == i+1 {
+			buf.WriteByte('$')
+			continue
+		}
+		buf.WriteString(value(tmpl[i+1 : end]))
+		i = end - 1
+	}
+	return buf.String()
+}
+
+func isWordByte(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// rewriteText returns the m match replacement, the tmpl vars are replaced with the capture texts.
+func rewriteText(tmpl string, m match) string {
+	return expandRewriteVars(tmpl, func(name string) string {
+		if name == "$" {
+			return matchText(m)
+		}
+		for _, c := range m.capture {
+			if c.data.Name == name {
+				return m.captureText(c)
+			}
+		}
+		return ""
+	})
+}
+
+func (p *program) applyRewrites() error {
+	var prompt *rewritePrompt
+	if p.args.interactive {
+		prompt = &rewritePrompt{
+			in:            bufio.NewReader(os.Stdin),
+			out:           os.Stderr,
+			colors:        !p.args.noColor,
+			filenameColor: p.args.filenameColor,
+		}
+	}
+	files, err := selectRewrites(p.args.rewrite, p.sortedMatches(), prompt)
+	if err != nil {
+		return err
+	}
+	numRewrites := 0
+	for _, f := range files {
+		if err := rewriteFile(f.filename, f.edits); err != nil {
+			return err
+		}
+		numRewrites += len(f.edits)
+	}
+	log.Printf("rewrote %d matches in %d files", numRewrites, len(files))
+	return nil
+}
+
+// selectRewrites returns the rewrites of the sorted matches, grouped by their files.
+// If prompt is not nil, only the rewrites accepted by the user are returned.
+//
+// The nested matches overlap with the enclosing ones, so they're skipped
+// unless the enclosing match rewrite was declined.
+func selectRewrites(tmpl string, matches []match, prompt *rewritePrompt) ([]fileRewrites, error) {
+	var files []fileRewrites
+	for len(matches) != 0 {
+		n := 1
+		for n < len(matches) && matches[n].filename == matches[0].filename {
+			n++
+		}
+		f := fileRewrites{filename: matches[0].filename}
+		lastEnd := -1
+		quit := false
+		for _, m := range matches[:n] {
+			if m.startOffset < lastEnd {
+				continue
+			}
+			edit := rewriteEdit{start: m.startOffset, end: m.endOffset, text: rewriteText(tmpl, m)}
+			if prompt != nil {
+				accept, err := prompt.ask(m, edit)
+				if err == errRewriteQuit {
+					quit = true
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				if !accept {
+					continue
+				}
+			}
+			lastEnd = m.endOffset
+			f.edits = append(f.edits, edit)
+		}
+		if len(f.edits) != 0 {
+			files = append(files, f)
+		}
+		if quit {
+			break
+		}
+		matches = matches[n:]
+	}
+	return files, nil
+}
+
+// rewriteFile applies the edits to the filename file contents.
+// The edits are applied back-to-front, so the offsets of the
+// remaining edits are not affected by the already applied ones.
+// The file is not written if the result is not a valid Go file.
+func rewriteFile(filename string, edits []rewriteEdit) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		if e.end > len(data) {
+			return fmt.Errorf("rewrite %s: the file was modified during the search", filename)
+		}
+		data = append(data[:e.start], append([]byte(e.text), data[e.end:]...)...)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), filename, data, parser.ParseComments); err != nil {
+		return fmt.Errorf("rewrite %s: the result is not a valid Go file: %v", filename, err)
+	}
+	return os.WriteFile(filename, data, info.Mode().Perm())
+}
+
+var errRewriteQuit = errors.New("rewrite: quit")
+
+// rewritePrompt asks the user whether a -rewrite replacement should be applied, in the -i mode.
+type rewritePrompt struct {
+	in            *bufio.Reader
+	out           io.Writer
+	colors        bool
+	filenameColor string
+
+	// all is set after the "a" answer, the remaining rewrites are accepted without asking.
+	all bool
+}
+
+// ask shows the m match rewrite and reads the answer.
+// errRewriteQuit is returned after the "q" answer or when the input is closed.
+func (prompt *rewritePrompt) ask(m match, edit rewriteEdit) (bool, error) {
+	if prompt.all {
+		return true, nil
+	}
+	filename := m.filename
+	if prompt.colors {
+		filename = mustColorizeText(filename, prompt.filenameColor)
+	}
+	fmt.Fprintf(prompt.out, "%s:%d:\n", filename, m.line)
+	prompt.printLines("-", matchText(m), "red")
+	prompt.printLines("+", edit.text, "green")
+	for {
+		fmt.Fprint(prompt.out, "Apply this rewrite [y,n,a,q,?]? ")
+		answer, err := prompt.in.ReadString('\n')
+		if err == io.EOF && answer == "" {
+			fmt.Fprintln(prompt.out)
+			return false, errRewriteQuit
+		}
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y":
+			return true, nil
+		case "n":
+			return false, nil
+		case "a":
+			prompt.all = true
+			return true, nil
+		case "q":
+			return false, errRewriteQuit
+		default:
+			fmt.Fprintln(prompt.out, "y - apply this rewrite")
+			fmt.Fprintln(prompt.out, "n - skip this rewrite")
+			fmt.Fprintln(prompt.out, "a - apply this and all the remaining rewrites")
+			fmt.Fprintln(prompt.out, "q - quit, the already accepted rewrites are applied")
+		}
+	}
+}
+
+func (prompt *rewritePrompt) printLines(prefix, text, color string) {
+	for _, line := range strings.Split(text, "\n") {
+		line = prefix + line
+		if prompt.colors {
+			line = mustColorizeText(line, color)
+		}
+		fmt.Fprintln(prompt.out, line)
+	}
+}
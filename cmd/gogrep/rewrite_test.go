@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandRewriteVars(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{``, ``},
+		{`f()`, `f()`},
+		{`f($x)`, `f(<x>)`},
+		{`$x.$y`, `<x>.<y>`},
+		{`$x_1+$x`, `<x_1>+<x>`},
+		{`wrap($$)`, `wrap(<$>)`},
+		{`"$" + $x`, `"$" + <x>`},
+		{`$`, `$`},
+	}
+
+	for _, test := range tests {
+		have := expandRewriteVars(test.tmpl, func(name string) string {
+			return "<" + name + ">"
+		})
+		if have != test.want {
+			t.Errorf("expand %q:\nhave: %q\nwant: %q", test.tmpl, have, test.want)
+		}
+	}
+}
+
+func TestRewriteFile(t *testing.T) {
+	src := `package p
+func f(xs []int) {
+	_ = len(xs) == 0
+	_ = len(append(xs, len(xs))) == 0
+	_ = len("abc") == 0
+	_ = len(xs[1:]) == 0
+}
+`
+
+	tests := []struct {
+		tmpl    string
+		answers string
+		want    []string
+	}{
+		{
+			tmpl: `isEmpty($x)`,
+			want: []string{
+				`_ = isEmpty(xs)`,
+				`_ = isEmpty(append(xs, len(xs)))`,
+				`_ = isEmpty("abc")`,
+				`_ = isEmpty(xs[1:])`,
+			},
+		},
+
+		{
+			tmpl:    `isEmpty($x)`,
+			answers: "n\ny\nq\n",
+			want: []string{
+				`_ = len(xs) == 0`,
+				`_ = isEmpty(append(xs, len(xs)))`,
+				`_ = len("abc") == 0`,
+				`_ = len(xs[1:]) == 0`,
+			},
+		},
+
+		{
+			tmpl:    `isEmpty($x)`,
+			answers: "?\nY\na\n",
+			want: []string{
+				`_ = isEmpty(xs)`,
+				`_ = isEmpty(append(xs, len(xs)))`,
+				`_ = isEmpty("abc")`,
+				`_ = isEmpty(xs[1:])`,
+			},
+		},
+
+		{
+			tmpl:    `isEmpty($x)`,
+			answers: "y\n",
+			want: []string{
+				`_ = isEmpty(xs)`,
+				`_ = len(append(xs, len(xs))) == 0`,
+				`_ = len("abc") == 0`,
+				`_ = len(xs[1:]) == 0`,
+			},
+		},
+
+		{
+			// The nested match is only rewritten if the enclosing one was declined.
+			tmpl:    `($$ != 1)`,
+			answers: "y\nn\ny\nn\nn\n",
+			want: []string{
+				`_ = (len(xs) != 1) == 0`,
+				`_ = len(append(xs, (len(xs) != 1))) == 0`,
+				`_ = len("abc") == 0`,
+				`_ = len(xs[1:]) == 0`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		pattern := `len($x) == 0`
+		if strings.Contains(test.tmpl, "$$") {
+			pattern = `len($x)`
+		}
+		w := testGrepSource(t, pattern, src, false)
+		filename := filepath.Join(t.TempDir(), "p.go")
+		if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		for i := range w.matches {
+			w.matches[i].filename = filename
+		}
+
+		var prompt *rewritePrompt
+		if test.answers != "" {
+			prompt = &rewritePrompt{in: bufio.NewReader(strings.NewReader(test.answers)), out: io.Discard}
+		}
+		p := &program{workers: []*worker{w}}
+		files, err := selectRewrites(test.tmpl, p.sortedMatches(), prompt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if err := rewriteFile(f.filename, f.edits); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(string(data), "\n")
+		var have []string
+		for _, l := range lines[2 : len(lines)-2] {
+			have = append(have, strings.TrimSpace(l))
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("rewrite %q with %q answers:\nhave: %q\nwant: %q", test.tmpl, test.answers, have, test.want)
+		}
+	}
+}
+
+func TestRewriteFileInvalidResult(t *testing.T) {
+	src := "package p\nvar x = len(y)\n"
+	filename := filepath.Join(t.TempDir(), "p.go")
+	if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := rewriteFile(filename, []rewriteEdit{{start: 18, end: 24, text: "y +"}})
+	if err == nil {
+		t.Fatal("expected an error for the invalid rewrite result")
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != src {
+		t.Errorf("the file is modified after the failed rewrite:\n%s", data)
+	}
+}
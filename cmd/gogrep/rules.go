@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
+)
+
+// rule is a pattern with its (optional) filter and metadata.
+//
+// When gogrep is executed without a rules file, there is only
+// one rule that is created from the command-line arguments.
+type rule struct {
+	id       string
+	severity string
+	message  string
+
+	pattern string
+	filter  string
+
+	// Where this rule was defined, used in error messages.
+	// For the rules file, it's a file name and a line;
+	// for -e patterns it's a label. Empty for the command-line pattern rule.
+	file  string
+	line  int
+	label string
+
+	m *gogrep.Pattern
+
+	// rootKind is the m match root node kind, the nodes of other
+	// kinds are not matched. It's nodetag.Node if it can't be narrowed.
+	rootKind nodetag.Value
+
+	// requiredIdents are the identifiers that are present in every pattern match.
+	// Only collected in -fast mode, files without them are not parsed.
+	requiredIdents [][]byte
+
+	filterHints  filterHints
+	filterInfo   filters.Info
+	filterExpr   *filters.Expr
+	heatmapBound bool
+
+	// valueSets are the `in @filename` filter sets, indexed by their filenames.
+	valueSets map[string]valueSet
+
+	// filterPatterns are the FollowedBy(), Contains() and FuncContains() filter patterns,
+	// indexed by their sources.
+	// They're shared by all workers, the matcher state is worker-local.
+	filterPatterns map[string]*gogrep.Pattern
+
+	// stringRegexps are the StringMatches() filter regexps, indexed by their sources.
+	stringRegexps map[string]*regexp.Regexp
+}
+
+func (r *rule) hasMetadata() bool {
+	return r.id != "" || r.severity != "" || r.message != ""
+}
+
+// infoPrefix returns a text output prefix describing the rule metadata.
+// For rules without metadata, it returns an empty string.
+func (r *rule) infoPrefix() string {
+	var parts []string
+	if r.severity != "" {
+		parts = append(parts, r.severity)
+	}
+	if r.id != "" {
+		parts = append(parts, "["+r.id+"]")
+	}
+	if r.message != "" {
+		parts = append(parts, r.message)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, " ") + ": "
+}
+
+// parseRulesFile reads the rules file.
+//
+// Every non-empty line that doesn't start with # describes a single rule:
+//
+//	pattern
+//	pattern => filter
+//	pattern @ {id: X, severity: warning, message: "..."}
+//	pattern => filter @ {id: X, severity: warning, message: "..."}
+//
+// A line that starts with define describes a macro, a named sub-pattern
+// that can be referenced from the rule patterns as $name:
+//
+//	define $name = pattern
+func parseRulesFile(filename string) ([]*rule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseRules(filename, data)
+}
+
+func parseRules(filename string, data []byte) ([]*rule, error) {
+	var rules []*rule
+	var macroList []*ruleMacro
+	macros := make(map[string]*ruleMacro)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "define ") {
+			m, err := parseMacro(line)
+			if err != nil {
+				return nil, &locatedError{filename: filename, line: lineNum, err: err}
+			}
+			if prev, ok := macros[m.name]; ok {
+				err := fmt.Errorf("define: $%s is already defined at line %d", m.name, prev.line)
+				return nil, &locatedError{filename: filename, line: lineNum, err: err}
+			}
+			m.line = lineNum
+			macros[m.name] = m
+			macroList = append(macroList, m)
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			return nil, &locatedError{filename: filename, line: lineNum, err: err}
+		}
+		r.file = filename
+		r.line = lineNum
+		rules = append(rules, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, &locatedError{filename: filename, err: errors.New("no rules defined")}
+	}
+
+	// Macros can be defined after their uses, so they're expanded after the entire file is parsed.
+	// The unused macros are checked as well, so a recursive definition is always reported.
+	for _, m := range macroList {
+		if _, err := expandMacros(macros, m.body, []string{m.name}); err != nil {
+			return nil, &locatedError{filename: filename, line: m.line, err: err}
+		}
+	}
+	for _, r := range rules {
+		pattern, err := expandMacros(macros, r.pattern, nil)
+		if err != nil {
+			return nil, &locatedError{filename: filename, line: r.line, err: err}
+		}
+		r.pattern = pattern
+	}
+	return rules, nil
+}
+
+func parseRule(line string) (*rule, error) {
+	r := &rule{}
+
+	if strings.HasSuffix(line, "}") {
+		if indexes := unquotedIndexes(line, " @ {"); len(indexes) != 0 {
+			i := indexes[len(indexes)-1]
+			if err := parseRuleMetadata(r, line[i+len(" @ "):]); err != nil {
+				return nil, err
+			}
+			line = strings.TrimSpace(line[:i])
+		}
+	}
+
+	if indexes := unquotedIndexes(line, " => "); len(indexes) != 0 {
+		i := indexes[0]
+		r.filter = strings.TrimSpace(line[i+len(" => "):])
+		line = strings.TrimSpace(line[:i])
+	}
+	r.pattern = line
+	if r.pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	return r, nil
+}
+
+// unquotedIndexes returns the sep occurrences in s that are not
+// a part of the string, raw string or rune literals, like " @ {" in `f(" @ {")`.
+func unquotedIndexes(s, sep string) []int {
+	var indexes []int
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == 0 && (s[i] == '"' || s[i] == '`' || s[i] == '\''):
+			quote = s[i]
+		case quote != 0 && s[i] == '\\' && quote != '`':
+			i++ // Skip the escaped char.
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote == 0 && strings.HasPrefix(s[i:], sep):
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func parseRuleMetadata(r *rule, s string) error {
+	// Metadata has a Go composite literal syntax, so we can use
+	// the Go parser to handle it.
+	e, err := parser.ParseExpr("T" + s)
+	if err != nil {
+		return fmt.Errorf("parse metadata: %v", err)
+	}
+	lit, ok := e.(*ast.CompositeLit)
+	if !ok {
+		return fmt.Errorf("parse metadata: expected {key: value, ...}")
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			return fmt.Errorf("metadata: expected a key: value pair")
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			return fmt.Errorf("metadata: key should be an identifier")
+		}
+		var value string
+		switch v := kv.Value.(type) {
+		case *ast.Ident:
+			value = v.Name
+		case *ast.BasicLit:
+			if v.Kind != token.STRING {
+				return fmt.Errorf("metadata: %s: expected a string or identifier", key.Name)
+			}
+			value, err = strconv.Unquote(v.Value)
+			if err != nil {
+				return fmt.Errorf("metadata: %s: %v", key.Name, err)
+			}
+		default:
+			return fmt.Errorf("metadata: %s: expected a string or identifier", key.Name)
+		}
+		switch key.Name {
+		case "id":
+			r.id = value
+		case "severity":
+			switch value {
+			case "error", "warning", "note":
+				r.severity = value
+			default:
+				return fmt.Errorf("metadata: unexpected severity %q (want error, warning or note)", value)
+			}
+		case "message":
+			r.message = value
+		default:
+			return fmt.Errorf("metadata: unexpected %s key", key.Name)
+		}
+	}
+	return nil
+}
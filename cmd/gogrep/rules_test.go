@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseRulesMacros(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{
+			"define $errcheck = if $e != nil { return $*_ }\n" +
+				"$_, $err := $f($*_); $errcheck",
+			[]string{`$_, $err := $f($*_); if $e != nil { return $*_ }`},
+		},
+
+		// Macros can be defined after their uses.
+		{
+			"$x := $y; $errcheck\n" +
+				"define $errcheck = if err != nil { return err }",
+			[]string{`$x := $y; if err != nil { return err }`},
+		},
+
+		// Nested macros.
+		{
+			"define $check = if $cond { $*_ }\n" +
+				"define $call = $x, $err := $f(); $check\n" +
+				"$call => $cond.IsPure()\n" +
+				"for { $call }",
+			[]string{
+				`$x, $err := $f(); if $cond { $*_ }`,
+				`for { $x, $err := $f(); if $cond { $*_ } }`,
+			},
+		},
+		{
+			"define $a = $b + $b\n" +
+				"define $b = ($c * 2)\n" +
+				"define $c = x\n" +
+				"f($a, $c)",
+			[]string{`f((x * 2) + (x * 2), x)`},
+		},
+
+		// Non-macro wildcards, seq wildcards and strings are not expanded.
+		{
+			"define $s = f()\n" +
+				"g($s, $*s, $ss, \"$s\")",
+			[]string{`g(f(), $*s, $ss, "$s")`},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := parseRules("rules.txt", []byte(test.src))
+		if err != nil {
+			t.Errorf("parse rules:\n%s\nerror: %v", test.src, err)
+			continue
+		}
+		var have []string
+		for _, r := range rules {
+			have = append(have, r.pattern)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("parse rules:\n%s\nhave:\n%s\nwant:\n%s",
+				test.src, strings.Join(have, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestParseRulesMacrosError(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{
+			"define $a = f($a)\nx",
+			`rules.txt:1: recursive macro: $a -> $a`,
+		},
+		{
+			"x\ndefine $a = f($b)\ndefine $b = g($c)\ndefine $c = $a + 1",
+			`rules.txt:2: recursive macro: $a -> $b -> $c -> $a`,
+		},
+		{
+			"define $a = f()\ndefine $a = g()\nx",
+			`rules.txt:2: define: $a is already defined at line 1`,
+		},
+		{
+			"define a = f()\nx",
+			`rules.txt:1: define: expected $name = pattern`,
+		},
+		{
+			"define $_ = f()\nx",
+			`rules.txt:1: define: invalid macro name $_`,
+		},
+		{
+			"define $a =\nx",
+			`rules.txt:1: define: empty $a pattern`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := parseRules("rules.txt", []byte(test.src))
+		if err == nil {
+			t.Errorf("parse rules:\n%s\nexpected an error", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("parse rules:\n%s\nhave: %v\nwant: %v", test.src, err, test.err)
+		}
+	}
+}
+
+func TestParseRulesMetadata(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`f($x)`, `pattern=f($x) filter= id= severity= message=`},
+		{
+			`fmt.Errorf($s) @ {id: errorf, severity: error, message: "use errors.New"}`,
+			`pattern=fmt.Errorf($s) filter= id=errorf severity=error message=use errors.New`,
+		},
+		{
+			`$x == $x => $x.IsPure() @ {severity: note, id: "dup-cmp"}`,
+			`pattern=$x == $x filter=$x.IsPure() id=dup-cmp severity=note message=`,
+		},
+		{
+			`f() @ {}`,
+			`pattern=f() filter= id= severity= message=`,
+		},
+
+		// The separators inside of the string literals belong to the pattern.
+		{
+			`if s == " @ {" { $*_ }`,
+			`pattern=if s == " @ {" { $*_ } filter= id= severity= message=`,
+		},
+		{
+			`if s == " @ {" { $*_ } @ {id: at}`,
+			`pattern=if s == " @ {" { $*_ } filter= id=at severity= message=`,
+		},
+		{
+			`f(" => ", '"', ` + "` @ {`" + `) => $$.Line() > 1 @ {message: "a \" @ {x}"}`,
+			`pattern=f(" => ", '"', ` + "` @ {`" + `) filter=$$.Line() > 1 id= severity= message=a " @ {x}`,
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := parseRules("rules.txt", []byte(test.src))
+		if err != nil {
+			t.Errorf("parse rules:\n%s\nerror: %v", test.src, err)
+			continue
+		}
+		r := rules[0]
+		have := fmt.Sprintf("pattern=%s filter=%s id=%s severity=%s message=%s",
+			r.pattern, r.filter, r.id, r.severity, r.message)
+		if have != test.want {
+			t.Errorf("parse rules:\n%s\nhave: %s\nwant: %s", test.src, have, test.want)
+		}
+	}
+}
+
+func TestParseRulesMetadataError(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{
+			`f() @ {id: x, level: error}`,
+			`rules.txt:1: metadata: unexpected level key`,
+		},
+		{
+			`f() @ {severity: fatal}`,
+			`rules.txt:1: metadata: unexpected severity "fatal" (want error, warning or note)`,
+		},
+		{
+			`f() @ {id: 10}`,
+			`rules.txt:1: metadata: id: expected a string or identifier`,
+		},
+		{
+			`f() @ {"id": x}`,
+			`rules.txt:1: metadata: key should be an identifier`,
+		},
+		{
+			`f() @ {x}`,
+			`rules.txt:1: metadata: expected a key: value pair`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := parseRules("rules.txt", []byte(test.src))
+		if err == nil {
+			t.Errorf("parse rules:\n%s\nexpected an error", test.src)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("parse rules:\n%s\nhave: %v\nwant: %v", test.src, err, test.err)
+		}
+	}
+}
+
+func TestSarifLevel(t *testing.T) {
+	tests := []struct {
+		severity string
+		want     string
+	}{
+		{"", "warning"},
+		{"error", "error"},
+		{"warning", "warning"},
+		{"note", "note"},
+	}
+
+	p := &program{}
+	for _, test := range tests {
+		m := match{
+			rule:        &rule{id: "r", severity: test.severity},
+			text:        "f()",
+			matchLength: len("f()"),
+		}
+		result := p.newSarifResult(m)
+		if result.Level != test.want {
+			t.Errorf("severity %q: have %q level, want %q", test.severity, result.Level, test.want)
+		}
+		if result.RuleID != "r" || result.Message.Text != "f()" {
+			t.Errorf("severity %q: unexpected result %+v", test.severity, result)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// SARIF 2.1.0 report types.
+// Only the properties that are used by gogrep are described here.
+
+type sarifReport struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string           `json:"ruleId,omitempty"`
+	Level      string           `json:"level"`
+	Message    sarifMessage     `json:"message"`
+	Locations  []sarifLocation  `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+// sarifProperties is a result property bag with the gogrep-specific data.
+type sarifProperties struct {
+	// ID is the -E mode match id.
+	ID int `json:"id"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// printSarifReport prints the collected results as a single SARIF report.
+func (p *program) printSarifReport(results []sarifResult) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name:           "gogrep",
+				InformationURI: "https://github.com/quasilyte/gogrep",
+			},
+		},
+		Results: results,
+	}
+	if run.Results == nil {
+		run.Results = []sarifResult{}
+	}
+	for _, r := range p.rules {
+		if r.id == "" {
+			continue
+		}
+		sr := sarifRule{ID: r.id}
+		if r.message != "" {
+			sr.ShortDescription = &sarifMessage{Text: r.message}
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sr)
+	}
+
+	report := sarifReport{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func (p *program) newSarifResult(m match) sarifResult {
+	filename := m.filename
+	if p.args.abs {
+		filename = filepathAbs(p.workDir, filename)
+	}
+
+	level := m.rule.severity
+	if level == "" {
+		level = "warning"
+	}
+	message := m.rule.message
+	if message == "" {
+		message = m.text[m.matchStartOffset : m.matchStartOffset+m.matchLength]
+		if m.file != nil {
+			message = "file matches the query"
+		}
+	}
+
+	location := sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(filename)},
+	}
+	// File query matches describe the entire file, so they have no region.
+	if m.file == nil {
+		location.Region = &sarifRegion{
+			StartLine:   m.line,
+			StartColumn: m.column,
+			EndLine:     m.endLine,
+			EndColumn:   m.endColumn,
+		}
+	}
+
+	result := sarifResult{
+		RuleID:    m.rule.id,
+		Level:     level,
+		Message:   sarifMessage{Text: message},
+		Locations: []sarifLocation{{PhysicalLocation: location}},
+	}
+	if m.id != 0 {
+		result.Properties = &sarifProperties{ID: m.id}
+	}
+	return result
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// shadowsOuterVar reports whether n is a `:=` declaration that introduces
+// a name that is already declared in one of the enclosing scopes.
+//
+// There is no types info, so this is a name-based heuristic: it only
+// takes the current file declarations into account and doesn't
+// distinguish the variables from other objects declared with var and const.
+func (ctx *filterContext) shadowsOuterVar(varname string, n ast.Node) bool {
+	newNames := make(map[string]struct{})
+	for _, id := range definedIdents(n) {
+		if id.Name != "_" {
+			newNames[id.Name] = struct{}{}
+		}
+	}
+	if len(newNames) == 0 {
+		return false
+	}
+
+	// The first scope-introducing ancestor is where the names are declared.
+	// The names that are already declared in that scope are re-assigned, not shadowed.
+	var declScope ast.Node
+	if _, ok := n.(*ast.RangeStmt); ok {
+		// The range statement variables are declared in its own scope.
+		declScope = n
+	}
+	shadows := false
+	child := n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		names, isScope := scopeNamesBefore(parent, child)
+		sameScope := declScope == nil
+		if isScope && declScope == nil {
+			declScope = parent
+		}
+		// Function params are in the same scope as the function body block.
+		if declScope == child && isFuncNode(parent) {
+			sameScope = true
+		}
+		child = parent
+		for _, name := range names {
+			if _, ok := newNames[name]; !ok {
+				continue
+			}
+			if sameScope {
+				delete(newNames, name)
+				continue
+			}
+			shadows = true
+			return false
+		}
+		return len(newNames) != 0
+	})
+	return shadows
+}
+
+// definedIdents returns the identifiers that are declared by the `:=` statement.
+func definedIdents(n ast.Node) []*ast.Ident {
+	var lhs []ast.Expr
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if n.Tok != token.DEFINE {
+			return nil
+		}
+		lhs = n.Lhs
+	case *ast.RangeStmt:
+		if n.Tok != token.DEFINE {
+			return nil
+		}
+		lhs = []ast.Expr{n.Key, n.Value}
+	default:
+		return nil
+	}
+	var idents []*ast.Ident
+	for _, e := range lhs {
+		if id, ok := e.(*ast.Ident); ok {
+			idents = append(idents, id)
+		}
+	}
+	return idents
+}
+
+func isFuncNode(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.FuncDecl, *ast.FuncLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// scopeNamesBefore returns the names that are declared inside the parent
+// scope and are visible at the child position.
+// isScope is false if parent doesn't introduce a new scope.
+func scopeNamesBefore(parent, child ast.Node) (names []string, isScope bool) {
+	switch parent := parent.(type) {
+	case *ast.BlockStmt:
+		return stmtListNamesBefore(parent.List, child), true
+	case *ast.CaseClause:
+		return stmtListNamesBefore(parent.Body, child), true
+	case *ast.CommClause:
+		names = stmtListNamesBefore(parent.Body, child)
+		if parent.Comm != child {
+			names = appendStmtNames(names, parent.Comm)
+		}
+		return names, true
+
+	case *ast.IfStmt:
+		if parent.Init != child {
+			names = appendStmtNames(names, parent.Init)
+		}
+		return names, true
+	case *ast.SwitchStmt:
+		if parent.Init != child {
+			names = appendStmtNames(names, parent.Init)
+		}
+		return names, true
+	case *ast.TypeSwitchStmt:
+		if parent.Init != child {
+			names = appendStmtNames(names, parent.Init)
+		}
+		if parent.Body == child {
+			names = appendStmtNames(names, parent.Assign)
+		}
+		return names, true
+	case *ast.ForStmt:
+		if parent.Init != child {
+			names = appendStmtNames(names, parent.Init)
+		}
+		return names, true
+	case *ast.RangeStmt:
+		if parent.Body == child {
+			for _, id := range definedIdents(parent) {
+				names = append(names, id.Name)
+			}
+		}
+		return names, true
+
+	case *ast.FuncDecl:
+		names = appendFieldListNames(names, parent.Recv)
+		names = appendFieldListNames(names, parent.Type.Params)
+		names = appendFieldListNames(names, parent.Type.Results)
+		return names, true
+	case *ast.FuncLit:
+		names = appendFieldListNames(names, parent.Type.Params)
+		names = appendFieldListNames(names, parent.Type.Results)
+		return names, true
+
+	case *ast.File:
+		for _, decl := range parent.Decls {
+			if decl, ok := decl.(*ast.GenDecl); ok {
+				names = appendGenDeclNames(names, decl)
+			}
+		}
+		return names, true
+
+	default:
+		return nil, false
+	}
+}
+
+func stmtListNamesBefore(list []ast.Stmt, child ast.Node) []string {
+	var names []string
+	for _, stmt := range list {
+		if stmt.End() > child.Pos() {
+			break
+		}
+		names = appendStmtNames(names, stmt)
+	}
+	return names
+}
+
+func appendStmtNames(names []string, n ast.Node) []string {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for _, id := range definedIdents(n) {
+			names = append(names, id.Name)
+		}
+	case *ast.DeclStmt:
+		if decl, ok := n.Decl.(*ast.GenDecl); ok {
+			names = appendGenDeclNames(names, decl)
+		}
+	case *ast.LabeledStmt:
+		names = appendStmtNames(names, n.Stmt)
+	}
+	return names
+}
+
+func appendGenDeclNames(names []string, decl *ast.GenDecl) []string {
+	if decl.Tok != token.VAR && decl.Tok != token.CONST {
+		return names
+	}
+	for _, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		for _, id := range spec.Names {
+			names = append(names, id.Name)
+		}
+	}
+	return names
+}
+
+func appendFieldListNames(names []string, list *ast.FieldList) []string {
+	if list == nil {
+		return names
+	}
+	for _, field := range list.List {
+		for _, id := range field.Names {
+			names = append(names, id.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go/ast"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// followedBy reports whether the statement that follows the varname node is matched by pat.
+// The pattern shares the captures with the current match,
+// so `$err` inside pat has to be identical to the $err that is already captured.
+func (ctx *filterContext) followedBy(varname string, pat *gogrep.Pattern) bool {
+	next := ctx.nextStmt(varname)
+	if next == nil {
+		return false
+	}
+	state := &ctx.w.filterPatternState
+	state.CapturePreset = ctx.m.Capture
+	matched := false
+	pat.MatchNode(state, next, func(gogrep.MatchData) {
+		matched = true
+	})
+	if stmt, ok := next.(*ast.ExprStmt); ok && !matched {
+		// Expression patterns like `f($x)` should match the `f(x)` statement.
+		pat.MatchNode(state, stmt.X, func(gogrep.MatchData) {
+			matched = true
+		})
+	}
+	state.CapturePreset = nil
+	return matched
+}
+
+// nextStmt returns the statement that follows the varname node statement
+// inside the enclosing statements list, like a block or a case clause body.
+// If varname node is not a statement, its innermost enclosing statement is used;
+// for a statements sequence match, it's the last statement of the sequence.
+// Returns nil if there is no next statement.
+func (ctx *filterContext) nextStmt(varname string) ast.Stmt {
+	n, ok := capturedByName(ctx.m, varname)
+	if !ok {
+		return nil
+	}
+	if slice, ok := n.(*gogrep.NodeSlice); ok {
+		if slice.Kind != gogrep.StmtNodeSlice || slice.Len() == 0 {
+			return nil
+		}
+		n = slice.At(slice.Len() - 1)
+	}
+
+	var next ast.Stmt
+	child := n
+	ctx.walkAncestors(varname, func(parent ast.Node) bool {
+		list, ok := stmtListOf(parent)
+		if !ok {
+			child = parent
+			return true
+		}
+		for i, stmt := range list {
+			if stmt == child && i+1 < len(list) {
+				next = list[i+1]
+			}
+		}
+		return false
+	})
+	return next
+}
+
+// stmtListOf returns the n statements list, if n has one.
+func stmtListOf(n ast.Node) ([]ast.Stmt, bool) {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		return n.List, true
+	case *ast.CaseClause:
+		return n.Body, true
+	case *ast.CommClause:
+		return n.Body, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+// editDistance returns the Levenshtein distance between a and b:
+// the minimal number of single rune insertions, deletions and substitutions
+// that turn a into b. The comparison is case-sensitive.
+//
+// Once the distance is known to exceed limit, limit+1 is returned,
+// so the `<= limit` checks don't pay for the full computation.
+func editDistance(a, b string, limit int) int {
+	x := []rune(a)
+	y := []rune(b)
+	if len(x)-len(y) > limit || len(y)-len(x) > limit {
+		return limit + 1
+	}
+
+	// Two rows of the dynamic programming table are enough.
+	prev := make([]int, len(y)+1)
+	curr := make([]int, len(y)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		curr[0] = i
+		rowMin := i
+		for j := 1; j <= len(y); j++ {
+			d := prev[j-1] // Substitution (or a match).
+			if x[i-1] != y[j-1] {
+				d++
+			}
+			if prev[j]+1 < d {
+				d = prev[j] + 1 // Deletion.
+			}
+			if curr[j-1]+1 < d {
+				d = curr[j-1] + 1 // Insertion.
+			}
+			curr[j] = d
+			if d < rowMin {
+				rowMin = d
+			}
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, curr = curr, prev
+	}
+	if prev[len(y)] > limit {
+		return limit + 1
+	}
+	return prev[len(y)]
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a     string
+		b     string
+		limit int
+		want  int
+	}{
+		{"context", "context", 0, 0},
+		{"context", "context", 2, 0},
+		{"", "", 0, 0},
+		{"", "ctx", 3, 3},
+		{"", "ctx", 2, 3},
+
+		{"contxt", "context", 1, 1},
+		{"contxt", "context", 0, 1},
+		{"Context", "context", 1, 1},
+		{"conetxt", "context", 2, 2},
+		{"conetxt", "context", 1, 2},
+		{"ctx", "context", 4, 4},
+		{"ctx", "context", 3, 4},
+		{"contexts", "context", 1, 1},
+		{"kontekst", "context", 3, 3},
+		{"kontekst", "context", 2, 3},
+
+		{"значение", "значения", 1, 1},
+	}
+
+	for _, test := range tests {
+		have := editDistance(test.a, test.b, test.limit)
+		if have != test.want {
+			t.Errorf("editDistance(%q, %q, %d): have %d, want %d",
+				test.a, test.b, test.limit, have, test.want)
+		}
+		if reversed := editDistance(test.b, test.a, test.limit); reversed != have {
+			t.Errorf("editDistance(%q, %q, %d): have %d, want %d",
+				test.b, test.a, test.limit, reversed, have)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// defaultSinks is a list of the functions that usually terminate
+// the program (or the current goroutine/test) execution.
+const defaultSinks = "panic,os.Exit,log.Fatal*,log.Panic*,t.Fatal*,t.FailNow,b.Fatal*,b.FailNow"
+
+// sinkPattern describes a function that is recognized by the IsSink() filter.
+//
+// For a `log.Fatal*` pattern, qualifier is "log", name is "Fatal"
+// and prefix is true.
+type sinkPattern struct {
+	qualifier string
+	name      string
+	prefix    bool
+}
+
+func parseSinkList(s string) ([]sinkPattern, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var list []sinkPattern
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		orig := part
+		var p sinkPattern
+		if strings.HasSuffix(part, "*") {
+			p.prefix = true
+			part = part[:len(part)-1]
+		}
+		if i := strings.IndexByte(part, '.'); i != -1 {
+			p.qualifier = part[:i]
+			part = part[i+1:]
+		}
+		p.name = part
+		if p.name == "" || strings.ContainsAny(p.name, ".*") || strings.ContainsAny(p.qualifier, "*") {
+			return nil, fmt.Errorf("invalid sink pattern %q", orig)
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func (p sinkPattern) matches(qualifier, name string) bool {
+	if p.qualifier != qualifier {
+		return false
+	}
+	if p.prefix {
+		return strings.HasPrefix(name, p.name)
+	}
+	return p.name == name
+}
+
+// isSinkCall reports whether n is a call to one of the sinks.
+// n can also be a function expression itself, like `os.Exit`.
+func isSinkCall(sinks []sinkPattern, n ast.Node) bool {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return false
+	}
+	e = unparenExpr(e)
+	if call, ok := e.(*ast.CallExpr); ok {
+		e = unparenExpr(call.Fun)
+	}
+
+	var qualifier, name string
+	switch fn := e.(type) {
+	case *ast.Ident:
+		name = fn.Name
+	case *ast.SelectorExpr:
+		x, ok := fn.X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		qualifier = x.Name
+		name = fn.Sel.Name
+	default:
+		return false
+	}
+
+	for _, p := range sinks {
+		if p.matches(qualifier, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func unparenExpr(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
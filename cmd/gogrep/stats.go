@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// skipReason describes why a target file was not searched.
+type skipReason int
+
+const (
+	skipExclude skipReason = iota
+	skipHeatmap
+	skipTestFilter
+	skipPrescreen
+	skipAutogenFilter
+	skipBuildConstraint
+	skipLineRanges
+	skipFileSize
+
+	numSkipReasons
+)
+
+func (r skipReason) String() string {
+	switch r {
+	case skipExclude:
+		return "exclude"
+	case skipHeatmap:
+		return "heatmap"
+	case skipTestFilter:
+		return "test filter"
+	case skipPrescreen:
+		return "prescreen"
+	case skipAutogenFilter:
+		return "autogen filter"
+	case skipBuildConstraint:
+		return "build constraint"
+	case skipLineRanges:
+		return "line ranges"
+	case skipFileSize:
+		return "file size"
+	default:
+		return "unknown"
+	}
+}
+
+// searchStats are the counters reported in the -stats mode.
+// Every worker collects its own stats, they're merged when the search is finished.
+type searchStats struct {
+	// filesScanned is the number of parsed and searched files.
+	filesScanned int
+
+	// filesFailed is the number of files that couldn't be read or parsed.
+	filesFailed int
+
+	filesSkipped [numSkipReasons]int
+
+	// dirsExcluded is the number of directories skipped due to -exclude,
+	// the files inside them are not counted.
+	dirsExcluded int
+
+	nodesVisited int
+}
+
+func (s *searchStats) merge(other *searchStats) {
+	s.filesScanned += other.filesScanned
+	s.filesFailed += other.filesFailed
+	for i := range s.filesSkipped {
+		s.filesSkipped[i] += other.filesSkipped[i]
+	}
+	s.dirsExcluded += other.dirsExcluded
+	s.nodesVisited += other.nodesVisited
+}
+
+func (s *searchStats) numSkipped() int {
+	n := 0
+	for _, count := range s.filesSkipped {
+		n += count
+	}
+	return n
+}
+
+func (p *program) printStats() error {
+	if !p.args.stats {
+		return nil
+	}
+
+	stats := p.stats
+	for _, w := range p.workers {
+		stats.merge(&w.stats)
+	}
+
+	out := tabwriter.NewWriter(os.Stderr, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(out, "files scanned:\t%d\n", stats.filesScanned)
+	fmt.Fprintf(out, "files failed:\t%d\n", stats.filesFailed)
+	fmt.Fprintf(out, "files skipped:\t%d\n", stats.numSkipped())
+	for i, count := range stats.filesSkipped {
+		fmt.Fprintf(out, "  by %s:\t%d\n", skipReason(i), count)
+	}
+	fmt.Fprintf(out, "dirs excluded:\t%d\n", stats.dirsExcluded)
+	fmt.Fprintf(out, "nodes visited:\t%d\n", stats.nodesVisited)
+	fmt.Fprintf(out, "matches:\t%d\n", p.numMatches)
+	fmt.Fprintf(out, "wall time:\t%s\n", time.Since(p.startTime).Round(time.Millisecond))
+	return out.Flush()
+}
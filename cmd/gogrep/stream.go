@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/template"
+)
+
+// fileTask is a file that is queued to be grepped by one of the workers.
+// The id is the file index in the walk order.
+type fileTask struct {
+	id       int
+	filename string
+}
+
+// fileResult holds a single file matches in the streaming output mode.
+// A result is sent for every queued file, even if it has no matches,
+// so the streamer knows that it can move to the next file.
+type fileResult struct {
+	id      int
+	matches []match
+}
+
+// streamOutput reports whether the matches are printed as soon as their file is processed.
+// The modes that need all matches to produce the output, like -c or -group-by-file, buffer them.
+func (p *program) streamOutput() bool {
+	switch {
+	case p.args.countMode || p.args.dryRun || p.args.watch || p.args.groupByFile || p.args.testMode:
+		return false
+	case p.args.distinct != "" || p.args.countBy != "" || p.args.writeBaseline != "" || p.args.rewrite != "":
+		return false
+	case p.args.clones || p.args.importAliases || p.args.receiverNames || p.args.blankImports:
+		return false
+	default:
+		return true
+	}
+}
+
+// streamMatches passes the file results to printFile in the walk order.
+// The results that are ready ahead of time are buffered until all preceding files are printed;
+// with N workers, there are usually no more than N results waiting.
+//
+// The results channel is drained even after a printing error,
+// so the workers are never blocked on it.
+func streamMatches(results <-chan fileResult, printFile func([]match) error) error {
+	pending := make(map[int][]match)
+	nextID := 0
+	var err error
+	for r := range results {
+		pending[r.id] = r.matches
+		for {
+			matches, ok := pending[nextID]
+			if !ok {
+				break
+			}
+			delete(pending, nextID)
+			nextID++
+			if err == nil && len(matches) != 0 {
+				err = printFile(matches)
+			}
+		}
+	}
+	return err
+}
+
+// takeFileMatches removes the last grepped file matches from the worker
+// and returns them sorted by their location.
+func (w *worker) takeFileMatches() []match {
+	matches := w.matches
+	w.matches = nil
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matchBefore(&matches[i], &matches[j])
+	})
+	return matches
+}
+
+// matchPrinter prints the matches one by one in the selected output format,
+// it's shared by the buffered and the streaming output modes.
+// The -E mode match ids are assigned in the printing order.
+type matchPrinter struct {
+	p *program
+
+	enc *json.Encoder
+
+	// sarifResults are collected to be printed as a single report in the end.
+	sarifResults []sarifResult
+
+	// ctagsEntries are collected to be printed as a single sorted tags file in the end.
+	ctagsEntries []ctagsEntry
+
+	printFn func(tmpl *template.Template, wd string, args *arguments, m match) error
+
+	// printContext is set when the function context is printed as a separate line
+	// that is shared by the consecutive matches from the same function.
+	printContext bool
+
+	lastContext  string
+	lastFilename string
+
+	printed uint64
+}
+
+func (p *program) newMatchPrinter() *matchPrinter {
+	mp := &matchPrinter{
+		p:            p,
+		printFn:      printMatch,
+		printContext: p.args.contextFunc && p.args.format == defaultFormat,
+	}
+	if p.args.format == jsonFormat {
+		mp.enc = json.NewEncoder(os.Stdout)
+	}
+	if p.args.fileQuery {
+		mp.printFn = printFileMatch
+	}
+	return mp
+}
+
+func (mp *matchPrinter) limitReached() bool {
+	return mp.printed >= mp.p.args.limit
+}
+
+// printFile prints the single file matches in the streaming output mode.
+func (mp *matchPrinter) printFile(matches []match) error {
+	if mp.p.args.progressMode == "update" {
+		// Clear the progress line, so it's not mixed with the matches.
+		os.Stderr.WriteString("\r\033[K")
+	}
+	for _, m := range matches {
+		if mp.limitReached() {
+			return nil
+		}
+		if err := mp.print(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mp *matchPrinter) print(m match) error {
+	p := mp.p
+	if p.args.matchIDs {
+		m.id = int(mp.printed) + 1
+	}
+	if p.args.format == sarifFormat {
+		mp.sarifResults = append(mp.sarifResults, p.newSarifResult(m))
+		mp.printed++
+		return nil
+	}
+	if p.args.format == ctagsFormat {
+		// The matches without a symbol can't be tagged, they're not printed.
+		if m.tag != "" {
+			mp.ctagsEntries = append(mp.ctagsEntries, p.newCtagsEntry(m))
+			mp.printed++
+		}
+		return nil
+	}
+	if mp.enc != nil {
+		var err error
+		if m.file != nil {
+			err = mp.enc.Encode(p.newJSONFileMatch(m))
+		} else {
+			err = mp.enc.Encode(p.newJSONMatch(m))
+		}
+		if err != nil {
+			return err
+		}
+		mp.printed++
+		return nil
+	}
+
+	if p.args.listFiles {
+		mp.printListedFile(m)
+		return nil
+	}
+
+	if p.args.groupByFile && m.filename != mp.lastFilename {
+		if mp.lastFilename != "" {
+			fmt.Println()
+		}
+		printFileHeader(p.workDir, &p.args, m)
+		mp.lastFilename = m.filename
+	}
+	if mp.printContext {
+		contextKey := m.filename + ":" + strconv.Itoa(m.contextLine)
+		if contextKey != mp.lastContext {
+			printMatchContext(p.workDir, &p.args, m)
+			mp.lastContext = contextKey
+		}
+	}
+	if err := mp.printFn(p.outputTemplate, p.workDir, &p.args, m); err != nil {
+		return err
+	}
+	mp.printed++
+	return nil
+}
+
+func (mp *matchPrinter) printSummary() {
+	if mp.limitReached() {
+		mp.p.printSummary("results limited to %d matches", mp.p.args.limit)
+		return
+	}
+	mp.p.printSummary("found %d matches", mp.printed)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/quasilyte/gogrep/filters"
+)
+
+// stringMatcher checks a decoded string literal value.
+// It's implemented by the *regexp.Regexp too.
+//
+// The matchers are shared by all workers, so they must be safe for concurrent use.
+type stringMatcher interface {
+	MatchString(s string) bool
+}
+
+type stringMatcherFunc func(s string) bool
+
+func (f stringMatcherFunc) MatchString(s string) bool { return f(s) }
+
+// stringValidators are the StringIs() filter validators, indexed by their names.
+var stringValidators = map[string]stringMatcher{}
+
+// registerStringValidator adds a named validator that can be used as the $x.StringIs(name) filter.
+// It's an extension point for the embedded mini-languages checks that can't be
+// expressed as a regexp; the validators should be registered from the init functions.
+func registerStringValidator(name string, v stringMatcher) {
+	if _, ok := stringValidators[name]; ok {
+		panic(fmt.Sprintf("%s string validator is already registered", name))
+	}
+	stringValidators[name] = v
+}
+
+func init() {
+	registerStringValidator("utf8", stringMatcherFunc(utf8.ValidString))
+}
+
+// compileStringMatchers compiles the StringMatches() filter regexps of the rule
+// and checks that all StringIs() validators exist.
+func (p *program) compileStringMatchers(r *rule, e *filters.Expr) error {
+	for _, arg := range e.Args {
+		if err := p.compileStringMatchers(r, arg); err != nil {
+			return err
+		}
+	}
+
+	switch e.Op {
+	case opVarStringIs:
+		name := e.Args[0].Str
+		if _, ok := stringValidators[name]; !ok {
+			return fmt.Errorf("StringIs: unknown %q validator", name)
+		}
+
+	case opVarStringMatches:
+		src := e.Args[0].Str
+		if _, ok := r.stringRegexps[src]; ok {
+			return nil
+		}
+		re, err := regexp.Compile(src)
+		if err != nil {
+			return fmt.Errorf("StringMatches: %v", err)
+		}
+		if r.stringRegexps == nil {
+			r.stringRegexps = make(map[string]*regexp.Regexp)
+		}
+		r.stringRegexps[src] = re
+	}
+	return nil
+}
+
+// stringLitValue returns the decoded value of the n string literal.
+// Both interpreted and raw literals are supported.
+func stringLitValue(n ast.Node) (string, bool) {
+	e, ok := n.(ast.Expr)
+	if !ok {
+		return "", false
+	}
+	lit, ok := unparenExpr(e).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+
+	"github.com/quasilyte/gogrep"
+)
+
+// matchNode runs the pat pattern for n, like pat.MatchNode does.
+// With -timeout, the match is interrupted once the search deadline is exceeded,
+// the worker then stops matching the other nodes, see checkTimeout.
+func (w *worker) matchNode(pat *gogrep.Pattern, state *gogrep.MatcherState, n ast.Node, cb func(gogrep.MatchData)) {
+	if w.ctx == nil {
+		pat.MatchNode(state, n, cb)
+		return
+	}
+	if err := pat.MatchNodeCtx(w.ctx, state, n, cb); err != nil {
+		w.interrupted = true
+	}
+}
+
+// checkTimeout reports the exceeded -timeout as an error.
+// It's done after the matches are printed, so the partial results are not lost.
+func (p *program) checkTimeout() error {
+	if p.ctx == nil || p.ctx.Err() != context.DeadlineExceeded {
+		return nil
+	}
+	return fmt.Errorf("the search is interrupted after %s, the results are incomplete", p.args.timeout)
+}
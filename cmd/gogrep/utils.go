@@ -8,8 +8,19 @@ import (
 
 	"github.com/quasilyte/gogrep"
 	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
 )
 
+// stringList is a flag.Value that collects all given flag values.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 func capturedByName(m gogrep.MatchData, name string) (ast.Node, bool) {
 	if filters.IsRootVarname(name) {
 		return m.Node, true
@@ -18,6 +29,61 @@ func capturedByName(m gogrep.MatchData, name string) (ast.Node, bool) {
 	return n, ok
 }
 
+// findNodePath returns the target node enclosing nodes inside the root, outermost first.
+// The root itself is included unless it's a node slice.
+// Returns nil if target is not found.
+func findNodePath(root, target ast.Node) []ast.Node {
+	if p, ok := root.(*gogrep.PartialNode); ok {
+		root = p.X
+	}
+	var stack []ast.Node
+	var path []ast.Node
+	found := false
+	gogrep.Walk(root, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		if n == target {
+			found = true
+			path = append(path, stack...)
+			return false
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return path
+}
+
+// nodeKindMatches reports whether n belongs to the specified node kind.
+func nodeKindMatches(kind nodetag.Value, n ast.Node) bool {
+	switch kind {
+	case nodetag.Node:
+		return true
+	case nodetag.Expr:
+		_, ok := n.(ast.Expr)
+		return ok
+	case nodetag.Stmt:
+		_, ok := n.(ast.Stmt)
+		return ok
+	default:
+		return nodetag.FromNode(n) == kind
+	}
+}
+
+// collapseSpaces replaces all whitespace sequences with a single space.
+// The spaces after the opening and before the closing parentheses are removed,
+// so a multi-line params list is printed as if it was written in one line.
+func collapseSpaces(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = strings.ReplaceAll(s, "( ", "(")
+	s = strings.ReplaceAll(s, ", )", ")")
+	return strings.ReplaceAll(s, " )", ")")
+}
+
 func isGoFilename(filename string) bool {
 	return strings.HasSuffix(filename, ".go") ||
 		strings.HasSuffix(filename, ".go2")
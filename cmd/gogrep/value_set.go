@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/quasilyte/gogrep/filters"
+	"golang.org/x/text/unicode/norm"
+)
+
+// valueSet is a set of strings used in the `x in @filename` filters.
+type valueSet map[string]struct{}
+
+// loadValueSets loads all value sets that are referenced by the rule filter.
+// Every file is loaded only once, even if it's used by several rules.
+//
+// For the rules from a rules file, the relative set filenames
+// are resolved relative to the rules file directory.
+func (p *program) loadValueSets(r *rule, e *filters.Expr) error {
+	for _, arg := range e.Args {
+		if err := p.loadValueSets(r, arg); err != nil {
+			return err
+		}
+	}
+	if e.Op != filters.OpIn {
+		return nil
+	}
+
+	name := e.Args[1].Str
+	filename := name
+	if r.file != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(r.file), filename)
+	}
+	set, ok := p.valueSets[filename]
+	if !ok {
+		var err error
+		set, err = readValueSet(filename, p.args.nfc)
+		if err != nil {
+			return fmt.Errorf("can't load @%s value set: %v", name, err)
+		}
+		if p.valueSets == nil {
+			p.valueSets = make(map[string]valueSet)
+		}
+		p.valueSets[filename] = set
+	}
+	if r.valueSets == nil {
+		r.valueSets = make(map[string]valueSet)
+	}
+	r.valueSets[name] = set
+	return nil
+}
+
+// readValueSet reads a file that contains one value per line.
+// Leading and trailing spaces are ignored, so are the empty lines and # comments.
+func readValueSet(filename string, nfc bool) (valueSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	set := make(valueSet)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if nfc {
+			line = norm.NFC.Bytes(line)
+		}
+		set[string(line)] = struct{}{}
+	}
+	return set, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wantAnnotation is a `// want` comment of the `gogrep test` mode,
+// it describes the matches that are expected on the comment line.
+type wantAnnotation struct {
+	filename string
+	line     int
+
+	// patterns are the match label regexps, every pattern expects exactly one match.
+	// A `// want` comment without arguments expects one match of any rule.
+	patterns []*regexp.Regexp
+}
+
+// testFailure is a `gogrep test` mode mismatch between the wanted and the actual matches.
+type testFailure struct {
+	filename string
+	line     int
+	message  string
+}
+
+// collectWants parses the filename `// want` comments.
+// Every file is read even if it's skipped by the worker later,
+// so the wanted matches in such files are reported as missing.
+func (w *worker) collectWants(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return &readFileError{err: err}
+	}
+	wants, err := parseWantComments(filename, data)
+	if err != nil {
+		return err
+	}
+	w.wants = append(w.wants, wants...)
+	return nil
+}
+
+// parseWantComments returns the `// want "re"...` comments of the src file.
+func parseWantComments(filename string, src []byte) ([]wantAnnotation, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile(filename, -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, scanner.ScanComments)
+
+	var wants []wantAnnotation
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.COMMENT || !strings.HasPrefix(lit, "//") {
+			continue
+		}
+		text := strings.TrimSpace(lit[len("//"):])
+		if text != "want" && !strings.HasPrefix(text, "want ") {
+			continue
+		}
+		line := fset.Position(pos).Line
+		patterns, err := parseWantPatterns(text[len("want"):])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		wants = append(wants, wantAnnotation{
+			filename: filename,
+			line:     line,
+			patterns: patterns,
+		})
+	}
+	return wants, nil
+}
+
+// parseWantPatterns parses the `// want` comment arguments,
+// a whitespace-separated list of Go string literals with the label regexps.
+func parseWantPatterns(args string) ([]*regexp.Regexp, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", -1, len(args))
+	var s scanner.Scanner
+	var scanErr error
+	s.Init(file, []byte(args), func(pos token.Position, msg string) {
+		if scanErr == nil {
+			scanErr = fmt.Errorf("want: %s", msg)
+		}
+	}, 0)
+
+	var patterns []*regexp.Regexp
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF || (tok == token.SEMICOLON && lit == "\n") {
+			break
+		}
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		if tok != token.STRING {
+			return nil, fmt.Errorf("want: expected a string literal, found %s", tok)
+		}
+		str, err := strconv.Unquote(lit)
+		if err != nil {
+			return nil, fmt.Errorf("want: %v", err)
+		}
+		re, err := regexp.Compile(str)
+		if err != nil {
+			return nil, fmt.Errorf("want: %v", err)
+		}
+		patterns = append(patterns, re)
+	}
+	if len(patterns) == 0 {
+		patterns = append(patterns, regexp.MustCompile(""))
+	}
+	return patterns, nil
+}
+
+// matchLabel returns the text that the `// want` patterns are matched against:
+// the rule id for the rules with metadata and the pattern source otherwise.
+func matchLabel(m match) string {
+	if m.rule.id != "" {
+		return m.rule.id
+	}
+	return m.rule.pattern
+}
+
+// checkWants compares the matches with the `// want` comments.
+// Every match should be wanted by one of the patterns of its line
+// and every want pattern should be matched exactly once.
+func checkWants(wants []wantAnnotation, matches []match) []testFailure {
+	type lineKey struct {
+		filename string
+		line     int
+	}
+	type wantPattern struct {
+		re   *regexp.Regexp
+		used bool
+	}
+	patternsByLine := make(map[lineKey][]*wantPattern)
+	for _, want := range wants {
+		key := lineKey{filename: want.filename, line: want.line}
+		for _, re := range want.patterns {
+			patternsByLine[key] = append(patternsByLine[key], &wantPattern{re: re})
+		}
+	}
+
+	var failures []testFailure
+	for _, m := range matches {
+		label := matchLabel(m)
+		found := false
+		for _, pat := range patternsByLine[lineKey{filename: m.filename, line: m.line}] {
+			if !pat.used && pat.re.MatchString(label) {
+				pat.used = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, testFailure{
+				filename: m.filename,
+				line:     m.line,
+				message:  fmt.Sprintf("unexpected match of %s: %s", label, firstLine(matchText(m))),
+			})
+		}
+	}
+	for key, patterns := range patternsByLine {
+		for _, pat := range patterns {
+			if pat.used {
+				continue
+			}
+			message := "missing match"
+			if pat.re.String() != "" {
+				message = fmt.Sprintf("missing match: want %q", pat.re.String())
+			}
+			failures = append(failures, testFailure{
+				filename: key.filename,
+				line:     key.line,
+				message:  message,
+			})
+		}
+	}
+
+	sort.SliceStable(failures, func(i, j int) bool {
+		if failures[i].filename != failures[j].filename {
+			return failures[i].filename < failures[j].filename
+		}
+		if failures[i].line != failures[j].line {
+			return failures[i].line < failures[j].line
+		}
+		return failures[i].message < failures[j].message
+	})
+	return failures
+}
+
+func matchText(m match) string {
+	return m.text[m.matchStartOffset : m.matchStartOffset+m.matchLength]
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i] + "..."
+	}
+	return s
+}
+
+// printTestResults reports the `gogrep test` mode failures.
+func (p *program) printTestResults() error {
+	var wants []wantAnnotation
+	filesFailed := 0
+	for _, w := range p.workers {
+		wants = append(wants, w.wants...)
+		filesFailed += w.stats.filesFailed
+	}
+	matches := p.sortedMatches()
+	failures := checkWants(wants, matches)
+	for _, f := range failures {
+		filename := f.filename
+		if p.args.abs {
+			filename = filepathAbs(p.workDir, filename)
+		}
+		fmt.Printf("%s:%d: %s\n", filename, f.line, f.message)
+	}
+
+	// The files that can't be grepped are reported as errors during the execution.
+	p.testFailures = len(failures) + filesFailed
+	if p.testFailures != 0 {
+		log.Printf("FAIL: %d mismatches with the want comments, %d failed files", len(failures), filesFailed)
+		return nil
+	}
+	log.Printf("PASS: %d matches, %d want comments", len(matches), len(wants))
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseWantComments(t *testing.T) {
+	src := "package p\n" +
+		"func f() {\n" +
+		"	f() // want\n" +
+		"	g() // want \"a\" `b$`\n" +
+		"	// wanted\n" +
+		"	s := \"// want\"\n" +
+		"	/* want \"c\" */\n" +
+		"	//want \"d\"\n" +
+		"}\n"
+
+	wants, err := parseWantComments("p.go", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, want := range wants {
+		var patterns []string
+		for _, re := range want.patterns {
+			patterns = append(patterns, "/"+re.String()+"/")
+		}
+		have = append(have, want.filename+":"+strconv.Itoa(want.line)+": "+strings.Join(patterns, " "))
+	}
+	want := []string{
+		"p.go:3: //",
+		"p.go:4: /a/ /b$/",
+		"p.go:8: /d/",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wants:\nhave: %q\nwant: %q", have, want)
+	}
+
+	errorTests := []struct {
+		src string
+		err string
+	}{
+		{`f() // want a`, `line 1: want: expected a string literal, found IDENT`},
+		{`f() // want "a" + "b"`, `line 1: want: expected a string literal, found +`},
+		{`f() // want "(a"`, "line 1: want: error parsing regexp: missing closing ): `(a`"},
+		{`f() // want "a`, `line 1: want: string literal not terminated`},
+	}
+	for _, test := range errorTests {
+		_, err := parseWantComments("p.go", []byte(test.src))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s:\nhave: %v\nwant: %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestCheckWants(t *testing.T) {
+	tests := []struct {
+		pattern string
+		src     string
+		want    []string
+	}{
+		{
+			pattern: `f($_)`,
+			src: `package p
+func _() {
+	f(1) // want
+	f(2) // want "f"
+	g(3) // want
+	f(4); f(5) // want "" ""
+	f(6)
+}`,
+			want: []string{
+				`p.go:5: missing match`,
+				`p.go:7: unexpected match of f($_): f(6)`,
+			},
+		},
+		{
+			pattern: `$x + $y`,
+			src: `package p
+var _ = 1 + 2 + 3 // want "and more" "[+]"
+var _ = "a" + "b" // want
+var _ = x +
+	y`,
+			want: []string{
+				`p.go:2: missing match: want "and more"`,
+				`p.go:2: unexpected match of $x + $y: 1 + 2`,
+				`p.go:4: unexpected match of $x + $y: x +...`,
+			},
+		},
+		{
+			pattern: `f()`,
+			src: `package p
+func _() { f() } // want
+`,
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		w := testGrepSource(t, test.pattern, test.src, false)
+		wants, err := parseWantComments("p.go", []byte(test.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range w.matches {
+			w.matches[i].filename = "p.go"
+		}
+		var have []string
+		for _, f := range checkWants(wants, w.matches) {
+			have = append(have, f.filename+":"+strconv.Itoa(f.line)+": "+f.message)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.pattern, have, test.want)
+		}
+	}
+}
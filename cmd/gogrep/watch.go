@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchDebounce is a delay between the last file change and the re-scan,
+// so saving several files at once (or an editor writing a file in several steps) triggers a single re-scan.
+const watchDebounce = 200 * time.Millisecond
+
+func (p *program) validateWatchFlags() error {
+	if !watchSupported {
+		return errors.New("-watch is not supported by this gogrep build, rebuild it with -tags watch")
+	}
+	switch {
+	case p.args.countMode:
+		return fmt.Errorf("can't use -c together with -watch")
+	case p.args.clones || p.args.importAliases || p.args.distinct != "":
+		return fmt.Errorf("can't use -clones, -import-aliases or -distinct together with -watch")
+	case p.args.writeBaseline != "" || p.args.dryRun:
+		return fmt.Errorf("can't use -write-baseline or -dry-run together with -watch")
+	case p.args.timeout != 0:
+		return fmt.Errorf("can't use -timeout together with -watch")
+	}
+	return nil
+}
+
+// watchChanges re-runs the search for the changed target files until the process is interrupted.
+func (p *program) watchChanges() error {
+	if !p.args.watch {
+		return nil
+	}
+	return p.watchFiles()
+}
+
+// watchTargets describes the paths that are watched in -watch mode.
+// The directories are watched as a whole, including the file targets,
+// since the editors often replace a file instead of writing it.
+type watchTargets struct {
+	// dirs are the directories of the walked targets, any Go file inside them is searched.
+	dirs map[string]bool
+
+	// files are the file targets, only these files are searched in their directories.
+	files map[string]bool
+}
+
+// collectWatchTargets returns the target directories (excluding -exclude matches) and files.
+func (p *program) collectWatchTargets() (*watchTargets, error) {
+	targets := &watchTargets{
+		dirs:  make(map[string]bool),
+		files: make(map[string]bool),
+	}
+	for _, target := range strings.Split(p.args.targets, ",") {
+		target = strings.TrimSpace(target)
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			targets.files[target] = true
+			continue
+		}
+		if err := p.addWatchDir(targets, target); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// addWatchDir adds dir and all of its subdirectories to the watched dirs.
+func (p *program) addWatchDir(targets *watchTargets, dir string) error {
+	return filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p.exclude != nil && p.exclude.MatchString(filepathAbs(p.workDir, path)) {
+			return filepath.SkipDir
+		}
+		targets.dirs[path] = true
+		return nil
+	})
+}
+
+// isWatched reports whether the changed file filename should be searched.
+func (p *program) isWatched(targets *watchTargets, filename string) bool {
+	if targets.files[filename] {
+		return true
+	}
+	if !isGoFilename(filename) || !targets.dirs[filepath.Dir(filename)] {
+		return false
+	}
+	return p.exclude == nil || !p.exclude.MatchString(filepathAbs(p.workDir, filename))
+}
+
+// rescanFiles replaces the matches of the changed files with their new search results.
+// The patterns are already compiled, so only the changed files are parsed again.
+// The removed files matches are discarded.
+func (p *program) rescanFiles(filenames []string) {
+	changed := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		changed[filename] = true
+	}
+	for _, w := range p.workers {
+		kept := w.matches[:0]
+		for _, m := range w.matches {
+			if !changed[m.filename] {
+				kept = append(kept, m)
+			}
+		}
+		w.matches = kept
+	}
+
+	// The files are searched sequentially: there are usually only a few of them.
+	w := p.workers[0]
+	for _, filename := range filenames {
+		if _, err := os.Stat(filename); err != nil {
+			// The file was removed or renamed, the new name is reported separately.
+			continue
+		}
+		if _, err := w.grepFile(filename); err != nil {
+			w.stats.filesFailed++
+			p.printFileError(fileError{filename: filename, err: err})
+		}
+	}
+
+	p.numMatches = 0
+	for _, w := range p.workers {
+		p.numMatches += uint64(len(w.matches))
+	}
+}
+
+// reprintMatches clears the terminal screen and prints all matches again.
+func (p *program) reprintMatches() error {
+	if !p.args.noColor {
+		os.Stdout.WriteString("\033[H\033[2J")
+	}
+	return p.printMatches()
+}
@@ -0,0 +1,115 @@
+//go:build watch
+// +build watch
+
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchSupported = true
+
+func (p *program) watchFiles() error {
+	targets, err := p.collectWatchTargets()
+	if err != nil {
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	watchedDirs := make(map[string]bool)
+	addDirs := func() error {
+		for dir := range targets.dirs {
+			if watchedDirs[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				return err
+			}
+			watchedDirs[dir] = true
+		}
+		return nil
+	}
+	for filename := range targets.files {
+		if err := watcher.Add(filepath.Dir(filename)); err != nil {
+			return err
+		}
+	}
+	if err := addDirs(); err != nil {
+		return err
+	}
+	log.Printf("watching for changes, press Ctrl+C to stop")
+
+	changed := make(map[string]bool)
+	var rescan <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// The event names are prefixed with the watched dir as is, like ./a.go for ".",
+			// while the walked filenames are cleaned.
+			name := filepath.Clean(event.Name)
+			if event.Op&fsnotify.Create != 0 && !targets.dirs[name] && targets.dirs[filepath.Dir(name)] {
+				// A new directory inside one of the walked targets.
+				if info, err := os.Stat(name); err == nil && info.IsDir() {
+					if err := p.addWatchDir(targets, name); err != nil {
+						return err
+					}
+					if err := addDirs(); err != nil {
+						return err
+					}
+					p.collectDirFiles(targets, name, changed)
+					rescan = time.After(watchDebounce)
+					continue
+				}
+			}
+			if event.Op == fsnotify.Chmod || !p.isWatched(targets, name) {
+				continue
+			}
+			changed[name] = true
+			rescan = time.After(watchDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+
+		case <-rescan:
+			rescan = nil
+			filenames := make([]string, 0, len(changed))
+			for filename := range changed {
+				filenames = append(filenames, filename)
+			}
+			sort.Strings(filenames)
+			changed = make(map[string]bool)
+			p.rescanFiles(filenames)
+			if err := p.reprintMatches(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// collectDirFiles adds the Go files from the created dir to the changed files set.
+// The files can be created before the dir is watched, so they're not reported by the events.
+func (p *program) collectDirFiles(targets *watchTargets, dir string, changed map[string]bool) {
+	_ = filepath.WalkDir(dir, func(path string, info fs.DirEntry, err error) error {
+		if err == nil && !info.IsDir() && p.isWatched(targets, path) {
+			changed[path] = true
+		}
+		return nil
+	})
+}
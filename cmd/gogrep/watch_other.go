@@ -0,0 +1,16 @@
+//go:build !watch
+// +build !watch
+
+package main
+
+import (
+	"errors"
+)
+
+// The -watch mode needs fsnotify, it's only built with the watch tag
+// so the default gogrep build doesn't depend on it.
+const watchSupported = false
+
+func (p *program) watchFiles() error {
+	return errors.New("-watch is not supported by this gogrep build")
+}
@@ -2,10 +2,9 @@ package main
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"go/ast"
 	"go/parser"
-	"go/printer"
 	"go/token"
 	"os"
 	"path/filepath"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/quasilyte/gogrep"
 	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
 	"github.com/quasilyte/perf-heatmap/heatmap"
 )
 
@@ -21,65 +21,295 @@ type worker struct {
 
 	countMode bool
 
-	needCapture   bool
-	needMatchLine bool
+	// dryRun is set for the -dry-run mode, the files that would be
+	// searched are collected into dryRunFiles instead of being read.
+	dryRun      bool
+	dryRunFiles []string
+
+	// testMode is set for the `gogrep test` mode, the `// want` comments
+	// of every file are collected into wants.
+	testMode bool
+	wants    []wantAnnotation
+
+	// fileQuery is set for the -file-query mode, the rules
+	// are applied to the files instead of the AST nodes.
+	fileQuery bool
+
+	// commentQuery is set for the -comment-query mode, the rules
+	// are applied to the file comments instead of the AST nodes.
+	commentQuery bool
+
+	// invertKind is a node kind for the -invert-match mode.
+	// nodetag.Unknown means that the mode is disabled.
+	invertKind nodetag.Value
+
+	sinks []sinkPattern
+
+	// nfc enables the Unicode NFC normalization for the filter text comparisons.
+	nfc bool
+
+	// lang is a -lang Go version, zero value means that any syntax is accepted.
+	lang langVersion
+
+	// checkBuildTags is unset for -include-ignored, otherwise the files which build
+	// constraints can't be satisfied with the buildTags on any platform are skipped.
+	checkBuildTags bool
+	buildTags      map[string]bool
+
+	// notIn are -not-in scope patterns, they're matched against the match ancestors.
+	// They need their own state as they're executed while the gogrepState is in use.
+	notIn      []*gogrep.Pattern
+	notInState gogrep.MatcherState
+
+	// mask are -mask patterns, the matching nodes are not traversed along with their subtrees.
+	// They're executed before the node is visited, so they can share the gogrepState.
+	mask []*gogrep.Pattern
+
+	// lineRanges are -lines ranges, the nodes outside of them are not traversed.
+	// linePosRanges are these ranges positions inside of the current file.
+	lineRanges    []lineRange
+	linePosRanges []posRange
+
+	// maxFileSize is a -max-filesize limit, zero means unlimited.
+	maxFileSize int64
+
+	// filterPatternState is used by the filter patterns, like the FollowedBy() one,
+	// they're executed while the gogrepState is in use.
+	filterPatternState gogrep.MatcherState
+
+	needCapture     bool
+	needMatchLine   bool
+	needFingerprint bool
+
+	// baseline is a set of the matches that should be suppressed, can be nil.
+	baseline *baseline
+	// baselineDir is the -baseline (or -write-baseline) file directory.
+	baselineDir string
+
+	// pkgPaths caches the directories package import paths, see filePkgPath.
+	pkgPaths map[string]pkgPathInfo
 
 	workDir            string
 	heatmapFilenameSet map[string]struct{}
 	heatmap            *heatmap.Index
 
-	filterHints filterHints
-	filterInfo  *filters.Info
-	filterExpr  *filters.Expr
+	// pkgRefs is a package identifiers index, it's only set if some rule uses PkgRefs().
+	pkgRefs *pkgRefsIndex
+
+	// ctx is the -timeout search context, it's nil if there is no timeout.
+	// interrupted is set once a match is interrupted by it, the remaining nodes are not matched.
+	ctx         context.Context
+	interrupted bool
+
+	rules []*rule
+
+	// patterns are worker-local rules[i].m clones.
+	patterns []*gogrep.Pattern
+
+	// activeRules are indexes of the rules that apply to the current file.
+	activeRules []int
+
+	// firstRuleWins is set for the -rule-mode first, the rules that follow
+	// the first matching rule are not tried for the same node.
+	firstRuleWins bool
 
-	m           *gogrep.Pattern
 	gogrepState gogrep.MatcherState
 	fset        *token.FileSet
 
+	files fileReader
+
 	matches []match
 
-	errors []string
+	errors []fileError
+
+	// warnings are the problems that don't prevent the file from being searched,
+	// like a malformed build constraint. They're reported after the search.
+	warnings []fileError
 
 	data      []byte
 	filename  string
 	pkgName   string
+	pkgLine   int
 	typeName  string
 	funcName  string
 	closureID int
 
+	// funcDecl is the function declaration that is being visited.
+	// It's nil for the file scope nodes.
+	funcDecl *ast.FuncDecl
+
+	// inFuncBody is set in -comment-query mode for the comments located
+	// inside a function body, including the package-level function literals.
+	inFuncBody bool
+
+	// contextFunc enables the -context-func match context recording.
+	contextFunc bool
+
+	// mergeOverlapping enables the -merge-overlapping mode, the file matches
+	// with identical positions are merged after the file is processed.
+	mergeOverlapping bool
+
+	// keepScope is a -first-per or -last-per scope, only one match
+	// of every rule is kept inside such scope. keepLast is set for -last-per.
+	keepScope matchScope
+	keepLast  bool
+
+	// clones enables the -clones mode match keys computation.
+	clones bool
+
+	// importAliases is set for the -import-aliases mode, the file
+	// import specs are collected instead of running the patterns.
+	importAliases bool
+
+	// receiverNames is set for the -receiver-names mode, the file
+	// method receivers are collected instead of running the patterns.
+	receiverNames bool
+
+	// ctags is set for the ctags output format, the match tag names are computed.
+	ctags bool
+
+	// blankImports is set for the -blank-imports mode, the file blank
+	// imports and init functions calls are collected instead of running the patterns.
+	blankImports bool
+
+	// report is a -report capture name, matches are reported using its position.
+	// An empty string means that the entire match is reported.
+	report string
+
+	// distinct is a -distinct capture name, its text values are collected instead of the matches.
+	distinct       string
+	distinctValues map[string]struct{}
+
+	// countByLine is set for -count-by line, the matches are counted per line instead of being collected.
+	countByLine bool
+	lineCounts  map[lineKey]int
+
+	// ancestors is a stack of the nodes enclosing the currently visited node.
+	ancestors []ast.Node
+
+	// visited is the currently visited node.
+	visited ast.Node
+
+	stats searchStats
+
 	n int
 }
 
 func (w *worker) grepFile(filename string) (int, error) {
+	if w.ctx != nil && w.ctx.Err() != nil {
+		// The search has timed out, the remaining files are drained from the queue.
+		return 0, nil
+	}
+
+	if w.testMode {
+		if err := w.collectWants(filename); err != nil {
+			return 0, err
+		}
+	}
+
 	// When doing a heatmap-based filtering, we can skip files
 	// that are 100% outside of the heatmap.
 	// heatmapFilenameSet is non nil if we should do this optimization.
 	if w.heatmapFilenameSet != nil {
 		if _, ok := w.heatmapFilenameSet[filepath.Base(filename)]; !ok {
+			w.stats.filesSkipped[skipHeatmap]++
 			return 0, nil
 		}
 	}
 
-	if w.filterHints.testCond != bool3unset {
-		isTest := strings.HasSuffix(filename, "_test.go")
-		if !w.filterHints.testCond.Eq(isTest) {
+	isTest := strings.HasSuffix(filename, "_test.go")
+	needComments := w.commentQuery
+	w.activeRules = w.activeRules[:0]
+	for i, r := range w.rules {
+		if r.filterHints.testCond != bool3unset && !r.filterHints.testCond.Eq(isTest) {
+			continue
+		}
+		if r.filterHints.autogenCond != bool3unset {
+			needComments = true
+		}
+		w.activeRules = append(w.activeRules, i)
+	}
+	if len(w.activeRules) == 0 {
+		w.stats.filesSkipped[skipTestFilter]++
+		return 0, nil
+	}
+
+	// The size is checked before the file is read, so the huge
+	// generated files are skipped without loading them into memory.
+	if w.maxFileSize > 0 {
+		info, err := os.Stat(filename)
+		if err == nil && info.Size() > w.maxFileSize {
+			w.stats.filesSkipped[skipFileSize]++
 			return 0, nil
 		}
 	}
 
-	data, err := os.ReadFile(filename)
+	data, err := w.files.read(filename)
 	if err != nil {
-		return 0, fmt.Errorf("read file: %v", err)
+		return 0, &readFileError{err: err}
+	}
+	// Matches copy everything they need from the file data,
+	// so it can be released as soon as the file is processed.
+	defer func() {
+		w.data = nil
+		_ = w.files.release()
+	}()
+
+	if w.checkBuildTags {
+		x, err := buildConstraint(filename, data)
+		if err != nil {
+			w.warnings = append(w.warnings, fileError{filename: filename, err: err})
+		}
+		if x != nil && isBuildIgnored(x, w.buildTags) {
+			w.stats.filesSkipped[skipBuildConstraint]++
+			return 0, nil
+		}
+	}
+
+	// The autogen hints can't be checked without parsing the file,
+	// so the dry run is optimistic about them.
+	if w.dryRun {
+		w.dryRunFiles = append(w.dryRunFiles, filename)
+		return 0, nil
+	}
+
+	w.activeRules = w.prescreenRules(data)
+	if len(w.activeRules) == 0 {
+		w.stats.filesSkipped[skipPrescreen]++
+		return 0, nil
 	}
 
+	// Every file is parsed exactly once, all active rules are then
+	// executed over this AST in a single walk.
+	// Any other per-file data (like types info) should be computed here as well,
+	// so it's shared between the rules instead of being re-created for every pattern.
 	w.fset = token.NewFileSet()
-	root, err := w.parseFile(w.fset, filename, data)
+	root, err := w.parseFile(w.fset, filename, data, needComments)
 	if err != nil {
 		return 0, err
 	}
 
-	if w.filterHints.autogenCond != bool3unset {
-		if !w.filterHints.autogenCond.Eq(isAutogenFile(root)) {
+	if w.lineRanges != nil {
+		w.linePosRanges = lineRangesPos(w.fset.File(root.Pos()), w.lineRanges)
+		if len(w.linePosRanges) == 0 {
+			w.stats.filesSkipped[skipLineRanges]++
+			return 0, nil
+		}
+	}
+
+	if needComments {
+		isAutogen := isAutogenFile(root)
+		active := w.activeRules[:0]
+		for _, i := range w.activeRules {
+			cond := w.rules[i].filterHints.autogenCond
+			if cond != bool3unset && !cond.Eq(isAutogen) {
+				continue
+			}
+			active = append(active, i)
+		}
+		w.activeRules = active
+		if len(w.activeRules) == 0 {
+			w.stats.filesSkipped[skipAutogenFilter]++
 			return 0, nil
 		}
 	}
@@ -87,23 +317,73 @@ func (w *worker) grepFile(filename string) (int, error) {
 	w.data = data
 	w.filename = filename
 	w.pkgName = root.Name.Name
+	w.pkgLine = w.fset.Position(root.Package).Line
 
 	w.n = 0
+	w.ancestors = w.ancestors[:0]
+	w.stats.filesScanned++
 
+	if w.fileQuery {
+		w.queryFile(root)
+		return w.n, nil
+	}
+	if w.commentQuery {
+		w.queryComments(root)
+		return w.n, nil
+	}
+	if w.importAliases {
+		w.collectImportSpecs(root)
+		return w.n, nil
+	}
+	if w.receiverNames {
+		w.collectReceivers(root)
+		return w.n, nil
+	}
+	if w.blankImports {
+		w.collectBlankImports(root)
+		return w.n, nil
+	}
+
+	fileMatchesStart := len(w.matches)
 	walker := astWalker{
 		worker: w,
 		visit:  w.Visit,
 	}
 	walker.walk(root)
+	if w.keepScope != scopeNone {
+		w.keepScopeMatches(fileMatchesStart)
+	}
+	if w.mergeOverlapping {
+		w.mergeOverlappingMatches(fileMatchesStart)
+	}
 
 	return w.n, nil
 }
 
-func (w *worker) parseFile(fset *token.FileSet, filename string, data []byte) (*ast.File, error) {
-	needComments := false
-	if w.filterHints.autogenCond != bool3unset {
-		needComments = true
+// prescreenRules removes the active rules that can't match the file,
+// as it doesn't contain some of their required identifiers.
+// It's a plain text search, so the identifiers inside the comments
+// and string literals are taken into account as well.
+func (w *worker) prescreenRules(data []byte) []int {
+	active := w.activeRules[:0]
+	for _, i := range w.activeRules {
+		if containsAll(data, w.rules[i].requiredIdents) {
+			active = append(active, i)
+		}
 	}
+	return active
+}
+
+func containsAll(data []byte, words [][]byte) bool {
+	for _, word := range words {
+		if !bytes.Contains(data, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *worker) parseFile(fset *token.FileSet, filename string, data []byte, needComments bool) (*ast.File, error) {
 	parserFlags := parser.Mode(0)
 	if needComments {
 		parserFlags |= parser.ParseComments
@@ -112,50 +392,243 @@ func (w *worker) parseFile(fset *token.FileSet, filename string, data []byte) (*
 	if err != nil {
 		return nil, err
 	}
+	if w.lang != 0 {
+		if err := checkLangVersion(fset, f, w.lang); err != nil {
+			return nil, err
+		}
+	}
 	return f, nil
 }
 
 func (w *worker) Visit(n ast.Node) {
-	w.m.MatchNode(&w.gogrepState, n, func(data gogrep.MatchData) {
-		accept := w.filterExpr.Op == filters.OpNop ||
-			applyFilter(filterContext{w: w, m: data}, w.filterExpr, data.Node)
-		if !accept {
-			return
+	if w.interrupted {
+		return
+	}
+	w.visited = n
+	w.stats.nodesVisited++
+	kind := nodetag.FromNode(n)
+	for _, i := range w.activeRules {
+		r := w.rules[i]
+		// Inverted matching needs the pattern to be executed for every candidate.
+		if r.rootKind != nodetag.Node && r.rootKind != kind && w.invertKind == nodetag.Unknown {
+			continue
+		}
+		if w.visitRule(r, w.patterns[i], n) && w.firstRuleWins {
+			break
 		}
+	}
+}
 
-		w.n++
+// visitRule runs the r rule for the n node and reports
+// whether any matches were found (even if they're suppressed by the baseline).
+func (w *worker) visitRule(r *rule, pat *gogrep.Pattern, n ast.Node) bool {
+	if w.invertKind != nodetag.Unknown {
+		return w.visitRuleInverted(r, pat, n)
+	}
 
-		if w.countMode {
+	found := false
+	w.matchNode(pat, &w.gogrepState, n, func(data gogrep.MatchData) {
+		if !w.acceptMatch(r, data) || w.inExcludedScope() {
 			return
 		}
+		found = true
+		w.addMatch(r, w.reportedNode(data), data.Capture)
+	})
+	return found
+}
 
-		start := w.fset.Position(data.Node.Pos())
-		end := w.fset.Position(data.Node.End())
-		m := match{
-			filename:    w.filename,
-			line:        start.Line,
-			startOffset: start.Offset,
-			endOffset:   end.Offset,
-		}
-		if w.needCapture {
-			w.initMatchCapture(&m, data.Capture)
+// visitRuleInverted reports n if it's an invert-match candidate
+// that is not matched by the pattern.
+func (w *worker) visitRuleInverted(r *rule, pat *gogrep.Pattern, n ast.Node) bool {
+	if !nodeKindMatches(w.invertKind, n) {
+		return false
+	}
+	matched := false
+	w.matchNode(pat, &w.gogrepState, n, func(data gogrep.MatchData) {
+		if !matched {
+			matched = w.acceptMatch(r, data)
 		}
-		w.initMatchText(&m, start.Offset, end.Offset)
-		w.matches = append(w.matches, m)
 	})
+	if !matched && !w.interrupted && !w.inExcludedScope() {
+		w.addMatch(r, n, nil)
+		return true
+	}
+	return false
+}
+
+// inExcludedScope reports whether any of the current node ancestors
+// is matched by one of the -not-in patterns.
+func (w *worker) inExcludedScope() bool {
+	if len(w.notIn) == 0 {
+		return false
+	}
+	matched := false
+	for i := len(w.ancestors) - 1; i >= 0; i-- {
+		for _, pat := range w.notIn {
+			pat.MatchNode(&w.notInState, w.ancestors[i], func(gogrep.MatchData) {
+				matched = true
+			})
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMasked reports whether n is matched by one of the -mask patterns.
+func (w *worker) isMasked(n ast.Node) bool {
+	if len(w.mask) == 0 {
+		return false
+	}
+	kind := nodetag.FromNode(n)
+	for _, pat := range w.mask {
+		if rootKind := pat.RootKind(); rootKind != nodetag.Node && rootKind != kind {
+			continue
+		}
+		matched := false
+		pat.MatchNode(&w.gogrepState, n, func(gogrep.MatchData) {
+			matched = true
+		})
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// reportedNode returns the -report capture node for the match.
+// If the capture is empty (like $*x that matched nothing), the entire match is reported.
+func (w *worker) reportedNode(data gogrep.MatchData) ast.Node {
+	if w.report == "" {
+		return data.Node
+	}
+	n, ok := data.CapturedByName(w.report)
+	if !ok || gogrep.IsEmptyNodeSlice(n) {
+		return data.Node
+	}
+	return n
+}
+
+func (w *worker) acceptMatch(r *rule, data gogrep.MatchData) bool {
+	return r.filterExpr.Op == filters.OpNop ||
+		applyFilter(filterContext{w: w, r: r, m: data}, r.filterExpr, data.Node)
+}
+
+func (w *worker) addMatch(r *rule, n ast.Node, capture []gogrep.CapturedNode) {
+	start := w.fset.Position(n.Pos())
+
+	fingerprint := ""
+	if w.needFingerprint {
+		fingerprint = w.matchFingerprint(r, w.sourceLine(start.Offset))
+		if w.baseline != nil && w.baseline.suppress(fingerprint) {
+			return
+		}
+	}
+
+	w.n++
+
+	if w.distinct != "" {
+		w.addDistinctValue(capture)
+		return
+	}
+	if w.countByLine {
+		w.addLineCount(start.Line)
+		return
+	}
+	if w.countMode {
+		return
+	}
+
+	end := w.fset.Position(n.End())
+	m := match{
+		rule:        r,
+		fingerprint: fingerprint,
+		kind:        nodeKindName(n),
+		filename:    w.filename,
+		line:        start.Line,
+		column:      start.Column,
+		endLine:     end.Line,
+		endColumn:   end.Column,
+		startOffset: start.Offset,
+		endOffset:   end.Offset,
+	}
+	if w.needCapture {
+		w.initMatchCapture(&m, capture)
+	}
+	if w.contextFunc {
+		w.initMatchContext(&m)
+	}
+	if w.keepScope == scopeFunc {
+		m.scopeOffset = fileScopeOffset
+		if w.funcDecl != nil {
+			m.scopeOffset = w.fset.Position(w.funcDecl.Pos()).Offset
+		}
+	}
+	if w.clones {
+		w.initMatchCloneKey(&m)
+	}
+	if w.ctags {
+		m.tag = w.matchTag(n, capture)
+	}
+	switch n.(type) {
+	case *ast.GenDecl, *ast.ValueSpec:
+		m.consts = w.matchConsts(n)
+	}
+	w.initMatchText(&m, start.Offset, end.Offset)
+	w.matches = append(w.matches, m)
 }
 
 func (w *worker) initMatchCapture(m *match, capture []gogrep.CapturedNode) {
-	m.capture = make([]capturedNode, len(capture))
-	for i, c := range capture {
+	m.capture = make([]capturedNode, 0, len(capture))
+	for _, c := range capture {
+		if gogrep.IsEmptyNodeSlice(c.Node) {
+			// Empty slices have no position, so they're bound to an empty text.
+			m.capture = append(m.capture, capturedNode{
+				startOffset: m.startOffset,
+				endOffset:   m.startOffset,
+				data:        c,
+			})
+			continue
+		}
+		if !hasSourcePos(c.Node) {
+			text, _ := w.nodeSourceText(c.Node)
+			m.capture = append(m.capture, capturedNode{
+				startOffset: m.startOffset,
+				endOffset:   m.startOffset,
+				data:        c,
+				reprinted:   true,
+				text:        string(text),
+			})
+			continue
+		}
 		startOffset := w.fset.Position(c.Node.Pos()).Offset
 		endOffset := w.fset.Position(c.Node.End()).Offset
-		m.capture[i] = capturedNode{
+		// With -report, the reported node may not contain all captures.
+		if startOffset < m.startOffset || endOffset > m.endOffset {
+			continue
+		}
+		m.capture = append(m.capture, capturedNode{
 			startOffset: startOffset,
 			endOffset:   endOffset,
 			data:        c,
-		}
+		})
+	}
+}
+
+// initMatchContext records the function signature that encloses the match.
+// For the file scope matches, the package clause is used instead.
+func (w *worker) initMatchContext(m *match) {
+	if w.funcDecl == nil {
+		m.context = "package " + w.pkgName
+		m.contextLine = w.pkgLine
+		return
 	}
+	decl := w.funcDecl
+	from := w.fset.Position(decl.Pos()).Offset
+	to := w.fset.Position(decl.Type.End()).Offset
+	m.context = collapseSpaces(string(w.data[from:to]))
+	m.contextLine = w.fset.Position(decl.Pos()).Line
 }
 
 func (w *worker) initMatchText(m *match, startPos, endPos int) {
@@ -195,29 +668,39 @@ func (w *worker) initMatchText(m *match, startPos, endPos int) {
 	m.matchLength = endPos - startPos
 }
 
+// nodeText returns the n source text.
+// The nodes that can't be sliced from the source are reprinted, see nodeSourceText.
 func (w *worker) nodeText(n ast.Node) []byte {
+	text, _ := w.nodeSourceText(n)
+	return text
+}
+
+// nodeSourceText is like nodeText, but it also reports whether the text
+// was reprinted instead of being taken from the source verbatim.
+//
+// The reprinted text is gofmt-consistent, but it doesn't preserve
+// the original formatting and comments.
+func (w *worker) nodeSourceText(n ast.Node) (text []byte, reprinted bool) {
 	if gogrep.IsEmptyNodeSlice(n) {
-		return nil
+		return nil, false
 	}
 
-	from := w.fset.Position(n.Pos()).Offset
-	to := w.fset.Position(n.End()).Offset
-	src := w.data
-	if (from >= 0 && from < len(src)) && (to >= 0 && to < len(src)) {
-		return src[from:to]
+	if hasSourcePos(n) {
+		from := w.fset.Position(n.Pos()).Offset
+		to := w.fset.Position(n.End()).Offset
+		src := w.data
+		// The end offset is exclusive, a node can end right at the end of file.
+		if from >= 0 && from <= to && to <= len(src) {
+			return src[from:to], false
+		}
 	}
 
-	// Go printer would panic on comments.
+	// The comment text is verbatim even if it can't be sliced from the source.
 	if n, ok := n.(*ast.Comment); ok {
-		return []byte(n.Text)
+		return []byte(n.Text), false
 	}
 
-	// Fallback to the printer.
-	var buf bytes.Buffer
-	if err := printer.Fprint(&buf, w.fset, n); err != nil {
-		panic(err)
-	}
-	return buf.Bytes()
+	return reprintNode(w.fset, n), true
 }
 
 func isAutogenFile(f *ast.File) bool {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
+)
+
+func BenchmarkVisit(b *testing.B) {
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "main.go", nil, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"call", `fmt.Errorf($*_)`},
+		{"ifStmt", `if err != nil { $*_ }`},
+		{"return", `return $x, nil`},
+		{"wildcard", `$x`},
+	}
+
+	for _, test := range tests {
+		pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: test.src})
+		if err != nil {
+			b.Fatal(err)
+		}
+		// If narrow is false, the pattern is executed for every node.
+		newWorker := func(narrow bool) *worker {
+			r := &rule{
+				m:          pat,
+				rootKind:   nodetag.Node,
+				filterExpr: &filters.Expr{Op: filters.OpNop},
+			}
+			if narrow {
+				r.rootKind = pat.RootKind()
+			}
+			return &worker{
+				countMode:   true,
+				rules:       []*rule{r},
+				patterns:    []*gogrep.Pattern{pat},
+				activeRules: []int{0},
+				gogrepState: gogrep.NewMatcherState(),
+				fset:        fset,
+			}
+		}
+		walk := func(w *worker) int {
+			w.n = 0
+			walker := astWalker{worker: w, visit: w.Visit}
+			walker.walk(root)
+			return w.n
+		}
+
+		have := walk(newWorker(true))
+		want := walk(newWorker(false))
+		if have != want {
+			b.Fatalf("%s: found %d matches with RootKind, %d without it", test.src, have, want)
+		}
+
+		b.Run(test.name+"/allNodes", func(b *testing.B) {
+			w := newWorker(false)
+			for i := 0; i < b.N; i++ {
+				walk(w)
+			}
+		})
+		b.Run(test.name+"/rootKind", func(b *testing.B) {
+			w := newWorker(true)
+			for i := 0; i < b.N; i++ {
+				walk(w)
+			}
+		})
+	}
+}
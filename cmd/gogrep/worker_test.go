@@ -0,0 +1,1661 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/quasilyte/gogrep"
+	"github.com/quasilyte/gogrep/filters"
+	"github.com/quasilyte/gogrep/nodetag"
+)
+
+func TestCaptureBodyTemplate(t *testing.T) {
+	const src = `package p
+
+func f(a int) (int, error) {
+	x := a + 1
+	if x > 0 {
+		return x, nil
+	}
+	return 0, nil
+}
+
+func g() {}
+`
+	const format = `func {{.name}}{{.params}} {{.results}} {
+	defer recover()
+	{{.body}}
+}`
+	want := []string{
+		`func f(a int) (int, error) {
+	defer recover()
+	x := a + 1
+	if x > 0 {
+		return x, nil
+	}
+	return 0, nil
+}`,
+		// The empty body leaves the template indentation only.
+		"func g()  {\n\tdefer recover()\n\t\n}",
+	}
+
+	w := testGrepSource(t, `func $name($*params) $results { $*body }`, src, false)
+
+	config := renderConfig{
+		tmpl:      template.Must(template.New("format").Parse(format)),
+		multiline: true,
+	}
+	var have []string
+	for _, m := range w.matches {
+		s, err := renderTemplate(m, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have = append(have, s)
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("render body:\nhave:\n%s\nwant:\n%s", strings.Join(have, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMatchText(t *testing.T) {
+	// The source doesn't end with a newline, so the last match ends right at the end of file.
+	const src = "package p\n" +
+		"func f() {\n" +
+		"\tx := g(1, 2) + T{a:  1}.b\n" +
+		"\tif x > 0 { return }\n" +
+		"}\n" +
+		"var v = []int{\n" +
+		"\t1,\n" +
+		"}"
+
+	tests := []struct {
+		pat     string
+		text    string
+		line    string
+		capture string
+	}{
+		{`$f($*args)`, `g(1, 2)`, "\tx := g(1, 2) + T{a:  1}.b", `g 1, 2`},
+		{`T{$k: $v}`, `T{a:  1}`, "\tx := g(1, 2) + T{a:  1}.b", `a 1`},
+		{`$x.b`, `T{a:  1}.b`, "\tx := g(1, 2) + T{a:  1}.b", `T{a:  1}`},
+		{`if $cond { $*body }`, `if x > 0 { return }`, "\tif x > 0 { return }", `x > 0 return`},
+		{`$x := $y; $*rest`, "x := g(1, 2) + T{a:  1}.b\n\tif x > 0 { return }", "\tx := g(1, 2) + T{a:  1}.b", `x g(1, 2) + T{a:  1}.b if x > 0 { return }`},
+		{`[]int{$*xs}`, "[]int{\n\t1,\n}", "var v = []int{", `1`},
+	}
+
+	for _, test := range tests {
+		for _, needMatchLine := range []bool{false, true} {
+			w := testGrepSource(t, test.pat, src, needMatchLine)
+			if len(w.matches) != 1 {
+				t.Fatalf("%s: expected 1 match, found %d", test.pat, len(w.matches))
+			}
+			m := w.matches[0]
+			text := m.text[m.matchStartOffset : m.matchStartOffset+m.matchLength]
+			if text != test.text {
+				t.Errorf("%s: match text:\nhave: %q\nwant: %q", test.pat, text, test.text)
+			}
+			if needMatchLine {
+				line := m.text
+				if i := strings.IndexByte(line, '\n'); i != -1 {
+					line = line[:i]
+				}
+				if line != test.line {
+					t.Errorf("%s: match line:\nhave: %q\nwant: %q", test.pat, line, test.line)
+				}
+			}
+			var capture []string
+			for _, c := range m.capture {
+				capture = append(capture, m.captureText(c))
+			}
+			if have := strings.Join(capture, " "); have != test.capture {
+				t.Errorf("%s: capture (match line=%v):\nhave: %q\nwant: %q", test.pat, needMatchLine, have, test.capture)
+			}
+		}
+	}
+}
+
+func TestNodeTextEndOfFile(t *testing.T) {
+	// The source text is used as is, even if it's not formatted.
+	const src = "package p\nvar v = T{a:  1}"
+	w := testGrepSource(t, `var v = $x`, src, false)
+	if len(w.matches) != 1 {
+		t.Fatalf("expected 1 match, found %d", len(w.matches))
+	}
+	lit := w.matches[0].capture[0].data.Node
+	if have := string(w.nodeText(lit)); have != `T{a:  1}` {
+		t.Errorf("node text:\nhave: %q\nwant: %q", have, `T{a:  1}`)
+	}
+}
+
+func TestNodeSourceTextReprint(t *testing.T) {
+	const src = "package p\nvar v = T{a:  1}"
+	w := testGrepSource(t, `var v = $x`, src, false)
+	if len(w.matches) != 1 {
+		t.Fatalf("expected 1 match, found %d", len(w.matches))
+	}
+
+	// Synthesized nodes have no positions, so they can't be sliced from the source.
+	call := &ast.CallExpr{
+		Fun:  ast.NewIdent("f"),
+		Args: []ast.Expr{ast.NewIdent("a"), &ast.BasicLit{Kind: token.INT, Value: "1"}},
+	}
+	field := &ast.Field{
+		Names: []*ast.Ident{ast.NewIdent("a"), ast.NewIdent("b")},
+		Type:  ast.NewIdent("int"),
+	}
+	tests := []struct {
+		node      ast.Node
+		text      string
+		reprinted bool
+	}{
+		{w.matches[0].capture[0].data.Node, `T{a:  1}`, false},
+		{call, `f(a, 1)`, true},
+		{field, `a, b int`, true},
+		{&ast.FieldList{List: []*ast.Field{field, {Type: ast.NewIdent("string")}}}, `a, b int, string`, true},
+		{&gogrep.OperatorNode{Op: token.ADD}, `+`, true},
+		{&ast.ReturnStmt{Results: []ast.Expr{call}}, `return f(a, 1)`, true},
+	}
+	for _, test := range tests {
+		text, reprinted := w.nodeSourceText(test.node)
+		if string(text) != test.text || reprinted != test.reprinted {
+			t.Errorf("%T text:\nhave: %q (reprinted=%v)\nwant: %q (reprinted=%v)",
+				test.node, text, reprinted, test.text, test.reprinted)
+		}
+	}
+
+	m := w.matches[0]
+	w.initMatchCapture(&m, []gogrep.CapturedNode{{Name: "x", Node: call}})
+	if len(m.capture) != 1 || !m.capture[0].reprinted {
+		t.Fatalf("expected a reprinted capture, found %+v", m.capture)
+	}
+	if have := m.captureText(m.capture[0]); have != `f(a, 1)` {
+		t.Errorf("capture text:\nhave: %q\nwant: %q", have, `f(a, 1)`)
+	}
+}
+
+func TestGrepFileStats(t *testing.T) {
+	files := map[string]string{
+		"a.go":      "package p\nfunc f() { println(1) }\n",
+		"a_test.go": "package p\nfunc g() { println(2) }\n",
+		"b.go":      "package p\nfunc h() {}\n",
+		"gen.go":    "// Code generated by gen. DO NOT EDIT.\n\npackage p\nfunc i() { println(3) }\n",
+		"big.go":    "package p\nfunc j() { println(`" + strings.Repeat("x", 100) + "`) }\n",
+	}
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: `println($_)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &worker{
+		countMode:   true,
+		maxFileSize: 100,
+		rules: []*rule{{
+			m:              pat,
+			rootKind:       pat.RootKind(),
+			filterExpr:     &filters.Expr{Op: filters.OpNop},
+			filterHints:    filterHints{testCond: newBool3(false), autogenCond: newBool3(false)},
+			requiredIdents: [][]byte{[]byte("println")},
+		}},
+		patterns:    []*gogrep.Pattern{pat},
+		gogrepState: gogrep.NewMatcherState(),
+	}
+	for _, name := range []string{"a.go", "a_test.go", "b.go", "gen.go", "big.go"} {
+		if _, err := w.grepFile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var want searchStats
+	want.filesScanned = 1
+	want.filesSkipped[skipTestFilter] = 1
+	want.filesSkipped[skipPrescreen] = 1
+	want.filesSkipped[skipAutogenFilter] = 1
+	want.filesSkipped[skipFileSize] = 1
+	// All a.go nodes, including the package and function names and the func type params.
+	want.nodesVisited = 11
+	if w.stats != want {
+		t.Errorf("stats:\nhave: %+v\nwant: %+v", w.stats, want)
+	}
+}
+
+func TestRescanFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, src string) string {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	a := writeFile("a.go", "package p\nfunc f() { println(1) }\n")
+	b := writeFile("b.go", "package p\nfunc g() { println(2) }\n")
+	c := writeFile("c.go", "package p\nfunc h() { println(3) }\n")
+
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: `println($_)`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newWorker := func() *worker {
+		return &worker{
+			rules: []*rule{{
+				m:          pat,
+				rootKind:   pat.RootKind(),
+				filterExpr: &filters.Expr{Op: filters.OpNop},
+			}},
+			patterns:    []*gogrep.Pattern{pat},
+			gogrepState: gogrep.NewMatcherState(),
+		}
+	}
+	p := &program{workers: []*worker{newWorker(), newWorker()}}
+	for i, filename := range []string{a, b, c} {
+		if _, err := p.workers[i%2].grepFile(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("a.go", "package p\nfunc f() { println(1); println(10) }\n")
+	if err := os.Remove(c); err != nil {
+		t.Fatal(err)
+	}
+	p.rescanFiles([]string{a, c})
+
+	var have []string
+	for _, m := range p.sortedMatches() {
+		have = append(have, fmt.Sprintf("%s:%d", filepath.Base(m.filename), m.startOffset))
+	}
+	want := []string{"a.go:21", "a.go:33", "b.go:21"}
+	if strings.Join(have, " ") != strings.Join(want, " ") {
+		t.Errorf("matches after rescan:\nhave: %v\nwant: %v", have, want)
+	}
+	if p.numMatches != 3 {
+		t.Errorf("numMatches after rescan: have %d, want 3", p.numMatches)
+	}
+}
+
+func TestReceiverNameGroups(t *testing.T) {
+	files := map[string]string{
+		"a.go":      "package p\ntype T struct{}\nfunc (t *T) A() {}\nfunc (t T) B() {}\nfunc (self *T) C() {}\n",
+		"b.go":      "package p\ntype U struct{}\nfunc (u U) A() {}\nfunc (U) B() {}\nfunc (_ U) C() {}\n",
+		"c.go":      "package p\ntype L[E any] struct{}\nfunc (l *L[E]) A() {}\nfunc (list L[E]) B() {}\n",
+		"c_test.go": "package p_test\nfunc (x *T) A() {}\n",
+		"sub/d.go":  "package sub\nfunc (x *T) A() {}\nfunc (y T) B() {}\n",
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	w := &worker{
+		receiverNames: true,
+		rules:         []*rule{{filterExpr: &filters.Expr{Op: filters.OpNop}}},
+	}
+	for name, src := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.grepFile(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &program{workers: []*worker{w}}
+	var have []string
+	for _, g := range p.collectReceiverNameGroups() {
+		have = append(have, g.typeName+": "+strings.Join(g.names, " "))
+	}
+	want := []string{
+		"p.T: self t",
+		"p.L: l list",
+		"sub.T: x y",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("groups:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestBlankImportGroups(t *testing.T) {
+	files := map[string]string{
+		"go.mod":     "module example.com/m\n",
+		"a.go":       "package p\nimport (\n\t_ \"embed\"\n\t_ \"example.com/m/drv\"\n\t\"fmt\"\n)\n",
+		"b.go":       "package p\nimport _ \"example.com/m/drv\"\n",
+		"drv/drv.go": "package drv\nimport \"database/sql\"\nfunc init() {\n\tsql.Register(\"fake\", nil)\n\tgo func() { sql.Drivers() }()\n\tregister()\n}\nfunc register() { sql.Register(\"fake2\", nil) }\n",
+		"sub/c.go":   "package sub\nimport \"os\"\nfunc init() { os.Setenv(\"X\", \"1\") }\n",
+	}
+	dir := t.TempDir()
+	for _, sub := range []string{"drv", "sub"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := &worker{
+		blankImports: true,
+		rules:        []*rule{{filterExpr: &filters.Expr{Op: filters.OpNop}}},
+	}
+	for name, src := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name := range files {
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		if _, err := w.grepFile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &program{workers: []*worker{w}}
+	var have []string
+	for _, g := range p.collectBlankImportGroups() {
+		s := fmt.Sprintf("%s: %d files", g.path, g.numFiles)
+		for _, m := range g.initCalls {
+			s += "; " + m.text
+		}
+		have = append(have, s)
+	}
+	want := []string{
+		"embed: 1 files",
+		`example.com/m/drv: 2 files; sql.Register("fake", nil)`,
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("groups:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestPkgRefs(t *testing.T) {
+	files := map[string]string{
+		"a.go":      "package p\nfunc Used() {}\nfunc Unused() {}\nfunc helper() { Used() }\ntype T struct{ f int }\n",
+		"a_test.go": "package p_test\nimport \"example.com/m/p\"\nvar _ p.T\n",
+		"sub/b.go":  "package sub\nfunc Unused2() { var x int; _ = x }\nfunc Unused() {}\n",
+		"broken.go": "package p\nfunc helper() {",
+	}
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pattern := `func $name($*_) $*_ { $*_ }`
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &rule{pattern: pattern, m: pat, rootKind: pat.RootKind(), filter: `$name.IsExported() && $name.PkgRefs() == 0`}
+	p := &program{args: arguments{targets: filepath.Join(dir, "a.go"), workers: 2}, workers: make([]*worker, 2)}
+	if err := p.compileFilter(newFilterOperationTable(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.indexPackages(); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.pkgRefs.dirs) != 1 {
+		t.Fatalf("indexed %d dirs, want only the a.go package dir", len(p.pkgRefs.dirs))
+	}
+
+	w := &worker{
+		needCapture: true,
+		rules:       []*rule{r},
+		patterns:    []*gogrep.Pattern{r.m},
+		gogrepState: gogrep.NewMatcherState(),
+		pkgRefs:     p.pkgRefs,
+	}
+	for _, name := range []string{"a.go", "sub/b.go"} {
+		if _, err := w.grepFile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var have []string
+	for _, m := range w.matches {
+		have = append(have, m.text)
+	}
+	// sub/b.go package is not indexed, so its funcs have -1 refs.
+	want := []string{"func Unused() {}"}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("matches:\nhave: %q\nwant: %q", have, want)
+	}
+	if n := p.pkgRefs.count(dir, "T"); n != 2 {
+		t.Errorf("T refs: have %d, want 2", n)
+	}
+	if n := p.pkgRefs.count(dir, "f"); n != 1 {
+		t.Errorf("f refs: have %d, want 1", n)
+	}
+}
+
+func TestCountByLine(t *testing.T) {
+	src := `package p
+func f() {
+	g(g(1), g(2), 3)
+	g(4)
+}
+func h() { g(g(g(5))) }
+`
+	r := testCompileRule(t, `g($_)`, "")
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &worker{
+		countByLine: true,
+		rules:       []*rule{r},
+		patterns:    []*gogrep.Pattern{r.m},
+		activeRules: []int{0},
+		gogrepState: gogrep.NewMatcherState(),
+		fset:        fset,
+		data:        []byte(src),
+		filename:    "p.go",
+	}
+	walker := astWalker{worker: w, visit: w.Visit}
+	walker.walk(root)
+
+	if len(w.matches) != 0 {
+		t.Errorf("have %d matches collected, want 0", len(w.matches))
+	}
+	p := &program{workers: []*worker{w}}
+	var have []string
+	for _, c := range p.collectLineCounts() {
+		have = append(have, fmt.Sprintf("%d %s:%d", c.count, c.filename, c.line))
+	}
+	want := []string{"3 p.go:6", "2 p.go:3", "1 p.go:4"}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("line counts:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	// Without an interruption, this pattern takes seconds to fail for the f body.
+	src := "package p\nfunc f() {\n" + strings.Repeat("\tf(1)\n", 200) + "}\nfunc h() { f(1); g() }\n"
+	r := testCompileRule(t, `$*_; $*_; $*_; f(1); $*_; g()`, "")
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	w := &worker{
+		ctx:         ctx,
+		rules:       []*rule{r},
+		patterns:    []*gogrep.Pattern{r.m},
+		activeRules: []int{0},
+		gogrepState: gogrep.NewMatcherState(),
+		fset:        fset,
+		data:        []byte(src),
+		filename:    "p.go",
+	}
+	start := time.Now()
+	walker := astWalker{worker: w, visit: w.Visit}
+	walker.walk(root)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the search is interrupted after %s", elapsed)
+	}
+	if !w.interrupted {
+		t.Errorf("the search is not interrupted")
+	}
+	if len(w.matches) != 0 {
+		t.Errorf("have %d matches, want 0", len(w.matches))
+	}
+	n, err := w.grepFile("p.go")
+	if n != 0 || err != nil {
+		t.Errorf("grepFile after timeout: have (%d, %v), want (0, nil)", n, err)
+	}
+}
+
+func TestRuleModeFirst(t *testing.T) {
+	src := `package p
+func f() {
+	fmt.Println(1)
+	fmt.Printf("%d", 2)
+}`
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, firstRuleWins := range []bool{false, true} {
+		w := &worker{
+			firstRuleWins: firstRuleWins,
+			gogrepState:   gogrep.NewMatcherState(),
+			fset:          fset,
+			data:          []byte(src),
+		}
+		for i, pattern := range []string{`fmt.Println($*_)`, `fmt.$_($*_)`} {
+			pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: pattern})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.rules = append(w.rules, &rule{
+				id:         pattern,
+				m:          pat,
+				rootKind:   pat.RootKind(),
+				filterExpr: &filters.Expr{Op: filters.OpNop},
+			})
+			w.patterns = append(w.patterns, pat)
+			w.activeRules = append(w.activeRules, i)
+		}
+		walker := astWalker{worker: w, visit: w.Visit}
+		walker.walk(root)
+
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, fmt.Sprintf("%d: %s", m.line, m.rule.id))
+		}
+		want := []string{
+			`3: fmt.Println($*_)`,
+			`3: fmt.$_($*_)`,
+			`4: fmt.$_($*_)`,
+		}
+		if firstRuleWins {
+			want = []string{
+				`3: fmt.Println($*_)`,
+				`4: fmt.$_($*_)`,
+			}
+		}
+		if strings.Join(have, "\n") != strings.Join(want, "\n") {
+			t.Errorf("firstRuleWins=%v:\nhave: %q\nwant: %q", firstRuleWins, have, want)
+		}
+	}
+}
+
+// testGrepSource runs the pattern over the src file contents and returns
+// the worker with the collected matches.
+func testGrepSource(t *testing.T, pattern, src string, needMatchLine bool) *worker {
+	t.Helper()
+	return testGrepRule(t, testCompileRule(t, pattern, ""), src, needMatchLine)
+}
+
+// testGrepSourceFilter is like testGrepSource, but only the matches
+// accepted by the filter are collected.
+func testGrepSourceFilter(t *testing.T, pattern, filter, src string, needMatchLine bool) *worker {
+	t.Helper()
+	return testGrepRule(t, testCompileRule(t, pattern, filter), src, needMatchLine)
+}
+
+// testCompileRule compiles the pattern and its filter into a rule.
+// An empty filter accepts every match.
+func testCompileRule(t *testing.T, pattern, filter string) *rule {
+	t.Helper()
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &rule{
+		pattern:    pattern,
+		m:          pat,
+		rootKind:   pat.RootKind(),
+		filterExpr: &filters.Expr{Op: filters.OpNop},
+	}
+	if filter != "" {
+		p := &program{}
+		r.filter = filter
+		if err := p.compileFilter(newFilterOperationTable(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// testGrepRule runs the rule over the src file contents and returns
+// the worker with the collected matches.
+func testGrepRule(t *testing.T, r *rule, src string, needMatchLine bool) *worker {
+	t.Helper()
+	return testGrepWorker(t, &worker{needCapture: true, needMatchLine: needMatchLine}, r, src)
+}
+
+// testGrepWorker is like testGrepRule, but the mode-specific worker settings
+// are taken from w, like its invertKind or mask patterns.
+func testGrepWorker(t *testing.T, w *worker, r *rule, src string) *worker {
+	t.Helper()
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.rules = []*rule{r}
+	w.patterns = []*gogrep.Pattern{r.m}
+	w.activeRules = []int{0}
+	w.gogrepState = gogrep.NewMatcherState()
+	w.fset = fset
+	w.data = []byte(src)
+	w.filename = "p.go"
+	w.pkgName = root.Name.Name
+	w.pkgLine = fset.Position(root.Package).Line
+	walker := astWalker{worker: w, visit: w.Visit}
+	walker.walk(root)
+	return w
+}
+
+// matchTexts returns the w matches source texts.
+func matchTexts(w *worker) []string {
+	var texts []string
+	for _, m := range w.matches {
+		texts = append(texts, m.text)
+	}
+	return texts
+}
+
+func TestStrictSyntaxParens(t *testing.T) {
+	src := `package p
+func _() {
+	f(a + b)
+	f((a + b))
+	f((a) + b)
+	g((x))
+}`
+
+	tests := []struct {
+		pattern string
+		strict  bool
+		want    []string
+	}{
+		// The parens are normalized by default.
+		{`f($x + $y)`, false, []string{`f(a + b)`, `f((a + b))`, `f((a) + b)`}},
+		{`g(x)`, false, []string{`g((x))`}},
+
+		// -strict-syntax keeps the parens significant, like before the normalization.
+		{`f($x + $y)`, true, []string{`f(a + b)`, `f((a) + b)`}},
+		{`f(($x + $y))`, true, []string{`f((a + b))`}},
+		{`g(x)`, true, nil},
+		{`g((x))`, true, []string{`g((x))`}},
+	}
+
+	for _, test := range tests {
+		p := &program{
+			args:  arguments{strictSyntax: test.strict, format: defaultFormat},
+			rules: []*rule{{pattern: test.pattern, filterExpr: &filters.Expr{Op: filters.OpNop}}},
+		}
+		if err := p.compilePatterns(); err != nil {
+			t.Fatal(err)
+		}
+		w := testGrepRule(t, p.rules[0], src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.text)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s (strict=%v):\nhave: %q\nwant: %q", test.pattern, test.strict, have, test.want)
+		}
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	src := `package p
+type T struct{}
+const (
+	A = 1
+	B = 2
+)
+var _ = 0
+func (t *T) Get() int { return 1 }
+func f() {
+	fmt.Println("x")
+	go func() { panic("y") }()
+loop:
+	for {
+		break loop
+	}
+}`
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`func $name($*_) $*_ { $*_ }`, []string{`f`}},
+		{`func ($_ $_) $name($*_) $*_ { $*_ }`, []string{`Get`}},
+		{`type $_ struct{}`, []string{`T`}},
+		{`const ($*_)`, []string{``}},
+		// The blank identifiers are not symbols.
+		{`var $x = $_`, []string{``}},
+		{`fmt.Println($*_)`, []string{`f`}},
+		{`panic($_)`, []string{`f`}},
+		{`func $_() { $*_ }`, []string{`f`}},
+		{`$l: for { $*_ }`, []string{`loop`}},
+	}
+
+	for _, test := range tests {
+		r := testCompileRule(t, test.pattern, "")
+		fset := token.NewFileSet()
+		root, err := parser.ParseFile(fset, "p.go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := &worker{
+			ctags:       true,
+			rules:       []*rule{r},
+			patterns:    []*gogrep.Pattern{r.m},
+			activeRules: []int{0},
+			gogrepState: gogrep.NewMatcherState(),
+			fset:        fset,
+			data:        []byte(src),
+		}
+		walker := astWalker{worker: w, visit: w.Visit}
+		walker.walk(root)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, m.tag)
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s:\nhave: %q\nwant: %q", test.pattern, have, test.want)
+		}
+	}
+}
+
+func TestMergeOverlappingMatches(t *testing.T) {
+	src := `package p
+func f() {
+	fmt.Println("x")
+	fmt.Printf("%d", 1)
+	g(fmt.Sprint(1))
+}
+`
+	patterns := []string{
+		`fmt.Println($*_)`,
+		`fmt.$_($*_)`,
+		`$f($*_);`,
+		`fmt.Println($*_)`,
+	}
+
+	fset := token.NewFileSet()
+	root, err := parser.ParseFile(fset, "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &worker{
+		mergeOverlapping: true,
+		gogrepState:      gogrep.NewMatcherState(),
+		fset:             fset,
+		data:             []byte(src),
+	}
+	for i, pattern := range patterns {
+		r := testCompileRule(t, pattern, "")
+		w.rules = append(w.rules, r)
+		w.patterns = append(w.patterns, r.m)
+		w.activeRules = append(w.activeRules, i)
+	}
+	walker := astWalker{worker: w, visit: w.Visit}
+	walker.walk(root)
+	w.mergeOverlappingMatches(0)
+
+	var have []string
+	for _, m := range w.takeFileMatches() {
+		have = append(have, fmt.Sprintf("%s: %s", formatPatterns(m.patterns), matchText(m)))
+	}
+	want := []string{
+		// The expression statement and its call have the same range.
+		`1,2,3,4: fmt.Println("x")`,
+		`2,3: fmt.Printf("%d", 1)`,
+		`3: g(fmt.Sprint(1))`,
+		`2: fmt.Sprint(1)`,
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("merged matches:\nhave: %q\nwant: %q", have, want)
+	}
+	if w.n != len(want) {
+		t.Errorf("matches count: have %d, want %d", w.n, len(want))
+	}
+}
+
+func TestMatchConsts(t *testing.T) {
+	src := `package p
+const (
+	A Status = iota
+	B
+	C = 10
+	D
+	E = iota * 2
+	F
+)
+const X, Y = 1 << 3, other
+var v = iota
+`
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`const ($*_)`, []string{
+			`A=0, B=1, C=10, D=10, E=8, F=10`,
+			`X=8, Y=?`,
+		}},
+		// The single spec values are computed using the entire decl.
+		{`$x $_ = iota`, []string{`A=0`}},
+		{`var $x = $_`, []string{``}},
+	}
+
+	for _, test := range tests {
+		w := testGrepSource(t, test.pattern, src, false)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, formatConsts(m.consts))
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s consts:\nhave: %q\nwant: %q", test.pattern, have, test.want)
+		}
+	}
+}
+
+func TestNestedMatchesOrder(t *testing.T) {
+	src := `package p
+func f() {
+	a.b().c.d(e.f(g.h(1)), i.j)
+}
+`
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{
+			pattern: `$_.$_`,
+			want: []string{
+				`a.b().c.d`,
+				`a.b().c`,
+				`a.b`,
+				`e.f`,
+				`g.h`,
+				`i.j`,
+			},
+		},
+
+		{
+			pattern: `$_($*_)`,
+			want: []string{
+				`a.b().c.d(e.f(g.h(1)), i.j)`,
+				`a.b()`,
+				`e.f(g.h(1))`,
+				`g.h(1)`,
+			},
+		},
+
+		{
+			pattern: `$x`,
+			want: []string{
+				src[:len(src)-1], // File
+				`p`,
+				src[len("package p\n") : len(src)-1], // FuncDecl
+				`func f()`,
+				`f`,
+				`()`,
+				"{\n\ta.b().c.d(e.f(g.h(1)), i.j)\n}",
+				`a.b().c.d(e.f(g.h(1)), i.j)`, // ExprStmt
+				`a.b().c.d(e.f(g.h(1)), i.j)`, // CallExpr
+				`a.b().c.d`,
+				`a.b().c`,
+				`a.b()`,
+				`a.b`,
+				`a`,
+				`b`,
+				`c`,
+				`d`,
+				`e.f(g.h(1))`,
+				`e.f`,
+				`e`,
+				`f`,
+				`g.h(1)`,
+				`g.h`,
+				`g`,
+				`h`,
+				`1`,
+				`i.j`,
+				`i`,
+				`j`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		w := testGrepSource(t, test.pattern, src, false)
+		// The order should not depend on the order the matches were found in.
+		for i, j := 0, len(w.matches)-1; i < j; i, j = i+1, j-1 {
+			w.matches[i], w.matches[j] = w.matches[j], w.matches[i]
+		}
+		p := &program{workers: []*worker{w}}
+		var have []string
+		for _, m := range p.sortedMatches() {
+			have = append(have, matchText(m))
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("pattern %s:\nhave: %q\nwant: %q", test.pattern, have, test.want)
+		}
+		streamed := w.takeFileMatches()
+		for i := range streamed {
+			if i < len(have) && matchText(streamed[i]) != have[i] {
+				t.Errorf("pattern %s: streamed match %d: have %q, want %q", test.pattern, i, matchText(streamed[i]), have[i])
+			}
+		}
+	}
+}
+
+func TestStreamMatches(t *testing.T) {
+	newResult := func(id int, filenames ...string) fileResult {
+		r := fileResult{id: id}
+		for _, filename := range filenames {
+			r.matches = append(r.matches, match{filename: filename})
+		}
+		return r
+	}
+	results := make(chan fileResult, 5)
+	results <- newResult(2, "c.go")
+	results <- newResult(0, "a.go", "a.go")
+	results <- newResult(3)
+	results <- newResult(4, "e.go")
+	results <- newResult(1, "b.go")
+	close(results)
+
+	var have []string
+	err := streamMatches(results, func(matches []match) error {
+		for _, m := range matches {
+			have = append(have, m.filename)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.go", "a.go", "b.go", "c.go", "e.go"}
+	if strings.Join(have, " ") != strings.Join(want, " ") {
+		t.Errorf("printed matches:\nhave: %v\nwant: %v", have, want)
+	}
+}
+
+func TestMatchPrinterIDs(t *testing.T) {
+	p := &program{args: arguments{format: sarifFormat, matchIDs: true, limit: 3}}
+	mp := p.newMatchPrinter()
+	var matches []match
+	for i := 0; i < 2; i++ {
+		matches = append(matches, match{rule: &rule{}, filename: "a.go", line: i + 1})
+	}
+	for _, file := range [][]match{matches, matches} {
+		if err := mp.printFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var have []string
+	for _, r := range mp.sarifResults {
+		have = append(have, fmt.Sprintf("%d:%d", r.Properties.ID, r.Locations[0].PhysicalLocation.Region.StartLine))
+	}
+	want := []string{"1:1", "2:2", "3:1"}
+	if strings.Join(have, " ") != strings.Join(want, " ") {
+		t.Errorf("results:\nhave: %v\nwant: %v", have, want)
+	}
+}
+
+func TestMatchPrinterListFiles(t *testing.T) {
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	defer func() { os.Stdout = stdout }()
+
+	p := &program{args: arguments{listFiles: true, nullSeparator: true, limit: 4}}
+	mp := p.newMatchPrinter()
+	files := [][]match{
+		{{filename: "a.go", line: 1}, {filename: "a.go", line: 2}},
+		{{filename: "dir name/b c.go", line: 1}},
+		{{filename: "d.go", line: 1}, {filename: "e.go", line: 1}},
+	}
+	for _, file := range files {
+		if err := mp.printFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	have, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limit is applied to the matches, e.go is the fifth one.
+	want := "a.go\x00dir name/b c.go\x00d.go\x00"
+	if string(have) != want {
+		t.Errorf("output:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestInvertMatch(t *testing.T) {
+	src := `package p
+func f() {
+	defer mu.Unlock()
+	x := g(1)
+	h(x)
+	for {
+		break
+	}
+	ch <- 1
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		kind    nodetag.Value
+		want    []string
+	}{
+		{`$f($*_)`, `$f.Text() == "g"`, nodetag.CallExpr, []string{`mu.Unlock()`, `h(x)`}},
+		{`$_($_)`, ``, nodetag.CallExpr, []string{`mu.Unlock()`}},
+		{`$_ := $_`, ``, nodetag.Stmt, []string{
+			"{\n\tdefer mu.Unlock()\n\tx := g(1)\n\th(x)\n\tfor {\n\t\tbreak\n\t}\n\tch <- 1\n}",
+			`defer mu.Unlock()`,
+			`h(x)`,
+			"for {\n\t\tbreak\n\t}",
+			"{\n\t\tbreak\n\t}",
+			`break`,
+			`ch <- 1`,
+		}},
+		{`$_ := $_`, ``, nodetag.AssignStmt, nil},
+		// The filtered out matches are reported as well.
+		{`$x := $_`, `$x.Text() != "x"`, nodetag.AssignStmt, []string{`x := g(1)`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepWorker(t, &worker{invertKind: test.kind}, testCompileRule(t, test.pattern, test.filter), src)
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s (%s) inverted:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestIsSink(t *testing.T) {
+	src := `package p
+func f(t *testing.T) {
+	panic("x")
+	os.Exit(1)
+	log.Fatalf("%v", err)
+	log.Printf("%v", err)
+	t.Fatal(err)
+	t.Error(err)
+	fatal()
+	exit := os.Exit
+	exit(2)
+	defer os.Exit(3)
+}`
+
+	tests := []struct {
+		sinks string
+		want  []string
+	}{
+		{defaultSinks, []string{`panic("x")`, `os.Exit(1)`, `log.Fatalf("%v", err)`, `t.Fatal(err)`, `os.Exit(3)`}},
+		{"fatal,t.Error", []string{`t.Error(err)`, `fatal()`}},
+		{"log.P*", []string{`log.Printf("%v", err)`}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		sinks, err := parseSinkList(test.sinks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := testCompileRule(t, `$_($*_)`, `$$.IsSink()`)
+		w := testGrepWorker(t, &worker{sinks: sinks}, r, src)
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("-sinks %q:\nhave: %q\nwant: %q", test.sinks, have, test.want)
+		}
+	}
+
+	for _, s := range []string{"os.", "os.*", "*", "a.b.c", "x*.Exit", "log.Fatal**"} {
+		if _, err := parseSinkList(s); err == nil {
+			t.Errorf("-sinks %q: expected an error", s)
+		}
+	}
+}
+
+func TestIsCalled(t *testing.T) {
+	src := `package p
+func f(s *Server) {
+	s.Close()
+	http.HandleFunc("/", s.Serve)
+	defer (s.Stop)()
+	fn := T.Method
+	T.Method(s)
+	go s.Run()
+	g(s.Close())
+}`
+
+	tests := []struct {
+		pattern string
+		filter  string
+		want    []string
+	}{
+		{`$x.$_`, `!$$.IsCalled()`, []string{`s.Serve`, `T.Method`}},
+		{`$x.$_`, `$$.IsCalled()`, []string{`s.Close`, `http.HandleFunc`, `s.Stop`, `T.Method`, `s.Run`, `s.Close`}},
+		{`$f($*_)`, `$f.IsCalled()`, []string{
+			`s.Close()`,
+			`http.HandleFunc("/", s.Serve)`,
+			`(s.Stop)()`,
+			`T.Method(s)`,
+			`s.Run()`,
+			`g(s.Close())`,
+			`s.Close()`,
+		}},
+		{`$f($*_)`, `$$.IsCalled()`, nil},
+	}
+
+	for _, test := range tests {
+		w := testGrepSourceFilter(t, test.pattern, test.filter, src, false)
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s with %s:\nhave: %q\nwant: %q", test.pattern, test.filter, have, test.want)
+		}
+	}
+}
+
+func TestContextFunc(t *testing.T) {
+	src := `package p
+
+var x = g(0)
+
+func f(a int,
+	b string,
+) (int, error) {
+	g(1)
+	go func() { g(2) }()
+	return 0, nil
+}
+
+func (s *S) Method() { g(3) }
+`
+
+	w := testGrepWorker(t, &worker{contextFunc: true}, testCompileRule(t, `g($_)`, ""), src)
+	var have []string
+	for _, m := range w.matches {
+		have = append(have, fmt.Sprintf("%s: %d: %s", m.text, m.contextLine, m.context))
+	}
+	want := []string{
+		`g(0): 1: package p`,
+		`g(1): 5: func f(a int, b string) (int, error)`,
+		`g(2): 5: func f(a int, b string) (int, error)`,
+		`g(3): 13: func (s *S) Method()`,
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("matches context:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestReportCapture(t *testing.T) {
+	src := `package p
+func f() {
+	x = g(1,
+		2)
+	f()
+	f(a, b)
+}`
+
+	tests := []struct {
+		pattern string
+		report  string
+		want    []string
+	}{
+		{`$x = $y`, "", []string{"3: x = g(1,\n\t\t2)"}},
+		{`$x = $y`, "y", []string{"3: g(1,\n\t\t2)"}},
+		{`$_ = g($_, $z)`, "z", []string{"4: 2"}},
+		// An empty node slice has no position, the whole match is reported instead.
+		{`f($*args)`, "args", []string{"5: f()", "6: a, b"}},
+	}
+
+	for _, test := range tests {
+		w := testGrepWorker(t, &worker{report: test.report}, testCompileRule(t, test.pattern, ""), src)
+		var have []string
+		for _, m := range w.matches {
+			have = append(have, fmt.Sprintf("%d: %s", m.line, m.text))
+		}
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s with -report %q:\nhave: %q\nwant: %q", test.pattern, test.report, have, test.want)
+		}
+	}
+
+	p := &program{
+		args:  arguments{report: "z", format: defaultFormat},
+		rules: []*rule{{pattern: `$x = $y`}},
+	}
+	err := p.compilePatterns()
+	if err == nil || err.Error() != "report: pattern has no $z capture" {
+		t.Errorf("unexpected -report error: %v", err)
+	}
+}
+
+func TestCloneGroups(t *testing.T) {
+	src := `package p
+func f() {
+	if err != nil { return err }
+	if err != nil {
+		// The comments and formatting are ignored.
+		return err
+	}
+	if err2 != nil { return err2 }
+	if err != nil { return nil }
+	if x != nil { return nil }
+	if ok { return err }
+}`
+
+	w := testGrepWorker(t, &worker{needCapture: true, clones: true}, testCompileRule(t, `if $x != nil { $*_ }`, ""), src)
+	p := &program{workers: []*worker{w}}
+	var have []string
+	for _, g := range p.collectCloneGroups() {
+		var lines []string
+		for _, m := range g.matches {
+			lines = append(lines, strconv.Itoa(m.line))
+		}
+		have = append(have, strings.Join(lines, " "))
+	}
+	// The $x captures are ignored, but the unnamed $*_ ones are not:
+	// the line 8 return statement is different.
+	want := []string{"3 4", "9 10"}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("clone groups:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestLangVersion(t *testing.T) {
+	tests := []struct {
+		lang string
+		src  string
+		err  string
+	}{
+		{"go1.13", "var x = 1_000", ""},
+		{"go1.12", "var x = 1_000", "p.go:1:20: digit separator requires go1.13 or later (-lang is go1.12)"},
+		{"go1.12", "var x = 0b101", "p.go:1:20: binary literal requires go1.13 or later (-lang is go1.12)"},
+		{"go1.17", "func f[T any](x T) {}", "p.go:1:12: type parameters requires go1.18 or later (-lang is go1.17)"},
+		{"go1.18", "func f[T any](x T) {}", ""},
+		{"go1.21", "func f() { for range 10 {} }", "p.go:1:23: range over int requires go1.22 or later (-lang is go1.21)"},
+		{"go1.22", "func f() { for range 10 {} }", ""},
+		{"go1.8", "type A = int", "p.go:1:17: type alias requires go1.9 or later (-lang is go1.8)"},
+		{"go1.23", "type L[T any] = []T", "p.go:1:17: generic type alias requires go1.24 or later (-lang is go1.23)"},
+		{"go1.1", "var x = s[1:2:3]", "p.go:1:20: 3-index slice expression requires go1.2 or later (-lang is go1.1)"},
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "p.go")
+	pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: `$_`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		lang, err := parseLangVersion(test.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte("package p; "+test.src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w := &worker{
+			lang:        lang,
+			countMode:   true,
+			rules:       []*rule{{m: pat, rootKind: pat.RootKind(), filterExpr: &filters.Expr{Op: filters.OpNop}}},
+			patterns:    []*gogrep.Pattern{pat},
+			gogrepState: gogrep.NewMatcherState(),
+		}
+		_, err = w.grepFile(filename)
+		have := ""
+		if err != nil {
+			have = strings.TrimPrefix(err.Error(), dir+string(filepath.Separator))
+		}
+		if have != test.err {
+			t.Errorf("-lang %s: %s:\nhave: %q\nwant: %q", test.lang, test.src, have, test.err)
+		}
+	}
+
+	for _, s := range []string{"1.18", "go2.0", "go1.", "go1.x", "go1.0"} {
+		if _, err := parseLangVersion(s); err == nil {
+			t.Errorf("-lang %q: expected an error", s)
+		}
+	}
+}
+
+// captureStdout returns the text printed to the os.Stdout by fn.
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	out, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = out
+	err = fn()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGroupByFile(t *testing.T) {
+	newMatch := func(filename string, line int, text string) match {
+		return match{rule: &rule{}, filename: filename, line: line, startOffset: line, text: text, matchLength: len(text)}
+	}
+	p := &program{
+		args: arguments{targets: ".", groupByFile: true, noColor: true, format: defaultFormat, limit: 1000},
+		workers: []*worker{
+			{matches: []match{newMatch("b.go", 3, "f(3)"), newMatch("a.go", 7, "f(7)")}},
+			{matches: []match{newMatch("a.go", 2, "f(2)"), newMatch("b.go", 1, "f(1)")}},
+		},
+	}
+	if err := p.compileOutputFormat(); err != nil {
+		t.Fatal(err)
+	}
+	have := captureStdout(t, p.printMatches)
+	want := "a.go\n  2: f(2)\n  7: f(7)\n\nb.go\n  1: f(1)\n  3: f(3)\n"
+	if have != want {
+		t.Errorf("output:\nhave: %q\nwant: %q", have, want)
+	}
+
+	p.args.pattern = "f($_)"
+	p.args.ruleMode = "all"
+	p.args.format = jsonFormat
+	if err := p.validateFlags(); err == nil || err.Error() != "can't use json format together with -group-by-file" {
+		t.Errorf("unexpected -group-by-file error: %v", err)
+	}
+}
+
+func TestKeepScopeMatches(t *testing.T) {
+	src := `package p
+var _ = g(0)
+var _ = g(1)
+func f() {
+	g(2)
+	g(g(3))
+	func() { g(4) }()
+}
+func h() {
+	g(5)
+}
+func k() { g(g(6)) }`
+
+	tests := []struct {
+		scope    matchScope
+		keepLast bool
+		want     []string
+	}{
+		// The outermost match wins if several matches start at the same position.
+		{scopeFunc, false, []string{`g(0)`, `g(2)`, `g(5)`, `g(g(6))`}},
+		{scopeFunc, true, []string{`g(1)`, `g(4)`, `g(5)`, `g(6)`}},
+		{scopeFile, false, []string{`g(0)`}},
+		{scopeFile, true, []string{`g(6)`}},
+		{scopeNone, false, []string{`g(0)`, `g(1)`, `g(2)`, `g(g(3))`, `g(3)`, `g(4)`, `g(5)`, `g(g(6))`, `g(6)`}},
+	}
+
+	for _, test := range tests {
+		w := testGrepWorker(t, &worker{keepScope: test.scope, keepLast: test.keepLast}, testCompileRule(t, `g($_)`, ""), src)
+		if test.scope != scopeNone {
+			w.keepScopeMatches(0)
+		}
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("scope=%d last=%v:\nhave: %q\nwant: %q", test.scope, test.keepLast, have, test.want)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	src := `package p
+func init() {
+	panic(1)
+}
+func f() {
+	panic(2)
+	if debug {
+		panic(3)
+	}
+	defer func() { panic(4) }()
+}`
+
+	compile := func(src string) *gogrep.Pattern {
+		pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: src})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pat
+	}
+
+	tests := []struct {
+		mask []string
+		want []string
+	}{
+		{nil, []string{`panic(1)`, `panic(2)`, `panic(3)`, `panic(4)`}},
+		{[]string{`func init() { $*_ }`}, []string{`panic(2)`, `panic(3)`, `panic(4)`}},
+		{[]string{`func init() { $*_ }`, `if debug { $*_ }`}, []string{`panic(2)`, `panic(4)`}},
+		{[]string{`func() { $*_ }`}, []string{`panic(1)`, `panic(2)`, `panic(3)`}},
+		// The masked node itself is not matched too.
+		{[]string{`panic(2)`}, []string{`panic(1)`, `panic(3)`, `panic(4)`}},
+	}
+
+	for _, test := range tests {
+		w := &worker{}
+		for _, src := range test.mask {
+			w.mask = append(w.mask, compile(src))
+		}
+		testGrepWorker(t, w, testCompileRule(t, `panic($_)`, ""), src)
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("-mask %q:\nhave: %q\nwant: %q", test.mask, have, test.want)
+		}
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	src := `package p
+func f() {
+	println(b, 1)
+	println(a)
+	println(b)
+	println(c(
+		1))
+	println()
+}`
+
+	w := testGrepWorker(t, &worker{distinct: "x"}, testCompileRule(t, `println($x, $*_)`, ""), src)
+	if len(w.matches) != 0 {
+		t.Errorf("-distinct should not collect the matches, found %d", len(w.matches))
+	}
+	w2 := testGrepWorker(t, &worker{distinct: "x"}, testCompileRule(t, `println($*x)`, ""), src)
+
+	p := &program{workers: []*worker{w, w2}}
+	have := captureStdout(t, p.printDistinctValues)
+	want := "a\nb\nb, 1\nc(\\n\t\t1)\n"
+	if have != want {
+		t.Errorf("output:\nhave: %q\nwant: %q", have, want)
+	}
+	p.args.multiline = true
+	have = captureStdout(t, p.printDistinctValues)
+	want = "a\nb\nb, 1\nc(\n\t\t1)\n"
+	if have != want {
+		t.Errorf("-multiline output:\nhave: %q\nwant: %q", have, want)
+	}
+
+	for _, name := range []string{"", "$", "$_", "$*x"} {
+		p := &program{args: arguments{distinct: name, format: defaultFormat}}
+		if err := p.validateDistinctFlags(); err == nil {
+			t.Errorf("-distinct %q: expected an error", name)
+		}
+	}
+	p = &program{args: arguments{distinct: "$x", format: jsonFormat}}
+	if err := p.validateDistinctFlags(); err == nil || err.Error() != "can't use -format together with -distinct" {
+		t.Errorf("unexpected -distinct error: %v", err)
+	}
+}
+
+func TestNotIn(t *testing.T) {
+	src := `package p
+func f(xs []int) {
+	defer unlock(1)
+	for _, x := range xs {
+		defer unlock(2)
+	}
+	for {
+		func() {
+			defer unlock(3)
+		}()
+	}
+	if true {
+		defer unlock(4)
+	}
+}`
+
+	compile := func(src string) *gogrep.Pattern {
+		pat, _, err := gogrep.Compile(gogrep.CompileConfig{Fset: token.NewFileSet(), Src: src})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pat
+	}
+
+	tests := []struct {
+		notIn []string
+		want  []string
+	}{
+		{nil, []string{`defer unlock(1)`, `defer unlock(2)`, `defer unlock(3)`, `defer unlock(4)`}},
+		{[]string{`for $*_; $*_; $*_ { $*_ }`}, []string{`defer unlock(1)`, `defer unlock(2)`, `defer unlock(4)`}},
+		{[]string{`for $*_; $*_; $*_ { $*_ }`, `for $_, $_ := range $_ { $*_ }`}, []string{`defer unlock(1)`, `defer unlock(4)`}},
+		{[]string{`if $*_ { $*_ }`}, []string{`defer unlock(1)`, `defer unlock(2)`, `defer unlock(3)`}},
+		// The match itself is not its own scope.
+		{[]string{`defer $f($*_)`}, []string{`defer unlock(1)`, `defer unlock(2)`, `defer unlock(3)`, `defer unlock(4)`}},
+	}
+
+	for _, test := range tests {
+		w := &worker{notInState: gogrep.NewMatcherState()}
+		for _, src := range test.notIn {
+			w.notIn = append(w.notIn, compile(src))
+		}
+		testGrepWorker(t, w, testCompileRule(t, `defer $f($*_)`, ""), src)
+		have := matchTexts(w)
+		if strings.Join(have, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("-not-in %q:\nhave: %q\nwant: %q", test.notIn, have, test.want)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.go":       "package p\nfunc f() { println(1) }\n",
+		"a_test.go":  "package p\n",
+		"broken.go":  "package p\nfunc (\n",
+		"ignored.go": "//go:build ignore\n\npackage main\n",
+		"linux.go":   "//go:build linux\n\npackage p\n",
+		"windows.go": "//go:build windows\n\npackage p\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := testCompileRule(t, `println($_)`, "")
+	r.filterHints = filterHints{testCond: newBool3(false)}
+	w := &worker{
+		dryRun:         true,
+		checkBuildTags: true,
+		buildTags:      parseBuildTags("linux"),
+		rules:          []*rule{r},
+		patterns:       []*gogrep.Pattern{r.m},
+		gogrepState:    gogrep.NewMatcherState(),
+	}
+	for name := range files {
+		if _, err := w.grepFile(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	if len(w.matches) != 0 {
+		t.Errorf("-dry-run should not collect the matches, found %d", len(w.matches))
+	}
+
+	p := &program{args: arguments{noColor: true}, workers: []*worker{w}}
+	have := captureStdout(t, p.printDryRunFiles)
+	// The broken.go is not parsed, so it's listed as well.
+	have = strings.ReplaceAll(have, dir+string(filepath.Separator), "")
+	want := "a.go\nbroken.go\nlinux.go\n"
+	if have != want {
+		t.Errorf("output:\nhave: %q\nwant: %q", have, want)
+	}
+	if p.numMatches != 3 {
+		t.Errorf("files count: have %d, want 3", p.numMatches)
+	}
+}
+
+func TestAggregationFlags(t *testing.T) {
+	tests := []struct {
+		args arguments
+		err  string
+	}{
+		{arguments{rulesFile: "rules.txt"}, "can't use -rules or -e together with %s"},
+		{arguments{clones: true}, "can't use -file-query, -comment-query, -clones or -distinct together with %s"},
+		{arguments{distinct: "$x"}, "can't use -file-query, -comment-query, -clones or -distinct together with %s"},
+		{arguments{decls: true}, "can't use -decls together with %s"},
+		{arguments{notIn: stringList{"for { $*_ }"}}, "can't use -invert-match, -not-in or -mask together with %s"},
+		{arguments{report: "x"}, "can't use -context-func or -report together with %s"},
+		{arguments{firstPer: "func"}, "can't use -first-per, -last-per, -group-by-file or -watch together with %s"},
+		{arguments{groupByFile: true}, "can't use -first-per, -last-per, -group-by-file or -watch together with %s"},
+		{arguments{watch: true}, "can't use -first-per, -last-per, -group-by-file or -watch together with %s"},
+		{arguments{countMode: true}, "can't use -c, -write-baseline or sarif format together with %s"},
+		{arguments{format: sarifFormat}, "can't use -c, -write-baseline or sarif format together with %s"},
+		{arguments{listFiles: true}, "can't use -l, -rewrite, -merge-overlapping or test mode together with %s"},
+		{arguments{rewrite: "$x"}, "can't use -l, -rewrite, -merge-overlapping or test mode together with %s"},
+		{arguments{mergeOverlapping: true}, "can't use -l, -rewrite, -merge-overlapping or test mode together with %s"},
+		{arguments{numPositional: 2, pattern: "f()"}, "can't use a pattern argument together with %s"},
+	}
+
+	modes := []struct {
+		name string
+		set  func(args *arguments)
+	}{
+		{"-import-aliases", func(args *arguments) { args.importAliases = true }},
+		{"-receiver-names", func(args *arguments) { args.receiverNames = true }},
+		{"-blank-imports", func(args *arguments) { args.blankImports = true }},
+	}
+
+	for _, mode := range modes {
+		p := &program{args: arguments{targets: ".", numPositional: 1, ruleMode: "all", format: defaultFormat, progressMode: "none"}}
+		mode.set(&p.args)
+		if err := p.validateFlags(); err != nil {
+			t.Errorf("%s: unexpected error: %v", mode.name, err)
+		}
+
+		for _, test := range tests {
+			args := test.args
+			args.targets = "."
+			args.ruleMode = "all"
+			if args.numPositional == 0 {
+				args.numPositional = 1
+			}
+			if args.format == "" {
+				args.format = defaultFormat
+			}
+			mode.set(&args)
+			p := &program{args: args}
+			want := fmt.Sprintf(test.err, mode.name)
+			if err := p.validateFlags(); err == nil || err.Error() != want {
+				t.Errorf("%s with %+v:\nhave: %v\nwant: %s", mode.name, test.args, err, want)
+			}
+		}
+	}
+}
@@ -22,6 +22,12 @@ type compiler struct {
 
 	info *PatternInfo
 
+	// opVars maps binary and unary expressions to their operator wildcard names.
+	opVars map[ast.Node]string
+
+	// varKinds are the kinds of the named wildcards compiled so far.
+	varKinds map[string]wildKind
+
 	insideStmtList bool
 }
 
@@ -47,6 +53,7 @@ func (c *compiler) Compile(root ast.Node, info *PatternInfo) (p *program, err er
 	}
 	c.stringIndexes = make(map[string]uint8)
 	c.ifaceIndexes = make(map[interface{}]uint8)
+	c.varKinds = make(map[string]wildKind)
 
 	c.compileNode(root)
 
@@ -70,7 +77,33 @@ func (c *compiler) toUint8(n ast.Node, v int) uint8 {
 	return uint8(v)
 }
 
-func (c *compiler) internVar(n ast.Node, s string) uint8 {
+// wildKind is a kind of the values that a named wildcard captures.
+// All wildcards with the same name should be of the same kind:
+// the first one captures a value and the others should match an equal one.
+type wildKind int
+
+const (
+	wildNode wildKind = iota
+	wildSeq
+	wildOperator
+)
+
+func (k wildKind) String() string {
+	switch k {
+	case wildNode:
+		return "node"
+	case wildSeq:
+		return "sequence"
+	default:
+		return "operator"
+	}
+}
+
+func (c *compiler) internVar(n ast.Node, s string, kind wildKind) uint8 {
+	if prevKind, ok := c.varKinds[s]; ok && prevKind != kind {
+		panic(c.errorf(n, "$%s is used as both %s and %s wildcard", s, prevKind, kind))
+	}
+	c.varKinds[s] = kind
 	c.info.Vars[s] = struct{}{}
 	index := c.internString(n, s)
 	return index
@@ -126,6 +159,8 @@ func (c *compiler) compileNode(n ast.Node) {
 		c.compileRangeClause(n)
 	case *rangeHeader:
 		c.compileRangeHeader(n)
+	case *importSpec:
+		c.compileImportSpec(n)
 	case *NodeSlice:
 		switch n.Kind {
 		case StmtNodeSlice:
@@ -172,7 +207,7 @@ func (c *compiler) compileOptFieldList(n *ast.FieldList) {
 			default:
 				c.emitInst(instruction{
 					op:         opNamedFieldNode,
-					valueIndex: c.internVar(n, info.Name),
+					valueIndex: c.internVar(n, info.Name, wildNode),
 				})
 			}
 			return
@@ -199,10 +234,20 @@ func (c *compiler) compileField(n *ast.Field) {
 		c.emitInstOp(opUnnamedField)
 	case len(n.Names) == 1:
 		name := n.Names[0]
-		if isWildName(name.Name) {
+		if isSeqParamType(n.Type) {
+			// `$*_` param that was mixed with the named params.
+			c.compileWildIdent(name, false)
+			return
+		}
+		switch {
+		case isWildName(name.Name) && decodeWildName(name.Name).Seq:
+			c.emitInstOp(opAnyNamesField)
+			c.compileWildIdent(name, false)
+			c.emitInstOp(opEnd)
+		case isWildName(name.Name):
 			c.emitInstOp(opField)
 			c.compileWildIdent(name, false)
-		} else {
+		default:
 			c.emitInst(instruction{
 				op:         opSimpleField,
 				valueIndex: c.internString(name, name.Name),
@@ -220,6 +265,10 @@ func (c *compiler) compileField(n *ast.Field) {
 
 func (c *compiler) compileValueSpec(spec *ast.ValueSpec) {
 	switch {
+	case isSeqParamType(spec.Type) && len(spec.Values) == 0:
+		// A `var ($*_)` spec that was parsed with a placeholder type.
+		c.compileIdent(spec.Names[0])
+		return
 	case spec.Type == nil && len(spec.Values) == 0:
 		if isWildName(spec.Names[0].String()) {
 			c.compileIdent(spec.Names[0])
@@ -248,9 +297,23 @@ func (c *compiler) compileValueSpec(spec *ast.ValueSpec) {
 	}
 }
 
+func (c *compiler) compileImportSpec(spec *importSpec) {
+	if spec.Name == nil {
+		c.emitInstOp(opImportSpec)
+	} else {
+		c.emitInstOp(opNamedImportSpec)
+		c.compileIdent(spec.Name)
+	}
+	c.compileExpr(spec.Path)
+}
+
 func (c *compiler) compileTypeSpec(spec *ast.TypeSpec) {
-	// Generic types can't have aliases, so we use that fact here.
 	typeParams := typeparams.ForTypeSpec(spec)
+	if isSeqParamType(spec.Type) && !spec.Assign.IsValid() && typeParams == nil {
+		// A `type ($*_)` spec that was parsed with a placeholder type.
+		c.compileIdent(spec.Name)
+		return
+	}
 	if !spec.Assign.IsValid() && !isWildName(spec.Name.Name) && typeParams == nil {
 		c.emitInst(instruction{
 			op:         opSimpleTypeSpec,
@@ -260,7 +323,8 @@ func (c *compiler) compileTypeSpec(spec *ast.TypeSpec) {
 		return
 	}
 	if typeParams != nil {
-		c.emitInstOp(opGenericTypeSpec)
+		// Since Go 1.24, the alias declarations can have type params too.
+		c.emitInstOp(pickOp(spec.Assign.IsValid(), opGenericTypeAliasSpec, opGenericTypeSpec))
 		c.compileIdent(spec.Name)
 		c.compileFieldList(typeParams)
 		c.compileTypeExpr(spec.Type)
@@ -321,6 +385,11 @@ func (c *compiler) compileFuncDecl(n *ast.FuncDecl) {
 }
 
 func (c *compiler) compileGenDecl(n *ast.GenDecl) {
+	if name, ok := declWildcard(n); ok {
+		c.compileWildIdent(name, false)
+		return
+	}
+
 	if c.insideStmtList {
 		c.emitInstOp(opDeclStmt)
 	}
@@ -443,10 +512,20 @@ func (c *compiler) compileBasicLit(n *ast.BasicLit) {
 }
 
 func (c *compiler) compileBinaryExpr(n *ast.BinaryExpr) {
-	c.prog.insts = append(c.prog.insts, instruction{
-		op:    opBinaryExpr,
-		value: c.toUint8(n, int(n.Op)),
-	})
+	switch name, ok := c.opVars[n]; {
+	case !ok:
+		c.prog.insts = append(c.prog.insts, instruction{
+			op:    opBinaryExpr,
+			value: c.toUint8(n, int(n.Op)),
+		})
+	case name == "_":
+		c.emitInstOp(opAnyBinaryExpr)
+	default:
+		c.emitInst(instruction{
+			op:         opNamedBinaryExpr,
+			valueIndex: c.internVar(n, name, wildOperator),
+		})
+	}
 	c.compileExpr(n.X)
 	c.compileExpr(n.Y)
 }
@@ -476,10 +555,10 @@ func (c *compiler) compileWildIdent(n *ast.Ident, optional bool) {
 		inst.op = pickOp(optional, opOptNode, opNodeSeq)
 	case info.Name != "_" && !info.Seq:
 		inst.op = opNamedNode
-		inst.valueIndex = c.internVar(n, info.Name)
+		inst.valueIndex = c.internVar(n, info.Name, wildNode)
 	default:
 		inst.op = pickOp(optional, opNamedOptNode, opNamedNodeSeq)
-		inst.valueIndex = c.internVar(n, info.Name)
+		inst.valueIndex = c.internVar(n, info.Name, wildSeq)
 	}
 	c.prog.insts = append(c.prog.insts, inst)
 }
@@ -609,10 +688,20 @@ func (c *compiler) compileSymbol(sym ast.Expr) {
 }
 
 func (c *compiler) compileUnaryExpr(n *ast.UnaryExpr) {
-	c.prog.insts = append(c.prog.insts, instruction{
-		op:    opUnaryExpr,
-		value: c.toUint8(n, int(n.Op)),
-	})
+	switch name, ok := c.opVars[n]; {
+	case !ok:
+		c.prog.insts = append(c.prog.insts, instruction{
+			op:    opUnaryExpr,
+			value: c.toUint8(n, int(n.Op)),
+		})
+	case name == "_":
+		c.emitInstOp(opAnyUnaryExpr)
+	default:
+		c.emitInst(instruction{
+			op:         opNamedUnaryExpr,
+			valueIndex: c.internVar(n, name, wildOperator),
+		})
+	}
 	c.compileExpr(n.X)
 }
 
@@ -622,6 +711,10 @@ func (c *compiler) compileStarExpr(n *ast.StarExpr) {
 }
 
 func (c *compiler) compileParenExpr(n *ast.ParenExpr) {
+	if c.config.IgnoreParens {
+		c.compileExpr(n.X)
+		return
+	}
 	c.emitInstOp(opParenExpr)
 	c.compileExpr(n.X)
 }
@@ -759,6 +852,13 @@ func (c *compiler) compileKeyValueExpr(n *ast.KeyValueExpr) {
 }
 
 func (c *compiler) compileSelectorExpr(n *ast.SelectorExpr) {
+	if x, ok := n.X.(*ast.Ident); ok && decodeWildNode(x).Seq {
+		// $*x.sel is a selector chain of any depth, including the unqualified sel.
+		c.emitInstOp(opChainSelectorExpr)
+		c.compileIdent(n.Sel)
+		c.compileWildIdent(x, true)
+		return
+	}
 	if isWildName(n.Sel.Name) {
 		c.emitInstOp(opSelectorExpr)
 		c.compileWildIdent(n.Sel, false)
@@ -895,11 +995,11 @@ func (c *compiler) compileIfStmt(n *ast.IfStmt) {
 		if info.Seq {
 			c.prog.insts = append(c.prog.insts, instruction{
 				op:         pickOp(n.Else == nil, opIfNamedOptStmt, opIfNamedOptElseStmt),
-				valueIndex: c.internVar(ident, info.Name),
+				valueIndex: c.internVar(ident, info.Name, wildSeq),
 			})
 			c.compileStmt(n.Body)
 			if n.Else != nil {
-				c.compileStmt(n.Else)
+				c.compileElse(n.Else)
 			}
 			return
 		}
@@ -919,19 +1019,29 @@ func (c *compiler) compileIfStmt(n *ast.IfStmt) {
 		c.emitInstOp(opIfElseStmt)
 		c.compileExpr(n.Cond)
 		c.compileStmt(n.Body)
-		c.compileStmt(n.Else)
+		c.compileElse(n.Else)
 	case n.Init != nil && n.Else != nil:
 		c.emitInstOp(opIfInitElseStmt)
 		c.compileOptStmt(n.Init)
 		c.compileExpr(n.Cond)
 		c.compileStmt(n.Body)
-		c.compileStmt(n.Else)
+		c.compileElse(n.Else)
 
 	default:
 		panic(c.errorf(n, "unexpected if stmt"))
 	}
 }
 
+// compileElse compiles the if statement else branch.
+// An else wildcard matches any branch, be it a block or another if statement.
+func (c *compiler) compileElse(n ast.Stmt) {
+	if ident, ok := elseWildcard(n); ok {
+		c.compileIdent(ident)
+		return
+	}
+	c.compileStmt(n)
+}
+
 func (c *compiler) compileCommClause(n *ast.CommClause) {
 	c.emitInstOp(pickOp(n.Comm == nil, opDefaultCommClause, opCommClause))
 	if n.Comm != nil {
@@ -1262,3 +1372,46 @@ func pickOp(cond bool, ifTrue, ifFalse operation) operation {
 func fitsUint8(v int) bool {
 	return v >= 0 && v <= 0xff
 }
+
+// isSeqParamType reports whether typ is a placeholder type
+// that is added by withSeqParamTypes.
+func isSeqParamType(typ ast.Expr) bool {
+	ident, ok := typ.(*ast.Ident)
+	return ok && ident.Name == seqParamType
+}
+
+// elseWildcard returns the wildcard of an else branch that
+// was produced by withElseWildcards.
+func elseWildcard(n ast.Stmt) (*ast.Ident, bool) {
+	block, ok := n.(*ast.BlockStmt)
+	if !ok || len(block.List) != 1 {
+		return nil, false
+	}
+	stmt, ok := block.List[0].(*ast.ExprStmt)
+	if !ok {
+		return nil, false
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, false
+	}
+	if fn, ok := call.Fun.(*ast.Ident); !ok || fn.Name != elseWildcardFunc {
+		return nil, false
+	}
+	ident, ok := call.Args[0].(*ast.Ident)
+	return ident, ok
+}
+
+// declWildcard returns the wildcard name of a declaration that
+// was produced by withDeclWildcards.
+func declWildcard(n *ast.GenDecl) (*ast.Ident, bool) {
+	if n.Tok != token.VAR || len(n.Specs) != 1 {
+		return nil, false
+	}
+	spec := n.Specs[0].(*ast.ValueSpec)
+	typ, ok := spec.Type.(*ast.Ident)
+	if !ok || typ.Name != declWildcardType || len(spec.Names) != 1 {
+		return nil, false
+	}
+	return spec.Names[0], true
+}
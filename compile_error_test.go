@@ -45,6 +45,16 @@ func TestCompileError(t *testing.T) {
 		intStatements:         `implementation limitation: too many values`,
 		strict(intStatements): `implementation limitation: too many string values`,
 
+		// All wildcards with the same name should be of the same kind.
+		`$x $op $y; $op`:        `$op is used as both operator and node wildcard`,
+		`$op $x; $op`:           `$op is used as both operator and node wildcard`,
+		`$x + $y; $x $x $y`:     `$x is used as both node and operator wildcard`,
+		`f($*xs); g($xs)`:       `$xs is used as both sequence and node wildcard`,
+		`f($xs); g($*xs)`:       `$xs is used as both node and sequence wildcard`,
+		`for $*x; b; $x {}`:     `$x is used as both sequence and node wildcard`,
+		`if $*x { $*_ }; $x`:    `$x is used as both sequence and node wildcard`,
+		`func $f() $*f { $*_ }`: `$f is used as both node and sequence wildcard`,
+
 		// Below is a list of patterns that caused gogrep to panic.
 		// Found with fuzzing.
 		`()`: `expected operand, found ')'`,
@@ -66,3 +76,30 @@ func TestCompileError(t *testing.T) {
 		}
 	}
 }
+
+func TestCompileVarPrefixError(t *testing.T) {
+	tests := []struct {
+		prefix rune
+		src    string
+		want   string
+	}{
+		{'!', `!x`, `unsupported pattern var prefix '!', expected one of @#%?^`},
+		{'x', `xa`, `unsupported pattern var prefix 'x'`},
+		{'ᐸ', `ᐸa`, `unsupported pattern var prefix 'ᐸ'`},
+		{'@', `$x + @y`, `unexpected $, the pattern vars prefix is @`},
+		{'@', `@`, `illegal character U+0040 '@'`},
+	}
+
+	for _, test := range tests {
+		config := CompileConfig{Fset: token.NewFileSet(), Src: test.src, VarPrefix: test.prefix}
+		_, _, err := Compile(config)
+		if err == nil {
+			t.Errorf("compile `%s` with %q prefix: expected error, got none", test.src, test.prefix)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("compile `%s` with %q prefix: error substring not found\nerror: %s\nsubstr: %s",
+				test.src, test.prefix, err, test.want)
+		}
+	}
+}
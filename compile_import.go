@@ -8,6 +8,17 @@ import (
 	"unicode/utf8"
 )
 
+// isImportDeclPattern reports whether src is an `import $x` pattern.
+// It matches the entire import decl, while other import patterns,
+// like `import $alias "path"`, match the individual import specs.
+func isImportDeclPattern(src string) bool {
+	if !strings.HasPrefix(src, "import $") {
+		return false
+	}
+	rest := strings.TrimSpace(src[len("import $"):])
+	return !strings.ContainsAny(rest, " \t\n\"`")
+}
+
 func compileImportPattern(config CompileConfig) (*Pattern, PatternInfo, error) {
 	// TODO: figure out how to compile it as a part of a normal pattern compilation?
 	// This is an adhoc solution to a problem.
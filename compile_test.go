@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/quasilyte/gogrep/nodetag"
 	"golang.org/x/exp/typeparams"
 )
 
@@ -446,6 +447,23 @@ func TestCompileWildcard(t *testing.T) {
 			` • End`,
 		},
 
+		`import "fmt"`: {
+			`ImportSpec`,
+			` • BasicLit "fmt"`,
+		},
+
+		`import $alias $path`: {
+			`NamedImportSpec`,
+			` • NamedNode alias`,
+			` • NamedNode path`,
+		},
+
+		`import . "fmt"`: {
+			`NamedImportSpec`,
+			` • Ident .`,
+			` • BasicLit "fmt"`,
+		},
+
 		`importFoo()`: {
 			`NonVariadicCallExpr`,
 			` • Ident importFoo`,
@@ -651,6 +669,19 @@ func TestCompileWithTypes(t *testing.T) {
 			` •  • Ident x`,
 		},
 
+		`$x $op 1`: {
+			`NamedBinaryExpr op`,
+			` • NamedNode x`,
+			` • BasicLit 1`,
+		},
+		`$x $_ $x || y`: {
+			`BinaryExpr ||`,
+			` • AnyBinaryExpr`,
+			` •  • NamedNode x`,
+			` •  • NamedNode x`,
+			` • Ident y`,
+		},
+
 		`f(1, 2)`: {
 			`NonVariadicCallExpr`,
 			` • Ident f`,
@@ -874,6 +905,24 @@ func TestCompileWithTypes(t *testing.T) {
 			` •  • BlockStmt`,
 			` •  •  • End`,
 		},
+		`if cond {} else $e`: {
+			`IfElseStmt`,
+			` • Ident cond`,
+			` • BlockStmt`,
+			` •  • End`,
+			` • NamedNode e`,
+		},
+		`if cond {} else if cond2 {} else $_`: {
+			`IfElseStmt`,
+			` • Ident cond`,
+			` • BlockStmt`,
+			` •  • End`,
+			` • IfElseStmt`,
+			` •  • Ident cond2`,
+			` •  • BlockStmt`,
+			` •  •  • End`,
+			` •  • Node`,
+		},
 		`if init1; cond {} else if init2; cond2 { f() } else {}`: {
 			`IfInitElseStmt`,
 			` • ExprStmt`,
@@ -1211,6 +1260,20 @@ func TestCompileWithTypes(t *testing.T) {
 				` •  •  •  • End`,
 				` • End`,
 			},
+
+			`type Foo[T any] = Bar[T]`: {
+				`TypeDecl`,
+				` • GenericTypeAliasSpec`,
+				` •  • Ident Foo`,
+				` •  • FieldList`,
+				` •  •  • SimpleField T`,
+				` •  •  •  • EfaceType`,
+				` •  •  • End`,
+				` •  • IndexExpr`,
+				` •  •  • Ident Bar`,
+				` •  •  • Ident T`,
+				` • End`,
+			},
 		})...)
 	}
 
@@ -1219,6 +1282,71 @@ func TestCompileWithTypes(t *testing.T) {
 	}
 }
 
+func TestRequiredIdents(t *testing.T) {
+	tests := []struct {
+		pat  string
+		want string
+	}{
+		{`$x`, ``},
+		{`$f($*_)`, ``},
+		{`1 + "s"`, ``},
+		{`panic($_)`, `panic`},
+		{`fmt.Printf($_, $x, $x)`, `Printf fmt`},
+		{`$x.Close()`, `Close`},
+		{`func init() { $*_ }`, `init`},
+		{`type Foo struct{ mu sync.Mutex }`, `Foo mu Mutex sync`},
+		{`f(x); g(x)`, `f x g`},
+		{`goto done; done: return nil`, `done nil`},
+		{`var x interface{}`, `x`},
+		{`import $imports`, ``},
+	}
+
+	for _, test := range tests {
+		config := CompileConfig{Fset: token.NewFileSet(), Src: test.pat}
+		pat, _, err := Compile(config)
+		if err != nil {
+			t.Fatalf("compile `%s`: %v", test.pat, err)
+		}
+		have := strings.Join(pat.RequiredIdents(), " ")
+		if have != test.want {
+			t.Errorf("pattern `%s`:\nhave: %s\nwant: %s", test.pat, have, test.want)
+		}
+	}
+}
+
+func TestRootKind(t *testing.T) {
+	tests := []struct {
+		pat  string
+		want nodetag.Value
+	}{
+		{`$x`, nodetag.Node},
+		{`$*_.Close()`, nodetag.CallExpr},
+		{`$*x.Close`, nodetag.Node},
+		{`f($*_)`, nodetag.CallExpr},
+		{`$x + 1`, nodetag.BinaryExpr},
+		{`x`, nodetag.Ident},
+		{`interface{}`, nodetag.Node},
+		{`interface{ String() string }`, nodetag.InterfaceType},
+		{`if $cond { $*_ }`, nodetag.IfStmt},
+		{`f(); g()`, nodetag.Node},
+		{`f(), g()`, nodetag.Node},
+		{`range $x`, nodetag.RangeStmt},
+		{`func $_($*_) { $*_ }`, nodetag.FuncDecl},
+		{`var $x = $y`, nodetag.GenDecl},
+	}
+
+	for _, test := range tests {
+		config := CompileConfig{Fset: token.NewFileSet(), Src: test.pat}
+		pat, _, err := Compile(config)
+		if err != nil {
+			t.Fatalf("compile `%s`: %v", test.pat, err)
+		}
+		if have := pat.RootKind(); have != test.want {
+			t.Errorf("pattern `%s`:\nhave: %v\nwant: %v", test.pat, have, test.want)
+		}
+	}
+}
+
 func testParseNode(t testing.TB, fset *token.FileSet, s string) ast.Node {
 	if strings.HasPrefix(s, "package ") {
 		file, err := parser.ParseFile(fset, "string", s, 0)
@@ -0,0 +1,186 @@
+package gogrep
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// ConstValue is a constant declared by a const spec.
+type ConstValue struct {
+	Name *ast.Ident
+
+	// Value is nil if the constant value can't be computed.
+	Value constant.Value
+}
+
+// ConstValues returns the decl constants with their values in the declaration order,
+// including the blank ones. It returns nil if decl is not a const declaration.
+//
+// The info values are used if info is not nil and has the constant definitions.
+// Otherwise, the values are computed from the source: the specs without values
+// repeat the previous spec expressions with the next iota value, like in
+// `A = 1 << iota; B; C`, and an explicit value interrupts the sequence.
+// Only the untyped arithmetic over the literals, iota and the constants
+// declared earlier in the same decl is evaluated, so the values that depend
+// on the other declarations or the typed constants overflow are unknown (nil).
+// A conversion, like `Status(iota)`, keeps its argument value.
+func ConstValues(info *types.Info, decl *ast.GenDecl) []ConstValue {
+	if decl.Tok != token.CONST {
+		return nil
+	}
+	var result []ConstValue
+	known := make(map[string]constant.Value)
+	var values []ast.Expr
+	for iota, spec := range decl.Specs {
+		spec, ok := spec.(*ast.ValueSpec)
+		if !ok {
+			continue
+		}
+		if len(spec.Values) != 0 {
+			values = spec.Values
+		}
+		for i, name := range spec.Names {
+			var v constant.Value
+			if info != nil {
+				if c, ok := info.Defs[name].(*types.Const); ok {
+					v = c.Val()
+				}
+			}
+			if v == nil && i < len(values) {
+				v = evalConstExpr(values[i], iota, known)
+			}
+			result = append(result, ConstValue{Name: name, Value: v})
+			if v != nil && name.Name != "_" {
+				known[name.Name] = v
+			}
+		}
+	}
+	return result
+}
+
+// evalConstExpr returns the e constant expression value, or nil if it can't be computed.
+func evalConstExpr(e ast.Expr, iota int, known map[string]constant.Value) constant.Value {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		v := constant.MakeFromLiteral(e.Value, e.Kind, 0)
+		if v.Kind() == constant.Unknown {
+			return nil
+		}
+		return v
+	case *ast.ParenExpr:
+		return evalConstExpr(e.X, iota, known)
+	case *ast.Ident:
+		switch e.Name {
+		case "iota":
+			return constant.MakeInt64(int64(iota))
+		case "true", "false":
+			return constant.MakeBool(e.Name == "true")
+		}
+		return known[e.Name]
+	case *ast.CallExpr:
+		// The only calls allowed in the constant expressions are conversions
+		// and predeclared functions calls like len. The latter aren't evaluated.
+		if len(e.Args) != 1 || e.Ellipsis.IsValid() {
+			return nil
+		}
+		switch fn := unparen(e.Fun).(type) {
+		case *ast.Ident:
+			if _, ok := builtinResults[fn.Name]; ok {
+				return nil
+			}
+		case *ast.SelectorExpr:
+			// Like unsafe.Sizeof(x).
+			if pkg, ok := fn.X.(*ast.Ident); ok && pkg.Name == "unsafe" {
+				return nil
+			}
+		}
+		return evalConstExpr(e.Args[0], iota, known)
+	case *ast.UnaryExpr:
+		x := evalConstExpr(e.X, iota, known)
+		switch {
+		case x == nil:
+			return nil
+		case e.Op == token.XOR:
+			// The result depends on the operand type size, like for ^uint8(0).
+			return nil
+		case e.Op == token.NOT && x.Kind() == constant.Bool:
+			return constant.UnaryOp(e.Op, x, 0)
+		case (e.Op == token.ADD || e.Op == token.SUB) && isNumericConst(x):
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+		return nil
+	case *ast.BinaryExpr:
+		return evalConstBinaryExpr(e, iota, known)
+	}
+	return nil
+}
+
+func evalConstBinaryExpr(e *ast.BinaryExpr, iota int, known map[string]constant.Value) constant.Value {
+	x := evalConstExpr(e.X, iota, known)
+	y := evalConstExpr(e.Y, iota, known)
+	if x == nil || y == nil {
+		return nil
+	}
+	switch e.Op {
+	case token.SHL, token.SHR:
+		s, ok := constant.Uint64Val(y)
+		if x.Kind() != constant.Int || !ok || s > 1024 {
+			return nil
+		}
+		return constant.Shift(x, e.Op, uint(s))
+
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		if !comparableConsts(x, y) {
+			return nil
+		}
+		return constant.MakeBool(constant.Compare(x, e.Op, y))
+
+	case token.LAND, token.LOR:
+		if x.Kind() != constant.Bool || y.Kind() != constant.Bool {
+			return nil
+		}
+		return constant.BinaryOp(x, e.Op, y)
+
+	case token.ADD:
+		if x.Kind() == constant.String && y.Kind() == constant.String {
+			return constant.BinaryOp(x, e.Op, y)
+		}
+		fallthrough
+	case token.SUB, token.MUL, token.QUO:
+		if !isNumericConst(x) || !isNumericConst(y) {
+			return nil
+		}
+		op := e.Op
+		if op == token.QUO {
+			if constant.Sign(y) == 0 {
+				return nil
+			}
+			if x.Kind() == constant.Int && y.Kind() == constant.Int {
+				// The integer constants division is truncated.
+				op = token.QUO_ASSIGN
+			}
+		}
+		return constant.BinaryOp(x, op, y)
+
+	case token.REM, token.AND, token.OR, token.XOR, token.AND_NOT:
+		if x.Kind() != constant.Int || y.Kind() != constant.Int {
+			return nil
+		}
+		if e.Op == token.REM && constant.Sign(y) == 0 {
+			return nil
+		}
+		return constant.BinaryOp(x, e.Op, y)
+	}
+	return nil
+}
+
+func isNumericConst(v constant.Value) bool {
+	switch v.Kind() {
+	case constant.Int, constant.Float, constant.Complex:
+		return true
+	default:
+		return false
+	}
+}
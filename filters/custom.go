@@ -0,0 +1,66 @@
+package filters
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sync"
+)
+
+// FilterContext gives the custom filter functions access to the current match.
+type FilterContext interface {
+	// Fset returns the file set that was used to parse the matched file.
+	Fset() *token.FileSet
+
+	// Filename returns the matched file name.
+	Filename() string
+
+	// Capture returns the node that is bound to the varname pattern variable.
+	// The varname is given without the $ prefix, like "x" for $x.
+	Capture(varname string) (ast.Node, bool)
+
+	// NodeText returns the n node source text.
+	NodeText(n ast.Node) []byte
+}
+
+// CustomFunc is a filter predicate that is called for the captured node,
+// like the $x node in `IsLegacyType($x)` filter.
+type CustomFunc func(ctx FilterContext, n ast.Node) bool
+
+var customFuncs struct {
+	sync.RWMutex
+	m map[string]CustomFunc
+}
+
+// Register makes fn callable from the filter expressions by the specified name.
+// The registered functions are called with a single pattern variable argument,
+// like `IsLegacyType($x)`; they can be combined with other filters as usual.
+//
+// It's intended to be called from the init functions.
+// Register panics if the name is not a valid identifier, fn is nil,
+// or the function with the same name is already registered.
+func Register(name string, fn CustomFunc) {
+	customFuncs.Lock()
+	defer customFuncs.Unlock()
+	if !token.IsIdentifier(name) {
+		panic(fmt.Sprintf("filters: register %q: name is not a valid identifier", name))
+	}
+	if fn == nil {
+		panic(fmt.Sprintf("filters: register %q: fn is nil", name))
+	}
+	if _, ok := customFuncs.m[name]; ok {
+		panic(fmt.Sprintf("filters: register %q: function is already registered", name))
+	}
+	if customFuncs.m == nil {
+		customFuncs.m = make(map[string]CustomFunc)
+	}
+	customFuncs.m[name] = fn
+}
+
+// LookupFunc returns the function registered by the specified name.
+// If there is no such function, nil is returned.
+func LookupFunc(name string) CustomFunc {
+	customFuncs.RLock()
+	defer customFuncs.RUnlock()
+	return customFuncs.m[name]
+}
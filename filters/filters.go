@@ -70,6 +70,12 @@ func NewOperationTable(varFuncs map[string]Operation) *OperationsTable {
 	return tab
 }
 
+// VarFuncName returns a method name that is associated with the var op.
+// If op is not a var function, an empty string is returned.
+func (tab *OperationsTable) VarFuncName(op Operation) string {
+	return tab.nameByOp[op]
+}
+
 func Parse(tab *OperationsTable, s string) (*Expr, Info, error) {
 	p := filterParser{tab: tab}
 	return p.Parse(s)
@@ -80,6 +86,9 @@ func Sprint(info *Info, e *Expr) string {
 	if e.Str != "" {
 		parts = append(parts, fmt.Sprintf("%q", e.Str))
 	}
+	if e.Op == OpInt {
+		parts = append(parts, fmt.Sprint(e.Num))
+	}
 	for _, arg := range e.Args {
 		parts = append(parts, Sprint(info, arg))
 	}
@@ -95,14 +104,14 @@ func Sprint(info *Info, e *Expr) string {
 	return "(" + opString + " " + strings.Join(parts, " ") + ")"
 }
 
+// Walk calls the callback for e and all of its sub-expressions, in depth-first order.
+// If the callback returns false, the sub-expressions of that e are not visited.
 func Walk(e *Expr, callback func(e *Expr) bool) {
 	if !callback(e) {
 		return
 	}
 	for _, arg := range e.Args {
-		if !callback(arg) {
-			return
-		}
+		Walk(arg, callback)
 	}
 }
 
@@ -122,6 +131,9 @@ const (
 	// OpString is a string literal that holds the value inside $Str.
 	OpString
 
+	// OpInt is an integer literal that holds the value inside $Num.
+	OpInt
+
 	// OpNot = !$Args[0]
 	OpNot
 
@@ -137,8 +149,32 @@ const (
 	// OpNotEq = $Args[0] != $Args[1]
 	OpNotEq
 
+	// OpLt = $Args[0] < $Args[1]
+	OpLt
+
+	// OpLtEq = $Args[0] <= $Args[1]
+	OpLtEq
+
+	// OpGt = $Args[0] > $Args[1]
+	OpGt
+
+	// OpGtEq = $Args[0] >= $Args[1]
+	OpGtEq
+
+	// OpIn = $Args[0] in @$Args[1]
+	// $Args[1] is a string literal that holds a value set filename.
+	OpIn
+
 	// OpFunctionVarFunc = function.$Str()
 	OpFunctionVarFunc
 
+	// OpCustomFunc = $Str($Args[0])
+	// $Str is a registered custom function name, see Register.
+	// $Args[0] is a pattern var argument.
+	OpCustomFunc
+
+	// OpVar is a $Str pattern var argument, like $y in $x.Equal($y); $Num is its ID.
+	OpVar
+
 	opLastBuiltin
 )
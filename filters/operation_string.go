@@ -11,30 +11,38 @@ func _() {
 	_ = x[OpInvalid-0]
 	_ = x[OpNop-4294967294]
 	_ = x[OpString-4294967293]
-	_ = x[OpNot-4294967292]
-	_ = x[OpAnd-4294967291]
-	_ = x[OpOr-4294967290]
-	_ = x[OpEq-4294967289]
-	_ = x[OpNotEq-4294967288]
-	_ = x[OpFunctionVarFunc-4294967287]
-	_ = x[opLastBuiltin-4294967286]
+	_ = x[OpInt-4294967292]
+	_ = x[OpNot-4294967291]
+	_ = x[OpAnd-4294967290]
+	_ = x[OpOr-4294967289]
+	_ = x[OpEq-4294967288]
+	_ = x[OpNotEq-4294967287]
+	_ = x[OpLt-4294967286]
+	_ = x[OpLtEq-4294967285]
+	_ = x[OpGt-4294967284]
+	_ = x[OpGtEq-4294967283]
+	_ = x[OpIn-4294967282]
+	_ = x[OpFunctionVarFunc-4294967281]
+	_ = x[OpCustomFunc-4294967280]
+	_ = x[OpVar-4294967279]
+	_ = x[opLastBuiltin-4294967278]
 }
 
 const (
 	_Operation_name_0 = "Invalid"
-	_Operation_name_1 = "opLastBuiltinFunctionVarFuncNotEqEqOrAndNotStringNop"
+	_Operation_name_1 = "opLastBuiltinVarCustomFuncFunctionVarFuncInGtEqGtLtEqLtNotEqEqOrAndNotIntStringNop"
 )
 
 var (
-	_Operation_index_1 = [...]uint8{0, 13, 28, 33, 35, 37, 40, 43, 49, 52}
+	_Operation_index_1 = [...]uint8{0, 13, 16, 26, 41, 43, 47, 49, 53, 55, 60, 62, 64, 67, 70, 73, 79, 82}
 )
 
 func (i Operation) String() string {
 	switch {
 	case i == 0:
 		return _Operation_name_0
-	case 4294967286 <= i && i <= 4294967294:
-		i -= 4294967286
+	case 4294967278 <= i && i <= 4294967294:
+		i -= 4294967278
 		return _Operation_name_1[_Operation_index_1[i]:_Operation_index_1[i+1]]
 	default:
 		return "Operation(" + strconv.FormatInt(int64(i), 10) + ")"
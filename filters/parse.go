@@ -1,12 +1,15 @@
 package filters
 
 import (
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 const mangledPatternVar = "__vAR_"
@@ -18,6 +21,66 @@ func preprocess(s string) string {
 	return strings.ReplaceAll(s, "$", mangledPatternVar)
 }
 
+// rewriteValueSets replaces `x in @filename` expressions with `x &^ "filename"`,
+// so they can be parsed as Go expressions.
+// The &^ operator has the highest binary operator precedence,
+// so `$x.Text() in @names.txt && $y.IsPure()` is grouped as expected.
+func rewriteValueSets(s string) (string, error) {
+	type tokenInfo struct {
+		offset int
+		tok    token.Token
+		lit    string
+	}
+	var toks []tokenInfo
+	var sc scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(s))
+	sc.Init(file, []byte(s), func(token.Position, string) {}, 0)
+	for {
+		pos, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.AND_NOT {
+			return "", errors.New("unexpected &^ operator")
+		}
+		toks = append(toks, tokenInfo{offset: file.Offset(pos), tok: tok, lit: lit})
+	}
+
+	var buf strings.Builder
+	last := 0
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.tok != token.IDENT || t.lit != "in" || i+1 == len(toks) || toks[i+1].tok != token.ILLEGAL || toks[i+1].lit != "@" {
+			continue
+		}
+		from := toks[i+1].offset + len("@")
+		to := from
+		for to < len(s) && s[to] != ')' && !unicode.IsSpace(rune(s[to])) {
+			to++
+		}
+		if from == to {
+			return "", errors.New("expected a filename after @")
+		}
+		buf.WriteString(s[last:t.offset])
+		buf.WriteString("&^ ")
+		buf.WriteString(strconv.Quote(s[from:to]))
+		last = to
+		for i+1 < len(toks) && toks[i+1].offset < to {
+			i++
+		}
+	}
+	buf.WriteString(s[last:])
+	return buf.String(), nil
+}
+
+// unpreprocess reverts the preprocess variable mangling,
+// so string literals like "$x" keep their original value.
+func unpreprocess(s string) string {
+	s = strings.ReplaceAll(s, mangledPatternVar+dollardollarVar, "$$")
+	return strings.ReplaceAll(s, mangledPatternVar, "$")
+}
+
 func isPatternVar(s string) bool { return strings.HasPrefix(s, mangledPatternVar) }
 
 func patternVarName(s string) string { return strings.TrimPrefix(s, mangledPatternVar) }
@@ -39,6 +102,10 @@ func (p *filterParser) Parse(s string) (*Expr, Info, error) {
 	if s == "" {
 		return &Expr{Op: OpNop}, p.info, nil
 	}
+	s, err := rewriteValueSets(s)
+	if err != nil {
+		return nil, Info{}, err
+	}
 
 	root, err := parser.ParseExpr(s)
 	if err != nil {
@@ -74,7 +141,10 @@ func (p *filterParser) removeNopRecursive(e *Expr) *Expr {
 		e.Args[i] = p.removeNopRecursive(arg)
 	}
 	switch e.Op {
-	case OpAnd:
+	case OpAnd, OpOr:
+		// The file predicates are only allowed in the conjunctions,
+		// so their Nop placeholders can be dropped: it's a true
+		// inside of an && and a false inside of a negated ||.
 		if e.Args[0].Op == OpNop {
 			*e = *e.Args[1]
 		} else if e.Args[1].Op == OpNop {
@@ -101,6 +171,12 @@ func (p *filterParser) convertExpr(root ast.Expr) (*Expr, error) {
 		return p.convertCallExpr(root)
 	case *ast.BasicLit:
 		return p.convertBasicLit(root)
+	case *ast.Ident:
+		if isPatternVar(root.Name) {
+			varName := patternVarName(root.Name)
+			return &Expr{Op: OpVar, Num: p.internVar(varName), Str: varName}, nil
+		}
+		return nil, fmt.Errorf("convert expr: unsupported %s identifier", root.Name)
 	default:
 		return nil, fmt.Errorf("convert expr: unsupported %T", root)
 	}
@@ -110,7 +186,10 @@ func (p *filterParser) convertBasicLit(root *ast.BasicLit) (*Expr, error) {
 	switch root.Kind {
 	case token.STRING:
 		val, err := strconv.Unquote(root.Value)
-		return &Expr{Op: OpString, Str: val}, err
+		return &Expr{Op: OpString, Str: unpreprocess(val)}, err
+	case token.INT:
+		val, err := strconv.ParseInt(root.Value, 0, 32)
+		return &Expr{Op: OpInt, Num: int32(val)}, err
 	default:
 		return nil, fmt.Errorf("convert basic lit: unsupported %s", root.Kind)
 	}
@@ -120,6 +199,9 @@ func (p *filterParser) convertCallExpr(root *ast.CallExpr) (*Expr, error) {
 	if selector, ok := root.Fun.(*ast.SelectorExpr); ok {
 		return p.convertMethodCallExpr(root, selector)
 	}
+	if ident, ok := root.Fun.(*ast.Ident); ok && LookupFunc(ident.Name) != nil {
+		return p.convertCustomCallExpr(root, ident)
+	}
 	return nil, fmt.Errorf("convert call expr: unsupported %v function", root.Fun)
 }
 
@@ -142,6 +224,13 @@ func (p *filterParser) convertMethodCallExpr(root *ast.CallExpr, selector *ast.S
 			op, ok := p.tab.opByVarFunc[selector.Sel.Name]
 			if ok {
 				e := &Expr{Op: op, Num: id, Str: varName}
+				for _, arg := range root.Args {
+					x, err := p.convertExpr(arg)
+					if err != nil {
+						return nil, err
+					}
+					e.Args = append(e.Args, x)
+				}
 				return e, nil
 			}
 			return nil, fmt.Errorf("convert method expr: unsupported %s method", selector.Sel.Name)
@@ -150,6 +239,19 @@ func (p *filterParser) convertMethodCallExpr(root *ast.CallExpr, selector *ast.S
 	}
 }
 
+func (p *filterParser) convertCustomCallExpr(root *ast.CallExpr, fn *ast.Ident) (*Expr, error) {
+	if len(root.Args) == 1 {
+		if arg, ok := root.Args[0].(*ast.Ident); ok && isPatternVar(arg.Name) {
+			x, err := p.convertExpr(arg)
+			if err != nil {
+				return nil, err
+			}
+			return &Expr{Op: OpCustomFunc, Num: x.Num, Str: fn.Name, Args: []*Expr{x}}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s() expects a single pattern var argument", fn.Name)
+}
+
 func (p *filterParser) convertFunctionMethodCallExpr(root *ast.CallExpr, method *ast.Ident) (*Expr, error) {
 	if !p.insideOr {
 		f := SpecialPredicate{Name: method.Name, Negated: p.insideNot}
@@ -163,7 +265,7 @@ func (p *filterParser) convertFunctionMethodCallExpr(root *ast.CallExpr, method
 
 func (p *filterParser) convertFileMethodCallExpr(root *ast.CallExpr, method *ast.Ident) (*Expr, error) {
 	if p.insideOr {
-		return nil, fmt.Errorf("file filters can't be a part of || expression")
+		return nil, fmt.Errorf("file filters can't be a part of || or negated && expression")
 	}
 	f := SpecialPredicate{Name: method.Name, Negated: p.insideNot}
 	p.info.FilePredicates = append(p.info.FilePredicates, f)
@@ -211,9 +313,13 @@ func (p *filterParser) convertBinaryExpr(root *ast.BinaryExpr) (*Expr, error) {
 }
 
 func (p *filterParser) convertBinaryExprXY(op token.Token, x, y ast.Expr) (*Expr, error) {
-	if op == token.LOR {
+	if op == token.LOR || op == token.LAND {
+		// A negated && is a disjunction, `!(a && b)` is `!a || !b`,
+		// while a negated || is a conjunction, `!(a || b)` is `!a && !b`.
 		insideOr := p.insideOr
-		p.insideOr = true
+		if (op == token.LOR) != p.insideNot {
+			p.insideOr = true
+		}
 		lhs, err := p.convertExpr(x)
 		if err != nil {
 			return nil, err
@@ -223,7 +329,10 @@ func (p *filterParser) convertBinaryExprXY(op token.Token, x, y ast.Expr) (*Expr
 			return nil, err
 		}
 		p.insideOr = insideOr
-		return &Expr{Op: OpOr, Args: []*Expr{lhs, rhs}}, nil
+		if op == token.LOR {
+			return &Expr{Op: OpOr, Args: []*Expr{lhs, rhs}}, nil
+		}
+		return &Expr{Op: OpAnd, Args: []*Expr{lhs, rhs}}, nil
 	}
 
 	lhs, err := p.convertExpr(x)
@@ -236,13 +345,21 @@ func (p *filterParser) convertBinaryExprXY(op token.Token, x, y ast.Expr) (*Expr
 	}
 
 	switch op {
-	case token.LAND:
-		return &Expr{Op: OpAnd, Args: []*Expr{lhs, rhs}}, nil
-
 	case token.EQL:
 		return &Expr{Op: OpEq, Args: []*Expr{lhs, rhs}}, nil
 	case token.NEQ:
 		return &Expr{Op: OpNotEq, Args: []*Expr{lhs, rhs}}, nil
+	case token.LSS:
+		return &Expr{Op: OpLt, Args: []*Expr{lhs, rhs}}, nil
+	case token.LEQ:
+		return &Expr{Op: OpLtEq, Args: []*Expr{lhs, rhs}}, nil
+	case token.GTR:
+		return &Expr{Op: OpGt, Args: []*Expr{lhs, rhs}}, nil
+	case token.GEQ:
+		return &Expr{Op: OpGtEq, Args: []*Expr{lhs, rhs}}, nil
+	case token.AND_NOT:
+		// A rewritten `x in @filename` expression.
+		return &Expr{Op: OpIn, Args: []*Expr{lhs, rhs}}, nil
 	}
 
 	return nil, fmt.Errorf("convert binary expr: unsupported %s", op)
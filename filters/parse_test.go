@@ -2,9 +2,15 @@ package filters
 
 import (
 	"fmt"
+	"go/ast"
+	"strings"
 	"testing"
 )
 
+func init() {
+	Register("IsLegacyType", func(ctx FilterContext, n ast.Node) bool { return false })
+}
+
 func TestParse(t *testing.T) {
 	tests := []struct {
 		input string
@@ -70,7 +76,7 @@ func TestParse(t *testing.T) {
 		{
 			input: `!($x.IsPure() || function.IsHot())`,
 			expr:  `(Not (Or (%IsPure "x") (FunctionVarFunc "IsHot")))`,
-			info:  `$x`,
+			info:  `!function.IsHot() $x`,
 		},
 
 		{
@@ -107,10 +113,55 @@ func TestParse(t *testing.T) {
 			info:  `file.IsTest() $c`,
 		},
 		{
-			input: `$x.IsConst() && !($y.IsConst() && file.IsTest())`,
+			input: `$x.IsConst() && !($y.IsConst() || file.IsTest())`,
 			expr:  `(And (%IsConst "x") (Not (%IsConst "y")))`,
 			info:  `!file.IsTest() $x $y`,
 		},
+		{
+			input: `!(!file.IsTest() || $x.IsConst())`,
+			expr:  `(Not (%IsConst "x"))`,
+			info:  `file.IsTest() $x`,
+		},
+		{
+			input: `!($x.IsPure() && function.IsHot())`,
+			expr:  `(Not (And (%IsPure "x") (FunctionVarFunc "IsHot")))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.IsPure() || $y.IsPure() && !$z.IsPure()`,
+			expr:  `(Or (%IsPure "x") (And (%IsPure "y") (Not (%IsPure "z"))))`,
+			info:  `$x $y $z`,
+		},
+		{
+			input: `($x.IsPure() || $y.IsPure()) && !$z.IsPure()`,
+			expr:  `(And (Or (%IsPure "x") (%IsPure "y")) (Not (%IsPure "z")))`,
+			info:  `$x $y $z`,
+		},
+		{
+			input: `$x.IsPure() && $y.IsPure() || $z.IsPure()`,
+			expr:  `(Or (And (%IsPure "x") (%IsPure "y")) (%IsPure "z"))`,
+			info:  `$x $y $z`,
+		},
+		{
+			input: `!$x.IsPure() || $y.IsPure()`,
+			expr:  `(Or (Not (%IsPure "x")) (%IsPure "y"))`,
+			info:  `$x $y`,
+		},
+		{
+			input: `!($x.IsPure() || $y.IsPure())`,
+			expr:  `(Not (Or (%IsPure "x") (%IsPure "y")))`,
+			info:  `$x $y`,
+		},
+		{
+			input: `!!$x.IsPure()`,
+			expr:  `(Not (Not (%IsPure "x")))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.Len() > 1 && $x.Text() == "a" || $x.Len() == 0`,
+			expr:  `(Or (And (Gt (%Len "x") (Int 1)) (Eq (%Text "x") (String "a"))) (Eq (%Len "x") (Int 0)))`,
+			info:  `$x`,
+		},
 
 		{
 			input: `$$.IsPure()`,
@@ -138,17 +189,115 @@ func TestParse(t *testing.T) {
 			expr:  `(NotEq (%Text "x") (String "String"))`,
 			info:  `$x`,
 		},
+
+		{
+			input: `$x.Len() > 10`,
+			expr:  `(Gt (%Len "x") (Int 10))`,
+			info:  `$x`,
+		},
+		{
+			input: `10 > $x.Len()`,
+			expr:  `(Lt (%Len "x") (Int 10))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.Len() <= 0x10`,
+			expr:  `(LtEq (%Len "x") (Int 16))`,
+			info:  `$x`,
+		},
+		{
+			input: `1 <= $x.Len() && $x.Len() < 5`,
+			expr:  `(And (GtEq (%Len "x") (Int 1)) (Lt (%Len "x") (Int 5)))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.Text() == $y.Text()`,
+			expr:  `(Eq (%Text "x") (%Text "y"))`,
+			info:  `$x $y`,
+		},
+
+		{
+			input: `$x.Text() in @banned.txt`,
+			expr:  `(In (%Text "x") (String "banned.txt"))`,
+			info:  `$x`,
+		},
+		{
+			input: `!($f.Text() in @testdata/funcs.txt) && $x.IsPure()`,
+			expr:  `(And (Not (In (%Text "f") (String "testdata/funcs.txt"))) (%IsPure "x"))`,
+			info:  `$f $x`,
+		},
+		{
+			input: `$x.Text() in @a.txt || $x.Text() in @b.txt`,
+			expr:  `(Or (In (%Text "x") (String "a.txt")) (In (%Text "x") (String "b.txt")))`,
+			info:  `$x`,
+		},
+
+		{
+			input: `$$.Has("fmt")`,
+			expr:  `(%Has "_Dollar2_" (String "fmt"))`,
+			info:  `$_Dollar2_`,
+		},
+		{
+			input: `$x.Text() == "$y"`,
+			expr:  `(Eq (%Text "x") (String "$y"))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.Has("$$ + $*_")`,
+			expr:  `(%Has "x" (String "$$ + $*_"))`,
+			info:  `$x`,
+		},
+		{
+			input: `$x.Similar("context", 2)`,
+			expr:  `(%Similar "x" (String "context") (Int 2))`,
+			info:  `$x`,
+		},
+		{
+			input: `!$x.Has("a") && $x.IsPure()`,
+			expr:  `(And (Not (%Has "x" (String "a"))) (%IsPure "x"))`,
+			info:  `$x`,
+		},
+
+		{
+			input: `$x.Equal($y)`,
+			expr:  `(%Equal "x" (Var "y"))`,
+			info:  `$x $y`,
+		},
+		{
+			input: `!$x.Equal($$)`,
+			expr:  `(Not (%Equal "x" (Var "_Dollar2_")))`,
+			info:  `$x $_Dollar2_`,
+		},
+
+		{
+			input: `IsLegacyType($x)`,
+			expr:  `(CustomFunc "IsLegacyType" (Var "x"))`,
+			info:  `$x`,
+		},
+		{
+			input: `!IsLegacyType($$) && $y.IsPure()`,
+			expr:  `(And (Not (CustomFunc "IsLegacyType" (Var "_Dollar2_"))) (%IsPure "y"))`,
+			info:  `$_Dollar2_ $y`,
+		},
 	}
 
 	const (
 		opVarIsConst = iota + 1
 		opVarIsPure
 		opVarText
+		opVarLen
+		opVarHas
+		opVarSimilar
+		opVarEqual
 	)
 	varOps := map[string]Operation{
 		"IsConst": opVarIsConst,
 		"IsPure":  opVarIsPure,
 		"Text":    opVarText,
+		"Len":     opVarLen,
+		"Has":     opVarHas,
+		"Similar": opVarSimilar,
+		"Equal":   opVarEqual,
 	}
 	optab := NewOperationTable(varOps)
 
@@ -170,3 +319,102 @@ func TestParse(t *testing.T) {
 		})
 	}
 }
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`file.IsTest() || $x.IsPure()`, `file filters can't be a part of || or negated && expression`},
+		{`$x.IsPure() && ($y.IsPure() || !file.IsTest())`, `file filters can't be a part of || or negated && expression`},
+		// `!($y && file.IsTest())` is `!$y || !file.IsTest()`.
+		{`$x.IsPure() && !($y.IsPure() && file.IsTest())`, `file filters can't be a part of || or negated && expression`},
+		{`!(!($x.IsPure() || file.IsTest()))`, `file filters can't be a part of || or negated && expression`},
+
+		{`$x.IsPure() & $y.IsPure()`, `convert binary expr: unsupported &`},
+		{`-$x.Len() > 1`, `convert unary expr: unsupported -`},
+
+		{`IsLegacyType($x, $y)`, `IsLegacyType() expects a single pattern var argument`},
+		{`IsLegacyType("x")`, `IsLegacyType() expects a single pattern var argument`},
+		{`IsLegacyType($x.Len())`, `IsLegacyType() expects a single pattern var argument`},
+		{`IsUnknown($x)`, `convert call expr: unsupported IsUnknown function`},
+		{`$x.Len(y)`, `convert expr: unsupported y identifier`},
+	}
+
+	optab := NewOperationTable(map[string]Operation{"IsPure": 1, "Len": 2})
+	for _, test := range tests {
+		_, _, err := Parse(optab, test.input)
+		if err == nil {
+			t.Errorf("parse %q: expected an error", test.input)
+			continue
+		}
+		if err.Error() != test.err {
+			t.Errorf("parse %q:\nhave: %s\nwant: %s", test.input, err, test.err)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	optab := NewOperationTable(map[string]Operation{"IsPure": 1, "IsConst": 2})
+	e, info, err := Parse(optab, `$a.IsPure() && !($b.IsConst() || $c.IsPure()) && ($d.IsPure() || $e.IsPure())`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var all []string
+	Walk(e, func(e *Expr) bool {
+		if name := optab.VarFuncName(e.Op); name != "" {
+			all = append(all, name)
+		} else {
+			all = append(all, e.Op.String())
+		}
+		return true
+	})
+	var noOr []string
+	Walk(e, func(e *Expr) bool {
+		if e.Op == OpOr {
+			return false
+		}
+		if e.Str != "" {
+			noOr = append(noOr, e.Str)
+		}
+		return true
+	})
+
+	ops := func(names ...string) string { return strings.Join(names, " ") }
+	if have, want := ops(all...), "And And IsPure Not Or IsConst IsPure Or IsPure IsPure"; have != want {
+		t.Errorf("%s walk:\nhave: %s\nwant: %s", Sprint(&info, e), have, want)
+	}
+	if have, want := ops(noOr...), "a"; have != want {
+		t.Errorf("%s walk without ||:\nhave: %s\nwant: %s", Sprint(&info, e), have, want)
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	fn := func(ctx FilterContext, n ast.Node) bool { return true }
+	tests := []struct {
+		name string
+		fn   CustomFunc
+		want string
+	}{
+		{"IsLegacyType", fn, `filters: register "IsLegacyType": function is already registered`},
+		{"", fn, `filters: register "": name is not a valid identifier`},
+		{"Is-Legacy", fn, `filters: register "Is-Legacy": name is not a valid identifier`},
+		{"IsNew", nil, `filters: register "IsNew": fn is nil`},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				have := fmt.Sprint(recover())
+				if have != test.want {
+					t.Errorf("register %q:\nhave: %s\nwant: %s", test.name, have, test.want)
+				}
+			}()
+			Register(test.name, test.fn)
+		}()
+	}
+	if LookupFunc("IsNew") != nil {
+		t.Errorf("IsNew: expected the failed registration to be discarded")
+	}
+}
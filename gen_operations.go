@@ -58,6 +58,7 @@ var opPrototypes = []operationProto{
 
 	{name: "SimpleSelectorExpr", tag: "SelectorExpr", args: "x", valueIndex: "strings | selector name"},
 	{name: "SelectorExpr", tag: "SelectorExpr", args: "x sel"},
+	{name: "ChainSelectorExpr", tag: "Node", args: "x sel", example: "$*x.sel"},
 	{name: "TypeAssertExpr", tag: "TypeAssertExpr", args: "x typ"},
 	{name: "TypeSwitchAssertExpr", tag: "TypeAssertExpr", args: "x"},
 
@@ -79,7 +80,11 @@ var opPrototypes = []operationProto{
 
 	{name: "StarExpr", tag: "StarExpr", args: "x"},
 	{name: "UnaryExpr", tag: "UnaryExpr", args: "x", value: "token.Token | unary operator"},
+	{name: "AnyUnaryExpr", tag: "UnaryExpr", args: "x", example: "$_ x"},
+	{name: "NamedUnaryExpr", tag: "UnaryExpr", args: "x", valueIndex: "strings | wildcard name", example: "$op x"},
 	{name: "BinaryExpr", tag: "BinaryExpr", args: "x y", value: "token.Token | binary operator"},
+	{name: "AnyBinaryExpr", tag: "BinaryExpr", args: "x y", example: "x $_ y"},
+	{name: "NamedBinaryExpr", tag: "BinaryExpr", args: "x y", valueIndex: "strings | wildcard name", example: "x $op y"},
 	{name: "ParenExpr", tag: "ParenExpr", args: "x"},
 
 	{
@@ -167,6 +172,7 @@ var opPrototypes = []operationProto{
 	{name: "SimpleField", args: "typ", valueIndex: "strings | field name", example: "name type"},
 	{name: "Field", args: "name typ", example: "$name type"},
 	{name: "MultiField", args: "names... typ", example: "name1, name2 type"},
+	{name: "AnyNamesField", args: "names... typ", example: "$*names type", note: "matches the fields with any number of names, including the embedded ones"},
 
 	{name: "ValueSpec", tag: "ValueSpec", args: "value"},
 	{name: "ValueInitSpec", tag: "ValueSpec", args: "lhs... rhs...", example: "lhs = rhs"},
@@ -177,6 +183,10 @@ var opPrototypes = []operationProto{
 	{name: "TypeSpec", tag: "TypeSpec", args: "name type", example: "name type"},
 	{name: "GenericTypeSpec", tag: "TypeSpec", args: "name typeparasm type", example: "name[typeparams] type"},
 	{name: "TypeAliasSpec", tag: "TypeSpec", args: "name type", example: "name = type"},
+	{name: "GenericTypeAliasSpec", tag: "TypeSpec", args: "name typeparams type", example: "name[typeparams] = type"},
+
+	{name: "ImportSpec", tag: "ImportSpec", args: "path", example: `"path"`},
+	{name: "NamedImportSpec", tag: "ImportSpec", args: "name path", example: `name "path"`},
 
 	{name: "SimpleFuncDecl", tag: "FuncDecl", args: "type block", valueIndex: "strings | field name"},
 	{name: "FuncDecl", tag: "FuncDecl", args: "name type block"},
@@ -1,11 +1,11 @@
 package gogrep
 
 import (
+	"context"
 	"errors"
 	"go/ast"
 	"go/token"
 	"go/types"
-	"strings"
 
 	"github.com/quasilyte/gogrep/nodetag"
 )
@@ -17,6 +17,13 @@ func IsEmptyNodeSlice(n ast.Node) bool {
 	return false
 }
 
+// EqualNodes reports whether x and y are structurally equal, their positions are ignored.
+// The captured node slices and operators are compared the same way as the backreferences,
+// so it's true for any two captures that could be bound to the same pattern var.
+func EqualNodes(x, y ast.Node) bool {
+	return equalNodes(x, y)
+}
+
 // MatchData describes a successful pattern match.
 type MatchData struct {
 	Node    ast.Node
@@ -45,6 +52,17 @@ type PartialNode struct {
 func (p *PartialNode) Pos() token.Pos { return p.from }
 func (p *PartialNode) End() token.Pos { return p.to }
 
+// OperatorNode is a binary or unary expression operator captured by an operator wildcard.
+// For `$x $op $y` pattern matching `a + b`, $op is bound to the `+` operator node.
+// For `($op $x)` pattern matching `!ok`, $op is bound to the `!` operator node.
+type OperatorNode struct {
+	Op    token.Token
+	OpPos token.Pos
+}
+
+func (o *OperatorNode) Pos() token.Pos { return o.OpPos }
+func (o *OperatorNode) End() token.Pos { return o.OpPos + token.Pos(len(o.Op.String())) }
+
 type MatcherState struct {
 	Types *types.Info
 
@@ -65,15 +83,27 @@ type MatcherState struct {
 	nodeSlices     []NodeSlice
 	nodeSlicesUsed int
 
+	opNodes     []OperatorNode
+	opNodesUsed int
+
 	pc int
 
 	partial PartialNode
+
+	// ctx is the MatchNodeCtx context, it's nil for the MatchNode calls.
+	ctx context.Context
+	// ctxErr is the ctx error that interrupted the current match.
+	ctxErr error
+	// steps counts the executed instructions, so ctx is checked every ctxCheckInterval steps.
+	// It's not reset between the calls: a lot of the short matches are checked too.
+	steps int
 }
 
 func NewMatcherState() MatcherState {
 	return MatcherState{
 		capture:    make([]CapturedNode, 0, 8),
 		nodeSlices: make([]NodeSlice, 16),
+		opNodes:    make([]OperatorNode, 4),
 	}
 }
 
@@ -89,11 +119,133 @@ func (p *Pattern) NodeTag() nodetag.Value {
 	return operationInfoTable[p.m.prog.insts[0].op].Tag
 }
 
+// RootKind returns the node kind of every pattern match root.
+// It returns nodetag.Node if the pattern can match several node kinds,
+// like a wildcard or a statements list pattern.
+func (p *Pattern) RootKind() nodetag.Value {
+	op := p.m.prog.insts[0].op
+	if op == opEfaceType {
+		return nodetag.Node // Also matches the any ident
+	}
+	tag := operationInfoTable[op].Tag
+	if tag == nodetag.Unknown || tag >= nodetag.NumBuckets {
+		return nodetag.Node
+	}
+	return tag
+}
+
+// RequiredIdents returns the identifier names that are present in every pattern match.
+// A source text that doesn't contain all of them can't be matched by the pattern,
+// so they can be used for a quick text-based prescreen.
+// The result can be empty, for example, for the patterns that only consist of the wildcards.
+func (p *Pattern) RequiredIdents() []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, inst := range p.m.prog.insts {
+		switch inst.op {
+		case opIdent, opSimpleSelectorExpr, opSimpleField, opSimpleTypeSpec, opSimpleFuncDecl,
+			opSimpleLabeledStmt, opSimpleLabeledBranchStmt:
+			// These ops compare the names exactly.
+			name := p.m.stringValue(inst)
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
 // MatchNode calls cb if n matches a pattern.
 func (p *Pattern) MatchNode(state *MatcherState, n ast.Node, cb func(MatchData)) {
 	p.m.MatchNode(state, n, cb)
 }
 
+// MatchNodeCtx is like MatchNode, but the match can be interrupted by the ctx cancellation.
+// The ctx is checked before the match and then periodically during it,
+// so even a pattern with a lot of backtracking, like `$*_; $*_; f($*_); $*_`
+// over a big block, stops shortly after the deadline.
+//
+// If the match is interrupted, cb is not called anymore and the ctx error is returned.
+// The cancellation inside of cb prevents the further cb calls too.
+// The state can be re-used after an interrupted match in the next match calls.
+func (p *Pattern) MatchNodeCtx(ctx context.Context, state *MatcherState, n ast.Node, cb func(MatchData)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	state.ctx = ctx
+	state.ctxErr = nil
+	p.m.MatchNode(state, n, func(data MatchData) {
+		if state.ctxErr != nil {
+			return
+		}
+		cb(data)
+		state.ctxErr = ctx.Err()
+	})
+	err := state.ctxErr
+	state.ctx = nil
+	state.ctxErr = nil
+	return err
+}
+
+// FileMatch is a pattern match with positions resolved against a file set.
+type FileMatch struct {
+	Data MatchData
+
+	Start token.Position
+	End   token.Position
+
+	// CapturePos contains positions for every Data.Capture element (same order).
+	CapturePos []CapturedPos
+}
+
+// CapturedPos is a captured node position range.
+// An empty node slice capture, like $*args in `f()`, has a zero range.
+type CapturedPos struct {
+	Start token.Position
+	End   token.Position
+}
+
+// MatchFile collects all matches inside already parsed file f.
+//
+// fset is used to resolve the positions, so it should be the same
+// file set that was used to parse f.
+//
+// Unlike MatchNode, the results do not share any memory with the state,
+// so they can be retained after the next match call.
+func (p *Pattern) MatchFile(state *MatcherState, fset *token.FileSet, f *ast.File) []FileMatch {
+	var result []FileMatch
+	accept := func(data MatchData) {
+		m := FileMatch{
+			Data: MatchData{
+				Node:    cloneMatchNode(data.Node),
+				Capture: make([]CapturedNode, len(data.Capture)),
+			},
+			Start:      fset.Position(data.Node.Pos()),
+			End:        fset.Position(data.Node.End()),
+			CapturePos: make([]CapturedPos, len(data.Capture)),
+		}
+		for i, c := range data.Capture {
+			m.Data.Capture[i] = CapturedNode{Name: c.Name, Node: cloneMatchNode(c.Node)}
+			if !IsEmptyNodeSlice(c.Node) {
+				m.CapturePos[i] = CapturedPos{
+					Start: fset.Position(c.Node.Pos()),
+					End:   fset.Position(c.Node.End()),
+				}
+			}
+		}
+		result = append(result, m)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		p.MatchNode(state, n, accept)
+		return true
+	})
+	return result
+}
+
 // Clone creates a pattern copy.
 func (p *Pattern) Clone() *Pattern {
 	clone := *p
@@ -118,18 +270,61 @@ type CompileConfig struct {
 	// random fmt variable.
 	WithTypes bool
 
+	// IgnoreParens makes the pattern treat redundant parenthesized expressions
+	// as if they had no parens at all. Both pattern and target parens are affected,
+	// so `($x)` and `$x` patterns would match both `(a + b)` and `a + b`.
+	// Since grouping is already encoded in the AST structure, this doesn't
+	// make `(a + b) * c` identical to `a + b * c`.
+	IgnoreParens bool
+
+	// TopLevelDecls makes the pattern a sequence of top-level declarations
+	// that is matched against the consecutive declarations of a file.
+	// Without it, `const $_ = $_; var $_ = $_` is parsed as statements
+	// and only matches the declarations inside the function bodies.
+	//
+	// In this mode, a `$x` in a declaration position matches any declaration
+	// and a `$*x` matches any number of them, so `type $T $_; $*_; func $_() *$T { $*_ }`
+	// doesn't require the declarations to be adjacent.
+	TopLevelDecls bool
+
 	// Imports specifies packages that should be recognized for the type-aware matching.
 	// It maps a package name to a package path.
 	// Only used if WithTypes is true.
 	Imports map[string]string
+
+	// VarPrefix is a pattern vars prefix that is used instead of $, like @ in `@x + @y`.
+	// It's useful when the patterns are embedded into the shell scripts or templates
+	// where $ has a special meaning. The supported prefixes are @ # % ? and ^.
+	//
+	// A doubled prefix stands for the literal character, so `@x %% @y` is `$x % $y`
+	// with a % prefix. A prefix that is not followed by a name or a * is literal too,
+	// like in `@x % @y`. The string literals and comments are not affected.
+	// The $ itself can't be used when a custom prefix is set.
+	//
+	// If it's 0, the default $ prefix is used.
+	VarPrefix rune
 }
 
 func Compile(config CompileConfig) (*Pattern, PatternInfo, error) {
-	if strings.HasPrefix(config.Src, "import $") {
+	if config.VarPrefix != 0 && config.VarPrefix != '$' {
+		src, err := replaceVarPrefix(config.Src, config.VarPrefix)
+		if err != nil {
+			return nil, newPatternInfo(), err
+		}
+		config.Src = src
+	}
+	if !config.TopLevelDecls && isImportDeclPattern(config.Src) {
 		return compileImportPattern(config)
 	}
 	info := newPatternInfo()
-	n, err := parseExpr(config.Fset, config.Src)
+	var n ast.Node
+	var opVars map[ast.Node]string
+	var err error
+	if config.TopLevelDecls {
+		n, err = parseDecls(config.Fset, config.Src)
+	} else {
+		n, opVars, err = parseExpr(config.Fset, config.Src)
+	}
 	if err != nil {
 		return nil, info, err
 	}
@@ -138,11 +333,13 @@ func Compile(config CompileConfig) (*Pattern, PatternInfo, error) {
 	}
 	var c compiler
 	c.config = config
+	c.opVars = opVars
 	prog, err := c.Compile(n, &info)
 	if err != nil {
 		return nil, info, err
 	}
 	m := newMatcher(prog)
+	m.ignoreParens = config.IgnoreParens
 	return &Pattern{m: m}, info, nil
 }
 
@@ -180,6 +377,24 @@ func Walk(root ast.Node, fn func(n ast.Node) bool) {
 	ast.Inspect(root, fn)
 }
 
+// cloneMatchNode copies the nodes that are owned by the MatcherState.
+// These nodes are re-used between the MatchNode calls.
+func cloneMatchNode(n ast.Node) ast.Node {
+	switch n := n.(type) {
+	case *NodeSlice:
+		clone := *n
+		return &clone
+	case *PartialNode:
+		clone := *n
+		return &clone
+	case *OperatorNode:
+		clone := *n
+		return &clone
+	default:
+		return n
+	}
+}
+
 func newPatternInfo() PatternInfo {
 	return PatternInfo{
 		Vars: make(map[string]struct{}),
@@ -15,6 +15,8 @@ type matcher struct {
 	prog *program
 
 	insts []instruction
+
+	ignoreParens bool
 }
 
 func newMatcher(prog *program) *matcher {
@@ -72,9 +74,19 @@ func (m *matcher) allocNodeSlice(state *MatcherState) *NodeSlice {
 	return &NodeSlice{}
 }
 
+func (m *matcher) allocOperatorNode(state *MatcherState) *OperatorNode {
+	if state.opNodesUsed < len(state.opNodes) {
+		i := state.opNodesUsed
+		state.opNodesUsed++
+		return &state.opNodes[i]
+	}
+	return &OperatorNode{}
+}
+
 func (m *matcher) MatchNode(state *MatcherState, n ast.Node, accept func(MatchData)) {
 	state.pc = 0
 	state.nodeSlicesUsed = 0
+	state.opNodesUsed = 0
 	inst := m.nextInst(state)
 	switch inst.op {
 	case opMultiStmt:
@@ -108,6 +120,11 @@ func (m *matcher) MatchNode(state *MatcherState, n ast.Node, accept func(MatchDa
 	case opRangeKeyValueHeader:
 		m.matchRangeKeyValueHeader(state, inst, n, accept)
 	default:
+		if _, ok := n.(*ast.ParenExpr); ok && m.ignoreParens {
+			// The parenthesized expression will be visited on its own.
+			// Matching it here would report the same match twice.
+			return
+		}
 		m.resetCapture(state)
 		if m.matchNodeWithInst(state, inst, n) {
 			accept(MatchData{
@@ -170,6 +187,20 @@ func (m *matcher) matchNamed(state *MatcherState, name string, n ast.Node) bool
 	return equalNodes(prev, n)
 }
 
+func (m *matcher) matchNamedOperator(state *MatcherState, name string, tok token.Token, pos token.Pos) bool {
+	prev, ok := findNamed(state.capture, name)
+	if !ok {
+		// First occurrence, record value.
+		op := m.allocOperatorNode(state)
+		op.Op = tok
+		op.OpPos = pos
+		state.capture = append(state.capture, CapturedNode{Name: name, Node: op})
+		return true
+	}
+	prevOp, ok := prev.(*OperatorNode)
+	return ok && prevOp.Op == tok
+}
+
 func (m *matcher) matchNamedField(state *MatcherState, name string, n ast.Node) bool {
 	prev, ok := findNamed(state.capture, name)
 	if !ok {
@@ -196,7 +227,30 @@ func (m *matcher) unwrapNode(x ast.Node) ast.Node {
 	return x
 }
 
+// ctxCheckInterval is the number of the matched instructions between the MatchNodeCtx context checks.
+const ctxCheckInterval = 1024
+
+// interrupted reports whether the MatchNodeCtx context is done.
+// Once it is, every match attempt fails until the end of the current match call.
+func (m *matcher) interrupted(state *MatcherState) bool {
+	if state.ctxErr != nil {
+		return true
+	}
+	state.steps++
+	if state.steps%ctxCheckInterval == 0 {
+		state.ctxErr = state.ctx.Err()
+	}
+	return state.ctxErr != nil
+}
+
 func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast.Node) bool {
+	if state.ctx != nil && m.interrupted(state) {
+		return false
+	}
+	if m.ignoreParens {
+		n = unparen(n)
+	}
+
 	switch inst.op {
 	case opNode:
 		return n != nil
@@ -206,13 +260,26 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 	case opNamedNode:
 		return n != nil && m.matchNamed(state, m.stringValue(inst), n)
 	case opNamedOptNode:
+		if isMissingNode(n) {
+			// A missing optional node (like if statement init) is bound
+			// to an empty slice, so the captures are never nil.
+			slice := m.allocNodeSlice(state)
+			slice.assignExprSlice(nil)
+			return m.matchNamed(state, m.stringValue(inst), slice)
+		}
 		return m.matchNamed(state, m.stringValue(inst), n)
 
 	case opFieldNode:
 		n, ok := n.(*ast.FieldList)
 		return ok && n != nil && len(n.List) == 1 && len(n.List[0].Names) == 0
 	case opNamedFieldNode:
-		return n != nil && m.matchNamedField(state, m.stringValue(inst), n)
+		if isMissingNode(n) {
+			// `func f() $results` - no results are bound to an empty slice.
+			slice := m.allocNodeSlice(state)
+			slice.assignFieldSlice(nil)
+			return m.matchNamed(state, m.stringValue(inst), slice)
+		}
+		return m.matchNamedField(state, m.stringValue(inst), n)
 
 	case opBasicLit:
 		n, ok := n.(*ast.BasicLit)
@@ -254,10 +321,25 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 		n, ok := n.(*ast.BinaryExpr)
 		return ok && n.Op == token.Token(inst.value) &&
 			m.matchNode(state, n.X) && m.matchNode(state, n.Y)
+	case opAnyBinaryExpr:
+		n, ok := n.(*ast.BinaryExpr)
+		return ok && m.matchNode(state, n.X) && m.matchNode(state, n.Y)
+	case opNamedBinaryExpr:
+		n, ok := n.(*ast.BinaryExpr)
+		return ok && m.matchNode(state, n.X) &&
+			m.matchNamedOperator(state, m.stringValue(inst), n.Op, n.OpPos) &&
+			m.matchNode(state, n.Y)
 
 	case opUnaryExpr:
 		n, ok := n.(*ast.UnaryExpr)
 		return ok && n.Op == token.Token(inst.value) && m.matchNode(state, n.X)
+	case opAnyUnaryExpr:
+		n, ok := n.(*ast.UnaryExpr)
+		return ok && m.matchNode(state, n.X)
+	case opNamedUnaryExpr:
+		n, ok := n.(*ast.UnaryExpr)
+		return ok && m.matchNamedOperator(state, m.stringValue(inst), n.Op, n.OpPos) &&
+			m.matchNode(state, n.X)
 
 	case opStarExpr:
 		n, ok := n.(*ast.StarExpr)
@@ -288,6 +370,15 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 	case opSelectorExpr:
 		n, ok := n.(*ast.SelectorExpr)
 		return ok && m.matchNode(state, n.Sel) && m.matchNode(state, n.X)
+	case opChainSelectorExpr:
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			return m.matchNode(state, n.Sel) && m.matchNode(state, n.X)
+		case *ast.Ident:
+			// An empty chain: the sel is matched against the unqualified ident.
+			return m.matchNode(state, n) && m.matchNode(state, nil)
+		}
+		return false
 
 	case opTypeAssertExpr:
 		n, ok := n.(*ast.TypeAssertExpr)
@@ -401,6 +492,9 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 	case opMultiField:
 		n, ok := n.(*ast.Field)
 		return ok && len(n.Names) >= 2 && m.matchIdentSlice(state, n.Names) && m.matchNode(state, n.Type)
+	case opAnyNamesField:
+		n, ok := n.(*ast.Field)
+		return ok && m.matchIdentSlice(state, n.Names) && m.matchNode(state, n.Type)
 	case opFieldList:
 		// FieldList could be nil in places like function return types.
 		n, ok := n.(*ast.FieldList)
@@ -626,6 +720,9 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 	case opTypeAliasSpec:
 		n, ok := n.(*ast.TypeSpec)
 		return ok && n.Assign.IsValid() && m.matchNode(state, n.Name) && m.matchNode(state, n.Type)
+	case opGenericTypeAliasSpec:
+		n, ok := n.(*ast.TypeSpec)
+		return ok && n.Assign.IsValid() && m.matchNode(state, n.Name) && m.matchNode(state, typeparams.ForTypeSpec(n)) && m.matchNode(state, n.Type)
 
 	case opDeclStmt:
 		n, ok := n.(*ast.DeclStmt)
@@ -640,6 +737,13 @@ func (m *matcher) matchNodeWithInst(state *MatcherState, inst instruction, n ast
 	case opTypeDecl:
 		n, ok := n.(*ast.GenDecl)
 		return ok && n.Tok == token.TYPE && m.matchSpecSlice(state, n.Specs)
+	case opImportSpec:
+		n, ok := n.(*ast.ImportSpec)
+		return ok && n.Name == nil && m.matchNode(state, n.Path)
+	case opNamedImportSpec:
+		n, ok := n.(*ast.ImportSpec)
+		return ok && n.Name != nil && m.matchNode(state, n.Name) && m.matchNode(state, n.Path)
+
 	case opAnyImportDecl:
 		n, ok := n.(*ast.GenDecl)
 		return ok && n.Tok == token.IMPORT
@@ -778,6 +882,10 @@ func (m *matcher) matchNodeList(state *MatcherState, nodes *NodeSlice, partial b
 		return m.matchNamed(state, wildName, slice)
 	}
 	for ; inst.op != opEnd || j < sliceLen; inst = m.nextInst(state) {
+		if state.ctxErr != nil {
+			// The MatchNodeCtx context is done, there is no point in trying the other restarts.
+			return nil, -1
+		}
 		if inst.op != opEnd {
 			if inst.op == opNodeSeq || inst.op == opNamedNodeSeq {
 				// keep track of where this wildcard
@@ -921,6 +1029,26 @@ func (m *matcher) setRangeHeaderPos(state *MatcherState, rng *ast.RangeStmt) {
 	state.partial.to = rng.Body.Pos() - 1
 }
 
+func unparen(n ast.Node) ast.Node {
+	for {
+		paren, ok := n.(*ast.ParenExpr)
+		if !ok {
+			return n
+		}
+		n = paren.X
+	}
+}
+
+// isMissingNode reports whether n is an absent optional node.
+// Function results are not an interface field, so they're a typed nil instead.
+func isMissingNode(n ast.Node) bool {
+	if n == nil {
+		return true
+	}
+	list, ok := n.(*ast.FieldList)
+	return ok && list == nil
+}
+
 func findNamed(capture []CapturedNode, name string) (ast.Node, bool) {
 	for _, c := range capture {
 		if c.Name == name {
@@ -969,9 +1097,21 @@ func equalNodes(x, y ast.Node) bool {
 	if x == nil || y == nil {
 		return x == y
 	}
+	if x, ok := x.(*OperatorNode); ok {
+		y, ok := y.(*OperatorNode)
+		return ok && x.Op == y.Op
+	}
 	if x, ok := x.(*NodeSlice); ok {
 		y, ok := y.(*NodeSlice)
-		if !ok || x.Kind != y.Kind || x.Len() != y.Len() {
+		if !ok || x.Len() != y.Len() {
+			return false
+		}
+		// A missing optional node is bound to an empty slice of its own kind,
+		// but all missing nodes are equal, like the nil nodes are.
+		if x.Len() == 0 {
+			return true
+		}
+		if x.Kind != y.Kind {
 			return false
 		}
 		switch x.Kind {
@@ -1,6 +1,8 @@
 package gogrep
 
 import (
+	"go/ast"
+	"go/parser"
 	"go/token"
 	"strings"
 	"testing"
@@ -199,3 +201,72 @@ func BenchmarkMatch(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkMatchFileMultiPattern(b *testing.B) {
+	patterns := []string{
+		`fmt.Println($*_)`,
+		`$x == $x`,
+		`if $cond { return $*_ }`,
+		`for $_, $_ := range $_ { $*_ }`,
+		`append($x, $*_)`,
+		`len($_) == 0`,
+	}
+
+	var src strings.Builder
+	src.WriteString("package p\n")
+	for i := 0; i < 50; i++ {
+		src.WriteString(`
+func f(xs []int) []int {
+	for _, x := range xs {
+		if x == x {
+			return nil
+		}
+		fmt.Println(x, len(xs) == 0)
+	}
+	return append(xs, 1, 2)
+}
+`)
+	}
+
+	compiled := make([]*Pattern, len(patterns))
+	for i, pat := range patterns {
+		p, _, err := Compile(CompileConfig{Fset: token.NewFileSet(), Src: pat})
+		if err != nil {
+			b.Fatalf("compile `%s`: %v", pat, err)
+		}
+		compiled[i] = p
+	}
+
+	parse := func(b *testing.B, fset *token.FileSet) *ast.File {
+		f, err := parser.ParseFile(fset, "p.go", src.String(), 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return f
+	}
+
+	// The file is parsed once and then all patterns are executed over it.
+	// This is how the gogrep CLI handles several patterns.
+	b.Run("parseOnce", func(b *testing.B) {
+		state := NewMatcherState()
+		for i := 0; i < b.N; i++ {
+			fset := token.NewFileSet()
+			f := parse(b, fset)
+			for _, p := range compiled {
+				p.MatchFile(&state, fset, f)
+			}
+		}
+	})
+
+	// A naive approach: the file is re-parsed for every pattern.
+	b.Run("parsePerPattern", func(b *testing.B) {
+		state := NewMatcherState()
+		for i := 0; i < b.N; i++ {
+			for _, p := range compiled {
+				fset := token.NewFileSet()
+				f := parse(b, fset)
+				p.MatchFile(&state, fset, f)
+			}
+		}
+	})
+}
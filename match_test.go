@@ -1,6 +1,7 @@
 package gogrep
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/importer"
@@ -9,7 +10,10 @@ import (
 	"go/types"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/quasilyte/gogrep/nodetag"
 	"golang.org/x/exp/typeparams"
 )
 
@@ -55,6 +59,10 @@ func TestMatchPresetCapture(t *testing.T) {
 			matches := 0
 			testAllMatches(pat, &state, target, func(m MatchData) {
 				matches++
+				// The walkers can skip the nodes of other kinds, see RootKind.
+				if kind := pat.RootKind(); kind != nodetag.Node && kind != nodetag.FromNode(m.Node) {
+					t.Errorf("test `%s`: %T match root for %v root kind", test.pat, m.Node, kind)
+				}
 			})
 			if matches != test.numMatches {
 				t.Fatalf("test `%s`:\ntarget: `%s`\nhave: %v\nwant: %v",
@@ -150,6 +158,67 @@ func TestMatchCapture(t *testing.T) {
 		{`import $i`, `package p; import ("fmt"; "strings")`, `i:"fmt"; "strings"`},
 		{`import $imports`, `package p; import ("fmt"; "strings")`, `imports:"fmt"; "strings"`},
 		{`import $imports`, `package p; import (crand "crypto/rand"; "strings")`, `imports:crand "crypto/rand"; "strings"`},
+		{`import $alias $path`, `package p; import crand "crypto/rand"`, `alias:crand, path:"crypto/rand"`},
+		{`func $_($*_, $last ...$T) { $*_ }`, `package p; func f(a int, xs ...[]byte) {}`, `last:xs, T:[]byte`},
+		{`func $_($*_, $last ...$T) { $*_ }`, `package p; func f(args ...interface{}) {}`, `last:args, T:interface{}`},
+		{`import $alias $path`, `package p; import _ "embed"`, `alias:_, path:"embed"`},
+		{`import $alias $path`, `package p; import . "fmt"`, `alias:., path:"fmt"`},
+		{`struct{ $*_; $name $T; $*_ }`, `package p; type T struct { mu sync.Mutex }`, `name:mu, T:sync.Mutex`},
+		{`struct{ $*_; $*names time.Time; $*_ }`, `package p; type T struct { x int; from, to time.Time }`, `names:from, to`},
+		{`map[$K]struct{ $_ $T }`, `package p; var m map[string]struct{ n int }`, `K:string, T:int`},
+		{`$_.(interface{ $m() $T })`, `package p; func _() { _ = x.(interface{ Close() error }) }`, `m:Close, T:error`},
+		{`$*x.Close()`, `package p; func _() { a.b.c.Close() }`, `x:a.b.c`},
+		{`$*x.Close()`, `package p; func _() { x.get().Close() }`, `x:x.get()`},
+		{`$*x.c.Close()`, `package p; func _() { a.b.c.Close() }`, `x:a.b`},
+		{`$*x.$m()`, `package p; func _() { a.b.c() }`, `m:c, x:a.b`},
+		{`$*x.Close()`, `package p; func _() { Close() }`, `x:`},
+
+		// Conditions are captured without the init statements.
+		{`if $cond { $*_ }`, `package p; func _() { if x > 0 {} }`, `cond:x > 0`},
+		{`if $*_; $cond { $*_ }`, `package p; func _() { if x > 0 {} }`, `cond:x > 0`},
+		{`if $*_; $cond { $*_ }`, `package p; func _() { if err := f(); err != nil {} }`, `cond:err != nil`},
+		{`if $*init; $cond { $*_ }`, `package p; func _() { if err := f(); err != nil {} }`, `init:err := f(), cond:err != nil`},
+		{`if $*init; $cond { $*_ }`, `package p; func _() { if ok {} }`, `init:, cond:ok`},
+		{`if $*init; $cond { $*_ } else { $*_ }`, `package p; func _() { if ok {} else {} }`, `init:, cond:ok`},
+		{`if $cond { $*_ } else $e`, `package p; func _() { if ok {} else { f() } }`, `cond:ok, e:{ f() }`},
+		{`if $cond { $*_ } else $e`, `package p; func _() { if ok {} else if x { f() } }`, `cond:ok, e:if x { f() }`},
+		{`if $*_; $cond { $*_ } else $e`, `package p; func _() { if err := f(); err != nil {} else { g() } }`, `cond:err != nil, e:{ g() }`},
+		{`if $cond { $*then } else if $cond2 { $*_ } else $e`, `package p; func _() { if a { f() } else if b {} else { g() } }`, `cond:a, then:f(), cond2:b, e:{ g() }`},
+		{`for $cond { $*_ }`, `package p; func _() { for i < n {} }`, `cond:i < n`},
+		{`for $*_; $cond; $*_ { $*_ }`, `package p; func _() { for i < n {} }`, `cond:i < n`},
+		{`for $*_; $cond; $*_ { $*_ }`, `package p; func _() { for i := 0; i < n; i++ {} }`, `cond:i < n`},
+		{`for $*init; $cond; $*post { $*_ }`, `package p; func _() { for i := 0; i < n; i++ {} }`, `init:i := 0, cond:i < n, post:i++`},
+		{`for $*init; $cond; $*post { $*_ }`, `package p; func _() { for i < n {} }`, `init:, cond:i < n, post:`},
+		{`switch $tag { $*_ }`, `package p; func _() { switch x.kind {} }`, `tag:x.kind`},
+		{`switch $*_; $tag { $*_ }`, `package p; func _() { switch k := f(); k {} }`, `tag:k`},
+		{`switch $*init; $tag { $*_ }`, `package p; func _() { switch k {} }`, `init:, tag:k`},
+
+		// Function signature parts and the body statements.
+		{
+			`func $name($*params) $results { $*body }`,
+			`package p; func f(a int, b string) (int, error) { x := a; return x, nil }`,
+			`name:f, params:(a int, b string), results:(int, error), body:x := a; return x, nil`,
+		},
+		{
+			`func $name($*params) $results { $*body }`,
+			`package p; func f() error { return nil }`,
+			`name:f, params:(), results:error, body:return nil`,
+		},
+		{
+			`func $name($*params) $results { $*body }`,
+			`package p; func f(x int) { println(x) }`,
+			`name:f, params:(x int), results:, body:println(x)`,
+		},
+		{
+			`func $name($*params) $*results { $*body }`,
+			`package p; func f() {}`,
+			`name:f, params:(), results:, body:`,
+		},
+		{
+			`func ($*recv) $name($*params) $*results { $*body }`,
+			`package p; func (t *T) m() {}`,
+			`recv:t *T, name:m, params:(), results:, body:`,
+		},
 
 		{
 			`range $x`,
@@ -162,6 +231,90 @@ func TestMatchCapture(t *testing.T) {
 			`package p; func _() { for range data[0] {} }`,
 			`x:data[0]`,
 		},
+
+		{
+			`for $k, $v := range $x { $*_ }`,
+			`package p; func _() { for i, elem := range xs[1:] {} }`,
+			`k:i, v:elem, x:xs[1:]`,
+		},
+		{
+			`for $k := range $x { $*_ }`,
+			`package p; func _() { for i := range 10 {} }`,
+			`k:i, x:10`,
+		},
+		{
+			`for _, $v = range $x { $*_ }`,
+			`package p; func _() { for _, v = range seq {} }`,
+			`v:v, x:seq`,
+		},
+
+		{
+			`$x $op $y`,
+			`package p; func _() { _ = a <= b }`,
+			`x:a, op:<=, y:b`,
+		},
+		{
+			`$x $op $y`,
+			`package p; func _() { _ = a &^ f(b) }`,
+			`x:a, op:&^, y:f(b)`,
+		},
+		{
+			`$x $op $y`,
+			`package p; func _() { _ = x && !y }`,
+			`x:x, op:&&, y:!y`,
+		},
+		{
+			`$x $op $y $op2 $z`,
+			`package p; func _() { _ = a + b == c }`,
+			`x:a, op:+, y:b, op2:==, z:c`,
+		},
+		{
+			`($op $x)`,
+			`package p; func _() { _ = !ok }`,
+			`op:!, x:ok`,
+		},
+		{
+			`$x $T`,
+			`package p; var (aa int)`,
+			`x:aa, T:int`,
+		},
+		{
+			`$x $op1 $op2 $y`,
+			`package p; func _() { _ = a - &b }`,
+			`x:a, op1:-, op2:&, y:b`,
+		},
+
+		{
+			`map[$K]$V`,
+			`package p; type T struct { fields map[string][]int }`,
+			`K:string, V:[]int`,
+		},
+		{
+			`[$n]$T`,
+			`package p; var buf [size * 2]byte`,
+			`n:size * 2, T:byte`,
+		},
+		{
+			`[]$T`,
+			`package p; func f(xs []*bytes.Buffer) {}`,
+			`T:*bytes.Buffer`,
+		},
+		{
+			`*$T`,
+			`package p; type T struct { mu *sync.Mutex }`,
+			`T:sync.Mutex`,
+		},
+
+		{
+			`$x := $y`,
+			`package p; func _() { 名前 := "値" }`,
+			`x:名前, y:"値"`,
+		},
+		{
+			`$f($*args)`,
+			`package p; func _() { перевести(строка, 'ё') }`,
+			`f:перевести, args:строка, 'ё'`,
+		},
 	}
 
 	for i := range tests {
@@ -178,6 +331,10 @@ func TestMatchCapture(t *testing.T) {
 			var capture []string
 			testAllMatches(pat, &state, target, func(m MatchData) {
 				for _, c := range m.Capture {
+					if IsEmptyNodeSlice(c.Node) {
+						capture = append(capture, c.Name+":")
+						continue
+					}
 					from := fset.Position(c.Node.Pos()).Offset
 					to := fset.Position(c.Node.End()).Offset
 					capture = append(capture, c.Name+":"+test.input[from:to])
@@ -193,6 +350,318 @@ func TestMatchCapture(t *testing.T) {
 	}
 }
 
+func TestMatchFile(t *testing.T) {
+	tests := []struct {
+		pat     string
+		matches []string
+	}{
+		{`f($*args)`, []string{
+			`file.go:4:2 file.go:4:9 f(1, 2) args=file.go:4:4-file.go:4:8`,
+			`file.go:5:2 file.go:5:5 f() args=-`,
+			`file.go:6:6 file.go:6:10 f(x) args=file.go:6:8-file.go:6:9`,
+			`file.go:8:2 file.go:8:6 f(x) args=file.go:8:4-file.go:8:5`,
+		}},
+		{`println($x); f($x)`, []string{
+			`file.go:7:2 file.go:8:6 println(x)\n\tf(x) x=file.go:7:10-file.go:7:11`,
+		}},
+		{`range $x`, []string{
+			`file.go:9:6 file.go:9:14 range xs x=file.go:9:12-file.go:9:14`,
+		}},
+	}
+
+	fileSrc := `package example
+
+func _() {
+	f(1, 2)
+	f()
+	_ = f(x)
+	println(x)
+	f(x)
+	for range xs {}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			state := NewMatcherState()
+			config := CompileConfig{Fset: token.NewFileSet(), Src: test.pat}
+			pat, _, err := Compile(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Formatting is performed after all matches are collected
+			// to make sure that the results don't share the state memory.
+			var have []string
+			for _, m := range pat.MatchFile(&state, fset, f) {
+				text := fileSrc[m.Start.Offset:m.End.Offset]
+				parts := []string{m.Start.String(), m.End.String(), strings.ReplaceAll(text, "\n\t", `\n\t`)}
+				for j, c := range m.Data.Capture {
+					pos := "-"
+					if m.CapturePos[j].Start.IsValid() {
+						pos = m.CapturePos[j].Start.String() + "-" + m.CapturePos[j].End.String()
+					}
+					parts = append(parts, c.Name+"="+pos)
+				}
+				have = append(have, strings.Join(parts, " "))
+			}
+			if diff := cmp.Diff(have, test.matches); diff != "" {
+				t.Errorf("pattern `%s` (+want -have):\n%s", test.pat, diff)
+			}
+		})
+	}
+}
+
+func TestMatchNodeCtx(t *testing.T) {
+	// The block pattern has a lot of restarts to try before it fails,
+	// it takes seconds to match without an interruption.
+	src := "package p\nfunc _() {\n" + strings.Repeat("\tf(1)\n", 200) + "}\n"
+	f, err := parser.ParseFile(token.NewFileSet(), "file.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := f.Decls[0].(*ast.FuncDecl).Body
+
+	compile := func(src string) *Pattern {
+		pat, _, err := Compile(CompileConfig{Fset: token.NewFileSet(), Src: src})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pat
+	}
+	slowPat := compile(`$*_; $*_; $*_; f(1); $*_; g()`)
+	callPat := compile(`f($x)`)
+	pairPat := compile(`f($x); f($y)`)
+
+	// checkReusable makes sure that the interrupted state works for the next matches.
+	checkReusable := func(t *testing.T, state *MatcherState) {
+		var have []string
+		callPat.MatchNode(state, body.List[0].(*ast.ExprStmt).X, func(data MatchData) {
+			for _, c := range data.Capture {
+				have = append(have, c.Name+"="+types.ExprString(c.Node.(ast.Expr)))
+			}
+		})
+		if len(have) != 1 || have[0] != "x=1" {
+			t.Fatalf("match after interruption: have %q, want x=1", have)
+		}
+		numMatches := 0
+		err := pairPat.MatchNodeCtx(context.Background(), state, body, func(MatchData) {
+			numMatches++
+		})
+		if err != nil || numMatches != 100 {
+			t.Fatalf("match after interruption: have %d matches (err=%v), want 100", numMatches, err)
+		}
+	}
+
+	t.Run("deadline", func(t *testing.T) {
+		state := NewMatcherState()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err := slowPat.MatchNodeCtx(ctx, &state, body, func(MatchData) {
+			t.Fatal("unexpected match")
+		})
+		if err != context.DeadlineExceeded {
+			t.Fatalf("have %v error, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("the match is interrupted after %s", elapsed)
+		}
+		checkReusable(t, &state)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		state := NewMatcherState()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := callPat.MatchNodeCtx(ctx, &state, body.List[0], func(MatchData) {
+			t.Fatal("unexpected match")
+		})
+		if err != context.Canceled {
+			t.Fatalf("have %v error, want %v", err, context.Canceled)
+		}
+		checkReusable(t, &state)
+	})
+
+	t.Run("canceledByCallback", func(t *testing.T) {
+		state := NewMatcherState()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		numMatches := 0
+		err := pairPat.MatchNodeCtx(ctx, &state, body, func(MatchData) {
+			numMatches++
+			cancel()
+		})
+		if err != context.Canceled || numMatches != 1 {
+			t.Fatalf("have %d matches (err=%v), want 1 match and %v error", numMatches, err, context.Canceled)
+		}
+		checkReusable(t, &state)
+	})
+}
+
+func TestMatchIgnoreParens(t *testing.T) {
+	tests := []struct {
+		pat        string
+		numMatches int
+		input      string
+	}{
+		{`len(($x))`, 1, `len((s))`},
+		{`len(($x))`, 1, `len(s)`},
+		{`len($x)`, 1, `len((s))`},
+		{`$x + $y`, 1, `(a + b)`},
+		{`$x + $y`, 1, `(((a + b)))`},
+		{`((($x + $y)))`, 1, `a + b`},
+		{`f(($x))`, 1, `f(((1)))`},
+		{`return ($x)`, 1, `return x`},
+		{`return $x`, 1, `return ((x))`},
+		{`f($x * c)`, 1, `f((a + b) * c)`},
+		{`f($x * c)`, 0, `f(a + b * c)`},
+		{`$x $op $y * $z`, 1, `(a + b) * c`},
+		{`$x $op $y * $z`, 0, `a + b*c`},
+		{`($x $op $y) * $z`, 1, `(a + b) * c`},
+		{`(a + b) * c`, 0, `a + b*c`},
+		{`a + (b * c)`, 1, `a + b*c`},
+		{`f($x, $x)`, 1, `f((a), a)`},
+		{`(*T)($x)`, 1, `(*T)(nil)`},
+		{`*T($x)`, 0, `(*T)(nil)`},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			state := NewMatcherState()
+			config := CompileConfig{
+				Fset:         token.NewFileSet(),
+				Src:          test.pat,
+				IgnoreParens: true,
+			}
+			pat, _, err := Compile(config)
+			if err != nil {
+				t.Fatalf("compile `%s`: %v", test.pat, err)
+			}
+			target := testParseNode(t, token.NewFileSet(), test.input)
+			matches := 0
+			testAllMatches(pat, &state, target, func(m MatchData) {
+				matches++
+			})
+			if matches != test.numMatches {
+				t.Fatalf("test `%s`:\ntarget: `%s`\nhave: %v\nwant: %v",
+					test.pat, test.input, matches, test.numMatches)
+			}
+		})
+	}
+}
+
+func TestMatchVarPrefix(t *testing.T) {
+	tests := []struct {
+		prefix     rune
+		pat        string
+		numMatches int
+		input      string
+	}{
+		{'@', `@x + @y`, 1, `a + b`},
+		{'@', `f(@*_)`, 1, `f(1, 2)`},
+		{'@', `@x = @x`, 1, `a = a`},
+		{'@', `@x = @x`, 0, `a = b`},
+		{'@', `fmt.Sprintf("@x", @*_)`, 1, `fmt.Sprintf("@x", 1)`},
+		{'@', `fmt.Sprintf("@x", @*_)`, 0, `fmt.Sprintf("%v", 1)`},
+		{'@', `f('@') /* @x */`, 1, `f('@')`},
+		{'#', `len(#s) == 0`, 1, `len(b) == 0`},
+		{'^', `^f(^*_) ^ 1`, 1, `g(x) ^ 1`},
+		{'^', `^x ^^y`, 1, `a ^ y`},
+		{'^', `^x ^^y`, 0, `a ^ b`},
+		{'%', `%x % %y`, 1, `a % b`},
+		{'%', `%x %%y`, 1, `a % y`},
+		{'%', `%x %= 2`, 1, `a %= 2`},
+		{'?', `?x.?y`, 1, `a.b`},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			state := NewMatcherState()
+			config := CompileConfig{
+				Fset:      token.NewFileSet(),
+				Src:       test.pat,
+				VarPrefix: test.prefix,
+			}
+			pat, _, err := Compile(config)
+			if err != nil {
+				t.Fatalf("compile `%s`: %v", test.pat, err)
+			}
+			target := testParseNode(t, token.NewFileSet(), test.input)
+			matches := 0
+			testAllMatches(pat, &state, target, func(m MatchData) {
+				matches++
+			})
+			if matches != test.numMatches {
+				t.Fatalf("test `%s` with %c prefix:\ntarget: `%s`\nhave: %v\nwant: %v",
+					test.pat, test.prefix, test.input, matches, test.numMatches)
+			}
+		})
+	}
+}
+
+func TestMatchTopLevelDecls(t *testing.T) {
+	tests := []struct {
+		pat        string
+		numMatches int
+		input      string
+	}{
+		{`const ($*_); var ($*_)`, 1, `package p; const (a = 1); var (b = 2)`},
+		{`const ($*_); var ($*_)`, 1, `package p; const a = 1; var b = 2`},
+		{`const ($*_); var ($*_)`, 0, `package p; var b = 2; const a = 1`},
+		{`const ($*_); var ($*_)`, 0, `package p; const a = 1; type T int; var b = 2`},
+		{`const ($*_); var ($*_)`, 0, `package p; func f() { const a = 1; var b = 2 }`},
+		{`const ($*_); $*_; var ($*_)`, 1, `package p; const a = 1; type T int; var b = 2`},
+		{`const ($*_); $*_; var ($*_)`, 1, `package p; const a = 1; var b = 2`},
+		{`const $_ = $_`, 1, `package p; const a = 1`},
+		{`const $_ = $_`, 0, `package p; func f() { const a = 1 }`},
+
+		{`type $T struct{$*_}; func $_() *$T { $*_ }`, 1, `package p; type T struct{}; func NewT() *T { return nil }`},
+		{`type $T struct{$*_}; func $_() *$T { $*_ }`, 0, `package p; type T struct{}; func NewT() *U { return nil }`},
+		{`type $T struct{$*_}; func $_() *$T { $*_ }`, 0, `package p; type T struct{}; func f() {}; func NewT() *T { return nil }`},
+		{`type $T struct{$*_}; $*_; func $_() *$T { $*_ }`, 1, `package p; type T struct{}; func f() {}; func NewT() *T { return nil }`},
+		{`type $T struct{$*_}; $*_; func $_() *$T { $*_ }`, 2, `package p; type T struct{}; func NewT() *T { return nil }; type U struct{}; func NewU() *U { return nil }`},
+
+		{`$_; func f() {}`, 1, `package p; type T int; func f() {}`},
+		{`$_; func f() {}`, 0, `package p; func f() {}`},
+		{`$x; $x`, 1, `package p; var a int; var a int`},
+		{`$x; $x`, 0, `package p; var a int; var b int`},
+		{`$*_; func f() {}`, 1, `package p; func f() {}`},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(fmt.Sprintf("test%d", i), func(t *testing.T) {
+			state := NewMatcherState()
+			config := CompileConfig{
+				Fset:          token.NewFileSet(),
+				Src:           test.pat,
+				TopLevelDecls: true,
+			}
+			pat, _, err := Compile(config)
+			if err != nil {
+				t.Fatalf("compile `%s`: %v", test.pat, err)
+			}
+			target := testParseNode(t, token.NewFileSet(), test.input)
+			matches := 0
+			testAllMatches(pat, &state, target, func(m MatchData) {
+				matches++
+			})
+			if matches != test.numMatches {
+				t.Fatalf("test `%s`:\ntarget: `%s`\nhave: %v\nwant: %v",
+					test.pat, test.input, matches, test.numMatches)
+			}
+		})
+	}
+}
+
 func TestMatchWithTypes(t *testing.T) {
 	tests := []struct {
 		pat        string
@@ -295,6 +764,106 @@ func _() {
 	}
 }
 
+func TestConstValues(t *testing.T) {
+	fileSrc := `package example
+
+const base = 100
+
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusFailed
+	_
+	StatusUnknown
+)
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+	GB
+)
+
+const (
+	A = iota * 10
+	B
+	C = 7
+	D
+	E = iota + C
+	F
+)
+
+const (
+	Max8 = ^uint8(0)
+	Next = base + 1
+	Name = "a" + "b"
+	Half = 5 / 2
+	Flag = Half > 1 && !false
+)
+
+const x, y = 1, x + 1
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+	}
+	typechecker := &types.Config{}
+	if _, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		withTypes string
+		noTypes   string
+	}{
+		{`base=100`, `base=100`},
+		{`StatusOK=0 StatusFailed=1 _=2 StatusUnknown=3`, `StatusOK=0 StatusFailed=1 _=2 StatusUnknown=3`},
+		{`KB=1024 MB=1048576 GB=1073741824`, `KB=1024 MB=1048576 GB=1073741824`},
+		{`A=0 B=10 C=7 D=7 E=11 F=12`, `A=0 B=10 C=7 D=7 E=11 F=12`},
+		// The other decls constants and the typed overflows are only known with types.
+		{`Max8=255 Next=101 Name="ab" Half=2 Flag=true`, `Max8=? Next=? Name="ab" Half=2 Flag=true`},
+		{`x=1 y=2`, `x=1 y=2`},
+	}
+
+	var decls []*ast.GenDecl
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.CONST {
+			decls = append(decls, decl)
+		}
+	}
+	if len(decls) != len(tests) {
+		t.Fatalf("expected %d const decls, found %d", len(tests), len(decls))
+	}
+	format := func(values []ConstValue) string {
+		parts := make([]string, len(values))
+		for i, c := range values {
+			v := "?"
+			if c.Value != nil {
+				v = c.Value.ExactString()
+			}
+			parts[i] = c.Name.Name + "=" + v
+		}
+		return strings.Join(parts, " ")
+	}
+	for i, test := range tests {
+		if have := format(ConstValues(typesInfo, decls[i])); have != test.withTypes {
+			t.Errorf("decl %d with types:\nhave: %s\nwant: %s", i, have, test.withTypes)
+		}
+		if have := format(ConstValues(nil, decls[i])); have != test.noTypes {
+			t.Errorf("decl %d without types:\nhave: %s\nwant: %s", i, have, test.noTypes)
+		}
+	}
+	for _, decl := range f.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok != token.CONST && ConstValues(nil, decl) != nil {
+			t.Errorf("%s decl: expected no const values", decl.Tok)
+		}
+	}
+}
+
 func TestMatch(t *testing.T) {
 	strict := func(s string) string {
 		return "STRICT " + s
@@ -335,6 +904,28 @@ func TestMatch(t *testing.T) {
 		{`;`, 1, `;`},
 		{`;`, 0, `1`},
 
+		// Empty blocks only match the blocks without statements;
+		// an explicit empty statement is a statement too.
+		{`if $cond {}`, 1, `if x {}`},
+		{`if $cond {}`, 1, "if x {\n}"},
+		{`if $cond {}`, 0, `if x { f() }`},
+		{`if $cond {}`, 0, `if x { ; }`},
+		{`if $cond { ; }`, 1, `if x { ; }`},
+		{`if $cond { $*_ } else $_`, 1, `if x {} else {}`},
+		{`if $cond { $*_ } else $_`, 1, `if x {} else if y {}`},
+		{`if $cond { $*_ } else $_`, 0, `if x {}`},
+		{`if $cond { $*_ } else $x`, 2, `if x {} else if y {} else {}`},
+		{`if $cond { $*_ } else if $_ { $*_ } else $_`, 1, `if x {} else if y {} else {}`},
+		{`if $cond { $*_ } else if $_ { $*_ } else $_`, 0, `if x {} else if y {}`},
+		{`if $cond {} else { $*_ }`, 1, `if x {} else { f() }`},
+		{`for {}`, 1, `for {}`},
+		{`for {}`, 0, `for { f() }`},
+		{`for $_, $_ := range $_ {}`, 1, `for k, v := range m {}`},
+		{`func $_() {}`, 1, `package p; func f() {}`},
+		{`func $_() {}`, 0, `package p; func f() { return }`},
+		{`func($*_) {}`, 1, `func(x int) {}`},
+		{`func($*_) {}`, 0, `func(x int) { f(x) }`},
+
 		// In strict mode, differently spelled literals won't match.
 		{strict(`"a"`), 1, `"a"`},
 		{strict("`a`"), 1, "`a`"},
@@ -383,6 +974,9 @@ func TestMatch(t *testing.T) {
 		// Forcing node to be a statement.
 		{`append($*_);`, 1, `{ f(); append(x, a) }`},
 		{`append($*_);`, 0, `{ f(); x = append(x, a) }`},
+		{`$f($*_);`, 2, `{ f(); g(h()) }`},
+		{`$f($*_);`, 0, `{ x := f(); _ = g(); defer h(); go h() }`},
+		{`$f($*_);`, 0, `{ if f() { return g() } }`},
 
 		// Call expr.
 		{`f(1, 2, "foo")`, 1, `f(1, 2, "foo")`},
@@ -390,6 +984,12 @@ func TestMatch(t *testing.T) {
 		{`f(1, 2, "foo")`, 0, `f(1, 2, "bar")`},
 		{`f(1, 2, "foo")`, 0, `f(1, 2)`},
 		{`f(1, 2)`, 0, `f(1, 2, "foo")`},
+		{`$T($T($x))`, 1, `[]byte([]byte(s))`},
+		{`$T($T($x))`, 1, `[]byte([] byte(s))`},
+		{`$T($T($x))`, 1, `(*T)((*T)(p))`},
+		{`$T($T($x))`, 1, `T(T(x))`},
+		{`$T($T($x))`, 0, `string([]byte(s))`},
+		{`$T($T($x))`, 0, `T(T(x), y)`},
 		{`print($*x)`, 1, `print()`},
 		{`print($*x)`, 1, `print(a, b)`},
 		{`print($*_)`, 1, `print()`},
@@ -434,6 +1034,13 @@ func TestMatch(t *testing.T) {
 		{`fmt.Sprintf($_, $args...)`, 0, `fmt.Sprintf(f)`},
 		{`fmt.Sprintf($_, $args...)`, 0, `fmt.Sprintf(f, a, b)`},
 		{`fmt.Sprintf($_, $args)`, 0, `fmt.Sprintf(f, a...)`},
+		{`append($_, $_...)`, 1, `append(s, xs...)`},
+		{`append($_, $_...)`, 0, `append(s, xs)`},
+		{`append($_, $_...)`, 0, `append(s, 1, 2)`},
+		{`append($_, $_)`, 0, `append(s, xs...)`},
+		{`append($_, $_, $_)`, 0, `append(s, x, xs...)`},
+		{`append($_, $*_, $_...)`, 1, `append(s, x, xs...)`},
+		{`append($_, $*_, $_...)`, 0, `append(s, x, y)`},
 		{`$fmt.$_($*_)`, 1, `fmt.Sprintf("%d", 1)`},
 
 		// OK: trailing $*_ can match variadic calls.
@@ -459,6 +1066,24 @@ func TestMatch(t *testing.T) {
 		{`$x.c`, 1, `a.b.c`},
 		{`a.$x`, 1, `a.b.c`},
 
+		// Selector chain wildcard.
+		{`$*_.Close()`, 1, `f.Close()`},
+		{`$*_.Close()`, 1, `a.b.c.Close()`},
+		{`$*_.Close()`, 1, `x.get().files[0].Close()`},
+		{`$*_.Close()`, 1, `Close()`},
+		{`$*_.Close()`, 0, `a.Close`},
+		{`$*_.Close()`, 0, `a.Close.x()`},
+		{`$*_.Close()`, 0, `a.b.Open()`},
+		{`$*_.Close()`, 2, `{ f.Close(); g().Close() }`},
+		{`$*_.$_()`, 1, `a.b.c()`},
+		{`$*_.b.c`, 1, `a.b.c`},
+		{`$*_.b.c`, 1, `b.c`},
+		{`$*_.b.c`, 0, `a.c`},
+		{`$*x.Close(); $*x.Open()`, 1, `{ a.b.Close(); a.b.Open() }`},
+		{`$*x.Close(); $*x.Open()`, 1, `{ Close(); Open() }`},
+		{`$*x.Close(); $*x.Open()`, 0, `{ a.b.Close(); a.Open() }`},
+		{`$*x.Close(); $*x.Open()`, 0, `{ a.Close(); Open() }`},
+
 		// Index expr.
 		{`$x[0][1]`, 1, `x[0][1]`},
 		{`$x[0][1]`, 1, `x[10][0][1]`},
@@ -535,6 +1160,33 @@ func TestMatch(t *testing.T) {
 		{`someStruct{fld: $x}`, 0, `someStruct{fld: a, fld2: b}`},
 		{`map[int]int{1: $x}`, 1, `map[int]int{1: a}`},
 		{`map[int]int{1: $x}`, 0, `map[int]byte{1: a}`},
+		{`config.Options{$*_}`, 1, `config.Options{}`},
+		{`config.Options{$*_}`, 1, `config.Options{Debug: true, Level: 2}`},
+		{`config.Options{$*_}`, 0, `other.Options{Debug: true}`},
+		{`config.Options{$*_}`, 0, `{Debug: true}`},
+		{`&pkg.Thing{$*_}`, 1, `&pkg.Thing{}`},
+		{`&pkg.Thing{$*_}`, 1, `x := &pkg.Thing{Name: "x"}`},
+		{`&pkg.Thing{$*_}`, 0, `pkg.Thing{Name: "x"}`},
+		{`&pkg.Thing{$*_}`, 0, `x & pkg.Thing`},
+		{`&T{$*_}`, 1, `&T{1, 2}`},
+		{`&T{}`, 1, `&T{}`},
+		{`&T{}`, 0, `&T{1}`},
+		{`&$T{$*_}`, 1, `&pkg.Thing{Name: "x"}`},
+		{`&$T{$*_}`, 0, `&x`},
+		{`$T{$*_}`, 2, `[]*pkg.Thing{&pkg.Thing{}}`},
+		{`$T{$*_}`, 1, `[]pkg.Thing{{}}`},
+		{`f(&$T{}); g(&$T{})`, 1, `{ f(&T{}); g(&T{}) }`},
+		{`f(&$T{}); g(&$T{})`, 0, `{ f(&T{}); g(&U{}) }`},
+		{`&$T{}`, 1, `&pkg.T{}`},
+		{`&$T{}`, 1, `&[]int{}`},
+		{`&$T{}`, 0, `&T{x: 1}`},
+		{`&$T{}`, 0, `T{}`},
+		{`new($T)`, 1, `new(T)`},
+		{`new($T)`, 1, `new(pkg.T)`},
+		{`new($T)`, 1, `new([]int)`},
+		{`new($T)`, 1, `new(struct{})`},
+		{`new($T)`, 0, `new()`},
+		{`new($T)`, 0, `make(T)`},
 
 		// Type assert.
 		{`$x.([]string)`, 1, `a.([]string)`},
@@ -546,6 +1198,48 @@ func TestMatch(t *testing.T) {
 
 		// Type expr.
 		{`[8]$x`, 1, `[8]int{4: 1}`},
+		{`[]$T`, 1, `var x []int`},
+		{`[]$T`, 2, `var x [][]string`},
+		{`[]$T`, 0, `var x [4]int`},
+		{`[]$T`, 0, `var x map[string]int`},
+		{`[$n]$T`, 1, `var x [4]int`},
+		{`[$n]$T`, 1, `var x [N * 2]T`},
+		{`[$n]$T`, 1, `x := [...]int{1, 2}`},
+		{`[$n]$T`, 0, `var x []int`},
+		{`[4]$T`, 0, `var x [5]int`},
+		{`[$n]$n`, 0, `var x [4]int`},
+		{`map[$K]$V`, 1, `var x map[string]int`},
+		{`map[$K]$V`, 1, `var x map[int][]string`},
+		{`map[$K]$V`, 0, `var x []int`},
+		{`map[$T]$T`, 1, `var x map[int]int`},
+		{`map[$T]$T`, 0, `var x map[int]string`},
+		{`map[$_]interface{}`, 1, `type T struct { m map[string]interface{} }`},
+		{`map[$_]interface{}`, 1, `var x map[int]interface{}`},
+		{`map[$_]interface{}`, 0, `var x map[string]interface{ String() string }`},
+		{`map[$_]interface{}`, 1, `var x map[string]any`},
+		{`map[$_]struct{$*_}`, 1, `var x map[string]struct{ a, b int }`},
+		{`map[$_]struct{$*_}`, 1, `var x map[string]struct{}`},
+		{`map[$_]struct{$*_}`, 0, `var x map[string]T`},
+		{`map[$_]struct{$_; $*_}`, 0, `var x map[string]struct{}`},
+		{`map[$_]struct{$_; $*_}`, 1, `x := map[int]struct{ name string }{}`},
+		{`struct{$*_}`, 1, `var f func(opts struct{ debug bool })`},
+		{`struct{$*_}`, 1, `var v struct{ A int; B string }`},
+		{`interface{$*_}`, 1, `_ = x.(interface{ Close() error })`},
+		{`interface{$*_}`, 1, `var f func(x interface{ M() })`},
+		{`interface{$*_}`, 1, `var x interface{}`},
+		{`interface{$_() error}`, 1, `_ = x.(interface{ Close() error })`},
+		{`interface{$_() error}`, 0, `_ = x.(interface{ Close() })`},
+		{`$_.(interface{$*_})`, 1, `_ = x.(interface{ Close() error })`},
+		{`$_.(interface{$*_})`, 0, `_ = x.(io.Closer)`},
+		{`interface{}`, 1, `var f func(x interface{})`},
+		{`interface{}`, 1, `var f func(x any)`},
+		{`interface{}`, 0, `var f func(x interface{ M() })`},
+		{`*$T`, 1, `var x *int`},
+		{`*$T`, 1, `var f func(x *bytes.Buffer)`},
+		{`*$T`, 0, `var x []int`},
+		{`[]*$T`, 1, `var x []*T`},
+		{`[]*$T`, 0, `var x []T`},
+		{`struct{ $*_; $_ map[$_]interface{}; $*_ }`, 1, `type T struct { a int; b map[string]interface{} }`},
 		{`struct{}`, 1, `type _ struct{}`},
 		{`struct{}`, 1, `struct{}{}`},
 		{`struct{}`, 0, `type _ struct{x int}`},
@@ -574,6 +1268,20 @@ func TestMatch(t *testing.T) {
 		{`struct{$_, $_ $_}`, 1, `struct{x, y int}{}`},
 		{`struct{$_, $_ $_}`, 0, `struct{x int}{}`},
 		{`struct{$_, $_ $_}`, 0, `struct{x int; y int}{}`},
+		{`var ($*_)`, 1, `var (a = 1; b int)`},
+		{`var ($*_)`, 1, `var ()`},
+		{`var ($*_)`, 0, `const a = 1`},
+		{`struct{ $*_; $_ sync.Mutex; $*_ }`, 1, `struct{ x int; mu sync.Mutex }{}`},
+		{`struct{ $*_; $_ sync.Mutex; $*_ }`, 0, `struct{ a, mu sync.Mutex }{}`},
+		{`struct{ $*_; $_ sync.Mutex; $*_ }`, 0, `struct{ sync.Mutex }{}`},
+		{`struct{ $*_; $*_ sync.Mutex; $*_ }`, 1, `struct{ x int; mu sync.Mutex }{}`},
+		{`struct{ $*_; $*_ sync.Mutex; $*_ }`, 1, `struct{ a, mu sync.Mutex }{}`},
+		{`struct{ $*_; $*_ sync.Mutex; $*_ }`, 1, `struct{ sync.Mutex }{}`},
+		{`struct{ $*_; $*_ sync.Mutex; $*_ }`, 0, `struct{ x int; mu *sync.Mutex }{}`},
+		{`struct{ $*_ $_ }`, 1, `struct{ x, y int }{}`},
+		{`struct{ $*_ $_ }`, 0, `struct{ x int; y int }{}`},
+		{`struct{ $*x int; $*x string }`, 1, `struct{ a, b int; a, b string }{}`},
+		{`struct{ $*x int; $*x string }`, 0, `struct{ a, b int; a string }{}`},
 		{`var x struct{$x}; var y $x`, 1, `{ var x struct{io.Reader}; var y io.Reader }`},
 		{`var x struct{$_ $x}; var y $x`, 1, `{ var x struct{r io.Reader}; var y io.Reader }`},
 		{`var x struct{$x}; var y $x`, 0, `{ var x struct{io.Writer}; var y io.Reader }`},
@@ -657,6 +1365,27 @@ func TestMatch(t *testing.T) {
 		{`func(x ...int) {}`, 0, `func(x ...string) {}`},
 		{`func($x ...$t) {}`, 1, `func(a ...int) {}`},
 
+		// Variadic params.
+		// $*_ can be mixed with the named params, it matches any number of params.
+		{`func $_($*_, $last ...$T) { $*_ }`, 1, `package p; func f(a int, xs ...string) {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 1, `package p; func f(xs ...string) {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 1, `package p; func f(a, b int, xs ...string) {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 0, `package p; func f(a int, xs []string) {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 0, `package p; func f(a, b int) {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 0, `package p; func f() {}`},
+		{`func $_($*_, $last ...$T) { $*_ }`, 0, `package p; func f(xs ...string) error { return nil }`},
+		{`func $_($*_, $last ...$T) $*_ { $*_ }`, 1, `package p; func f(xs ...string) error { return nil }`},
+		{`func $_($*_, $last ...$T) $*_ { $*_ }`, 1, `package p; func f(xs ...string) {}`},
+		{`func $_($*_, $_ ...interface{}) { $*_ }`, 1, `package p; func f(format string, args ...interface{}) {}`},
+		{`func $_($*_, $_ ...interface{}) { $*_ }`, 0, `package p; func f(format string, args ...string) {}`},
+		{`func $_($*_, $_ ...interface{}) { $*_ }`, 0, `package p; func f(format string, args []interface{}) {}`},
+		{`func ($_ $_) $_($*_, $_ ...$_) { $*_ }`, 1, `package p; func (t *T) f(format string, args ...int) {}`},
+		{`func ($_ $_) $_($*_, $_ ...$_) { $*_ }`, 0, `package p; func f(format string, args ...int) {}`},
+		{`func $_($*_, ...$T) { $*_ }`, 1, `package p; func f(int, ...string) {}`},
+		{`func $_($*_, ...$T) { $*_ }`, 0, `package p; func f(int, []string) {}`},
+		{`func($*_, $x int, $*_) {}`, 1, `func(a, b string, c int, d bool) {}`},
+		{`func($*_, $x int, $*_) {}`, 0, `func(a string, c float64) {}`},
+
 		// Func lit - non-strict mode.
 		// TODO: reject these in strict mode.
 		{`func () (int) {}`, 1, `func () int {}`},
@@ -733,6 +1462,14 @@ func TestMatch(t *testing.T) {
 		{`if $_; cond {}`, 1, `if init; cond {}`},
 		{`if $x; cond {}`, 0, `if cond {}`},
 		{`if $x; cond {}`, 1, `if init; cond {}`},
+		// A missing optional node is equal to any other empty capture.
+		{`if $*x; cond { $*x }`, 1, `if cond {}`},
+		{`if $*x; cond { $*x }`, 0, `if cond { f() }`},
+		{`if $*x; cond { $*x }`, 0, `if init; cond {}`},
+		{`for $*x; cond; $*x { $*x }`, 1, `for cond {}`},
+		{`for $*x; cond; $*x { $*x }`, 0, `for ; cond; i++ {}`},
+		{`switch $*x; $*x { $*x }`, 1, `switch {}`},
+		{`if $*x; cond { return $*x }`, 1, `if cond { return }`},
 		{`if $x {} else if $x {}`, 1, `if cond {} else if cond {}`},
 		{`if $x {} else if $x {}`, 0, `if cond {} else if cond2 {}`},
 		{`if $x {} else if $x {}`, 0, `if cond {} else {}`},
@@ -755,6 +1492,10 @@ func TestMatch(t *testing.T) {
 		{`if $*_; cond {}`, 1, `if init; cond {}`},
 		{`if $*x; cond {}`, 1, `if cond {}`},
 		{`if $*x; cond {}`, 1, `if init; cond {}`},
+		{`if $*x; a {}; if $*x; b {}`, 1, `{ if a {}; if b {} }`},
+		{`if $*x; a {}; if $*x; b {}`, 1, `{ if f(); a {}; if f(); b {} }`},
+		{`if $*x; a {}; if $*x; b {}`, 0, `{ if f(); a {}; if b {} }`},
+		{`if $*x; a {}; if $*x; b {}`, 0, `{ if a {}; if f(); b {} }`},
 		{`if $*_ {}`, 1, `if cond {}`},
 		{`if $*_ {}`, 1, `if init; cond {}`},
 		{`if $*x {}; if $*x {}`, 1, `for cond() { if a(); b {}; if a(); b {} }`},
@@ -826,6 +1567,26 @@ func TestMatch(t *testing.T) {
 		// TODO {`switch $*x {}; switch $*x {}`, 1, `{ switch a(); b {}; switch a(); b {} }`},
 		{`switch $*x {}; switch $*x {}`, 0, `{ switch a(); b {}; switch b {} }`},
 		{`switch a(); $*_ {}`, 0, `for b {}`},
+		{`switch $x { case $v: $*body }`, 1, `switch x { case 1: f(); g() }`},
+		{`switch $x { case $v: $*body }`, 0, `switch x { case 1: f(); case 2: g() }`},
+		{`switch $x { case $v: $*body }`, 0, `switch x { case 1, 2: f() }`},
+		{`switch $x { case $v: $*body }`, 0, `switch { case x: f() }`},
+		{`switch { case $cond: $*_ }`, 1, `switch { case x > 0: f() }`},
+		{`switch { $*_; default: $*_ }`, 1, `switch { case x: f(); default: g() }`},
+		{`switch { $*_; default: $*_ }`, 0, `switch { case x: f(); case y: g() }`},
+		{`select { $*_; default: $*_ }`, 1, `select { case <-ch: f(); default: g() }`},
+		{`switch $x { $*_ }`, 0, `switch x.(type) {}`},
+		{`switch $*_; $*_ { $*_ }`, 0, `switch y := x.(type) {}`},
+
+		// Case clause.
+		{`case $v: $*_`, 2, `switch x { case 1: f(); case 2: }`},
+		{`case $v: $*_`, 0, `switch x { case 1, 2: f(); default: g() }`},
+		{`case $*vals: $*_`, 2, `switch x { case 1, 2: f(); case 3: }`},
+		{`case $*_: f()`, 1, `switch x { case 1, 2: f(); case 3: }`},
+		{`case $*_: f()`, 0, `switch x { default: f() }`},
+		{`default: $*_`, 1, `switch x { case 1: f(); default: g() }`},
+		{`default: $*_`, 0, `switch x { case 1: f() }`},
+		{`case int: $*_`, 1, `switch x.(type) { case int: }`},
 
 		// Type switch stmt.
 		{`switch x := $x.(type) {}`, 1, `switch x := y.(type) {}`},
@@ -936,6 +1697,162 @@ func TestMatch(t *testing.T) {
 		{`for $x = range $y { $z }`, 0, `for i := range l { c() }`},
 		{`for range $y { $z }`, 0, `for _, e := range l { e() }`},
 
+		// Range stmt - key/value shapes are matched distinctly.
+		{`for $k, $v := range $x { $*_ }`, 1, `for k, v := range xs {}`},
+		{`for $k, $v := range $x { $*_ }`, 0, `for k, v = range xs {}`},
+		{`for $k, $v := range $x { $*_ }`, 0, `for k := range xs {}`},
+		{`for $k, $v := range $x { $*_ }`, 0, `for range xs {}`},
+		{`for $k, $v = range $x { $*_ }`, 1, `for k, v = range xs {}`},
+		{`for $k, $v = range $x { $*_ }`, 0, `for k, v := range xs {}`},
+		{`for $k := range $x { $*_ }`, 1, `for k := range xs {}`},
+		{`for $k := range $x { $*_ }`, 0, `for k, v := range xs {}`},
+		{`for $k := range $x { $*_ }`, 0, `for range xs {}`},
+		{`for range $x { $*_ }`, 1, `for range xs {}`},
+		{`for range $x { $*_ }`, 0, `for k := range xs {}`},
+		{`for _, $v := range $x { $*_ }`, 1, `for _, v := range xs {}`},
+		{`for _, $v := range $x { $*_ }`, 0, `for i, v := range xs {}`},
+		{`for _, $v := range $x { $*_ }`, 0, `for i := range xs {}`},
+		{`for $_, $v := range $x { $*_ }`, 1, `for i, v := range xs {}`},
+		{`for $k, _ := range $x { $*_ }`, 1, `for k, _ := range xs {}`},
+		{`for $k, _ := range $x { $*_ }`, 0, `for k := range xs {}`},
+		{`for $x, $x := range $_ { $*_ }`, 0, `for i, v := range xs {}`},
+		// Range-over-int and range-over-func have the same syntax as ordinary ranges.
+		// The range expression type is not checked, so any go/parser version can parse these.
+		{`for $i := range 10 { $*_ }`, 1, `for i := range 10 {}`},
+		{`for $i := range $n { $*_ }`, 1, `for i := range len(xs) {}`},
+		{`for range $n { $*_ }`, 1, `for range 10 { f() }`},
+		{`for $k, $v := range $f { $*_ }`, 1, `for k, v := range maps.All(m) {}`},
+		{`for $x := range $seq { $*_ }`, 1, `for x := range func(yield func(int) bool) {} { println(x) }`},
+		{`for $x := range $n`, 1, `for i := range 10 {}`},
+		{`for $x := range $n`, 1, `for i := range n { println(i) }`},
+		{`for $x := range $n`, 0, `for range 10 {}`},
+		{`for range $n`, 1, `for range 10 {}`},
+		{`for range $n`, 0, `for i := range 10 {}`},
+		{`range $n`, 1, `for i := range 10 {}`},
+		{`for $k, $v := range $f($*_)`, 1, `for k, v := range seq.All() {}`},
+		{`for $x := range $f($*_)`, 0, `for x := range seq {}`},
+		{`for $x := range func($*_) { $*_ } { $*_ }`, 1, `for x := range func(yield func(int) bool) {} {}`},
+		{`for $x := range func($*_) { $*_ } { $*_ }`, 0, `for x := range seq {}`},
+
+		// Operator wildcards.
+		{`$x $op $y`, 1, `a + b`},
+		{`$x $op $y`, 1, `a % b`},
+		{`$x $op $y`, 1, `a << 1`},
+		{`$x $op $y`, 1, `a &^ b`},
+		{`$x $op $y`, 1, `a == b`},
+		{`$x $op $y`, 1, `a >= b`},
+		{`$x $op $y`, 1, `a && b`},
+		{`$x $op $y`, 1, `a || b`},
+		{`$x $op $y`, 0, `-a`},
+		{`$x $op $y`, 0, `f(a, b)`},
+		{`$x $_ $y`, 1, `a != b`},
+		{`$x $op $x`, 1, `a - a`},
+		{`$x $op $x`, 0, `a - b`},
+		{`$x $op 0`, 1, `len(xs) > 0`},
+		{`$x $op 0`, 0, `len(xs) > 1`},
+		{`f($x $op -1)`, 1, `f(a * -1)`},
+		{`$x $op $y $op $z`, 1, `a + b + c`},
+		{`$x $op $y $op $z`, 0, `a + b - c`},
+		{`$x $op ($y $op $z)`, 1, `a && (b && c)`},
+		{`$x $op ($y $op $z)`, 0, `a && (b || c)`},
+		{`$x $op1 $y && $x $op2 $z`, 1, `a > 0 && a < 10`},
+		{`$x $op1 $y || $z`, 1, `a < b || c`},
+		// Operator wildcards have the highest precedence.
+		{`a + b $op c`, 1, `a + b*c`},
+		{`a + b $op c`, 0, `a + b < c`},
+		{`$x $op $y &^ $z`, 1, `a * b &^ c`},
+		{`$x $op $y &^ $z`, 0, `a == b &^ c`},
+		{`$x $op $y * $z`, 1, `a * b * c`},
+		{`$x $op $y * $z`, 0, `a + b*c`},
+		{`$x $op $y * $z`, 0, `(a + b) * c`},
+		{`$x + $y $op $z`, 1, `a + b*c`},
+		{`$x + $y $op $z`, 0, `a + b == c`},
+		{`$x * $y $op $z`, 1, `a*b + c`},
+		{`$x * $y $op $z`, 0, `a + b*c`},
+		// The operator wildcards are only compared with each other.
+		{`$x $op $y; $z $op $w`, 1, `{ a + b; c + d }`},
+		{`$x $op $y; $z $op $w`, 0, `{ a + b; c - d }`},
+
+		// Unary expressions.
+		{`&$x`, 1, `&a`},
+		{`&$x`, 1, `p := &T{}`},
+		{`&$x`, 0, `a & b`},
+		{`*$x`, 1, `*p`},
+		{`*$x`, 1, `*p = 10`},
+		{`*$x`, 0, `a * b`},
+		{`-$x`, 1, `-a`},
+		{`-$x`, 0, `a - b`},
+		{`+$x`, 1, `+a`},
+		{`!$x`, 1, `if !ok {}`},
+		{`!$x`, 0, `if ok {}`},
+		{`!$x`, 2, `!!ok`},
+		{`^$x`, 1, `^a`},
+		{`^$x`, 0, `a ^ b`},
+		{`<-$x`, 1, `<-ch`},
+		{`-$x`, 0, `!a`},
+
+		// Unary operator wildcards.
+		{`($op $x)`, 1, `-a`},
+		{`($op $x)`, 1, `+a`},
+		{`($op $x)`, 1, `!a`},
+		{`($op $x)`, 1, `^a`},
+		{`($op $x)`, 1, `&a`},
+		{`($op $x)`, 1, `<-ch`},
+		{`($op $x)`, 1, `(-a)`},
+		{`($op $x)`, 0, `a - b`},
+		// The dereference is a star expression, like a pointer type.
+		{`($op $x)`, 0, `*p`},
+		{`($_ $x)`, 1, `!a`},
+		{`($op $x)`, 2, `!!ok`},
+		{`$op 1`, 1, `-1`},
+		{`$op f($x)`, 1, `!f(a)`},
+		// A standalone wildcard followed by a type is a value spec.
+		{`$x $T`, 1, `var (aa int)`},
+		{`$x $T`, 2, `var (aa int; bb []string)`},
+		{`$x $T`, 0, `var aa = 1`},
+		{`$x $T`, 0, `-a`},
+		{`$x int`, 1, `var (aa int; bb string)`},
+		{`$op a`, 0, `-a`},
+		{`!$op $x`, 1, `!-a`},
+		{`!$op $x`, 0, `-!a`},
+		{`-$op $x`, 1, `-^a`},
+		{`-$op $x`, 0, `^-a`},
+		// A parenthesized operand is a call.
+		{`$op ($x)`, 0, `-(a)`},
+		{`$op $x == nil`, 1, `&a == nil`},
+		{`$op $x == nil`, 0, `a == nil`},
+		{`$x + $op $y`, 1, `a + -b`},
+		{`$x + $op $y`, 0, `a + b`},
+		{`$op $x + $op $y`, 1, `-a + -b`},
+		{`$op $x + $op $y`, 0, `-a + ^b`},
+		{`$x ^ $op $y`, 1, `a ^ -b`},
+		{`f($op $x, $y)`, 1, `f(&a, b)`},
+		{`return $op $x`, 1, `return !a`},
+		{`$op $x; $y $op $z`, 1, `{ -a; b - c }`},
+		{`$op $x; $y $op $z`, 0, `{ -a; b + c }`},
+
+		// All occurrences of a named wildcard should match equal nodes.
+		{`f($*xs); g($*xs)`, 1, `{ f(a, b); g(a, b) }`},
+		{`f($*xs); g($*xs)`, 0, `{ f(a, b); g(a) }`},
+		{`f($*xs); g($*xs)`, 1, `{ f(); g() }`},
+		{`$x.f($x)`, 1, `a.f(a)`},
+		{`$x.f($x)`, 0, `a.f(b)`},
+		{`$x := $f(); $f($x)`, 1, `{ v := g(); g(v) }`},
+		{`$x := $f(); $f($x)`, 0, `{ v := g(); h(v) }`},
+
+		// Unicode identifiers.
+		{`π * $r * $r`, 1, `π * r * r`},
+		{`$x + $x`, 1, `π + π`},
+		{`$x + $x`, 1, `переменная + переменная`},
+		{`$x + $x`, 0, `переменная + переменнaя`}, // Latin "a" in the second ident
+		{`$x + $x`, 0, "a + \u0430"},              // Latin "a" vs Cyrillic "a"
+		{`日本.$f()`, 1, `日本.語()`},
+		{`$x.Ä`, 1, `ö.Ä`},
+		// Composed and decomposed forms are different literals.
+		{`"é"`, 1, `"é"`},
+		{`"é"`, 0, "\"e\u0301\""},
+		{`$s + $s`, 0, "\"\u00e9\" + \"e\u0301\""},
+
 		// Range stmt - optional matching.
 		{`for $*x; b; $*x {}`, 1, `for b {}`},
 		{`for $*x; b; $*x {}`, 1, `for a(); b; a() {}`},
@@ -1203,6 +2120,9 @@ func TestMatch(t *testing.T) {
 		{`type ()`, 1, `type ()`},
 		{`type ()`, 0, `type (x int)`},
 		{`type ()`, 0, `type x int`},
+		{`type ($*_)`, 1, `type (x int; y = int)`},
+		{`type ($*_)`, 1, `type x = int`},
+		{`type ($*_)`, 0, `var x int`},
 		{`type $_ struct{$*_}`, 1, `type foo struct{}`},
 		{`type $_ struct{$*_}`, 1, `type foo struct{x int}`},
 		{`type $_ struct{$*_}`, 0, `type foo int`},
@@ -1264,6 +2184,24 @@ func TestMatch(t *testing.T) {
 		{`import $_`, 1, `package foo; import ("fmt")`},
 		{`import $a`, 1, `package foo; import "fmt"`},
 		{`import $a`, 1, `package foo; import ("fmt")`},
+
+		// Import specs.
+		{`import "fmt"`, 1, `package foo; import "fmt"`},
+		{`import "fmt"`, 1, `package foo; import ("fmt"; "os")`},
+		{`import "fmt"`, 1, "package foo; import `fmt`"},
+		{`import "fmt"`, 0, `package foo; import f "fmt"`},
+		{`import "fmt"`, 0, `package foo; import "os"`},
+		{`import $alias "fmt"`, 1, `package foo; import f "fmt"`},
+		{`import $alias "fmt"`, 1, `package foo; import _ "fmt"`},
+		{`import $alias "fmt"`, 1, `package foo; import . "fmt"`},
+		{`import $alias "fmt"`, 0, `package foo; import "fmt"`},
+		{`import $alias $path`, 2, `package foo; import (f "fmt"; "os"; _ "embed")`},
+		{`import _ $path`, 1, `package foo; import (f "fmt"; "os"; _ "embed")`},
+		{`import . $path`, 1, `package foo; import (. "fmt"; "os")`},
+		{`import . $path`, 0, `package foo; import (f "fmt"; _ "os")`},
+		{`import f $_`, 1, `package foo; import (f "fmt"; "os")`},
+		{`import f $_`, 0, `package foo; import (g "fmt"; "os")`},
+		{`import $x $x`, 0, `package foo; import f "fmt"`},
 	}
 
 	if typeparams.Enabled() {
@@ -1297,6 +2235,17 @@ func TestMatch(t *testing.T) {
 			{`type Foo[T any] struct { x T }`, 1, `package p; type Foo[T any] struct { x T }`},
 			{`type Foo[T any] struct { x T }`, 0, `package p; type Foo struct { x T }`},
 			{`type Foo struct { x T }`, 0, `package p; type Foo[T any] struct { x T }`},
+			{`type $name $T`, 1, `package p; type List[E any] []E`},
+			{`type $name = $T`, 0, `package p; type List[E any] []E`},
+
+			// Generic type alias decl.
+			{`type $name = $T`, 1, `package p; type Set[K comparable] = map[K]bool`},
+			{`type $name $T`, 0, `package p; type Set[K comparable] = map[K]bool`},
+			{`type Set[$k comparable] = $T`, 1, `package p; type Set[K comparable] = map[K]bool`},
+			{`type Set[$k comparable] = $T`, 0, `package p; type Set[K comparable] map[K]bool`},
+			{`type Set[$k comparable] $T`, 0, `package p; type Set[K comparable] = map[K]bool`},
+			{`type Set[$k comparable] = map[$k]bool`, 1, `package p; type Set[T comparable] = map[T]bool`},
+			{`type Set[$k comparable] = map[$k]bool`, 0, `package p; type Set[T comparable] = map[int]bool`},
 
 			// Generic literals.
 			{`Foo{1}`, 0, `Foo[int]{1}`},
@@ -42,109 +42,118 @@ func _() {
 	_ = x[opTypedCompositeLit-31]
 	_ = x[opSimpleSelectorExpr-32]
 	_ = x[opSelectorExpr-33]
-	_ = x[opTypeAssertExpr-34]
-	_ = x[opTypeSwitchAssertExpr-35]
-	_ = x[opStructType-36]
-	_ = x[opInterfaceType-37]
-	_ = x[opEfaceType-38]
-	_ = x[opVoidFuncType-39]
-	_ = x[opGenericVoidFuncType-40]
-	_ = x[opFuncType-41]
-	_ = x[opGenericFuncType-42]
-	_ = x[opArrayType-43]
-	_ = x[opSliceType-44]
-	_ = x[opMapType-45]
-	_ = x[opChanType-46]
-	_ = x[opKeyValueExpr-47]
-	_ = x[opEllipsis-48]
-	_ = x[opTypedEllipsis-49]
-	_ = x[opStarExpr-50]
-	_ = x[opUnaryExpr-51]
-	_ = x[opBinaryExpr-52]
-	_ = x[opParenExpr-53]
-	_ = x[opArgList-54]
-	_ = x[opSimpleArgList-55]
-	_ = x[opVariadicCallExpr-56]
-	_ = x[opNonVariadicCallExpr-57]
-	_ = x[opMaybeVariadicCallExpr-58]
-	_ = x[opCallExpr-59]
-	_ = x[opAssignStmt-60]
-	_ = x[opMultiAssignStmt-61]
-	_ = x[opBranchStmt-62]
-	_ = x[opSimpleLabeledBranchStmt-63]
-	_ = x[opLabeledBranchStmt-64]
-	_ = x[opSimpleLabeledStmt-65]
-	_ = x[opLabeledStmt-66]
-	_ = x[opBlockStmt-67]
-	_ = x[opExprStmt-68]
-	_ = x[opGoStmt-69]
-	_ = x[opDeferStmt-70]
-	_ = x[opSendStmt-71]
-	_ = x[opEmptyStmt-72]
-	_ = x[opIncDecStmt-73]
-	_ = x[opReturnStmt-74]
-	_ = x[opIfStmt-75]
-	_ = x[opIfInitStmt-76]
-	_ = x[opIfElseStmt-77]
-	_ = x[opIfInitElseStmt-78]
-	_ = x[opIfNamedOptStmt-79]
-	_ = x[opIfNamedOptElseStmt-80]
-	_ = x[opSwitchStmt-81]
-	_ = x[opSwitchTagStmt-82]
-	_ = x[opSwitchInitStmt-83]
-	_ = x[opSwitchInitTagStmt-84]
-	_ = x[opSelectStmt-85]
-	_ = x[opTypeSwitchStmt-86]
-	_ = x[opTypeSwitchInitStmt-87]
-	_ = x[opCaseClause-88]
-	_ = x[opDefaultCaseClause-89]
-	_ = x[opCommClause-90]
-	_ = x[opDefaultCommClause-91]
-	_ = x[opForStmt-92]
-	_ = x[opForPostStmt-93]
-	_ = x[opForCondStmt-94]
-	_ = x[opForCondPostStmt-95]
-	_ = x[opForInitStmt-96]
-	_ = x[opForInitPostStmt-97]
-	_ = x[opForInitCondStmt-98]
-	_ = x[opForInitCondPostStmt-99]
-	_ = x[opRangeStmt-100]
-	_ = x[opRangeKeyStmt-101]
-	_ = x[opRangeKeyValueStmt-102]
-	_ = x[opRangeClause-103]
-	_ = x[opRangeHeader-104]
-	_ = x[opRangeKeyHeader-105]
-	_ = x[opRangeKeyValueHeader-106]
-	_ = x[opFieldList-107]
-	_ = x[opUnnamedField-108]
-	_ = x[opSimpleField-109]
-	_ = x[opField-110]
-	_ = x[opMultiField-111]
-	_ = x[opValueSpec-112]
-	_ = x[opValueInitSpec-113]
-	_ = x[opTypedValueInitSpec-114]
-	_ = x[opTypedValueSpec-115]
-	_ = x[opSimpleTypeSpec-116]
-	_ = x[opTypeSpec-117]
-	_ = x[opGenericTypeSpec-118]
-	_ = x[opTypeAliasSpec-119]
-	_ = x[opSimpleFuncDecl-120]
-	_ = x[opFuncDecl-121]
-	_ = x[opMethodDecl-122]
-	_ = x[opFuncProtoDecl-123]
-	_ = x[opMethodProtoDecl-124]
-	_ = x[opDeclStmt-125]
-	_ = x[opConstDecl-126]
-	_ = x[opVarDecl-127]
-	_ = x[opTypeDecl-128]
-	_ = x[opAnyImportDecl-129]
-	_ = x[opImportDecl-130]
-	_ = x[opEmptyPackage-131]
+	_ = x[opChainSelectorExpr-34]
+	_ = x[opTypeAssertExpr-35]
+	_ = x[opTypeSwitchAssertExpr-36]
+	_ = x[opStructType-37]
+	_ = x[opInterfaceType-38]
+	_ = x[opEfaceType-39]
+	_ = x[opVoidFuncType-40]
+	_ = x[opGenericVoidFuncType-41]
+	_ = x[opFuncType-42]
+	_ = x[opGenericFuncType-43]
+	_ = x[opArrayType-44]
+	_ = x[opSliceType-45]
+	_ = x[opMapType-46]
+	_ = x[opChanType-47]
+	_ = x[opKeyValueExpr-48]
+	_ = x[opEllipsis-49]
+	_ = x[opTypedEllipsis-50]
+	_ = x[opStarExpr-51]
+	_ = x[opUnaryExpr-52]
+	_ = x[opAnyUnaryExpr-53]
+	_ = x[opNamedUnaryExpr-54]
+	_ = x[opBinaryExpr-55]
+	_ = x[opAnyBinaryExpr-56]
+	_ = x[opNamedBinaryExpr-57]
+	_ = x[opParenExpr-58]
+	_ = x[opArgList-59]
+	_ = x[opSimpleArgList-60]
+	_ = x[opVariadicCallExpr-61]
+	_ = x[opNonVariadicCallExpr-62]
+	_ = x[opMaybeVariadicCallExpr-63]
+	_ = x[opCallExpr-64]
+	_ = x[opAssignStmt-65]
+	_ = x[opMultiAssignStmt-66]
+	_ = x[opBranchStmt-67]
+	_ = x[opSimpleLabeledBranchStmt-68]
+	_ = x[opLabeledBranchStmt-69]
+	_ = x[opSimpleLabeledStmt-70]
+	_ = x[opLabeledStmt-71]
+	_ = x[opBlockStmt-72]
+	_ = x[opExprStmt-73]
+	_ = x[opGoStmt-74]
+	_ = x[opDeferStmt-75]
+	_ = x[opSendStmt-76]
+	_ = x[opEmptyStmt-77]
+	_ = x[opIncDecStmt-78]
+	_ = x[opReturnStmt-79]
+	_ = x[opIfStmt-80]
+	_ = x[opIfInitStmt-81]
+	_ = x[opIfElseStmt-82]
+	_ = x[opIfInitElseStmt-83]
+	_ = x[opIfNamedOptStmt-84]
+	_ = x[opIfNamedOptElseStmt-85]
+	_ = x[opSwitchStmt-86]
+	_ = x[opSwitchTagStmt-87]
+	_ = x[opSwitchInitStmt-88]
+	_ = x[opSwitchInitTagStmt-89]
+	_ = x[opSelectStmt-90]
+	_ = x[opTypeSwitchStmt-91]
+	_ = x[opTypeSwitchInitStmt-92]
+	_ = x[opCaseClause-93]
+	_ = x[opDefaultCaseClause-94]
+	_ = x[opCommClause-95]
+	_ = x[opDefaultCommClause-96]
+	_ = x[opForStmt-97]
+	_ = x[opForPostStmt-98]
+	_ = x[opForCondStmt-99]
+	_ = x[opForCondPostStmt-100]
+	_ = x[opForInitStmt-101]
+	_ = x[opForInitPostStmt-102]
+	_ = x[opForInitCondStmt-103]
+	_ = x[opForInitCondPostStmt-104]
+	_ = x[opRangeStmt-105]
+	_ = x[opRangeKeyStmt-106]
+	_ = x[opRangeKeyValueStmt-107]
+	_ = x[opRangeClause-108]
+	_ = x[opRangeHeader-109]
+	_ = x[opRangeKeyHeader-110]
+	_ = x[opRangeKeyValueHeader-111]
+	_ = x[opFieldList-112]
+	_ = x[opUnnamedField-113]
+	_ = x[opSimpleField-114]
+	_ = x[opField-115]
+	_ = x[opMultiField-116]
+	_ = x[opAnyNamesField-117]
+	_ = x[opValueSpec-118]
+	_ = x[opValueInitSpec-119]
+	_ = x[opTypedValueInitSpec-120]
+	_ = x[opTypedValueSpec-121]
+	_ = x[opSimpleTypeSpec-122]
+	_ = x[opTypeSpec-123]
+	_ = x[opGenericTypeSpec-124]
+	_ = x[opTypeAliasSpec-125]
+	_ = x[opGenericTypeAliasSpec-126]
+	_ = x[opImportSpec-127]
+	_ = x[opNamedImportSpec-128]
+	_ = x[opSimpleFuncDecl-129]
+	_ = x[opFuncDecl-130]
+	_ = x[opMethodDecl-131]
+	_ = x[opFuncProtoDecl-132]
+	_ = x[opMethodProtoDecl-133]
+	_ = x[opDeclStmt-134]
+	_ = x[opConstDecl-135]
+	_ = x[opVarDecl-136]
+	_ = x[opTypeDecl-137]
+	_ = x[opAnyImportDecl-138]
+	_ = x[opImportDecl-139]
+	_ = x[opEmptyPackage-140]
 }
 
-const _operation_name = "InvalidNodeNamedNodeNodeSeqNamedNodeSeqOptNodeNamedOptNodeFieldNodeNamedFieldNodeMultiStmtMultiExprMultiDeclEndBasicLitStrictIntLitStrictFloatLitStrictCharLitStrictStringLitStrictComplexLitIdentPkgIndexExprIndexListExprSliceExprSliceFromExprSliceToExprSliceFromToExprSliceToCapExprSliceFromToCapExprFuncLitCompositeLitTypedCompositeLitSimpleSelectorExprSelectorExprTypeAssertExprTypeSwitchAssertExprStructTypeInterfaceTypeEfaceTypeVoidFuncTypeGenericVoidFuncTypeFuncTypeGenericFuncTypeArrayTypeSliceTypeMapTypeChanTypeKeyValueExprEllipsisTypedEllipsisStarExprUnaryExprBinaryExprParenExprArgListSimpleArgListVariadicCallExprNonVariadicCallExprMaybeVariadicCallExprCallExprAssignStmtMultiAssignStmtBranchStmtSimpleLabeledBranchStmtLabeledBranchStmtSimpleLabeledStmtLabeledStmtBlockStmtExprStmtGoStmtDeferStmtSendStmtEmptyStmtIncDecStmtReturnStmtIfStmtIfInitStmtIfElseStmtIfInitElseStmtIfNamedOptStmtIfNamedOptElseStmtSwitchStmtSwitchTagStmtSwitchInitStmtSwitchInitTagStmtSelectStmtTypeSwitchStmtTypeSwitchInitStmtCaseClauseDefaultCaseClauseCommClauseDefaultCommClauseForStmtForPostStmtForCondStmtForCondPostStmtForInitStmtForInitPostStmtForInitCondStmtForInitCondPostStmtRangeStmtRangeKeyStmtRangeKeyValueStmtRangeClauseRangeHeaderRangeKeyHeaderRangeKeyValueHeaderFieldListUnnamedFieldSimpleFieldFieldMultiFieldValueSpecValueInitSpecTypedValueInitSpecTypedValueSpecSimpleTypeSpecTypeSpecGenericTypeSpecTypeAliasSpecSimpleFuncDeclFuncDeclMethodDeclFuncProtoDeclMethodProtoDeclDeclStmtConstDeclVarDeclTypeDeclAnyImportDeclImportDeclEmptyPackage"
+const _operation_name = "InvalidNodeNamedNodeNodeSeqNamedNodeSeqOptNodeNamedOptNodeFieldNodeNamedFieldNodeMultiStmtMultiExprMultiDeclEndBasicLitStrictIntLitStrictFloatLitStrictCharLitStrictStringLitStrictComplexLitIdentPkgIndexExprIndexListExprSliceExprSliceFromExprSliceToExprSliceFromToExprSliceToCapExprSliceFromToCapExprFuncLitCompositeLitTypedCompositeLitSimpleSelectorExprSelectorExprChainSelectorExprTypeAssertExprTypeSwitchAssertExprStructTypeInterfaceTypeEfaceTypeVoidFuncTypeGenericVoidFuncTypeFuncTypeGenericFuncTypeArrayTypeSliceTypeMapTypeChanTypeKeyValueExprEllipsisTypedEllipsisStarExprUnaryExprAnyUnaryExprNamedUnaryExprBinaryExprAnyBinaryExprNamedBinaryExprParenExprArgListSimpleArgListVariadicCallExprNonVariadicCallExprMaybeVariadicCallExprCallExprAssignStmtMultiAssignStmtBranchStmtSimpleLabeledBranchStmtLabeledBranchStmtSimpleLabeledStmtLabeledStmtBlockStmtExprStmtGoStmtDeferStmtSendStmtEmptyStmtIncDecStmtReturnStmtIfStmtIfInitStmtIfElseStmtIfInitElseStmtIfNamedOptStmtIfNamedOptElseStmtSwitchStmtSwitchTagStmtSwitchInitStmtSwitchInitTagStmtSelectStmtTypeSwitchStmtTypeSwitchInitStmtCaseClauseDefaultCaseClauseCommClauseDefaultCommClauseForStmtForPostStmtForCondStmtForCondPostStmtForInitStmtForInitPostStmtForInitCondStmtForInitCondPostStmtRangeStmtRangeKeyStmtRangeKeyValueStmtRangeClauseRangeHeaderRangeKeyHeaderRangeKeyValueHeaderFieldListUnnamedFieldSimpleFieldFieldMultiFieldAnyNamesFieldValueSpecValueInitSpecTypedValueInitSpecTypedValueSpecSimpleTypeSpecTypeSpecGenericTypeSpecTypeAliasSpecGenericTypeAliasSpecImportSpecNamedImportSpecSimpleFuncDeclFuncDeclMethodDeclFuncProtoDeclMethodProtoDeclDeclStmtConstDeclVarDeclTypeDeclAnyImportDeclImportDeclEmptyPackage"
 
-var _operation_index = [...]uint16{0, 7, 11, 20, 27, 39, 46, 58, 67, 81, 90, 99, 108, 111, 119, 131, 145, 158, 173, 189, 194, 197, 206, 219, 228, 241, 252, 267, 281, 299, 306, 318, 335, 353, 365, 379, 399, 409, 422, 431, 443, 462, 470, 485, 494, 503, 510, 518, 530, 538, 551, 559, 568, 578, 587, 594, 607, 623, 642, 663, 671, 681, 696, 706, 729, 746, 763, 774, 783, 791, 797, 806, 814, 823, 833, 843, 849, 859, 869, 883, 897, 915, 925, 938, 952, 969, 979, 993, 1011, 1021, 1038, 1048, 1065, 1072, 1083, 1094, 1109, 1120, 1135, 1150, 1169, 1178, 1190, 1207, 1218, 1229, 1243, 1262, 1271, 1283, 1294, 1299, 1309, 1318, 1331, 1349, 1363, 1377, 1385, 1400, 1413, 1427, 1435, 1445, 1458, 1473, 1481, 1490, 1497, 1505, 1518, 1528, 1540}
+var _operation_index = [...]uint16{0, 7, 11, 20, 27, 39, 46, 58, 67, 81, 90, 99, 108, 111, 119, 131, 145, 158, 173, 189, 194, 197, 206, 219, 228, 241, 252, 267, 281, 299, 306, 318, 335, 353, 365, 382, 396, 416, 426, 439, 448, 460, 479, 487, 502, 511, 520, 527, 535, 547, 555, 568, 576, 585, 597, 611, 621, 634, 649, 658, 665, 678, 694, 713, 734, 742, 752, 767, 777, 800, 817, 834, 845, 854, 862, 868, 877, 885, 894, 904, 914, 920, 930, 940, 954, 968, 986, 996, 1009, 1023, 1040, 1050, 1064, 1082, 1092, 1109, 1119, 1136, 1143, 1154, 1165, 1180, 1191, 1206, 1221, 1240, 1249, 1261, 1278, 1289, 1300, 1314, 1333, 1342, 1354, 1365, 1370, 1380, 1393, 1402, 1415, 1433, 1447, 1461, 1469, 1484, 1497, 1517, 1527, 1542, 1556, 1564, 1574, 1587, 1602, 1610, 1619, 1626, 1634, 1647, 1657, 1669}
 
 func (i operation) String() string {
 	if i >= operation(len(_operation_index)-1) {
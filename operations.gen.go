@@ -150,462 +150,510 @@ const (
 	// Args: x sel
 	opSelectorExpr operation = 33
 
+	// Tag: Node
+	// Args: x sel
+	// Example: $*x.sel
+	opChainSelectorExpr operation = 34
+
 	// Tag: TypeAssertExpr
 	// Args: x typ
-	opTypeAssertExpr operation = 34
+	opTypeAssertExpr operation = 35
 
 	// Tag: TypeAssertExpr
 	// Args: x
-	opTypeSwitchAssertExpr operation = 35
+	opTypeSwitchAssertExpr operation = 36
 
 	// Tag: StructType
 	// Args: fields
-	opStructType operation = 36
+	opStructType operation = 37
 
 	// Tag: InterfaceType
 	// Args: fields
-	opInterfaceType operation = 37
+	opInterfaceType operation = 38
 
 	// Tag: InterfaceType
-	opEfaceType operation = 38
+	opEfaceType operation = 39
 
 	// Tag: FuncType
 	// Args: params
-	opVoidFuncType operation = 39
+	opVoidFuncType operation = 40
 
 	// Tag: FuncType
 	// Args: typeparams params
-	opGenericVoidFuncType operation = 40
+	opGenericVoidFuncType operation = 41
 
 	// Tag: FuncType
 	// Args: params results
-	opFuncType operation = 41
+	opFuncType operation = 42
 
 	// Tag: FuncType
 	// Args: typeparams params results
-	opGenericFuncType operation = 42
+	opGenericFuncType operation = 43
 
 	// Tag: ArrayType
 	// Args: length elem
-	opArrayType operation = 43
+	opArrayType operation = 44
 
 	// Tag: ArrayType
 	// Args: elem
-	opSliceType operation = 44
+	opSliceType operation = 45
 
 	// Tag: MapType
 	// Args: key value
-	opMapType operation = 45
+	opMapType operation = 46
 
 	// Tag: ChanType
 	// Args: value
 	// Value: ast.ChanDir | channel direction
-	opChanType operation = 46
+	opChanType operation = 47
 
 	// Tag: KeyValueExpr
 	// Args: key value
-	opKeyValueExpr operation = 47
+	opKeyValueExpr operation = 48
 
 	// Tag: Ellipsis
-	opEllipsis operation = 48
+	opEllipsis operation = 49
 
 	// Tag: Ellipsis
 	// Args: type
-	opTypedEllipsis operation = 49
+	opTypedEllipsis operation = 50
 
 	// Tag: StarExpr
 	// Args: x
-	opStarExpr operation = 50
+	opStarExpr operation = 51
 
 	// Tag: UnaryExpr
 	// Args: x
 	// Value: token.Token | unary operator
-	opUnaryExpr operation = 51
+	opUnaryExpr operation = 52
+
+	// Tag: UnaryExpr
+	// Args: x
+	// Example: $_ x
+	opAnyUnaryExpr operation = 53
+
+	// Tag: UnaryExpr
+	// Args: x
+	// Example: $op x
+	// ValueIndex: strings | wildcard name
+	opNamedUnaryExpr operation = 54
 
 	// Tag: BinaryExpr
 	// Args: x y
 	// Value: token.Token | binary operator
-	opBinaryExpr operation = 52
+	opBinaryExpr operation = 55
+
+	// Tag: BinaryExpr
+	// Args: x y
+	// Example: x $_ y
+	opAnyBinaryExpr operation = 56
+
+	// Tag: BinaryExpr
+	// Args: x y
+	// Example: x $op y
+	// ValueIndex: strings | wildcard name
+	opNamedBinaryExpr operation = 57
 
 	// Tag: ParenExpr
 	// Args: x
-	opParenExpr operation = 53
+	opParenExpr operation = 58
 
 	// Tag: Unknown
 	// Args: exprs...
 	// Example: 1, 2, 3
-	opArgList operation = 54
+	opArgList operation = 59
 
 	// Tag: Unknown
 	// Like ArgList, but pattern contains no $*
 	// Args: exprs[]
 	// Example: 1, 2, 3
 	// Value: int | slice len
-	opSimpleArgList operation = 55
+	opSimpleArgList operation = 60
 
 	// Tag: CallExpr
 	// Args: fn args
 	// Example: f(1, xs...)
-	opVariadicCallExpr operation = 56
+	opVariadicCallExpr operation = 61
 
 	// Tag: CallExpr
 	// Args: fn args
 	// Example: f(1, xs)
-	opNonVariadicCallExpr operation = 57
+	opNonVariadicCallExpr operation = 62
 
 	// Tag: CallExpr
 	// Args: fn args
 	// Example: f(1, xs) or f(1, xs...)
 	// Value: int | can be variadic if len(args)>value
-	opMaybeVariadicCallExpr operation = 58
+	opMaybeVariadicCallExpr operation = 63
 
 	// Tag: CallExpr
 	// Args: fn args
 	// Example: f(1, xs) or f(1, xs...)
-	opCallExpr operation = 59
+	opCallExpr operation = 64
 
 	// Tag: AssignStmt
 	// Args: lhs rhs
 	// Example: lhs := rhs()
 	// Value: token.Token | ':=' or '='
-	opAssignStmt operation = 60
+	opAssignStmt operation = 65
 
 	// Tag: AssignStmt
 	// Args: lhs... rhs...
 	// Example: lhs1, lhs2 := rhs()
 	// Value: token.Token | ':=' or '='
-	opMultiAssignStmt operation = 61
+	opMultiAssignStmt operation = 66
 
 	// Tag: BranchStmt
 	// Args: x
 	// Value: token.Token | branch kind
-	opBranchStmt operation = 62
+	opBranchStmt operation = 67
 
 	// Tag: BranchStmt
 	// Args: x
 	// Value: token.Token | branch kind
 	// ValueIndex: strings | label name
-	opSimpleLabeledBranchStmt operation = 63
+	opSimpleLabeledBranchStmt operation = 68
 
 	// Tag: BranchStmt
 	// Args: label x
 	// Value: token.Token | branch kind
-	opLabeledBranchStmt operation = 64
+	opLabeledBranchStmt operation = 69
 
 	// Tag: LabeledStmt
 	// Args: x
 	// ValueIndex: strings | label name
-	opSimpleLabeledStmt operation = 65
+	opSimpleLabeledStmt operation = 70
 
 	// Tag: LabeledStmt
 	// Args: label x
-	opLabeledStmt operation = 66
+	opLabeledStmt operation = 71
 
 	// Tag: BlockStmt
 	// Args: body...
-	opBlockStmt operation = 67
+	opBlockStmt operation = 72
 
 	// Tag: ExprStmt
 	// Args: x
-	opExprStmt operation = 68
+	opExprStmt operation = 73
 
 	// Tag: GoStmt
 	// Args: x
-	opGoStmt operation = 69
+	opGoStmt operation = 74
 
 	// Tag: DeferStmt
 	// Args: x
-	opDeferStmt operation = 70
+	opDeferStmt operation = 75
 
 	// Tag: SendStmt
 	// Args: ch value
-	opSendStmt operation = 71
+	opSendStmt operation = 76
 
 	// Tag: EmptyStmt
-	opEmptyStmt operation = 72
+	opEmptyStmt operation = 77
 
 	// Tag: IncDecStmt
 	// Args: x
 	// Value: token.Token | '++' or '--'
-	opIncDecStmt operation = 73
+	opIncDecStmt operation = 78
 
 	// Tag: ReturnStmt
 	// Args: results...
-	opReturnStmt operation = 74
+	opReturnStmt operation = 79
 
 	// Tag: IfStmt
 	// Args: cond block
 	// Example: if cond {}
-	opIfStmt operation = 75
+	opIfStmt operation = 80
 
 	// Tag: IfStmt
 	// Args: init cond block
 	// Example: if init; cond {}
-	opIfInitStmt operation = 76
+	opIfInitStmt operation = 81
 
 	// Tag: IfStmt
 	// Args: cond block else
 	// Example: if cond {} else ...
-	opIfElseStmt operation = 77
+	opIfElseStmt operation = 82
 
 	// Tag: IfStmt
 	// Args: init cond block else
 	// Example: if init; cond {} else ...
-	opIfInitElseStmt operation = 78
+	opIfInitElseStmt operation = 83
 
 	// Tag: IfStmt
 	// Args: block
 	// Example: if $*x {}
 	// ValueIndex: strings | wildcard name
-	opIfNamedOptStmt operation = 79
+	opIfNamedOptStmt operation = 84
 
 	// Tag: IfStmt
 	// Args: block else
 	// Example: if $*x {} else ...
 	// ValueIndex: strings | wildcard name
-	opIfNamedOptElseStmt operation = 80
+	opIfNamedOptElseStmt operation = 85
 
 	// Tag: SwitchStmt
 	// Args: body...
 	// Example: switch {}
-	opSwitchStmt operation = 81
+	opSwitchStmt operation = 86
 
 	// Tag: SwitchStmt
 	// Args: tag body...
 	// Example: switch tag {}
-	opSwitchTagStmt operation = 82
+	opSwitchTagStmt operation = 87
 
 	// Tag: SwitchStmt
 	// Args: init body...
 	// Example: switch init; {}
-	opSwitchInitStmt operation = 83
+	opSwitchInitStmt operation = 88
 
 	// Tag: SwitchStmt
 	// Args: init tag body...
 	// Example: switch init; tag {}
-	opSwitchInitTagStmt operation = 84
+	opSwitchInitTagStmt operation = 89
 
 	// Tag: SelectStmt
 	// Args: body...
-	opSelectStmt operation = 85
+	opSelectStmt operation = 90
 
 	// Tag: TypeSwitchStmt
 	// Args: x block
 	// Example: switch x.(type) {}
-	opTypeSwitchStmt operation = 86
+	opTypeSwitchStmt operation = 91
 
 	// Tag: TypeSwitchStmt
 	// Args: init x block
 	// Example: switch init; x.(type) {}
-	opTypeSwitchInitStmt operation = 87
+	opTypeSwitchInitStmt operation = 92
 
 	// Tag: CaseClause
 	// Args: values... body...
-	opCaseClause operation = 88
+	opCaseClause operation = 93
 
 	// Tag: CaseClause
 	// Args: body...
-	opDefaultCaseClause operation = 89
+	opDefaultCaseClause operation = 94
 
 	// Tag: CommClause
 	// Args: comm body...
-	opCommClause operation = 90
+	opCommClause operation = 95
 
 	// Tag: CommClause
 	// Args: body...
-	opDefaultCommClause operation = 91
+	opDefaultCommClause operation = 96
 
 	// Tag: ForStmt
 	// Args: blocl
 	// Example: for {}
-	opForStmt operation = 92
+	opForStmt operation = 97
 
 	// Tag: ForStmt
 	// Args: post block
 	// Example: for ; ; post {}
-	opForPostStmt operation = 93
+	opForPostStmt operation = 98
 
 	// Tag: ForStmt
 	// Args: cond block
 	// Example: for ; cond; {}
-	opForCondStmt operation = 94
+	opForCondStmt operation = 99
 
 	// Tag: ForStmt
 	// Args: cond post block
 	// Example: for ; cond; post {}
-	opForCondPostStmt operation = 95
+	opForCondPostStmt operation = 100
 
 	// Tag: ForStmt
 	// Args: init block
 	// Example: for init; ; {}
-	opForInitStmt operation = 96
+	opForInitStmt operation = 101
 
 	// Tag: ForStmt
 	// Args: init post block
 	// Example: for init; ; post {}
-	opForInitPostStmt operation = 97
+	opForInitPostStmt operation = 102
 
 	// Tag: ForStmt
 	// Args: init cond block
 	// Example: for init; cond; {}
-	opForInitCondStmt operation = 98
+	opForInitCondStmt operation = 103
 
 	// Tag: ForStmt
 	// Args: init cond post block
 	// Example: for init; cond; post {}
-	opForInitCondPostStmt operation = 99
+	opForInitCondPostStmt operation = 104
 
 	// Tag: RangeStmt
 	// Args: x block
 	// Example: for range x {}
-	opRangeStmt operation = 100
+	opRangeStmt operation = 105
 
 	// Tag: RangeStmt
 	// Args: key x block
 	// Example: for key := range x {}
 	// Value: token.Token | ':=' or '='
-	opRangeKeyStmt operation = 101
+	opRangeKeyStmt operation = 106
 
 	// Tag: RangeStmt
 	// Args: key value x block
 	// Example: for key, value := range x {}
 	// Value: token.Token | ':=' or '='
-	opRangeKeyValueStmt operation = 102
+	opRangeKeyValueStmt operation = 107
 
 	// Tag: RangeStmt
 	// Args: x
 	// Example: range x
-	opRangeClause operation = 103
+	opRangeClause operation = 108
 
 	// Tag: RangeStmt
 	// Args: x
 	// Example: for range x
-	opRangeHeader operation = 104
+	opRangeHeader operation = 109
 
 	// Tag: RangeStmt
 	// Args: key x
 	// Example: for key := range x
 	// Value: token.Token | ':=' or '='
-	opRangeKeyHeader operation = 105
+	opRangeKeyHeader operation = 110
 
 	// Tag: RangeStmt
 	// Args: key value x
 	// Example: for key, value := range x
 	// Value: token.Token | ':=' or '='
-	opRangeKeyValueHeader operation = 106
+	opRangeKeyValueHeader operation = 111
 
 	// Tag: Unknown
 	// Args: fields...
-	opFieldList operation = 107
+	opFieldList operation = 112
 
 	// Tag: Unknown
 	// Args: typ
 	// Example: type
-	opUnnamedField operation = 108
+	opUnnamedField operation = 113
 
 	// Tag: Unknown
 	// Args: typ
 	// Example: name type
 	// ValueIndex: strings | field name
-	opSimpleField operation = 109
+	opSimpleField operation = 114
 
 	// Tag: Unknown
 	// Args: name typ
 	// Example: $name type
-	opField operation = 110
+	opField operation = 115
 
 	// Tag: Unknown
 	// Args: names... typ
 	// Example: name1, name2 type
-	opMultiField operation = 111
+	opMultiField operation = 116
+
+	// Tag: Unknown
+	// matches the fields with any number of names, including the embedded ones
+	// Args: names... typ
+	// Example: $*names type
+	opAnyNamesField operation = 117
 
 	// Tag: ValueSpec
 	// Args: value
-	opValueSpec operation = 112
+	opValueSpec operation = 118
 
 	// Tag: ValueSpec
 	// Args: lhs... rhs...
 	// Example: lhs = rhs
-	opValueInitSpec operation = 113
+	opValueInitSpec operation = 119
 
 	// Tag: ValueSpec
 	// Args: lhs... type rhs...
 	// Example: lhs typ = rhs
-	opTypedValueInitSpec operation = 114
+	opTypedValueInitSpec operation = 120
 
 	// Tag: ValueSpec
 	// Args: lhs... type
 	// Example: lhs typ
-	opTypedValueSpec operation = 115
+	opTypedValueSpec operation = 121
 
 	// Tag: TypeSpec
 	// Args: type
 	// Example: name type
 	// ValueIndex: strings | type name
-	opSimpleTypeSpec operation = 116
+	opSimpleTypeSpec operation = 122
 
 	// Tag: TypeSpec
 	// Args: name type
 	// Example: name type
-	opTypeSpec operation = 117
+	opTypeSpec operation = 123
 
 	// Tag: TypeSpec
 	// Args: name typeparasm type
 	// Example: name[typeparams] type
-	opGenericTypeSpec operation = 118
+	opGenericTypeSpec operation = 124
 
 	// Tag: TypeSpec
 	// Args: name type
 	// Example: name = type
-	opTypeAliasSpec operation = 119
+	opTypeAliasSpec operation = 125
+
+	// Tag: TypeSpec
+	// Args: name typeparams type
+	// Example: name[typeparams] = type
+	opGenericTypeAliasSpec operation = 126
+
+	// Tag: ImportSpec
+	// Args: path
+	// Example: "path"
+	opImportSpec operation = 127
+
+	// Tag: ImportSpec
+	// Args: name path
+	// Example: name "path"
+	opNamedImportSpec operation = 128
 
 	// Tag: FuncDecl
 	// Args: type block
 	// ValueIndex: strings | field name
-	opSimpleFuncDecl operation = 120
+	opSimpleFuncDecl operation = 129
 
 	// Tag: FuncDecl
 	// Args: name type block
-	opFuncDecl operation = 121
+	opFuncDecl operation = 130
 
 	// Tag: FuncDecl
 	// Args: recv name type block
-	opMethodDecl operation = 122
+	opMethodDecl operation = 131
 
 	// Tag: FuncDecl
 	// Args: name type
-	opFuncProtoDecl operation = 123
+	opFuncProtoDecl operation = 132
 
 	// Tag: FuncDecl
 	// Args: recv name type
-	opMethodProtoDecl operation = 124
+	opMethodProtoDecl operation = 133
 
 	// Tag: DeclStmt
 	// Args: decl
-	opDeclStmt operation = 125
+	opDeclStmt operation = 134
 
 	// Tag: GenDecl
 	// Args: valuespecs...
-	opConstDecl operation = 126
+	opConstDecl operation = 135
 
 	// Tag: GenDecl
 	// Args: valuespecs...
-	opVarDecl operation = 127
+	opVarDecl operation = 136
 
 	// Tag: GenDecl
 	// Args: typespecs...
-	opTypeDecl operation = 128
+	opTypeDecl operation = 137
 
 	// Tag: GenDecl
-	opAnyImportDecl operation = 129
+	opAnyImportDecl operation = 138
 
 	// Tag: GenDecl
 	// Args: importspecs...
-	opImportDecl operation = 130
+	opImportDecl operation = 139
 
 	// Tag: File
 	// Args: name
-	opEmptyPackage operation = 131
+	opEmptyPackage operation = 140
 )
 
 type operationInfo struct {
@@ -884,6 +932,14 @@ var operationInfoTable = [256]operationInfo{
 		VariadicMap:    0, // 0
 		SliceIndex:     -1,
 	},
+	opChainSelectorExpr: {
+		Tag:            nodetag.Node,
+		NumArgs:        2,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
 	opTypeAssertExpr: {
 		Tag:            nodetag.TypeAssertExpr,
 		NumArgs:        2,
@@ -1028,6 +1084,22 @@ var operationInfoTable = [256]operationInfo{
 		VariadicMap:    0, // 0
 		SliceIndex:     -1,
 	},
+	opAnyUnaryExpr: {
+		Tag:            nodetag.UnaryExpr,
+		NumArgs:        1,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
+	opNamedUnaryExpr: {
+		Tag:            nodetag.UnaryExpr,
+		NumArgs:        1,
+		ValueKind:      emptyValue,
+		ExtraValueKind: stringValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
 	opBinaryExpr: {
 		Tag:            nodetag.BinaryExpr,
 		NumArgs:        2,
@@ -1036,6 +1108,22 @@ var operationInfoTable = [256]operationInfo{
 		VariadicMap:    0, // 0
 		SliceIndex:     -1,
 	},
+	opAnyBinaryExpr: {
+		Tag:            nodetag.BinaryExpr,
+		NumArgs:        2,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
+	opNamedBinaryExpr: {
+		Tag:            nodetag.BinaryExpr,
+		NumArgs:        2,
+		ValueKind:      emptyValue,
+		ExtraValueKind: stringValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
 	opParenExpr: {
 		Tag:            nodetag.ParenExpr,
 		NumArgs:        1,
@@ -1508,6 +1596,14 @@ var operationInfoTable = [256]operationInfo{
 		VariadicMap:    1, // 1
 		SliceIndex:     -1,
 	},
+	opAnyNamesField: {
+		Tag:            nodetag.Unknown,
+		NumArgs:        2,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    1, // 1
+		SliceIndex:     -1,
+	},
 	opValueSpec: {
 		Tag:            nodetag.ValueSpec,
 		NumArgs:        1,
@@ -1572,6 +1668,30 @@ var operationInfoTable = [256]operationInfo{
 		VariadicMap:    0, // 0
 		SliceIndex:     -1,
 	},
+	opGenericTypeAliasSpec: {
+		Tag:            nodetag.TypeSpec,
+		NumArgs:        3,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
+	opImportSpec: {
+		Tag:            nodetag.ImportSpec,
+		NumArgs:        1,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
+	opNamedImportSpec: {
+		Tag:            nodetag.ImportSpec,
+		NumArgs:        2,
+		ValueKind:      emptyValue,
+		ExtraValueKind: emptyValue,
+		VariadicMap:    0, // 0
+		SliceIndex:     -1,
+	},
 	opSimpleFuncDecl: {
 		Tag:            nodetag.FuncDecl,
 		NumArgs:        2,
@@ -5,16 +5,40 @@ package gogrep
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/parser"
 	"go/scanner"
 	"go/token"
+	"sort"
 	"strings"
 	"text/template"
+	"unicode"
+	"unicode/utf8"
 )
 
-func transformSource(expr string) (string, []posOffset, error) {
+// operatorVars are the operator wildcard names collected by transformSource.
+type operatorVars struct {
+	// withUnary enables the unary operator wildcards, like $op in `$op $x`.
+	withUnary bool
+
+	binary []string
+	unary  []string
+}
+
+// transformSource converts a pattern source into a parsable Go code.
+//
+// If opVars is not nil, wildcards that are placed in a binary operator
+// position (like $op in `$x $op $y`) are replaced with `&^` operators,
+// so they get the highest binary operator precedence.
+// For every `&^` in the result source, opVars.binary gets a wildcard name
+// or an empty string if it was a `&^` operator in the pattern.
+//
+// With opVars.withUnary, wildcards that are placed in a unary operator
+// position (like $op in `$op $x`) are replaced with unary `^` operators
+// and recorded to opVars.unary in the same way.
+func transformSource(expr string, opVars *operatorVars) (string, []posOffset, error) {
 	toks, err := tokenize([]byte(expr))
 	if err != nil {
 		return "", nil, fmt.Errorf("cannot tokenize expr: %v", err)
@@ -22,6 +46,9 @@ func transformSource(expr string) (string, []posOffset, error) {
 	var offs []posOffset
 	lbuf := lineColBuffer{line: 1, col: 1}
 	lastLit := false
+	if opVars != nil {
+		replaceOperatorWildcards(toks, opVars)
+	}
 	for _, t := range toks {
 		if lbuf.offs >= t.pos.Offset && lastLit && t.lit != "" {
 			_, _ = lbuf.WriteString(" ")
@@ -41,17 +68,356 @@ func transformSource(expr string) (string, []posOffset, error) {
 	return strings.TrimSpace(lbuf.String()), offs, nil
 }
 
-func parseExpr(fset *token.FileSet, expr string) (ast.Node, error) {
-	exprStr, offs, err := transformSource(expr)
+func replaceOperatorWildcards(toks []fullToken, opVars *operatorVars) {
+	for i, t := range toks {
+		switch {
+		case t.tok == token.AND_NOT:
+			opVars.binary = append(opVars.binary, "")
+		case isOperatorWildcard(toks, i, !opVars.withUnary):
+			opVars.binary = append(opVars.binary, decodeWildName(t.lit).Name)
+			toks[i] = fullToken{pos: t.pos, tok: token.AND_NOT}
+		}
+	}
+	if !opVars.withUnary {
+		return
+	}
+	// The binary operators are replaced first, so `$x` in `$x $op $y` is not
+	// mistaken for a unary operator wildcard.
+	for i, t := range toks {
+		switch {
+		case t.tok == token.XOR && (i == 0 || !isOperandEnd(toks[i-1].tok)):
+			opVars.unary = append(opVars.unary, "")
+		case isUnaryOperatorWildcard(toks, i):
+			opVars.unary = append(opVars.unary, decodeWildName(t.lit).Name)
+			toks[i] = fullToken{pos: t.pos, tok: token.XOR}
+		}
+	}
+}
+
+// isOperatorWildcard reports whether toks[i] is a wildcard
+// that is located between two operands, like $op in `$x $op $y`.
+// Tokens before i should already have operator wildcards replaced.
+//
+// If unaryRight is false, the right operand can't start with a unary operator:
+// $x in `$op $x + $y` is an operand of the unary operator wildcard.
+func isOperatorWildcard(toks []fullToken, i int, unaryRight bool) bool {
+	if i == 0 || i == len(toks)-1 {
+		return false
+	}
+	t := toks[i]
+	if t.tok != token.IDENT || !isWildName(t.lit) || decodeWildName(t.lit).Seq {
+		return false
+	}
+	if !isOperandEnd(toks[i-1].tok) {
+		return false
+	}
+	switch toks[i+1].tok {
+	case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING,
+		token.LPAREN, token.LBRACK, token.FUNC, token.MAP, token.CHAN, token.STRUCT, token.INTERFACE:
+		// The right operand start.
+		return true
+	case token.ADD, token.SUB, token.NOT, token.XOR, token.MUL, token.AND, token.ARROW:
+		// The right operand starts with a unary operator.
+		return unaryRight
+	default:
+		return false
+	}
+}
+
+// isUnaryOperatorWildcard reports whether toks[i] is a wildcard
+// that is located right before an operand, like $op in `$op $x`.
+// All binary operator wildcards and the unary operator wildcards before i should already be replaced.
+func isUnaryOperatorWildcard(toks []fullToken, i int) bool {
+	if i == len(toks)-1 {
+		return false
+	}
+	t := toks[i]
+	if t.tok != token.IDENT || !isWildName(t.lit) || decodeWildName(t.lit).Seq {
+		return false
+	}
+	if i != 0 && isOperandEnd(toks[i-1].tok) {
+		return false
+	}
+	// The parenthesized operand is not accepted: `$f($x)` is a call.
+	switch toks[i+1].tok {
+	case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING:
+		return true
+	default:
+		return false
+	}
+}
+
+func isOperandEnd(tok token.Token) bool {
+	switch tok {
+	case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING,
+		token.RPAREN, token.RBRACK, token.RBRACE:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseExpr(fset *token.FileSet, expr string) (ast.Node, map[ast.Node]string, error) {
+	exprStr, offs, err := transformSource(expr, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	node, err := parseDetectingNode(fset, exprStr)
+	if err == nil {
+		// A standalone `$op $x` is a value spec with a wildcard type, so the
+		// unary operator wildcard is written as `($op $x)` instead.
+		// It's parsed as a grouped value spec, but a single value spec
+		// doesn't need the parentheses, so they're only a disambiguation syntax.
+		if isWildValueSpec(node) && strings.HasPrefix(strings.TrimSpace(exprStr), "(") {
+			if opNode, opVars, ok := parseOperatorWildcards(fset, expr, exprStr, true); ok {
+				opNode, bindings, err := bindOperatorWildcards(opNode, opVars)
+				if paren, ok := opNode.(*ast.ParenExpr); ok && err == nil {
+					return paren.X, bindings, nil
+				}
+			}
+		}
+		return node, nil, nil
+	}
+
+	// Sequence wildcards in the parameter lists, like $*_ in `func $_($*_, $last ...$T)`,
+	// are parsed as unnamed params that can't be mixed with the named ones.
+	// Try again with a placeholder type for them.
+	if seqExprStr, ok := withSeqParamTypes(exprStr); ok {
+		if seqNode, seqErr := parseDetectingNode(fset, seqExprStr); seqErr == nil {
+			return seqNode, nil, nil
+		}
+	}
+
+	// An else branch wildcard, like $e in `if $cond { $*_ } else $e`,
+	// is not a valid syntax as well: only a block or an if statement can follow the else.
+	if elseExprStr, ok := withElseWildcards(exprStr); ok {
+		if elseNode, elseErr := parseDetectingNode(fset, elseExprStr); elseErr == nil {
+			return elseNode, nil, nil
+		}
+	}
+
+	// The pattern may contain operator wildcards.
+	// They're not valid Go syntax, so we only try them after the normal parsing fails.
+	// The unary operator wildcards are tried last: a wildcard before an operand
+	// can also be a part of a declaration, like `$x $T` in `func($x $T)`.
+	for _, withUnary := range []bool{false, true} {
+		if opNode, opVars, ok := parseOperatorWildcards(fset, expr, exprStr, withUnary); ok {
+			return bindOperatorWildcards(opNode, opVars)
+		}
+	}
+
+	err = subPosOffsets(err, offs...)
+	return nil, nil, fmt.Errorf("cannot parse expr: %v", err)
+}
+
+// parseOperatorWildcards parses the expr with its operator wildcards replaced, see transformSource.
+// The exprStr is the expr source without the replacements.
+func parseOperatorWildcards(fset *token.FileSet, expr, exprStr string, withUnary bool) (ast.Node, *operatorVars, bool) {
+	opVars := &operatorVars{withUnary: withUnary}
+	opExprStr, _, err := transformSource(expr, opVars)
+	if err != nil || opExprStr == exprStr {
+		return nil, nil, false
+	}
+	node, err := parseDetectingNode(fset, opExprStr)
+	if err != nil {
+		return nil, nil, false
+	}
+	return node, opVars, true
+}
+
+// isWildValueSpec reports whether n is a `$x $T` value spec.
+func isWildValueSpec(n ast.Node) bool {
+	spec, ok := n.(*ast.ValueSpec)
+	if !ok || len(spec.Names) != 1 || len(spec.Values) != 0 {
+		return false
+	}
+	typ, ok := spec.Type.(*ast.Ident)
+	return ok && isWildName(spec.Names[0].Name) && isWildName(typ.Name)
+}
+
+// parseDecls parses a pattern that is a sequence of top-level declarations.
+// Unlike parseDetectingNode, it never treats const and var declarations as statements.
+// The result is always a decl slice, even if there is only one declaration.
+func parseDecls(fset *token.FileSet, expr string) (ast.Node, error) {
+	src, offs, err := transformSource(expr, nil)
+	if err != nil {
+		return nil, err
+	}
+	if src == "" {
+		return nil, fmt.Errorf("empty source code")
+	}
+	src = withDeclWildcards(src)
+	f, err := parseDeclsFile(fset, src)
+	if err != nil {
+		// See the withSeqParamTypes comment in parseExpr.
+		if seqSrc, ok := withSeqParamTypes(src); ok {
+			if seqFile, seqErr := parseDeclsFile(fset, seqSrc); seqErr == nil {
+				f, err = seqFile, nil
+			}
+		}
+	}
+	if err != nil {
+		offs = append(offs, posOffset{1, 1, len("package p; ")})
+		return nil, fmt.Errorf("cannot parse decls: %v", subPosOffsets(err, offs...))
+	}
+	slice := &NodeSlice{}
+	slice.assignDeclSlice(f.Decls)
+	return slice, nil
+}
+
+func parseDeclsFile(fset *token.FileSet, src string) (*ast.File, error) {
+	f, err := parser.ParseFile(fset, "", execTmpl(tmplDecl, src), parser.SkipObjectResolution)
+	if err == nil && !noBadNodes(f) {
+		err = errors.New("invalid declarations syntax")
+	}
+	return f, err
+}
+
+// declWildcardType is a placeholder type for the wildcards in a declaration position.
+const declWildcardType = "gogrep_decl"
+
+// withDeclWildcards turns every wildcard that is located in a declaration position
+// into a var declaration with a placeholder type, so `$x; func f() {}`
+// becomes `var $x T; func f() {}`.
+func withDeclWildcards(src string) string {
+	toks, err := tokenize([]byte(src))
+	if err != nil {
+		return src
+	}
+	var buf strings.Builder
+	last := 0
+	for i, t := range toks {
+		if t.tok != token.IDENT || !isWildName(t.lit) {
+			continue
+		}
+		if i != 0 && toks[i-1].tok != token.SEMICOLON {
+			continue
+		}
+		if i != len(toks)-1 && toks[i+1].tok != token.SEMICOLON {
+			continue
+		}
+		buf.WriteString(src[last:t.pos.Offset])
+		buf.WriteString("var " + t.lit + " " + declWildcardType)
+		last = t.pos.Offset + len(t.lit)
+	}
+	buf.WriteString(src[last:])
+	return buf.String()
+}
+
+// seqParamType is a placeholder type for the sequence wildcard params.
+const seqParamType = wildSeparator + "seqParamType"
+
+// withSeqParamTypes adds a placeholder type to every sequence wildcard
+// that looks like a parameter, so `($*_, x int)` becomes `($*_ T, x int)`.
+// It reports false if there are no such wildcards in src.
+func withSeqParamTypes(src string) (string, bool) {
+	toks, err := tokenize([]byte(src))
 	if err != nil {
-		err = subPosOffsets(err, offs...)
-		return nil, fmt.Errorf("cannot parse expr: %v", err)
+		return "", false
 	}
-	return node, nil
+	var buf strings.Builder
+	changed := false
+	last := 0
+	for i, t := range toks {
+		if t.tok != token.IDENT || !isWildName(t.lit) || !decodeWildName(t.lit).Seq {
+			continue
+		}
+		if i == 0 || i == len(toks)-1 {
+			continue
+		}
+		prev := toks[i-1].tok
+		next := toks[i+1].tok
+		if (prev != token.LPAREN && prev != token.COMMA) || (next != token.COMMA && next != token.RPAREN) {
+			continue
+		}
+		end := t.pos.Offset + len(t.lit)
+		buf.WriteString(src[last:end])
+		buf.WriteString(" " + seqParamType)
+		last = end
+		changed = true
+	}
+	buf.WriteString(src[last:])
+	return buf.String(), changed
+}
+
+// elseWildcardFunc is a placeholder function that wraps the else branch wildcards.
+const elseWildcardFunc = wildSeparator + "elseWildcard"
+
+// withElseWildcards wraps every wildcard that follows the else keyword
+// into a placeholder block, so `else $e` becomes `else { F($e) }`.
+// It reports false if there are no such wildcards in src.
+func withElseWildcards(src string) (string, bool) {
+	toks, err := tokenize([]byte(src))
+	if err != nil {
+		return "", false
+	}
+	var buf strings.Builder
+	changed := false
+	last := 0
+	for i, t := range toks {
+		if t.tok != token.IDENT || !isWildName(t.lit) || decodeWildName(t.lit).Seq {
+			continue
+		}
+		if i == 0 || toks[i-1].tok != token.ELSE {
+			continue
+		}
+		buf.WriteString(src[last:t.pos.Offset])
+		buf.WriteString("{ " + elseWildcardFunc + "(" + t.lit + ") }")
+		last = t.pos.Offset + len(t.lit)
+		changed = true
+	}
+	buf.WriteString(src[last:])
+	return buf.String(), changed
+}
+
+func bindOperatorWildcards(root ast.Node, opVars *operatorVars) (ast.Node, map[ast.Node]string, error) {
+	// All `&^` binary and `^` unary operators are collected in the source order,
+	// so they can be mapped to the opVars.
+	var binaryExprs []*ast.BinaryExpr
+	var unaryExprs []*ast.UnaryExpr
+	collect := func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.BinaryExpr:
+			if e.Op == token.AND_NOT {
+				binaryExprs = append(binaryExprs, e)
+			}
+		case *ast.UnaryExpr:
+			if e.Op == token.XOR && opVars.withUnary {
+				unaryExprs = append(unaryExprs, e)
+			}
+		}
+		return true
+	}
+	switch n := root.(type) {
+	case *rangeClause:
+		ast.Inspect(n.X, collect)
+	case *rangeHeader:
+		ast.Inspect(n.Node, collect)
+	default:
+		Walk(root, collect)
+	}
+	if len(binaryExprs) != len(opVars.binary) || len(unaryExprs) != len(opVars.unary) {
+		return nil, nil, errors.New("cannot parse expr: unexpected operator wildcards usage")
+	}
+	sort.SliceStable(binaryExprs, func(i, j int) bool {
+		return binaryExprs[i].OpPos < binaryExprs[j].OpPos
+	})
+	sort.SliceStable(unaryExprs, func(i, j int) bool {
+		return unaryExprs[i].OpPos < unaryExprs[j].OpPos
+	})
+
+	bindings := make(map[ast.Node]string)
+	for i, e := range binaryExprs {
+		if opVars.binary[i] != "" {
+			bindings[e] = opVars.binary[i]
+		}
+	}
+	for i, e := range unaryExprs {
+		if opVars.unary[i] != "" {
+			bindings[e] = opVars.unary[i]
+		}
+	}
+	return root, bindings, nil
 }
 
 type lineColBuffer struct {
@@ -155,6 +521,24 @@ func parseDetectingNode(fset *token.FileSet, src string) (ast.Node, error) {
 		}
 	}
 
+	// A switch case clause, like `case $x: $*_` or `default: $*_`.
+	if strings.HasPrefix(src, "case ") || strings.HasPrefix(src, "default:") {
+		asSwitch := execTmpl(tmplStmts, "switch {\n"+src+"\n}")
+		f, err := parser.ParseFile(fset, "", asSwitch, parser.SkipObjectResolution)
+		if err == nil && noBadNodes(f) {
+			clauses := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.SwitchStmt).Body.List
+			if len(clauses) == 1 {
+				return clauses[0], nil
+			}
+		}
+	}
+
+	if strings.HasPrefix(src, "import ") {
+		if spec := parseImportSpec(src[len("import "):]); spec != nil {
+			return spec, nil
+		}
+	}
+
 	// try as a block; otherwise blocks might be mistaken for composite
 	// literals further below\
 	asBlock := execTmpl(tmplBlock, src)
@@ -162,7 +546,11 @@ func parseDetectingNode(fset *token.FileSet, src string) (ast.Node, error) {
 		bl := f.Decls[0].(*ast.FuncDecl).Body
 		if len(bl.List) == 1 {
 			ifs := bl.List[0].(*ast.IfStmt)
-			return ifs.Body, nil
+			// A binary expression prefix becomes a part of the condition,
+			// like `&T{}` that is parsed as `if true & T {}`.
+			if cond, ok := ifs.Cond.(*ast.Ident); ok && cond.Name == "true" {
+				return ifs.Body, nil
+			}
 		}
 	}
 
@@ -256,6 +644,83 @@ func subPosOffsets(err error, offs ...posOffset) error {
 	return list
 }
 
+// varPrefixes are the supported CompileConfig.VarPrefix values.
+// They're not used in the Go syntax, or used only as the operators.
+const varPrefixes = "@#%?^"
+
+// replaceVarPrefix returns the src with its prefix pattern vars replaced with the $ ones,
+// see CompileConfig.VarPrefix. The string and rune literals and the comments are copied as is.
+func replaceVarPrefix(src string, prefix rune) (string, error) {
+	if prefix > unicode.MaxASCII || !strings.ContainsRune(varPrefixes, prefix) {
+		return "", fmt.Errorf("unsupported pattern var prefix %q, expected one of %s", prefix, varPrefixes)
+	}
+	p := byte(prefix)
+	var buf strings.Builder
+	buf.Grow(len(src))
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case ch == '"' || ch == '\'' || ch == '`':
+			end := skipQuoted(src, i)
+			buf.WriteString(src[i:end])
+			i = end - 1
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				end = len(src) - i
+			}
+			buf.WriteString(src[i : i+end])
+			i += end - 1
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+len("/*"):], "*/")
+			if end == -1 {
+				buf.WriteString(src[i:])
+				return buf.String(), nil
+			}
+			end += len("/*") + len("*/")
+			buf.WriteString(src[i : i+end])
+			i += end - 1
+		case ch == '$':
+			return "", fmt.Errorf("unexpected $, the pattern vars prefix is %c", prefix)
+		case ch == p && i+1 < len(src) && src[i+1] == p:
+			buf.WriteByte(p)
+			i++
+		case ch == p && i+1 < len(src) && isVarStart(src[i+1:]):
+			buf.WriteByte('$')
+		default:
+			buf.WriteByte(ch)
+		}
+	}
+	return buf.String(), nil
+}
+
+// skipQuoted returns the src index after the literal that starts at src[i].
+// An unterminated literal spans till the end of src, so the parser reports it.
+func skipQuoted(src string, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case '\n':
+			if quote != '`' {
+				return j
+			}
+		case quote:
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// isVarStart reports whether s starts with a pattern var name or a * of the $*x.
+func isVarStart(s string) bool {
+	ch, _ := utf8.DecodeRuneInString(s)
+	return ch == '*' || ch == '_' || unicode.IsLetter(ch)
+}
+
 type fullToken struct {
 	pos token.Position
 	tok token.Token
@@ -300,8 +765,8 @@ func tokenize(src []byte) ([]fullToken, error) {
 	for t := next(); t.tok != token.EOF; t = next() {
 		switch t.lit {
 		case "$": // continues below
-		case "switch", "select", "case":
-			if t.lit == "case" {
+		case "switch", "select", "case", "default":
+			if t.lit == "case" || t.lit == "default" {
 				caseStat = caseNone
 			} else {
 				caseStat = caseNeedBlock
@@ -396,8 +861,70 @@ type rangeHeader struct {
 	Node *ast.RangeStmt
 }
 
+// importSpec is a single import spec pattern, like `import $alias "path"`.
+// Unlike the ast.ImportSpec, its path can be a wildcard.
+type importSpec struct {
+	Name *ast.Ident
+	Path ast.Expr
+}
+
 func (*rangeClause) Pos() token.Pos { return 0 }
 func (*rangeClause) End() token.Pos { return 0 }
 
 func (*rangeHeader) Pos() token.Pos { return 0 }
 func (*rangeHeader) End() token.Pos { return 0 }
+
+func (*importSpec) Pos() token.Pos { return 0 }
+func (*importSpec) End() token.Pos { return 0 }
+
+// parseImportSpec parses an unparenthesized import spec pattern.
+// The spec name is optional, it can be an ident, `_`, `.` or a wildcard;
+// the path is either a string literal or a wildcard.
+// It returns nil if src is not an import spec pattern.
+func parseImportSpec(src string) *importSpec {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	s.Init(file, []byte(src), nil, 0)
+	var toks []fullToken
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue // Automatically inserted semicolon
+		}
+		toks = append(toks, fullToken{tok: tok, lit: lit})
+	}
+
+	spec := &importSpec{}
+	switch len(toks) {
+	case 1:
+		// Only string paths are allowed here, `import $x` is an import decl pattern.
+		if toks[0].tok != token.STRING {
+			return nil
+		}
+	case 2:
+		switch name := toks[0]; name.tok {
+		case token.IDENT:
+			spec.Name = &ast.Ident{Name: name.lit}
+		case token.PERIOD:
+			spec.Name = &ast.Ident{Name: "."}
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	switch path := toks[len(toks)-1]; {
+	case path.tok == token.STRING:
+		spec.Path = &ast.BasicLit{Kind: token.STRING, Value: path.lit}
+	case path.tok == token.IDENT && isWildName(path.lit):
+		spec.Path = &ast.Ident{Name: path.lit}
+	default:
+		return nil
+	}
+	return spec
+}
@@ -0,0 +1,237 @@
+package gogrep
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// IsConversion reports whether call is a type conversion, like `string(b)`,
+// rather than a function call. Both have the same syntax, so the info is
+// used to find out whether the callee denotes a type.
+//
+// The known result is false if the call kind can't be determined.
+// It happens for calls like `T(x)` when info is nil or has no data for the callee.
+// Without info, the type literal callees like `[]byte` and the predeclared
+// types like `string` are still recognized.
+func IsConversion(info *types.Info, call *ast.CallExpr) (isConversion, known bool) {
+	if info != nil {
+		if tv, ok := info.Types[call.Fun]; ok {
+			return tv.IsType(), true
+		}
+	}
+	switch fn := unparen(call.Fun).(type) {
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType, *ast.StarExpr:
+		return true, true
+	case *ast.FuncLit, *ast.CallExpr:
+		// Function calls never return types.
+		return false, true
+	case *ast.Ident:
+		if info == nil {
+			if _, ok := types.Universe.Lookup(fn.Name).(*types.TypeName); ok {
+				return true, true
+			}
+		}
+	}
+	return false, false
+}
+
+// IsRedundantConversion reports whether call is a conversion of another conversion
+// to the same type, like `[]byte([]byte(s))` or `T(T(x))`.
+// The types are compared textually, so `[]byte` and `[] byte` are the same type,
+// but a type and its alias are different ones.
+//
+// Both conversions need to be recognized by IsConversion, the calls of
+// unknown kind are never reported: with nil info, `T(T(x))` and the generic
+// type instantiations like `G[int](G[int](x))` are not reported, since T and G
+// can be functions.
+func IsRedundantConversion(info *types.Info, call *ast.CallExpr) bool {
+	if len(call.Args) != 1 || call.Ellipsis.IsValid() {
+		return false
+	}
+	inner, ok := unparen(call.Args[0]).(*ast.CallExpr)
+	if !ok || len(inner.Args) != 1 || inner.Ellipsis.IsValid() {
+		return false
+	}
+	// Both callees are expressions, since the parens can only enclose an expression.
+	outerType := unparen(call.Fun).(ast.Expr)
+	innerType := unparen(inner.Fun).(ast.Expr)
+	if types.ExprString(outerType) != types.ExprString(innerType) {
+		return false
+	}
+	isConversion, known := IsConversion(info, call)
+	return known && isConversion
+}
+
+// CallResults returns the number of values the call returns, like 2 for
+// `strconv.Atoi(s)` and 0 for `close(ch)`. A conversion returns 1 value.
+//
+// The known result is false if the callee can't be resolved.
+// Without info (or its data for the call), only the conversions recognized
+// by IsConversion, the function literal calls and the predeclared functions
+// calls, like `len(b)`, are resolved.
+func CallResults(info *types.Info, call *ast.CallExpr) (n int, known bool) {
+	if info != nil {
+		if tv, ok := info.Types[call]; ok {
+			if tv.IsVoid() {
+				return 0, true
+			}
+			if tuple, ok := tv.Type.(*types.Tuple); ok {
+				return tuple.Len(), true
+			}
+			return 1, true
+		}
+	}
+	if isConversion, known := IsConversion(nil, call); known && isConversion {
+		return 1, true
+	}
+	switch fn := unparen(call.Fun).(type) {
+	case *ast.FuncLit:
+		return fn.Type.Results.NumFields(), true
+	case *ast.Ident:
+		n, ok := builtinResults[fn.Name]
+		return n, ok
+	}
+	return 0, false
+}
+
+// builtinResults maps the predeclared functions to the number of their results.
+var builtinResults = map[string]int{
+	"append":  1,
+	"cap":     1,
+	"clear":   0,
+	"close":   0,
+	"complex": 1,
+	"copy":    1,
+	"delete":  0,
+	"imag":    1,
+	"len":     1,
+	"make":    1,
+	"max":     1,
+	"min":     1,
+	"new":     1,
+	"panic":   0,
+	"print":   0,
+	"println": 0,
+	"real":    1,
+	"recover": 1,
+}
+
+// IsErrorValue reports whether e is a value of a type that implements the error interface,
+// like `err` or `errors.New("x")`, rather than a string, a number or some other value.
+// It can be used to find the panics with the non-error arguments, like `panic("unreachable")`.
+//
+// The known result is false if the e type can't be determined.
+// Without info (or its data for e), only the literals, the comparisons and
+// a few well-known calls, like `errors.New(s)` and `fmt.Sprintf(format, args...)`,
+// are recognized; the errors and fmt package names are assumed to be not shadowed.
+func IsErrorValue(info *types.Info, e ast.Expr) (isError, known bool) {
+	if info != nil {
+		if tv, ok := info.Types[e]; ok {
+			if tv.IsNil() {
+				return false, true
+			}
+			return types.Implements(tv.Type, errorInterface), true
+		}
+	}
+	switch e := unparen(e).(type) {
+	case *ast.BasicLit:
+		return false, true
+	case *ast.BinaryExpr:
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.LAND, token.LOR:
+			return false, true
+		}
+		// The arithmetic results have the operands type.
+		xIsError, xKnown := IsErrorValue(nil, e.X)
+		yIsError, yKnown := IsErrorValue(nil, e.Y)
+		if xKnown && yKnown && !xIsError && !yIsError {
+			return false, true
+		}
+	case *ast.CallExpr:
+		sel, ok := unparen(e.Fun).(*ast.SelectorExpr)
+		if !ok {
+			break
+		}
+		if pkg, ok := sel.X.(*ast.Ident); ok {
+			isError, ok := knownErrorCalls[pkg.Name+"."+sel.Sel.Name]
+			return isError, ok
+		}
+	}
+	return false, false
+}
+
+var errorInterface = types.Universe.Lookup("error").Type().Underlying().(*types.Interface)
+
+// knownErrorCalls maps the well-known functions to whether they return an error.
+var knownErrorCalls = map[string]bool{
+	"errors.New":   true,
+	"errors.Join":  true,
+	"fmt.Errorf":   true,
+	"fmt.Sprint":   false,
+	"fmt.Sprintf":  false,
+	"fmt.Sprintln": false,
+}
+
+// EnumConsts returns the constants of the typ named type, like StatusOK and StatusFailed
+// for `type Status int`, sorted by their names.
+//
+// Only the constants of the exact typ type that are declared at the package level
+// of the typ package are collected, the constants declared inside functions
+// (or in other packages) and untyped constants are not included.
+func EnumConsts(typ types.Type) []*types.Const {
+	named, ok := typ.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return nil
+	}
+	var result []*types.Const
+	scope := named.Obj().Pkg().Scope()
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if ok && types.Identical(c.Type(), typ) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// IsEnumValue reports whether e is a constant expression which value
+// is equal to one of the typ constants, see EnumConsts.
+// Both `StatusOK` and `1` are enum values if StatusOK is a Status(1) constant.
+//
+// The info is required to evaluate e: it's never an enum value if the info is nil
+// or has no data for it. Since the constants are enumerated using the typ package scope,
+// that package should be type-checked from the source or loaded from the export data.
+func IsEnumValue(info *types.Info, e ast.Expr, typ types.Type) bool {
+	if info == nil {
+		return false
+	}
+	tv, ok := info.Types[e]
+	if !ok || tv.Value == nil {
+		return false
+	}
+	for _, c := range EnumConsts(typ) {
+		if comparableConsts(tv.Value, c.Val()) && constant.Compare(tv.Value, token.EQL, c.Val()) {
+			return true
+		}
+	}
+	return false
+}
+
+// comparableConsts reports whether x and y values can be compared,
+// constant.Compare panics for the mismatching kinds like a string and an int.
+func comparableConsts(x, y constant.Value) bool {
+	isNumeric := func(v constant.Value) bool {
+		switch v.Kind() {
+		case constant.Int, constant.Float, constant.Complex:
+			return true
+		default:
+			return false
+		}
+	}
+	if isNumeric(x) || isNumeric(y) {
+		return isNumeric(x) && isNumeric(y)
+	}
+	return x.Kind() == y.Kind()
+}
@@ -0,0 +1,418 @@
+package gogrep
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestIsConversion(t *testing.T) {
+	// T(x) and f(x) have the same shape, only the types info can tell them apart.
+	fileSrc := `package example
+
+type T []byte
+
+func f(b []byte) []byte { return b }
+
+func _(b []byte) {
+	_ = T(b)
+	_ = f(b)
+	_ = string(b)
+	_ = []byte("x")
+	_ = len(b)
+	_ = func() int { return 0 }()
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	typechecker := &types.Config{}
+	if _, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		isConversion bool
+		known        bool
+	}
+	tests := []struct {
+		call      string
+		withTypes result
+		noTypes   result
+	}{
+		{`T(b)`, result{true, true}, result{false, false}},
+		{`f(b)`, result{false, true}, result{false, false}},
+		{`string(b)`, result{true, true}, result{true, true}},
+		{`[]byte("x")`, result{true, true}, result{true, true}},
+		{`len(b)`, result{false, true}, result{false, false}},
+		{`func() int { return 0 }()`, result{false, true}, result{false, true}},
+	}
+
+	var calls []*ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	if len(calls) != len(tests) {
+		t.Fatalf("expected %d calls, found %d", len(tests), len(calls))
+	}
+	for i, test := range tests {
+		call := calls[i]
+		var have result
+		have.isConversion, have.known = IsConversion(typesInfo, call)
+		if have != test.withTypes {
+			t.Errorf("IsConversion(%s) with types:\nhave: %+v\nwant: %+v", test.call, have, test.withTypes)
+		}
+		have.isConversion, have.known = IsConversion(nil, call)
+		if have != test.noTypes {
+			t.Errorf("IsConversion(%s) without types:\nhave: %+v\nwant: %+v", test.call, have, test.noTypes)
+		}
+	}
+}
+
+func TestIsRedundantConversion(t *testing.T) {
+	fileSrc := `package example
+
+type T int
+
+func f(x int) int { return x }
+
+func _(x int, s string) {
+	_ = T(T(x))
+	_ = []byte([]byte(s))
+	_ = []byte([] byte(s))
+	_ = string((string(s)))
+	_ = (*T)((*T)(nil))
+	_ = f(f(x))
+	_ = string([]byte(s))
+	_ = T(x)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	typechecker := &types.Config{}
+	if _, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		call      string
+		withTypes bool
+		noTypes   bool
+	}{
+		{`T(T(x))`, true, false},
+		{`[]byte([]byte(s))`, true, true},
+		{`[]byte([] byte(s))`, true, true},
+		{`string((string(s)))`, true, true},
+		{`(*T)((*T)(nil))`, true, true},
+		{`f(f(x))`, false, false},
+		{`string([]byte(s))`, false, false},
+		{`T(x)`, false, false},
+	}
+
+	var calls []*ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if assign, ok := n.(*ast.AssignStmt); ok {
+			calls = append(calls, assign.Rhs[0].(*ast.CallExpr))
+		}
+		return true
+	})
+	if len(calls) != len(tests) {
+		t.Fatalf("expected %d calls, found %d", len(tests), len(calls))
+	}
+	for i, test := range tests {
+		if have := IsRedundantConversion(typesInfo, calls[i]); have != test.withTypes {
+			t.Errorf("IsRedundantConversion(%s) with types: have %v, want %v", test.call, have, test.withTypes)
+		}
+		if have := IsRedundantConversion(nil, calls[i]); have != test.noTypes {
+			t.Errorf("IsRedundantConversion(%s) without types: have %v, want %v", test.call, have, test.noTypes)
+		}
+	}
+
+	// A generic type instantiation can't be told from a generic function call without types.
+	call, err := parser.ParseExpr(`G[int](G[int](x))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsRedundantConversion(nil, call.(*ast.CallExpr)) {
+		t.Errorf("IsRedundantConversion(G[int](G[int](x))) without types: have true, want false")
+	}
+}
+
+func TestCallResults(t *testing.T) {
+	fileSrc := `package example
+
+import "strconv"
+
+type T int
+
+type S struct{}
+
+func (S) Pair() (int, error) { return 0, nil }
+
+func f() {}
+
+func _(s S, ch chan int, fn func() (int, int, int)) {
+	strconv.Atoi("1")
+	s.Pair()
+	f()
+	fn()
+	_ = T(1)
+	close(ch)
+	_ = len(ch)
+	func() (a, b int) { return 0, 0 }()
+	_ = []byte("x")
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	typechecker := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		n     int
+		known bool
+	}
+	tests := []struct {
+		call      string
+		withTypes result
+		noTypes   result
+	}{
+		{`strconv.Atoi("1")`, result{2, true}, result{0, false}},
+		{`s.Pair()`, result{2, true}, result{0, false}},
+		{`f()`, result{0, true}, result{0, false}},
+		{`fn()`, result{3, true}, result{0, false}},
+		{`T(1)`, result{1, true}, result{0, false}},
+		{`close(ch)`, result{0, true}, result{0, true}},
+		{`len(ch)`, result{1, true}, result{1, true}},
+		{`func() (a, b int) { return 0, 0 }()`, result{2, true}, result{2, true}},
+		{`[]byte("x")`, result{1, true}, result{1, true}},
+	}
+
+	var calls []*ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	if len(calls) != len(tests) {
+		t.Fatalf("expected %d calls, found %d", len(tests), len(calls))
+	}
+	for i, test := range tests {
+		call := calls[i]
+		var have result
+		have.n, have.known = CallResults(typesInfo, call)
+		if have != test.withTypes {
+			t.Errorf("CallResults(%s) with types:\nhave: %+v\nwant: %+v", test.call, have, test.withTypes)
+		}
+		have.n, have.known = CallResults(nil, call)
+		if have != test.noTypes {
+			t.Errorf("CallResults(%s) without types:\nhave: %+v\nwant: %+v", test.call, have, test.noTypes)
+		}
+	}
+}
+
+func TestIsErrorValue(t *testing.T) {
+	fileSrc := `package example
+
+import (
+	"errors"
+	"fmt"
+)
+
+type E struct{}
+
+func (*E) Error() string { return "" }
+
+type S string
+
+func _(err error, s string, v interface{}, e *E) {
+	_ = err
+	_ = "unreachable"
+	_ = s
+	_ = 1 + 2
+	_ = "a" + "b"
+	_ = s == "x"
+	_ = v
+	_ = e
+	_ = &E{}
+	_ = S("x")
+	_ = errors.New("x")
+	_ = fmt.Errorf("%w", err)
+	_ = fmt.Sprintf("%v", v)
+	_ = (err)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	typechecker := &types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		isError bool
+		known   bool
+	}
+	tests := []struct {
+		expr      string
+		withTypes result
+		noTypes   result
+	}{
+		{`err`, result{true, true}, result{false, false}},
+		{`"unreachable"`, result{false, true}, result{false, true}},
+		{`s`, result{false, true}, result{false, false}},
+		{`1 + 2`, result{false, true}, result{false, true}},
+		{`"a" + "b"`, result{false, true}, result{false, true}},
+		{`s == "x"`, result{false, true}, result{false, true}},
+		{`v`, result{false, true}, result{false, false}},
+		{`e`, result{true, true}, result{false, false}},
+		{`&E{}`, result{true, true}, result{false, false}},
+		{`S("x")`, result{false, true}, result{false, false}},
+		{`errors.New("x")`, result{true, true}, result{true, true}},
+		{`fmt.Errorf("%w", err)`, result{true, true}, result{true, true}},
+		{`fmt.Sprintf("%v", v)`, result{false, true}, result{false, true}},
+		{`(err)`, result{true, true}, result{false, false}},
+	}
+
+	var exprs []ast.Expr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if assign, ok := n.(*ast.AssignStmt); ok {
+			exprs = append(exprs, assign.Rhs[0])
+		}
+		return true
+	})
+	if len(exprs) != len(tests) {
+		t.Fatalf("expected %d exprs, found %d", len(tests), len(exprs))
+	}
+	for i, test := range tests {
+		var have result
+		have.isError, have.known = IsErrorValue(typesInfo, exprs[i])
+		if have != test.withTypes {
+			t.Errorf("IsErrorValue(%s) with types:\nhave: %+v\nwant: %+v", test.expr, have, test.withTypes)
+		}
+		have.isError, have.known = IsErrorValue(nil, exprs[i])
+		if have != test.noTypes {
+			t.Errorf("IsErrorValue(%s) without types:\nhave: %+v\nwant: %+v", test.expr, have, test.noTypes)
+		}
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	fileSrc := `package example
+
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusFailed
+)
+
+const untyped = 2
+
+type Color string
+
+const Red Color = "red"
+
+func _(x int) {
+	const local Status = 5
+	_ = Status(0)
+	_ = Status(StatusFailed)
+	_ = Status(2)
+	_ = Status(untyped)
+	_ = Status(x)
+	_ = Status(local)
+	_ = Color("red")
+	_ = Color("blue")
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "file.go", fileSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typesInfo := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+	}
+	typechecker := &types.Config{}
+	pkg, err := typechecker.Check("example", fset, []*ast.File{f}, typesInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var consts []string
+	for _, c := range EnumConsts(pkg.Scope().Lookup("Status").Type()) {
+		consts = append(consts, c.Name())
+	}
+	if have := strings.Join(consts, " "); have != "StatusFailed StatusOK" {
+		t.Errorf("Status consts:\nhave: %s\nwant: StatusFailed StatusOK", have)
+	}
+
+	tests := []struct {
+		conversion string
+		want       bool
+	}{
+		{`Status(0)`, true},
+		{`Status(StatusFailed)`, true},
+		{`Status(2)`, false},
+		{`Status(untyped)`, false},
+		{`Status(x)`, false},
+		// The constants declared inside functions are not enumerated.
+		{`Status(local)`, false},
+		{`Color("red")`, true},
+		{`Color("blue")`, false},
+	}
+
+	var calls []*ast.CallExpr
+	ast.Inspect(f, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			calls = append(calls, call)
+		}
+		return true
+	})
+	if len(calls) != len(tests) {
+		t.Fatalf("expected %d conversions, found %d", len(tests), len(calls))
+	}
+	for i, test := range tests {
+		call := calls[i]
+		typ := typesInfo.TypeOf(call.Fun)
+		if have := IsEnumValue(typesInfo, call.Args[0], typ); have != test.want {
+			t.Errorf("IsEnumValue(%s):\nhave: %v\nwant: %v", test.conversion, have, test.want)
+		}
+		if IsEnumValue(nil, call.Args[0], typ) {
+			t.Errorf("IsEnumValue(%s) without types: expected false", test.conversion)
+		}
+	}
+}